@@ -0,0 +1,589 @@
+package api
+
+import (
+	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/infrastructure/database"
+	"ai_tg_writer/internal/monitoring"
+	"ai_tg_writer/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminAPIPrefix префикс административного REST API
+const AdminAPIPrefix = "/admin/api/v1"
+
+type adminTokenContextKey struct{}
+
+// adminTokenStore хранилище токенов административного API
+type adminTokenStore interface {
+	GetAdminAPITokenByHash(hash string) (*database.AdminAPIToken, error)
+	TouchAdminAPIToken(id int64) error
+	IsAdmin(userID int64) (bool, error)
+}
+
+// AdminAPIHandler обрабатывает запросы административного REST API
+type AdminAPIHandler struct {
+	tokens       adminTokenStore
+	db           *database.DB
+	subs         *service.SubscriptionService
+	quotas       *service.QuotaService
+	refunds      *service.RefundService
+	acquisitions *service.AcquisitionService
+	posts        *database.PostHistoryRepository
+}
+
+func NewAdminAPIHandler(db *database.DB, subs *service.SubscriptionService, quotas *service.QuotaService, refunds *service.RefundService, acquisitions *service.AcquisitionService, posts *database.PostHistoryRepository) *AdminAPIHandler {
+	return &AdminAPIHandler{tokens: db, db: db, subs: subs, quotas: quotas, refunds: refunds, acquisitions: acquisitions, posts: posts}
+}
+
+// SetupRoutes регистрирует маршруты API. charge — ручное рекуррентное списание, доступно только роли admin.
+func (h *AdminAPIHandler) SetupRoutes(r *mux.Router, charge http.HandlerFunc) {
+	api := r.PathPrefix(AdminAPIPrefix).Subrouter()
+	api.Use(h.authenticate)
+
+	viewer, operator, admin := database.AdminRoleViewer, database.AdminRoleOperator, database.AdminRoleAdmin
+
+	api.HandleFunc("/usage", h.require(viewer, h.GetUsageSummary)).Methods("GET")
+	api.HandleFunc("/subscriptions", h.require(viewer, h.ListSubscriptions)).Methods("GET")
+	api.HandleFunc("/payments", h.require(viewer, h.GetPaymentTotals)).Methods("GET")
+	api.HandleFunc("/acquisition", h.require(viewer, h.GetAcquisitionFunnel)).Methods("GET")
+
+	api.HandleFunc("/users/{ref}", h.require(viewer, h.GetUser)).Methods("GET")
+	api.HandleFunc("/users/{ref}/subscription", h.require(viewer, h.GetUserSubscription)).Methods("GET")
+	api.HandleFunc("/users/{ref}/payments", h.require(viewer, h.GetUserPayments)).Methods("GET")
+	api.HandleFunc("/users/{ref}/posts", h.require(viewer, h.GetUserPosts)).Methods("GET")
+	api.HandleFunc("/users/{ref}/usage", h.require(viewer, h.GetUserUsage)).Methods("GET")
+
+	api.HandleFunc("/users/{ref}/reset_limits", h.require(operator, h.ResetLimits)).Methods("POST")
+	api.HandleFunc("/users/{ref}/quota", h.require(operator, h.GrantQuota)).Methods("POST")
+	api.HandleFunc("/users/{ref}/premium", h.require(operator, h.GrantPremium)).Methods("POST")
+	api.HandleFunc("/users/{ref}/premium", h.require(operator, h.RevokePremium)).Methods("DELETE")
+	api.HandleFunc("/users/{ref}/ban", h.require(operator, h.Ban)).Methods("POST")
+	api.HandleFunc("/users/{ref}/ban", h.require(operator, h.Unban)).Methods("DELETE")
+
+	api.HandleFunc("/tariffs", h.require(viewer, h.ListTariffs)).Methods("GET")
+	api.HandleFunc("/tariffs/{id}", h.require(admin, h.SaveTariff)).Methods("PUT")
+
+	api.HandleFunc("/payments/{id}/refund", h.require(admin, h.RefundPayment)).Methods("POST")
+	api.HandleFunc("/charge", h.require(admin, h.audited("charge", charge))).Methods("POST")
+}
+
+// authenticate проверяет токен из заголовка Authorization: Bearer <token>
+func (h *AdminAPIHandler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		raw = strings.TrimSpace(raw)
+		if !ok || raw == "" {
+			writeJSONError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		token, err := h.tokens.GetAdminAPITokenByHash(database.HashAdminAPIToken(raw))
+		if err != nil {
+			log.Printf("❌ Admin API: ошибка проверки токена: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "token lookup failed")
+			return
+		}
+		if token == nil {
+			monitoring.RecordError("auth", "admin_api")
+			writeJSONError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		// Токен действует, только пока его создатель остается администратором
+		isAdmin, err := h.tokens.IsAdmin(token.CreatedBy)
+		if err != nil {
+			log.Printf("❌ Admin API: ошибка проверки прав создателя токена %d: %v", token.ID, err)
+			writeJSONError(w, http.StatusInternalServerError, "token lookup failed")
+			return
+		}
+		if !isAdmin {
+			monitoring.RecordError("auth", "admin_api")
+			writeJSONError(w, http.StatusUnauthorized, "token creator is no longer an administrator")
+			return
+		}
+		if err := h.tokens.TouchAdminAPIToken(token.ID); err != nil {
+			log.Printf("⚠️ Admin API: не удалось обновить last_used_at токена %d: %v", token.ID, err)
+		}
+
+		ctx := context.WithValue(r.Context(), adminTokenContextKey{}, token)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// require пропускает запрос, только если роль токена не ниже required
+func (h *AdminAPIHandler) require(required database.AdminRole, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := tokenFromContext(r)
+		if token == nil || !token.Role.Allows(required) {
+			writeJSONError(w, http.StatusForbidden, fmt.Sprintf("role %s required", required))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// audited записывает вызов в журнал действий администраторов
+func (h *AdminAPIHandler) audited(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var target *int64
+		if userID, err := strconv.ParseInt(r.URL.Query().Get("user_id"), 10, 64); err == nil {
+			target = &userID
+		}
+		h.audit(r, action, target, r.URL.RawQuery)
+		next(w, r)
+	}
+}
+
+// audit записывает действие, выполненное через API
+func (h *AdminAPIHandler) audit(r *http.Request, action string, target *int64, details string) {
+	token := tokenFromContext(r)
+	if token == nil || h.db == nil {
+		return
+	}
+	details = strings.TrimSpace(fmt.Sprintf("api_token=%d %s", token.ID, details))
+	if err := h.db.LogAdminAction(token.CreatedBy, "api:"+action, target, details); err != nil {
+		log.Printf("❌ Admin API: ошибка записи в журнал: %v", err)
+	}
+}
+
+func tokenFromContext(r *http.Request) *database.AdminAPIToken {
+	token, _ := r.Context().Value(adminTokenContextKey{}).(*database.AdminAPIToken)
+	return token
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// findUser ищет пользователя из параметра пути {ref} (ID или @username)
+func (h *AdminAPIHandler) findUser(w http.ResponseWriter, r *http.Request) *database.AdminUserInfo {
+	ref := mux.Vars(r)["ref"]
+	user, err := h.db.FindUser(ref)
+	if err != nil {
+		log.Printf("❌ Admin API: ошибка поиска пользователя %s: %v", ref, err)
+		writeJSONError(w, http.StatusInternalServerError, "user lookup failed")
+		return nil
+	}
+	if user == nil {
+		writeJSONError(w, http.StatusNotFound, "user not found")
+		return nil
+	}
+	return user
+}
+
+// queryInt читает целочисленный query-параметр со значением по умолчанию
+func queryInt(r *http.Request, key string, def, max int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s", key)
+	}
+	if max > 0 && n > max {
+		n = max
+	}
+	return n, nil
+}
+
+// GET /admin/api/v1/usage
+func (h *AdminAPIHandler) GetUsageSummary(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.db.GetUsageSummary()
+	if err != nil {
+		log.Printf("❌ Admin API: ошибка получения статистики: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to get usage summary")
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// GET /admin/api/v1/payments?days=30
+func (h *AdminAPIHandler) GetPaymentTotals(w http.ResponseWriter, r *http.Request) {
+	days, err := queryInt(r, "days", 30, 366)
+	if err != nil || days == 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid days")
+		return
+	}
+	totals, err := h.subs.GetPaymentTotals(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("❌ Admin API: ошибка получения итогов платежей: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to get payments")
+		return
+	}
+	if totals == nil {
+		totals = []*domain.PaymentTotals{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"days": days, "totals": totals})
+}
+
+// GET /admin/api/v1/acquisition?days=30&format=csv — воронка по источникам привлечения, по умолчанию JSON
+func (h *AdminAPIHandler) GetAcquisitionFunnel(w http.ResponseWriter, r *http.Request) {
+	if h.acquisitions == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "acquisition tracking is not configured")
+		return
+	}
+	days, err := queryInt(r, "days", 30, 366)
+	if err != nil || days == 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid days")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		writeJSONError(w, http.StatusBadRequest, "invalid format")
+		return
+	}
+	funnel, err := h.acquisitions.Funnel(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("❌ Admin API: ошибка получения источников привлечения: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to get acquisition funnel")
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="acquisition_%dd.csv"`, days))
+		if err := service.WriteAcquisitionCSV(w, funnel); err != nil {
+			log.Printf("❌ Admin API: ошибка выгрузки источников привлечения: %v", err)
+		}
+		return
+	}
+	if funnel == nil {
+		funnel = []*domain.AcquisitionStats{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"days": days, "funnel": funnel})
+}
+
+// POST /admin/api/v1/payments/{id}/refund {"amount": 490, "reason": "..."} — без суммы возвращается весь остаток
+// (только роль admin). {id} — ID платежа в журнале или ID платежа YooKassa.
+func (h *AdminAPIHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	if h.refunds == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "refunds are not configured")
+		return
+	}
+	var req struct {
+		Amount float64 `json:"amount"`
+		Reason string  `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Amount < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid refund json")
+			return
+		}
+	}
+
+	ref := mux.Vars(r)["id"]
+	payment, err := h.refunds.FindPayment(ref)
+	if err != nil {
+		log.Printf("❌ Admin API: ошибка поиска платежа %s: %v", ref, err)
+		writeJSONError(w, http.StatusInternalServerError, "payment lookup failed")
+		return
+	}
+	if payment == nil {
+		writeJSONError(w, http.StatusNotFound, "payment not found")
+		return
+	}
+
+	token := tokenFromContext(r)
+	outcome, err := h.refunds.Refund(payment, req.Amount, token.CreatedBy, req.Reason)
+	if errors.Is(err, domain.ErrRefundNotAllowed) {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Admin API: ошибка возврата платежа %d: %v", payment.ID, err)
+		writeJSONError(w, http.StatusBadGateway, "refund failed")
+		return
+	}
+	h.audit(r, "refund", &payment.UserID, fmt.Sprintf("payment=%d amount=%.2f refund=%d reason=%s",
+		payment.ID, outcome.Refund.Amount, outcome.Refund.ID, req.Reason))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"refund":          outcome.Refund,
+		"full":            outcome.Full,
+		"downgraded":      outcome.Downgraded,
+		"revoked_credits": outcome.RevokedCredits,
+		"gift_cancelled":  outcome.GiftCancelled,
+	})
+}
+
+// GET /admin/api/v1/subscriptions
+func (h *AdminAPIHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.subs.GetAllActiveSubscriptions()
+	if err != nil {
+		log.Printf("❌ Admin API: ошибка получения подписок: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to get subscriptions")
+		return
+	}
+	if subscriptions == nil {
+		subscriptions = []*domain.Subscription{}
+	}
+	writeJSON(w, http.StatusOK, subscriptions)
+}
+
+// GET /admin/api/v1/users/{ref}
+func (h *AdminAPIHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user := h.findUser(w, r)
+	if user == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// GET /admin/api/v1/users/{ref}/subscription
+func (h *AdminAPIHandler) GetUserSubscription(w http.ResponseWriter, r *http.Request) {
+	user := h.findUser(w, r)
+	if user == nil {
+		return
+	}
+	subscription, err := h.subs.GetUserSubscription(user.ID)
+	if err != nil {
+		log.Printf("❌ Admin API: ошибка получения подписки пользователя %d: %v", user.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to get subscription")
+		return
+	}
+	if subscription == nil {
+		writeJSONError(w, http.StatusNotFound, "subscription not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, subscription)
+}
+
+// GET /admin/api/v1/users/{ref}/payments
+func (h *AdminAPIHandler) GetUserPayments(w http.ResponseWriter, r *http.Request) {
+	user := h.findUser(w, r)
+	if user == nil {
+		return
+	}
+	history, err := h.subs.GetUserPaymentHistory(user.ID)
+	if err != nil {
+		log.Printf("❌ Admin API: ошибка получения платежей пользователя %d: %v", user.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to get payments")
+		return
+	}
+	if history == nil {
+		history = []*domain.Payment{}
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+// GET /admin/api/v1/users/{ref}/posts?limit=20&offset=0
+func (h *AdminAPIHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
+	user := h.findUser(w, r)
+	if user == nil {
+		return
+	}
+	limit, err := queryInt(r, "limit", 20, 100)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	offset, err := queryInt(r, "offset", 0, 0)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	posts, err := h.posts.GetUserPostHistory(user.ID, limit, offset)
+	if err != nil {
+		log.Printf("❌ Admin API: ошибка получения постов пользователя %d: %v", user.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to get posts")
+		return
+	}
+	if posts == nil {
+		posts = []*database.PostHistory{}
+	}
+	writeJSON(w, http.StatusOK, posts)
+}
+
+// GET /admin/api/v1/users/{ref}/usage
+func (h *AdminAPIHandler) GetUserUsage(w http.ResponseWriter, r *http.Request) {
+	user := h.findUser(w, r)
+	if user == nil {
+		return
+	}
+	quotas, err := h.quotas.Usage(user.ID)
+	if err != nil {
+		log.Printf("❌ Admin API: ошибка получения квот пользователя %d: %v", user.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to get usage")
+		return
+	}
+	total, err := h.db.GetUserUsageTotal(user.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to get usage")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"user_id":    user.ID,
+		"quotas":     quotas,
+		"used_total": total,
+	})
+}
+
+// POST /admin/api/v1/users/{ref}/reset_limits
+func (h *AdminAPIHandler) ResetLimits(w http.ResponseWriter, r *http.Request) {
+	user := h.findUser(w, r)
+	if user == nil {
+		return
+	}
+	if err := h.quotas.ResetUsage(user.ID); err != nil {
+		log.Printf("❌ Admin API: ошибка сброса лимитов пользователя %d: %v", user.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to reset limits")
+		return
+	}
+	h.audit(r, "reset_limits", &user.ID, "")
+	writeJSON(w, http.StatusOK, map[string]interface{}{"user_id": user.ID, "status": "ok"})
+}
+
+// POST /admin/api/v1/users/{ref}/quota {"amount": 10, "resource": "rewrites"}; по умолчанию — создания постов
+func (h *AdminAPIHandler) GrantQuota(w http.ResponseWriter, r *http.Request) {
+	user := h.findUser(w, r)
+	if user == nil {
+		return
+	}
+	var req struct {
+		Amount   int                  `json:"amount"`
+		Resource domain.QuotaResource `json:"resource"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Amount <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "positive amount required")
+		return
+	}
+	if req.Resource == "" {
+		req.Resource = domain.QuotaGenerations
+	}
+	if !domain.IsValidQuotaResource(req.Resource) {
+		writeJSONError(w, http.StatusBadRequest, "unknown resource")
+		return
+	}
+	token := tokenFromContext(r)
+	if err := h.db.GrantQuota(user.ID, req.Resource, req.Amount, token.CreatedBy); err != nil {
+		log.Printf("❌ Admin API: ошибка выдачи квоты пользователю %d: %v", user.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to grant quota")
+		return
+	}
+	h.audit(r, "grant_quota", &user.ID, fmt.Sprintf("resource=%s amount=%d", req.Resource, req.Amount))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"user_id": user.ID, "resource": req.Resource, "granted": req.Amount})
+}
+
+// POST /admin/api/v1/users/{ref}/premium {"days": 30}
+func (h *AdminAPIHandler) GrantPremium(w http.ResponseWriter, r *http.Request) {
+	user := h.findUser(w, r)
+	if user == nil {
+		return
+	}
+	var req struct {
+		Days int `json:"days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Days <= 0 || req.Days > database.MaxPremiumGrantDays {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("days must be between 1 and %d", database.MaxPremiumGrantDays))
+		return
+	}
+	until, err := h.db.ExtendPremium(user.ID, req.Days)
+	if err != nil {
+		log.Printf("❌ Admin API: ошибка выдачи Premium пользователю %d: %v", user.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to grant premium")
+		return
+	}
+	h.audit(r, "grant_premium", &user.ID, fmt.Sprintf("days=%d", req.Days))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"user_id": user.ID, "premium_until": until})
+}
+
+// DELETE /admin/api/v1/users/{ref}/premium
+func (h *AdminAPIHandler) RevokePremium(w http.ResponseWriter, r *http.Request) {
+	user := h.findUser(w, r)
+	if user == nil {
+		return
+	}
+	if err := h.db.SetPremiumUntil(user.ID, nil); err != nil {
+		log.Printf("❌ Admin API: ошибка отзыва Premium пользователя %d: %v", user.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to revoke premium")
+		return
+	}
+	h.audit(r, "revoke_premium", &user.ID, "")
+	writeJSON(w, http.StatusOK, map[string]interface{}{"user_id": user.ID, "status": "ok"})
+}
+
+// POST /admin/api/v1/users/{ref}/ban
+func (h *AdminAPIHandler) Ban(w http.ResponseWriter, r *http.Request) {
+	h.setBanned(w, r, true)
+}
+
+// DELETE /admin/api/v1/users/{ref}/ban
+func (h *AdminAPIHandler) Unban(w http.ResponseWriter, r *http.Request) {
+	h.setBanned(w, r, false)
+}
+
+func (h *AdminAPIHandler) setBanned(w http.ResponseWriter, r *http.Request, banned bool) {
+	user := h.findUser(w, r)
+	if user == nil {
+		return
+	}
+	if banned && (user.IsAdmin || database.IsEnvAdmin(user.ID)) {
+		writeJSONError(w, http.StatusConflict, "cannot ban an admin")
+		return
+	}
+	if err := h.db.SetUserBanned(user.ID, banned); err != nil {
+		log.Printf("❌ Admin API: ошибка изменения бана пользователя %d: %v", user.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to update ban")
+		return
+	}
+	action := "unban"
+	if banned {
+		action = "ban"
+	}
+	h.audit(r, action, &user.ID, "")
+	writeJSON(w, http.StatusOK, map[string]interface{}{"user_id": user.ID, "is_banned": banned})
+}
+
+// GET /admin/api/v1/tariffs
+func (h *AdminAPIHandler) ListTariffs(w http.ResponseWriter, r *http.Request) {
+	tariffs, err := h.subs.GetAllTariffs()
+	if err != nil {
+		log.Printf("❌ Admin API: ошибка получения тарифов: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to get tariffs")
+		return
+	}
+	if tariffs == nil {
+		tariffs = []*domain.Tariff{}
+	}
+	writeJSON(w, http.StatusOK, tariffs)
+}
+
+// PUT /admin/api/v1/tariffs/{id} — создает или полностью заменяет тариф (только роль admin)
+func (h *AdminAPIHandler) SaveTariff(w http.ResponseWriter, r *http.Request) {
+	var tariff domain.Tariff
+	if err := json.NewDecoder(r.Body).Decode(&tariff); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid tariff json")
+		return
+	}
+	tariff.ID = mux.Vars(r)["id"]
+	if tariff.Features == nil {
+		tariff.Features = []string{}
+	}
+	if err := service.ValidateTariff(&tariff); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.subs.SaveTariff(&tariff); err != nil {
+		log.Printf("❌ Admin API: ошибка сохранения тарифа %s: %v", tariff.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to save tariff")
+		return
+	}
+	h.audit(r, "save_tariff", nil, fmt.Sprintf("tariff=%s price=%.2f period=%s visible=%v",
+		tariff.ID, tariff.Price, tariff.Period, tariff.Visible))
+	writeJSON(w, http.StatusOK, tariff)
+}
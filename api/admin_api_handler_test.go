@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ai_tg_writer/internal/infrastructure/database"
+
+	"github.com/gorilla/mux"
+)
+
+// stubTokenStore хранилище токенов для тестов
+type stubTokenStore map[string]*database.AdminAPIToken
+
+func (s stubTokenStore) GetAdminAPITokenByHash(hash string) (*database.AdminAPIToken, error) {
+	return s[hash], nil
+}
+
+func (s stubTokenStore) TouchAdminAPIToken(id int64) error {
+	return nil
+}
+
+// removedAdminID администратор, у которого сняли права после выпуска токена
+const removedAdminID = 99
+
+func (s stubTokenStore) IsAdmin(userID int64) (bool, error) {
+	return userID != removedAdminID, nil
+}
+
+func TestAdminAPIAuth(t *testing.T) {
+	tokens := stubTokenStore{
+		database.HashAdminAPIToken("viewer-token"): {ID: 1, Role: database.AdminRoleViewer},
+		database.HashAdminAPIToken("admin-token"):  {ID: 2, Role: database.AdminRoleAdmin},
+		database.HashAdminAPIToken("orphan-token"): {ID: 3, Role: database.AdminRoleAdmin, CreatedBy: removedAdminID},
+	}
+	handler := &AdminAPIHandler{tokens: tokens}
+
+	charged := false
+	router := mux.NewRouter()
+	handler.SetupRoutes(router, func(w http.ResponseWriter, r *http.Request) {
+		charged = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		expected      int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"not bearer", "viewer-token", http.StatusUnauthorized},
+		{"unknown token", "Bearer wrong", http.StatusUnauthorized},
+		{"insufficient role", "Bearer viewer-token", http.StatusForbidden},
+		{"admin role", "Bearer admin-token", http.StatusOK},
+		{"creator is no longer admin", "Bearer orphan-token", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			charged = false
+			req := httptest.NewRequest("POST", AdminAPIPrefix+"/charge?user_id=1&amount=1.00", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("Ожидался статус %d, получен %d", tt.expected, rec.Code)
+			}
+			if charged != (tt.expected == http.StatusOK) {
+				t.Errorf("Списание вызвано = %v при статусе %d", charged, rec.Code)
+			}
+		})
+	}
+}
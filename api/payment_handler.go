@@ -2,7 +2,9 @@ package api
 
 import (
 	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/infrastructure/bot"
 	"ai_tg_writer/internal/infrastructure/database"
+	"ai_tg_writer/internal/infrastructure/prodamus_payments"
 	"ai_tg_writer/internal/service"
 	"encoding/json"
 	"log"
@@ -16,15 +18,19 @@ import (
 
 type PaymentHandler struct {
 	subscriptionService *service.SubscriptionService
-	prodamusHandler     interface{} // Временно используем interface{} для совместимости
+	creditService       *service.CreditService
+	prodamus            domain.PaymentProvider // nil — Prodamus не подключен, уведомления отклоняются
 	db                  *database.DB
+	bot                 *bot.Bot
 }
 
-func NewPaymentHandler(subscriptionService *service.SubscriptionService, prodamusHandler interface{}, db *database.DB) *PaymentHandler {
+func NewPaymentHandler(subscriptionService *service.SubscriptionService, creditService *service.CreditService, prodamus domain.PaymentProvider, db *database.DB, bot *bot.Bot) *PaymentHandler {
 	return &PaymentHandler{
 		subscriptionService: subscriptionService,
-		prodamusHandler:     prodamusHandler,
+		creditService:       creditService,
+		prodamus:            prodamus,
 		db:                  db,
+		bot:                 bot,
 	}
 }
 
@@ -275,13 +281,17 @@ func (h *PaymentHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Временно отключаем проверку подписи
-	// TODO: Добавить проверку подписи для нового платежного модуля
-	// if !h.prodamusHandler.VerifyWebhook(r.Form, signature) {
-	// 	log.Println("Неверная подпись вебхука")
-	// 	http.Error(w, "Неверная подпись", http.StatusUnauthorized)
-	// 	return
-	// }
+	// Без подключенного Prodamus подпись проверить нечем, поэтому уведомление не принимаем
+	if h.prodamus == nil {
+		log.Println("Prodamus не подключен, вебхук отклонен")
+		http.Error(w, "Prodamus не подключен", http.StatusServiceUnavailable)
+		return
+	}
+	if err := h.prodamus.VerifyWebhook(&domain.WebhookRequest{Form: r.Form, Signature: signature}); err != nil {
+		log.Printf("Неверная подпись вебхука: %v", err)
+		http.Error(w, "Неверная подпись", http.StatusUnauthorized)
+		return
+	}
 
 	// Временно отключаем обработку вебхука
 	// TODO: Добавить обработку вебхука для нового платежного модуля
@@ -294,14 +304,16 @@ func (h *PaymentHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 
 	// Временно используем заглушку для webhookData
 	webhookData := struct {
-		OrderID string
-		Sum     string
+		OrderID       string
+		Sum           string
+		PaymentStatus string
 	}{
-		OrderID: r.Form.Get("order_id"),
-		Sum:     r.Form.Get("sum"),
+		OrderID:       r.Form.Get("order_id"),
+		Sum:           r.Form.Get("sum"),
+		PaymentStatus: r.Form.Get("payment_status"),
 	}
 
-	log.Printf("Обработка вебхука для заказа: %s, сумма: %s", webhookData.OrderID, webhookData.Sum)
+	log.Printf("Обработка вебхука для заказа: %s, сумма: %s, статус: %s", webhookData.OrderID, webhookData.Sum, webhookData.PaymentStatus)
 
 	// Парсим order_id для получения userID
 	parts := strings.Split(webhookData.OrderID, "_")
@@ -318,6 +330,49 @@ func (h *PaymentHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	amount, err := strconv.ParseFloat(webhookData.Sum, 64)
+	if err != nil || amount <= 0 {
+		log.Printf("Неверная сумма в вебхуке заказа %s: %q", webhookData.OrderID, webhookData.Sum)
+		http.Error(w, "Неверная сумма", http.StatusBadRequest)
+		return
+	}
+
+	// Записываем оплату в журнал платежей; order_id — идентификатор платежа у Prodamus
+	status := prodamus_payments.PaymentStatus(webhookData.PaymentStatus)
+	kind := domain.PaymentKindInitial
+	if strings.HasPrefix(webhookData.OrderID, "pay_") {
+		kind = domain.PaymentKindCreditPack
+	}
+	h.subscriptionService.RecordPayment(&domain.Payment{
+		UserID:            userID,
+		Provider:          domain.PaymentProviderProdamus,
+		ProviderPaymentID: &webhookData.OrderID,
+		Kind:              kind,
+		Amount:            amount,
+		Currency:          "RUB",
+		Status:            status,
+	})
+
+	// Тариф и кредиты выдаются только за оплаченный заказ; об отказе Prodamus тоже уведомляет
+	if status != domain.PaymentStatusSucceeded {
+		log.Printf("Заказ %s не оплачен (payment_status=%q), доступ не выдается", webhookData.OrderID, webhookData.PaymentStatus)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("success"))
+		return
+	}
+
+	// Разовая оплата (order_id pay_*) — покупка пакета кредитов, тариф не меняется
+	if kind == domain.PaymentKindCreditPack {
+		if err := completeCreditPurchase(h.creditService, h.bot, domain.PaymentProviderProdamus, webhookData.OrderID); err != nil {
+			log.Printf("Ошибка зачисления кредитов по заказу %s: %v", webhookData.OrderID, err)
+			http.Error(w, "Ошибка зачисления кредитов", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("success"))
+		return
+	}
+
 	// Обновляем тариф пользователя
 	if err := h.db.UpdateUserTariff(userID, "payed"); err != nil {
 		log.Printf("Ошибка обновления тарифа пользователя %d: %v", userID, err)
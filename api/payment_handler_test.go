@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/infrastructure/prodamus_payments"
+	"ai_tg_writer/internal/service"
+)
+
+// stubPaymentLedger запоминает платежи, записанные в журнал
+type stubPaymentLedger struct {
+	domain.PaymentRepository
+	recorded []*domain.Payment
+}
+
+func (l *stubPaymentLedger) Record(payment *domain.Payment) error {
+	l.recorded = append(l.recorded, payment)
+	return nil
+}
+
+func TestProdamusWebhookGrantsOnlyPaidOrders(t *testing.T) {
+	prodamus := prodamus_payments.NewProdamusHandler("", "", "secret")
+	ledger := &stubPaymentLedger{}
+	subs := service.NewSubscriptionService(nil, nil, ledger, nil, nil, nil, nil, nil)
+	// Без базы данных выдача тарифа упала бы: тест проверяет, что до нее не доходит
+	h := NewPaymentHandler(subs, nil, prodamus_payments.NewProvider(prodamus), nil, nil)
+
+	send := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/prodamus/webhook", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Sign", prodamus.CreateSignature(form))
+		rec := httptest.NewRecorder()
+		h.HandleWebhook(rec, req)
+		return rec
+	}
+
+	rec := send(url.Values{"order_id": {"sub_42_20250101"}, "sum": {"299.00"}, "payment_status": {"order_denied"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Уведомление об отказе должно приниматься, получен %d", rec.Code)
+	}
+	if len(ledger.recorded) != 1 || ledger.recorded[0].Status != domain.PaymentStatusFailed || ledger.recorded[0].Amount != 299 {
+		t.Fatalf("Отказ должен попасть в журнал как неуспешный платеж, записано %+v", ledger.recorded)
+	}
+
+	rec = send(url.Values{"order_id": {"pay_42_20250101"}, "sum": {"abc"}, "payment_status": {"success"}})
+	if rec.Code != http.StatusBadRequest || len(ledger.recorded) != 1 {
+		t.Errorf("Уведомление с неверной суммой должно отклоняться, получен %d, записей %d", rec.Code, len(ledger.recorded))
+	}
+
+	form := url.Values{"order_id": {"pay_42_20250101"}, "sum": {"299.00"}, "payment_status": {"success"}}
+	req := httptest.NewRequest(http.MethodPost, "/prodamus/webhook", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Sign", strings.Repeat("0", 64))
+	rec = httptest.NewRecorder()
+	h.HandleWebhook(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Уведомление с неверной подписью должно отклоняться, получен %d", rec.Code)
+	}
+}
@@ -1,27 +1,32 @@
 package api
 
 import (
+	"ai_tg_writer/internal/domain"
 	"ai_tg_writer/internal/infrastructure/bot"
 	"ai_tg_writer/internal/infrastructure/database"
+	"ai_tg_writer/internal/infrastructure/yookassa"
 	"ai_tg_writer/internal/monitoring"
 	"ai_tg_writer/internal/service"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
 
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type Server struct {
-	router        *mux.Router
-	port          string
-	healthChecker *monitoring.HealthChecker
+	router         *mux.Router
+	port           string
+	healthChecker  *monitoring.HealthChecker
+	trustedProxies []*net.IPNet // Обратные прокси, которым доверяем адрес клиента в заголовках
 }
 
 func NewServer(port string) *Server {
@@ -31,6 +36,12 @@ func NewServer(port string) *Server {
 	}
 }
 
+// SetTrustedProxies задает обратные прокси, чьим заголовкам X-Forwarded-For и X-Real-IP можно доверять.
+// Вызывается до SetupRoutes.
+func (s *Server) SetTrustedProxies(proxies []*net.IPNet) {
+	s.trustedProxies = proxies
+}
+
 func (s *Server) AddHealthCheck(healthChecker *monitoring.HealthChecker) {
 	s.healthChecker = healthChecker
 	s.router.HandleFunc("/health", s.healthChecker.HealthHandler).Methods("GET")
@@ -39,12 +50,22 @@ func (s *Server) AddHealthCheck(healthChecker *monitoring.HealthChecker) {
 // SetupRoutes настраивает все маршруты сервера
 func (s *Server) SetupRoutes(
 	subscriptionService *service.SubscriptionService,
-	prodamusHandler interface{},
+	quotaService *service.QuotaService,
+	creditService *service.CreditService,
+	giftService *service.GiftService,
+	acquisitionService *service.AcquisitionService,
+	refundService *service.RefundService,
+	providers *service.PaymentProviders,
+	ykProvider *yookassa.Provider,
 	db *database.DB,
 	bot *bot.Bot,
 ) {
-	// Создаем обработчик платежей
-	paymentHandler := NewPaymentHandler(subscriptionService, prodamusHandler, db)
+	// Создаем обработчик платежей Prodamus; без подключенного провайдера его уведомления отклоняются
+	var prodamus domain.PaymentProvider
+	if provider, err := providers.Get(domain.PaymentProviderProdamus); err == nil {
+		prodamus = provider
+	}
+	paymentHandler := NewPaymentHandler(subscriptionService, creditService, prodamus, db, bot)
 
 	// Настраиваем маршруты для платежей
 	paymentHandler.SetupRoutes(s.router)
@@ -62,8 +83,19 @@ func (s *Server) SetupRoutes(
 	s.router.Use(monitoringMiddleware)
 	s.router.Use(otelhttp.NewMiddleware("ai_tg_writer"))
 
-	yk := NewYooKassaHandler(subscriptionService, db, bot)
+	yk := NewYooKassaHandler(subscriptionService, creditService, giftService, refundService, ykProvider, db, bot, s.trustedProxies)
 	yk.SetupRoutes(s.router)
+
+	// Административное API; ручное списание доступно только через него
+	adminAPI := NewAdminAPIHandler(db, subscriptionService, quotaService, refundService, acquisitionService, database.NewPostHistoryRepository(db.DB))
+	adminAPI.SetupRoutes(s.router, yk.Charge)
+}
+
+// AddTelegramWebhook регистрирует приемник обновлений Telegram и возвращает канал обновлений
+func (s *Server) AddTelegramWebhook(path, secret string) tgbotapi.UpdatesChannel {
+	handler := NewTelegramWebhookHandler(secret, 100)
+	handler.SetupRoutes(s.router, path)
+	return handler.Updates()
 }
 
 // handlePing — простой эндпоинт для проверки доступности сервера
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"ai_tg_writer/internal/monitoring"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/gorilla/mux"
+)
+
+// telegramSecretHeader заголовок, в котором Telegram передает secret_token из setWebhook
+const telegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// telegramEnqueueTimeout сколько ждать места в очереди обновлений. Если диспетчер не успевает,
+// Telegram получает 503 и повторит доставку позже, а запросы не копятся в ожидании.
+const telegramEnqueueTimeout = 5 * time.Second
+
+// TelegramWebhookHandler принимает обновления от Telegram и передает их в общий диспетчер
+type TelegramWebhookHandler struct {
+	secret  string
+	updates chan tgbotapi.Update
+}
+
+func NewTelegramWebhookHandler(secret string, bufferSize int) *TelegramWebhookHandler {
+	return &TelegramWebhookHandler{
+		secret:  secret,
+		updates: make(chan tgbotapi.Update, bufferSize),
+	}
+}
+
+// Updates возвращает канал обновлений, совместимый с GetUpdatesChan
+func (h *TelegramWebhookHandler) Updates() tgbotapi.UpdatesChannel {
+	return h.updates
+}
+
+// Webhook обрабатывает входящее обновление
+// URL: POST /telegram/webhook
+func (h *TelegramWebhookHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	// Без настроенного секрета обновления не принимаются: подлинность запроса нечем проверить
+	got := r.Header.Get(telegramSecretHeader)
+	if h.secret == "" || subtle.ConstantTimeCompare([]byte(got), []byte(h.secret)) != 1 {
+		log.Printf("⛔ Telegram webhook: неверный secret token от %s", r.RemoteAddr)
+		monitoring.RecordError("webhook", "telegram")
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		log.Printf("❌ Telegram webhook: ошибка разбора обновления: %v", err)
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+
+	// Отвечаем Telegram сразу, обработка идет в диспетчере
+	timer := time.NewTimer(telegramEnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case h.updates <- update:
+		w.WriteHeader(http.StatusOK)
+	case <-timer.C:
+		log.Printf("⚠️ Telegram webhook: очередь обновлений заполнена, обновление %d отклонено", update.UpdateID)
+		monitoring.RecordError("webhook_queue", "telegram")
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+		log.Printf("⚠️ Telegram webhook: запрос отменен до постановки обновления %d в очередь", update.UpdateID)
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}
+}
+
+func (h *TelegramWebhookHandler) SetupRoutes(r *mux.Router, path string) {
+	r.HandleFunc(path, h.Webhook).Methods("POST")
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTelegramWebhook(t *testing.T) {
+	handler := NewTelegramWebhookHandler("secret", 1)
+
+	tests := []struct {
+		name     string
+		secret   string
+		body     string
+		expected int
+	}{
+		{"без secret token", "", `{"update_id": 1}`, http.StatusForbidden},
+		{"неверный secret token", "wrong", `{"update_id": 1}`, http.StatusForbidden},
+		{"некорректный JSON", "secret", `{"update_id":`, http.StatusBadRequest},
+		{"обновление", "secret", `{"update_id": 7, "message": {"message_id": 3, "text": "привет"}}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(tt.body))
+			if tt.secret != "" {
+				req.Header.Set(telegramSecretHeader, tt.secret)
+			}
+			rec := httptest.NewRecorder()
+			handler.Webhook(rec, req)
+			if rec.Code != tt.expected {
+				t.Errorf("Ожидался статус %d, получено %d", tt.expected, rec.Code)
+			}
+		})
+	}
+
+	select {
+	case update := <-handler.Updates():
+		if update.UpdateID != 7 || update.Message == nil || update.Message.Text != "привет" {
+			t.Errorf("Получено неожиданное обновление: %+v", update)
+		}
+	default:
+		t.Fatal("Обновление не передано в канал")
+	}
+	select {
+	case update := <-handler.Updates():
+		t.Errorf("Отклоненные запросы не должны попадать в канал: %+v", update)
+	default:
+	}
+}
+
+func TestTelegramWebhookQueueFull(t *testing.T) {
+	handler := NewTelegramWebhookHandler("secret", 1)
+	send := func(ctx context.Context) int {
+		req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(`{"update_id": 1}`)).WithContext(ctx)
+		req.Header.Set(telegramSecretHeader, "secret")
+		rec := httptest.NewRecorder()
+		handler.Webhook(rec, req)
+		return rec.Code
+	}
+
+	if code := send(context.Background()); code != http.StatusOK {
+		t.Fatalf("Первое обновление должно попасть в очередь, получено %d", code)
+	}
+
+	// Очередь заполнена: запрос не висит, а отвечает 503, чтобы Telegram повторил доставку
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if code := send(ctx); code != http.StatusServiceUnavailable {
+		t.Errorf("При заполненной очереди ожидался статус 503, получено %d", code)
+	}
+}
+
+func TestTelegramWebhookWithoutSecretRejectsUpdates(t *testing.T) {
+	handler := NewTelegramWebhookHandler("", 1)
+	req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(`{"update_id": 1}`))
+	rec := httptest.NewRecorder()
+	handler.Webhook(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Без настроенного секрета ожидался статус 403, получено %d", rec.Code)
+	}
+}
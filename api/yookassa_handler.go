@@ -1,16 +1,22 @@
 package api
 
 import (
+	"ai_tg_writer/internal/domain"
 	"ai_tg_writer/internal/infrastructure/bot"
 	"ai_tg_writer/internal/infrastructure/database"
 	"ai_tg_writer/internal/infrastructure/yookassa"
 	"ai_tg_writer/internal/monitoring"
 	"ai_tg_writer/internal/service"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -18,14 +24,31 @@ import (
 )
 
 type YooKassaHandler struct {
-	subs *service.SubscriptionService
-	db   *database.DB
-	yc   *yookassa.Client
-	bot  *bot.Bot
+	subs     *service.SubscriptionService
+	credits  *service.CreditService
+	gifts    *service.GiftService
+	refunds  *service.RefundService
+	provider *yookassa.Provider
+	db       *database.DB
+	events   domain.WebhookEventRepository
+	bot      *bot.Bot
+
+	trustedProxies []*net.IPNet // Прокси, которым доверяем адрес отправителя в заголовках
 }
 
-func NewYooKassaHandler(subs *service.SubscriptionService, db *database.DB, bot *bot.Bot) *YooKassaHandler {
-	return &YooKassaHandler{subs: subs, db: db, yc: yookassa.New(), bot: bot}
+func NewYooKassaHandler(subs *service.SubscriptionService, credits *service.CreditService, gifts *service.GiftService, refunds *service.RefundService, provider *yookassa.Provider, db *database.DB, bot *bot.Bot, trustedProxies []*net.IPNet) *YooKassaHandler {
+	return &YooKassaHandler{
+		subs:     subs,
+		credits:  credits,
+		gifts:    gifts,
+		refunds:  refunds,
+		provider: provider,
+		db:       db,
+		events:   database.NewWebhookEventRepository(db),
+		bot:      bot,
+
+		trustedProxies: trustedProxies,
+	}
 }
 
 // 6.1 Создать первичный платеж для привязки карты
@@ -39,16 +62,23 @@ func (h *YooKassaHandler) CreateInit(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "user_id and amount required", http.StatusBadRequest)
 		return
 	}
+	uid, err := strconv.ParseInt(userID, 10, 64)
+	amountFloat, aerr := strconv.ParseFloat(amount, 64)
+	if err != nil || aerr != nil {
+		monitoring.RecordError("payment", "yookassa")
+		http.Error(w, "invalid user_id or amount", http.StatusBadRequest)
+		return
+	}
 	idem := time.Now().UTC().Format("20060102T150405.000000000Z") + "-" + userID
-	retURL := os.Getenv("YK_RETURN_URL_BASE")
-	payment, err := h.yc.CreateInitialPayment(
-		idem,
-		yookassa.Amount{Value: amount, Currency: "RUB"},
-		"Подписка AI TG Writer",
-		userID,
-		retURL,
-		map[string]string{"tg_user_id": userID},
-	)
+	payment, err := h.provider.CreatePayment(&domain.PaymentRequest{
+		UserID:         uid,
+		Kind:           domain.PaymentKindInitial,
+		Amount:         amountFloat,
+		Currency:       "RUB",
+		Description:    "Подписка AI TG Writer",
+		IdempotencyKey: idem,
+		ReturnURL:      os.Getenv("YK_RETURN_URL_BASE"),
+	})
 	if err != nil {
 		monitoring.RecordError("payment", "yookassa")
 		http.Error(w, err.Error(), http.StatusBadGateway)
@@ -56,7 +86,6 @@ func (h *YooKassaHandler) CreateInit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Записываем метрики успешного создания платежа
-	amountFloat, _ := strconv.ParseFloat(amount, 64)
 	monitoring.RecordPayment("pending", "yookassa", amountFloat, time.Since(startTime))
 
 	w.Header().Set("Content-Type", "application/json")
@@ -65,131 +94,381 @@ func (h *YooKassaHandler) CreateInit(w http.ResponseWriter, r *http.Request) {
 
 // 6.2 Вебхук
 // URL: POST /yookassa/webhook
+// Уведомление принимается только с адресов YooKassa, сохраняется в журнал событий и обрабатывается
+// ровно один раз: повторные доставки того же события отвечают 200 без повторной активации.
+// Ошибка обработки возвращает 500, чтобы YooKassa повторила доставку.
 func (h *YooKassaHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	if err := h.provider.VerifyWebhook(&domain.WebhookRequest{SourceIP: webhookSourceIP(r, h.trustedProxies)}); err != nil {
+		log.Printf("⛔ YooKassa webhook rejected: %v", err)
+		monitoring.RecordError("webhook_ip", "yookassa")
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "bad body", http.StatusBadRequest)
+		return
+	}
 	var evt struct {
 		Event  string         `json:"event"`
 		Object map[string]any `json:"object"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&evt); err != nil {
+	if err := json.Unmarshal(body, &evt); err != nil {
 		http.Error(w, "bad json", http.StatusBadRequest)
 		return
 	}
 	monitoring.Debug("YooKassa Webhook: Event=%s", evt.Event)
 	monitoring.RecordLogMessage("info", "payment")
 
-	// Получим платеж, чтобы подтвердить статус
 	id, _ := evt.Object["id"].(string)
-	if id == "" {
-		monitoring.Error("Payment ID not found in webhook object")
+	if id == "" || evt.Event == "" {
+		monitoring.Error("Event or object ID not found in webhook")
 		monitoring.RecordLogMessage("error", "payment")
 		w.WriteHeader(http.StatusOK)
 		return
 	}
-	log.Printf("Payment ID from webhook: %s", id)
+	log.Printf("YooKassa webhook: event=%s, object=%s", evt.Event, id)
 
-	payment, err := h.yc.GetPayment(id)
+	event, err := h.events.Receive(&domain.WebhookEvent{
+		Provider:  domain.PaymentProviderYooKassa,
+		EventKey:  evt.Event + ":" + id,
+		EventType: evt.Event,
+		ObjectID:  id,
+		Payload:   body,
+	})
 	if err != nil {
-		log.Printf("❌ YK get payment err: %v", err)
-		w.WriteHeader(http.StatusOK)
+		log.Printf("❌ Failed to store webhook event %s:%s: %v", evt.Event, id, err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	if event.Status.IsFinal() {
+		log.Printf("ℹ️ Duplicate webhook event %s (status %s), skipping", event.EventKey, event.Status)
+		w.Write([]byte("ok"))
+		return
+	}
+	claimed, err := h.events.Claim(event.ID)
+	if err != nil {
+		log.Printf("❌ Failed to claim webhook event %s: %v", event.EventKey, err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		log.Printf("ℹ️ Webhook event %s is already being processed", event.EventKey)
+		w.Write([]byte("ok"))
 		return
 	}
-	log.Printf("=== Payment Details from YooKassa API ===")
-	log.Printf("Payment ID: %s", id)
-	log.Printf("Full payment response: %+v", payment)
 
-	status, _ := payment["status"].(string)
-	log.Printf("Payment Status: %s", status)
+	status, err := h.processEvent(evt.Event, id)
+	lastError := ""
+	if err != nil {
+		status, lastError = domain.WebhookEventFailed, err.Error()
+		log.Printf("❌ Webhook event %s failed: %v", event.EventKey, err)
+	}
+	if ferr := h.events.Finish(event.ID, status, lastError); ferr != nil {
+		log.Printf("❌ Failed to finish webhook event %s: %v", event.EventKey, ferr)
+	}
+	if err != nil {
+		http.Error(w, "processing error", http.StatusInternalServerError)
+		return
+	}
 
-	if status == "succeeded" {
-		log.Printf("✅ Payment succeeded, processing...")
+	log.Printf("=== End Webhook Processing: %s → %s ===", event.EventKey, status)
+	w.Write([]byte("ok"))
+}
 
-		// Метаданные и пользователь
-		meta, _ := payment["metadata"].(map[string]any)
-		tgUser := ""
-		if meta != nil {
-			log.Printf("Metadata: %+v", meta)
-			if v, ok := meta["tg_user_id"].(string); ok {
-				tgUser = v
-				log.Printf("TG User ID from metadata: %s", tgUser)
-			}
-		}
+// processEvent обрабатывает событие по типу. Объект всегда перечитывается из API:
+// данным из тела уведомления не доверяем.
+func (h *YooKassaHandler) processEvent(eventType, objectID string) (domain.WebhookEventStatus, error) {
+	switch eventType {
+	case yookassa.EventPaymentSucceeded:
+		return h.handlePaymentSucceeded(objectID)
+	case yookassa.EventPaymentCanceled:
+		return h.handlePaymentCanceled(objectID)
+	case yookassa.EventRefundSucceeded:
+		return h.handleRefundSucceeded(objectID)
+	case yookassa.EventPaymentMethodActive:
+		// Способ оплаты привязывается к подписке по payment.succeeded первого платежа,
+		// отдельное сохранение карты без платежа бот не использует
+		log.Printf("ℹ️ Payment method %s is active, binding is saved on payment success", objectID)
+		return domain.WebhookEventIgnored, nil
+	default:
+		log.Printf("⚠️ Unsupported YooKassa event %s", eventType)
+		return domain.WebhookEventIgnored, nil
+	}
+}
 
-		// payment_method.id
-		pm := ""
-		if pmObj, ok := payment["payment_method"].(map[string]any); ok {
-			log.Printf("Payment Method: %+v", pmObj)
-			if v, ok := pmObj["id"].(string); ok {
-				pm = v
-				log.Printf("Payment Method ID: %s", pm)
-			}
+// fetchPayment перечитывает платеж из API и пишет его текущее состояние в журнал платежей.
+// Payment в результате nil, если платеж не сопоставлен с пользователем.
+func (h *YooKassaHandler) fetchPayment(id string) (*domain.ProviderPayment, error) {
+	payment, err := h.provider.GetPayment(id)
+	if err != nil {
+		return nil, fmt.Errorf("get payment %s: %w", id, err)
+	}
+	if payment.Payment == nil {
+		log.Printf("⚠️ YooKassa payment %s has no user metadata, not recorded", id)
+		return payment, nil
+	}
+	log.Printf("Payment %s status from API: %s", id, payment.Payment.Status)
+	h.subs.RecordPayment(payment.Payment)
+	return payment, nil
+}
+
+// handlePaymentSucceeded активирует подписку, зачисляет пакет кредитов или отправляет код подарка
+func (h *YooKassaHandler) handlePaymentSucceeded(id string) (domain.WebhookEventStatus, error) {
+	payment, err := h.fetchPayment(id)
+	if err != nil {
+		return "", err
+	}
+	record := payment.Payment
+	if record == nil {
+		log.Printf("❌ Missing required data: tg_user_id in payment %s", id)
+		return domain.WebhookEventIgnored, nil
+	}
+	if record.Status != domain.PaymentStatusSucceeded {
+		log.Printf("⚠️ Payment %s is %s in API, ignoring succeeded notification", id, record.Status)
+		return domain.WebhookEventIgnored, nil
+	}
+
+	// Разовая покупка пакета кредитов: способ оплаты не сохраняется, подписка не активируется
+	if record.Kind == domain.PaymentKindCreditPack {
+		return domain.WebhookEventProcessed, h.completeCreditPurchase(id)
+	}
+	// Подарок оплачивается разово: Premium получит тот, кто активирует код
+	if record.Kind == domain.PaymentKindGift {
+		return domain.WebhookEventProcessed, h.completeGiftPurchase(id)
+	}
+	if payment.PaymentMethodID == "" {
+		log.Printf("❌ Missing required data: payment_method_id in payment %s", id)
+		return domain.WebhookEventIgnored, nil
+	}
+
+	// customerID = telegram user ID (metadata)
+	uid := record.UserID
+	customerID := strconv.FormatInt(uid, 10)
+
+	// Привязка карты для пробного периода: цену тарифа спишет воркер после его окончания
+	if record.Kind == domain.PaymentKindTrial {
+		if err := h.subs.SavePaymentBindingAndStartTrial(uid, h.provider.Name(), customerID, payment.PaymentMethodID, id); err != nil {
+			return "", fmt.Errorf("start trial: %w", err)
 		}
+		log.Printf("🎁 Binding saved and trial started for user %d", uid)
+		h.refundCardBinding(record)
+		h.sendTrialStartedMessage(uid)
+		return domain.WebhookEventProcessed, nil
+	}
 
-		// Определяем customerID = telegram user ID (metadata)
-		customerID := tgUser
-
-		// Сохраняем привязку, если есть tg_user_id и payment_method.id
-		if customerID != "" && pm != "" {
-			log.Printf("✅ Required data found, saving binding (customerID = TG user)...")
-
-			// Извлекаем сумму
-			amountValue := 0.0
-			if amt, ok := payment["amount"].(map[string]any); ok {
-				if val, ok := amt["value"].(string); ok {
-					if f, err := strconv.ParseFloat(val, 64); err == nil {
-						amountValue = f
-					}
-				}
-			}
+	if err := h.subs.SavePaymentBindingAndActivate(uid, h.provider.Name(), customerID, payment.PaymentMethodID, id, record.Amount); err != nil {
+		return "", fmt.Errorf("save binding: %w", err)
+	}
+	log.Printf("✅ Binding saved and subscription activated for user %d", uid)
+
+	// Об успешном автопродлении отдельно не сообщаем: подписка просто продолжает действовать
+	if record.Kind != domain.PaymentKindRecurring {
+		h.sendSubscriptionActivatedMessage(uid)
+	}
+	return domain.WebhookEventProcessed, nil
+}
+
+// refundCardBinding возвращает платеж за привязку карты: сохраненная карта остается для списаний.
+// Ошибка возврата не откладывает пробный период — платеж можно вернуть вручную через /refund.
+func (h *YooKassaHandler) refundCardBinding(record *domain.Payment) {
+	if h.refunds == nil {
+		log.Printf("⚠️ Card binding payment %d of user %d not refunded: refunds are not configured", record.ID, record.UserID)
+		return
+	}
+	if _, err := h.refunds.RefundCardBinding(record); errors.Is(err, domain.ErrRefundNotAllowed) {
+		log.Printf("ℹ️ Card binding payment %d of user %d is already refunded: %v", record.ID, record.UserID, err)
+	} else if err != nil {
+		log.Printf("❌ Failed to refund card binding payment %d of user %d: %v", record.ID, record.UserID, err)
+	}
+}
+
+// handlePaymentCanceled сообщает пользователю, что оплата по ссылке не прошла.
+// Отказы автопродления обрабатывает воркер при списании.
+func (h *YooKassaHandler) handlePaymentCanceled(id string) (domain.WebhookEventStatus, error) {
+	payment, err := h.fetchPayment(id)
+	if err != nil {
+		return "", err
+	}
+	record := payment.Payment
+	if record == nil || record.Status != domain.PaymentStatusFailed || record.Kind == domain.PaymentKindRecurring {
+		return domain.WebhookEventIgnored, nil
+	}
+
+	// Пользователь закрыл страницу оплаты — напоминать об этом не нужно
+	if record.FailureReason == "expired_on_confirmation" {
+		return domain.WebhookEventProcessed, nil
+	}
+	msg := tgbotapi.NewMessage(record.UserID, "❌ Оплата не прошла. Попробуйте еще раз или выберите другой способ оплаты.")
+	if _, err := h.bot.Send(msg); err != nil {
+		log.Printf("❌ Error sending payment canceled message to user %d: %v", record.UserID, err)
+	}
+	return domain.WebhookEventProcessed, nil
+}
+
+// handleRefundSucceeded отмечает возврат в журнале платежей и применяет его последствия:
+// возвраты из личного кабинета YooKassa понижают подписку так же, как возвраты из админки
+func (h *YooKassaHandler) handleRefundSucceeded(id string) (domain.WebhookEventStatus, error) {
+	refund, err := h.provider.GetRefund(id)
+	if err != nil {
+		return "", fmt.Errorf("get refund %s: %w", id, err)
+	}
+	if refund.Status != domain.RefundStatusSucceeded {
+		log.Printf("⚠️ Refund %s is %s in API, ignoring", id, refund.Status)
+		return domain.WebhookEventIgnored, nil
+	}
+	paymentID := refund.ProviderPaymentID
+	if paymentID == "" {
+		return domain.WebhookEventIgnored, nil
+	}
+	if _, err := h.fetchPayment(paymentID); err != nil {
+		return "", err
+	}
+	if h.refunds == nil {
+		log.Printf("↩️ Refund %s for payment %s recorded, refund effects are not configured", id, paymentID)
+		return domain.WebhookEventProcessed, nil
+	}
+	if _, err := h.refunds.ApplyProviderRefund(h.provider.Name(), refund); err != nil {
+		return "", fmt.Errorf("apply refund %s: %w", id, err)
+	}
+	log.Printf("↩️ Refund %s for payment %s recorded", id, paymentID)
+	return domain.WebhookEventProcessed, nil
+}
 
-			if uid, err := strconv.ParseInt(customerID, 10, 64); err == nil {
-				if err := h.subs.SaveYooKassaBindingAndActivate(uid, customerID, pm, id, amountValue); err != nil {
-					log.Printf("❌ Save binding error: %v", err)
-				} else {
-					log.Printf("✅ Binding saved and subscription activated successfully")
-
-					// Отправляем уведомление пользователю в Telegram
-					h.sendSubscriptionActivatedMessage(uid)
-				}
-			} else {
-				log.Printf("❌ Failed to parse TG User ID: %v", err)
+// webhookSourceIP возвращает адрес отправителя уведомления. Заголовки X-Forwarded-For и X-Real-IP
+// читаются, только если соединение пришло от доверенного прокси: за Docker или балансировщиком
+// любой внешний запрос приходит с частного адреса, и заголовки от него подделываются.
+// Из X-Forwarded-For берется самый правый адрес, который не принадлежит доверенным прокси:
+// левее него адреса дописал сам отправитель.
+func webhookSourceIP(r *http.Request, trustedProxies []*net.IPNet) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer := net.ParseIP(host)
+	if peer == nil || !isTrustedProxy(peer, trustedProxies) {
+		return peer
+	}
+	if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+		hops := strings.Split(strings.Join(fwd, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := net.ParseIP(strings.TrimSpace(hops[i]))
+			if hop == nil {
+				return nil
+			}
+			if !isTrustedProxy(hop, trustedProxies) {
+				return hop
 			}
-		} else {
-			log.Printf("❌ Missing required data: tg_user_id=%s, payment_method_id=%s", customerID, pm)
 		}
-	} else {
-		log.Printf("⚠️ Payment status is not 'succeeded': %s", status)
 	}
+	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		return net.ParseIP(strings.TrimSpace(realIP))
+	}
+	return peer
+}
 
-	log.Printf("=== End Webhook Processing ===")
-	w.Write([]byte("ok"))
+// isTrustedProxy проверяет, входит ли адрес в сети доверенных прокси
+func isTrustedProxy(ip net.IP, trustedProxies []*net.IPNet) bool {
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
 
-// 6.3 Принудительное списание (тест рекуррента)
-// POST /yookassa/charge?user_id=123&amount=990.00
+// 6.3 Принудительное списание продления по сохраненному способу оплаты подписки.
+// Сумма берется из подписки, попытка записывается в журнал платежей, как у воркера продлений.
+// POST /admin/api/v1/charge?user_id=123 (только роль admin)
 func (h *YooKassaHandler) Charge(w http.ResponseWriter, r *http.Request) {
-	userID := r.URL.Query().Get("user_id")
-	amount := r.URL.Query().Get("amount")
-	if userID == "" || amount == "" {
-		http.Error(w, "user_id and amount required", http.StatusBadRequest)
+	userID, err := strconv.ParseInt(r.URL.Query().Get("user_id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid user_id")
 		return
 	}
-	// достать из БД yk_customer_id, yk_payment_method_id
-	// ...
-	idem := time.Now().UTC().Format("20060102T150405.000000000Z") + "-rec-" + userID
-	payment, err := h.yc.CreateRecurringPayment(
-		idem,
-		yookassa.Amount{Value: amount, Currency: "RUB"},
-		"Продление подписки AI TG Writer",
-		/*customerID*/ userID,
-		/*paymentMethodID*/ "pm_xxx",
-		map[string]string{"tg_user_id": userID},
-	)
+	subscription, err := h.subs.GetUserSubscription(userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
+		log.Printf("❌ Admin API: ошибка получения подписки пользователя %d: %v", userID, err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to get subscription")
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(payment)
+	if subscription == nil {
+		writeJSONError(w, http.StatusNotFound, "subscription not found")
+		return
+	}
+	if subscription.YKCustomerID == nil || subscription.YKPaymentMethodID == nil {
+		writeJSONError(w, http.StatusConflict, "subscription has no saved payment method")
+		return
+	}
+
+	if err := h.subs.ProcessRecurringPayment(subscription); err != nil {
+		if errors.Is(err, domain.ErrRenewalInProgress) {
+			writeJSONError(w, http.StatusConflict, "renewal is already in progress")
+			return
+		}
+		log.Printf("❌ Admin API: ошибка списания продления пользователя %d: %v", userID, err)
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, subscription)
+}
+
+// completeCreditPurchase зачисляет кредиты по оплаченному пакету и уведомляет пользователя
+func (h *YooKassaHandler) completeCreditPurchase(paymentID string) error {
+	return completeCreditPurchase(h.credits, h.bot, h.provider.Name(), paymentID)
+}
+
+// completeCreditPurchase зачисляет кредиты по пакету, оплаченному у провайдера, и уведомляет пользователя
+func completeCreditPurchase(credits *service.CreditService, bot *bot.Bot, provider, paymentID string) error {
+	if credits == nil {
+		return fmt.Errorf("credit payment %s received, but credit packs are not configured", paymentID)
+	}
+	purchase, err := credits.CompletePurchase(provider, paymentID)
+	if err != nil {
+		return fmt.Errorf("complete credit purchase: %w", err)
+	}
+	if purchase == nil {
+		return nil
+	}
+
+	text := fmt.Sprintf("🎟 Оплата прошла! Зачислено кредитов: %d.\n\n"+
+		"Один кредит — одно создание, рерайт или правка поста. Кредиты расходуются раньше бесплатного лимита", purchase.Credits)
+	if purchase.ExpiresAt != nil {
+		text += fmt.Sprintf(" и действуют до %s", purchase.ExpiresAt.Format("02.01.2006"))
+	}
+	text += "."
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📝 Создать пост", "create_post"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👤 Мой профиль", "profile"),
+		),
+	)
+	msg := tgbotapi.NewMessage(purchase.UserID, text)
+	msg.ReplyMarkup = &keyboard
+	if _, err := bot.Send(msg); err != nil {
+		log.Printf("❌ Error sending credits purchased message to user %d: %v", purchase.UserID, err)
+	}
+	return nil
+}
+
+// completeGiftPurchase отмечает подарок оплаченным и отправляет покупателю код и ссылку для получателя
+func (h *YooKassaHandler) completeGiftPurchase(paymentID string) error {
+	if h.gifts == nil {
+		return fmt.Errorf("gift payment %s received, but gifts are not configured", paymentID)
+	}
+	gift, err := h.gifts.CompleteGiftPurchase(h.provider.Name(), paymentID)
+	if err != nil {
+		return fmt.Errorf("complete gift purchase: %w", err)
+	}
+	if gift == nil {
+		return nil
+	}
+	h.bot.SendGiftPurchasedMessage(gift)
+	return nil
 }
 
 // sendSubscriptionActivatedMessage отправляет уведомление об активации подписки
@@ -240,8 +519,41 @@ func (h *YooKassaHandler) sendSubscriptionActivatedMessage(userID int64) {
 	}
 }
 
+// sendTrialStartedMessage сообщает о начале пробного периода и дате первого списания
+func (h *YooKassaHandler) sendTrialStartedMessage(userID int64) {
+	entitlement := h.bot.Entitlement(userID)
+	if entitlement.Source != domain.EntitlementTrial || entitlement.Until == nil {
+		h.sendSubscriptionActivatedMessage(userID)
+		return
+	}
+
+	text := fmt.Sprintf("🎁 *Пробный период активирован!*\n\n"+
+		"До %s (UTC) тебе доступны все возможности Premium.\n"+
+		"Затем с привязанной карты спишется %.2f ₽ и подписка продлится автоматически. "+
+		"За день до списания мы напомним, а отменить можно в любой момент в разделе «Моя подписка».",
+		entitlement.Until.Format("02.01.2006 15:04"), entitlement.Subscription.Amount)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📝 Создать пост", "create_post"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💎 Моя подписка", "subscription"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(userID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = &keyboard
+
+	if _, err := h.bot.Send(msg); err != nil {
+		log.Printf("❌ Error sending trial started message to user %d: %v", userID, err)
+	} else {
+		log.Printf("✅ Trial started message sent to user %d", userID)
+	}
+}
+
 func (h *YooKassaHandler) SetupRoutes(r *mux.Router) {
 	r.HandleFunc("/yookassa/init", h.CreateInit).Methods("POST")
 	r.HandleFunc("/yookassa/webhook", h.Webhook).Methods("POST")
-	r.HandleFunc("/yookassa/charge", h.Charge).Methods("POST")
 }
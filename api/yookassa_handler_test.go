@@ -0,0 +1,132 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/infrastructure/yookassa"
+)
+
+// stubWebhookEvents журнал событий для тестов: хранит события по ключу
+type stubWebhookEvents struct {
+	events map[string]*domain.WebhookEvent
+	claims int
+}
+
+func (s *stubWebhookEvents) Receive(event *domain.WebhookEvent) (*domain.WebhookEvent, error) {
+	if stored, ok := s.events[event.EventKey]; ok {
+		return stored, nil
+	}
+	event.ID = int64(len(s.events) + 1)
+	event.Status = domain.WebhookEventReceived
+	s.events[event.EventKey] = event
+	return event, nil
+}
+
+func (s *stubWebhookEvents) Claim(id int64) (bool, error) {
+	s.claims++
+	return false, nil
+}
+
+func (s *stubWebhookEvents) Finish(id int64, status domain.WebhookEventStatus, lastError string) error {
+	return nil
+}
+
+func TestYooKassaWebhookSourceAndDeduplication(t *testing.T) {
+	events := &stubWebhookEvents{events: map[string]*domain.WebhookEvent{
+		"payment.succeeded:pay-1": {ID: 1, EventKey: "payment.succeeded:pay-1", Status: domain.WebhookEventProcessed},
+	}}
+	handler := &YooKassaHandler{events: events, provider: yookassa.NewProvider(yookassa.New())}
+	body := `{"type":"notification","event":"payment.succeeded","object":{"id":"pay-1"}}`
+
+	// Адрес вне диапазонов YooKassa отклоняется
+	req := httptest.NewRequest(http.MethodPost, "/yookassa/webhook", strings.NewReader(body))
+	req.RemoteAddr = "203.0.113.5:443"
+	rec := httptest.NewRecorder()
+	handler.Webhook(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Ожидался 403 для чужого адреса, получено %d", rec.Code)
+	}
+
+	// Повторная доставка обработанного события подтверждается без повторной обработки
+	req = httptest.NewRequest(http.MethodPost, "/yookassa/webhook", strings.NewReader(body))
+	req.RemoteAddr = "185.71.76.10:443"
+	rec = httptest.NewRecorder()
+	handler.Webhook(rec, req)
+	if rec.Code != http.StatusOK || events.claims != 0 {
+		t.Errorf("Ожидался 200 без повторной обработки, получено %d, claims=%d", rec.Code, events.claims)
+	}
+
+	// За доверенным nginx адрес отправителя берется из X-Real-IP
+	handler.trustedProxies = mustParseCIDRs(t, "127.0.0.1/32")
+	req = httptest.NewRequest(http.MethodPost, "/yookassa/webhook", strings.NewReader(body))
+	req.RemoteAddr = "127.0.0.1:51000"
+	req.Header.Set("X-Real-IP", "203.0.113.5")
+	rec = httptest.NewRecorder()
+	handler.Webhook(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Ожидался 403 для чужого адреса за прокси, получено %d", rec.Code)
+	}
+}
+
+func TestWebhookSourceIPTrustsOnlyConfiguredProxies(t *testing.T) {
+	trusted := mustParseCIDRs(t, "10.0.0.0/8")
+	cases := []struct {
+		name    string
+		peer    string
+		headers map[string]string
+		want    string
+	}{
+		{"частный адрес вне доверенных прокси подменяет X-Real-IP", "172.17.0.1:40000",
+			map[string]string{"X-Real-IP": "185.71.76.1"}, "172.17.0.1"},
+		{"частный адрес вне доверенных прокси подменяет X-Forwarded-For", "192.168.1.7:40000",
+			map[string]string{"X-Forwarded-For": "185.71.76.1"}, "192.168.1.7"},
+		{"доверенный прокси: самый правый недоверенный адрес", "10.0.0.2:40000",
+			map[string]string{"X-Forwarded-For": "185.71.76.1, 203.0.113.5, 10.0.0.3"}, "203.0.113.5"},
+		{"доверенный прокси: X-Real-IP", "10.0.0.2:40000",
+			map[string]string{"X-Real-IP": "185.71.76.1"}, "185.71.76.1"},
+		{"внешний адрес без прокси", "203.0.113.5:443",
+			map[string]string{"X-Real-IP": "185.71.76.1"}, "203.0.113.5"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/yookassa/webhook", nil)
+		req.RemoteAddr = tc.peer
+		for key, value := range tc.headers {
+			req.Header.Set(key, value)
+		}
+		if got := webhookSourceIP(req, trusted); got.String() != tc.want {
+			t.Errorf("%s: ожидался %s, получено %s", tc.name, tc.want, got)
+		}
+	}
+
+	// Без настроенных прокси заголовки не читаются даже от частного адреса: запрос YooKassa
+	// с подмененным адресом отклоняется
+	events := &stubWebhookEvents{events: map[string]*domain.WebhookEvent{}}
+	handler := &YooKassaHandler{events: events, provider: yookassa.NewProvider(yookassa.New())}
+	req := httptest.NewRequest(http.MethodPost, "/yookassa/webhook",
+		strings.NewReader(`{"type":"notification","event":"payment.succeeded","object":{"id":"pay-1"}}`))
+	req.RemoteAddr = "172.17.0.1:40000"
+	req.Header.Set("X-Real-IP", "185.71.76.1")
+	rec := httptest.NewRecorder()
+	handler.Webhook(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Ожидался 403 для подмененного X-Real-IP, получено %d", rec.Code)
+	}
+}
+
+func mustParseCIDRs(t *testing.T, cidrs ...string) []*net.IPNet {
+	t.Helper()
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -11,11 +11,14 @@ import (
 	"sync/atomic"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Часовые пояса пользователей доступны и в образах без системной базы tzdata
 
 	"ai_tg_writer/api"
 	"ai_tg_writer/internal/config"
+	"ai_tg_writer/internal/domain"
 	"ai_tg_writer/internal/infrastructure/bot"
 	"ai_tg_writer/internal/infrastructure/database"
+	"ai_tg_writer/internal/infrastructure/prodamus_payments"
 	"ai_tg_writer/internal/infrastructure/voice"
 	"ai_tg_writer/internal/infrastructure/yookassa"
 	"ai_tg_writer/internal/monitoring"
@@ -92,41 +95,107 @@ func main() {
 
 	// Создаем репозиторий подписок
 	subscriptionRepo := database.NewSubscriptionRepository(db)
+	tariffRepo := database.NewTariffRepository(db)
+	paymentRepo := database.NewPaymentRepository(db)
 
 	// Создаем сервис подписок (временно без платежного модуля)
 	// Загружаем конфигурацию
 	cfg := config.NewConfig()
-	log.Printf("📋 Configuration loaded: Mode=%s, SubscriptionInterval=%s, WorkerCheckInterval=%s",
-		cfg.Mode, cfg.SubscriptionInterval, cfg.WorkerCheckInterval)
+	log.Printf("📋 Configuration loaded: Mode=%s, SubscriptionInterval=%s, WorkerCheckInterval=%s, UpdatesMode=%s",
+		cfg.Mode, cfg.SubscriptionInterval, cfg.WorkerCheckInterval, cfg.UpdatesMode)
+
+	// Платежные провайдеры: YooKassa подключена всегда, Prodamus — если задан PRODAMUS_SECRET_KEY.
+	// Провайдер по умолчанию задает PAYMENT_PROVIDER, тариф и администратор могут его переопределить.
+	ykProvider := yookassa.NewProvider(yookassa.New())
+	providerList := []domain.PaymentProvider{ykProvider}
+	if secret := os.Getenv("PRODAMUS_SECRET_KEY"); secret != "" {
+		payformURL := os.Getenv("PRODAMUS_PAYFORM_URL")
+		if payformURL == "" {
+			payformURL = "https://shakirovai.payform.ru"
+		}
+		providerList = append(providerList, prodamus_payments.NewProvider(prodamus_payments.NewProdamusHandler("", payformURL, secret)))
+	}
+	defaultProvider := os.Getenv("PAYMENT_PROVIDER")
+	if defaultProvider == "" {
+		defaultProvider = domain.PaymentProviderYooKassa
+	}
+	paymentProviders := service.NewPaymentProviders(defaultProvider, db, providerList...)
+	log.Printf("💳 Payment providers: %v, default: %s", paymentProviders.Names(), defaultProvider)
 
-	// Инициализируем клиента YooKassa
-	ykClient := yookassa.New()
+	// Журнал напоминаний о продлении: каждое напоминание уходит один раз на дату списания
+	noticeRepo := database.NewSubscriptionNoticeRepository(db)
+
+	// Промокоды: скидка на первую оплату и заданное число продлений
+	promoRepo := database.NewPromoRepository(db)
 
 	// Создаем временный сервис подписок для создания SubscriptionHandler
-	tempSubscriptionService := service.NewSubscriptionService(subscriptionRepo, ykClient, cfg)
+	tempSubscriptionService := service.NewSubscriptionService(subscriptionRepo, tariffRepo, paymentRepo, db, noticeRepo, promoRepo, paymentProviders, cfg)
 
 	// Создаем SubscriptionHandler для отправки сообщений
 	subscriptionHandler := bot.NewSubscriptionHandler(tempSubscriptionService)
 
 	// Создаем сервис подписок с ботом для отправки сообщений
-	subscriptionService := service.NewSubscriptionServiceWithBot(subscriptionRepo, ykClient, cfg, subscriptionHandler)
+	subscriptionService := service.NewSubscriptionServiceWithBot(subscriptionRepo, tariffRepo, paymentRepo, db, noticeRepo, promoRepo, paymentProviders, cfg, subscriptionHandler)
 
 	fmt.Println("Сервис подписок инициализирован")
 
+	// Пакеты кредитов: разовая покупка, кредиты расходуются раньше бесплатного лимита
+	creditRepo := database.NewCreditRepository(db)
+	creditService := service.NewCreditService(creditRepo, paymentRepo, db, paymentProviders)
+
+	// Подарочный Premium: разовая оплата, код активируется получателем по ссылке /start gift_<код>
+	giftRepo := database.NewGiftRepository(db)
+	giftService := service.NewGiftService(giftRepo, tariffRepo, paymentRepo, db, paymentProviders, cfg)
+
+	// Реферальная программа: награда приглашающему за первую оплату приглашенного
+	referralService := service.NewReferralService(database.NewReferralRepository(db), subscriptionHandler, cfg)
+
+	// Источники привлечения: первое касание по параметру /start и воронка до первой оплаты
+	acquisitionService := service.NewAcquisitionService(database.NewAcquisitionRepository(db))
+
+	// Возвраты через провайдера платежа: понижают подписку или списывают кредиты и уведомляют пользователя
+	refundService := service.NewRefundService(database.NewRefundRepository(db), paymentRepo, subscriptionRepo, creditRepo, giftRepo, db, paymentProviders, subscriptionHandler)
+
+	// Права доступа: единые правила оплаченного периода и grace period для бота, квот и воркера
+	entitlementService := service.NewEntitlementService(subscriptionService, db, cfg)
+
+	// Создаем сервис квот: лимиты ресурсов по тарифу пользователя
+	quotaService := service.NewQuotaService(database.NewQuotaRepository(db), subscriptionService, entitlementService, creditRepo)
+
 	// Создаем обработчики
 	customBot := bot.NewBotWithSubscriptionService(botAPI, db, subscriptionService)
+	customBot.QuotaService = quotaService
+	customBot.CreditService = creditService
+	customBot.GiftService = giftService
+	customBot.Referrals = referralService
+	customBot.Acquisitions = acquisitionService
+	customBot.RefundService = refundService
+	customBot.PaymentProviders = paymentProviders
+	customBot.Entitlements = entitlementService
 
 	// Устанавливаем бота в SubscriptionHandler для отправки сообщений
 	subscriptionHandler.SetBot(customBot)
 
 	// Создаем HTTP-сервер для обработки платежей
 	httpServer := api.NewServer("8080")
-	httpServer.SetupRoutes(subscriptionService, nil, db, customBot)
+	httpServer.SetTrustedProxies(cfg.TrustedProxies)
+	httpServer.SetupRoutes(subscriptionService, quotaService, creditService, giftService, acquisitionService, refundService, paymentProviders, ykProvider, db, customBot)
 
 	// Добавляем health check
 	healthChecker := monitoring.NewHealthChecker(db.DB)
 	httpServer.AddHealthCheck(healthChecker)
 
+	// Приемник webhook должен быть зарегистрирован до запуска HTTP-сервера
+	var webhookUpdates tgbotapi.UpdatesChannel
+	if cfg.IsWebhookMode() {
+		// Без секрета любой, кто знает путь, может прислать обновление от имени администратора
+		if cfg.WebhookSecret == "" {
+			logger.Fatal("TELEGRAM_WEBHOOK_SECRET обязателен в режиме webhook")
+		}
+		webhookUpdates = httpServer.AddTelegramWebhook(cfg.WebhookPath, cfg.WebhookSecret)
+		logger.WithField("path", cfg.WebhookPath).Info("Приемник Telegram webhook зарегистрирован")
+	}
+
 	// Запускаем HTTP-сервер в горутине
 	go func() {
 		if err := httpServer.Start(); err != nil {
@@ -140,7 +209,7 @@ func main() {
 	defer cancel()
 
 	// Запускаем воркер для рекуррентных платежей
-	subscriptionWorker := worker.NewSubscriptionWorker(subscriptionService, cfg)
+	subscriptionWorker := worker.NewSubscriptionWorker(subscriptionService, entitlementService, referralService, acquisitionService, cfg)
 	subscriptionWorker.Start(ctx)
 
 	// Настраиваем graceful shutdown
@@ -151,15 +220,35 @@ func main() {
 
 	voiceHandler := voice.NewVoiceHandler(botAPI, postHistoryRepo)
 	stateManager := bot.NewStateManager(db)
-	inlineHandler := bot.NewInlineHandler(stateManager, voiceHandler, subscriptionService, postHistoryRepo)
+	inlineHandler := bot.NewInlineHandler(stateManager, voiceHandler, subscriptionService, quotaService, entitlementService, postHistoryRepo)
 	messageHandler := bot.NewMessageHandler(stateManager, voiceHandler, inlineHandler)
+	broadcastRepo := database.NewBroadcastRepository(db)
+	adminHandler := bot.NewAdminHandler(postHistoryRepo, broadcastRepo)
+
+	// Запускаем воркер рассылок
+	broadcastWorker := worker.NewBroadcastWorker(broadcastRepo, customBot)
+	broadcastWorker.Start(ctx)
 	fmt.Println("Обработчики созданы")
-	// Настраиваем обновления
-	updateConfig := tgbotapi.NewUpdate(0)
-	updateConfig.Timeout = 60
-	fmt.Println("Настройки обновлений установлены")
-	updates := botAPI.GetUpdatesChan(updateConfig)
-	fmt.Println("Обновления получены")
+	// Настраиваем источник обновлений: webhook или long polling
+	var updates tgbotapi.UpdatesChannel
+	if cfg.IsWebhookMode() {
+		if err := customBot.SetWebhook(cfg.WebhookURL, cfg.WebhookSecret, cfg.WebhookCertPath, cfg.WebhookMaxConnections); err != nil {
+			logger.WithError(err).Fatal("Ошибка регистрации webhook")
+		}
+		updates = webhookUpdates
+		fmt.Println("Обновления получаем через webhook")
+	} else {
+		// Telegram не отдает getUpdates, пока установлен webhook
+		if err := customBot.DeleteWebhook(); err != nil {
+			logger.WithError(err).Warn("Не удалось удалить webhook")
+		}
+		updateConfig := tgbotapi.NewUpdate(0)
+		updateConfig.Timeout = 60
+		updateConfig.AllowedUpdates = bot.AllowedUpdates
+		fmt.Println("Настройки обновлений установлены")
+		updates = botAPI.GetUpdatesChan(updateConfig)
+		fmt.Println("Обновления получаем через long polling")
+	}
 
 	// Создаем семафор для ограничения одновременных обработок
 	const maxConcurrentHandlers = 10
@@ -230,6 +319,18 @@ func main() {
 					handlerID, update.CallbackQuery.From.ID, update.CallbackQuery.From.UserName)
 			}
 
+			// Пользователь заблокировал или разблокировал бота
+			if update.MyChatMember != nil {
+				customBot.HandleMyChatMember(update.MyChatMember)
+				return
+			}
+			customBot.HandleUserActivity(&update)
+
+			// Заблокированным администратором пользователям бот не отвечает
+			if isBannedUpdate(customBot, update) {
+				return
+			}
+
 			// Обрабатываем callback от инлайн-кнопок
 			if update.CallbackQuery != nil {
 				inlineHandler.HandleCallback(customBot, update.CallbackQuery)
@@ -257,6 +358,10 @@ func main() {
 				if state.WaitingForEmail {
 					state.WaitingForEmail = false
 				}
+				state.WaitingForPromo = ""
+				if adminHandler.HandleCommand(customBot, update.Message) {
+					return
+				}
 				handleMessage(customBot, update.Message, voiceHandler, stateManager, inlineHandler)
 				return
 			}
@@ -303,6 +408,18 @@ func handleMessage(bot *bot.Bot, message *tgbotapi.Message, voiceHandler *voice.
 func handleCommand(bot *bot.Bot, message *tgbotapi.Message) {
 	switch message.Command() {
 	case "start":
+		payload := bot.ParseStartPayload(message.CommandArguments())
+		// Источник сохраняется только при первом открытии бота
+		bot.RecordAcquisition(message.From, payload.Acquisition)
+		switch payload.Source {
+		case domain.AcquisitionGift:
+			// Ссылка на подарок: /start gift_<код>
+			bot.RedeemGift(message.Chat.ID, message.From, payload.Code)
+			return
+		case domain.AcquisitionReferral:
+			// Реферальная ссылка: /start <реферальный код>
+			bot.ApplyReferral(message.From, payload.Code)
+		}
 		sendWelcomeMessage(bot, message.Chat.ID)
 	case "help":
 		sendHelpMessage(bot, message.Chat.ID)
@@ -310,8 +427,12 @@ func handleCommand(bot *bot.Bot, message *tgbotapi.Message) {
 		sendProfileMessage(bot, message.Chat.ID, message.From.ID)
 	case "subscription":
 		sendSubscriptionMessage(bot, message.Chat.ID)
-	case "admin":
-		handleAdminCommand(bot, message)
+	case "news_off":
+		bot.SetMarketingOptOut(message.Chat.ID, message.From.ID, true)
+	case "news_on":
+		bot.SetMarketingOptOut(message.Chat.ID, message.From.ID, false)
+	case "timezone":
+		bot.SetTimezone(message.Chat.ID, message.From.ID, message.CommandArguments())
 	default:
 		sendUnknownCommandMessage(bot, message.Chat.ID)
 	}
@@ -412,13 +533,18 @@ func sendHelpMessage(bot *bot.Bot, chatID int64) {
 • Поддерживаются все основные языки
 
 📊 Лимиты использования:
-• Бесплатный тариф: 5 сообщений в день
-• Премиум тариф: неограниченно
+• Лимиты зависят от тарифа и обновляются раз в период
+• Период отсчитывается от даты подписки или регистрации
+• /timezone - часовой пояс, по которому обновляются лимиты
 
 👤 Профиль (/profile):
 • Просмотр текущего тарифа
 • Остаток использований
-• Статистика использования`
+• Дата обновления лимитов
+
+🔔 Новости и акции:
+• /news_off - отписаться от рассылок
+• /news_on - подписаться снова`
 
 	msg := tgbotapi.NewMessage(chatID, text)
 	bot.Send(msg)
@@ -427,11 +553,15 @@ func sendHelpMessage(bot *bot.Bot, chatID int64) {
 func sendProfileMessage(bot *bot.Bot, chatID int64, userID int64) {
 	text := `👤 Ваш профиль
 
-🆔 ID пользователя: ` + strconv.FormatInt(userID, 10) + `
-📊 Тариф: Бесплатный
-📈 Использовано сегодня: 0/5`
+🆔 ID пользователя: ` + strconv.FormatInt(userID, 10)
+	if quotaText := bot.QuotaProfileText(userID); quotaText != "" {
+		text += "\n\n" + quotaText
+	}
 
 	msg := tgbotapi.NewMessage(chatID, text)
+	if keyboard := bot.ReferralKeyboard(); keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
 	bot.Send(msg)
 }
 
@@ -439,20 +569,25 @@ func sendSubscriptionMessage(bot *bot.Bot, chatID int64) {
 	// Получаем информацию о подписке пользователя
 	userID := chatID // В Telegram chatID обычно равен userID для личных чатов
 
-	subscription, err := bot.SubscriptionService.GetUserSubscription(userID)
-	if err != nil {
-		log.Printf("Ошибка получения подписки для пользователя %d: %v", userID, err)
-		subscription = nil
-	}
+	entitlement := bot.Entitlement(userID)
+	subscription := entitlement.Subscription
 
 	var text string
 	var keyboard tgbotapi.InlineKeyboardMarkup
 
-	if subscription != nil && subscription.Active {
+	if entitlement.Status == domain.SubscriptionStatusPaused {
+		// Подписка на паузе: предлагаем возобновить, а не купить новую
+		text, keyboard = bot.PausedSubscriptionView(subscription)
+	} else if entitlement.HasSubscriptionAccess() {
 		// У пользователя есть активная подписка
 		statusText := "Активна"
-		if subscription.Status == "cancelled" {
+		switch {
+		case entitlement.Status == domain.SubscriptionStatusCancelled:
 			statusText = "Отменена (работает до конца периода)"
+		case entitlement.Source == domain.EntitlementGrace:
+			statusText = "Продление не оплачено, доступ до " + entitlement.Until.Format("02.01.2006 15:04")
+		case entitlement.Source == domain.EntitlementTrial:
+			statusText = "Пробный период, затем автоматическое продление"
 		}
 
 		nextPaymentText := "Не указана"
@@ -484,7 +619,7 @@ func sendSubscriptionMessage(bot *bot.Bot, chatID int64) {
 		)
 	} else {
 		// У пользователя нет активной подписки
-		text = `💎 *Подписка*
+		text = fmt.Sprintf(`💎 *Подписка*
 
 📊 Текущий тариф: Бесплатный
 ⏰ Срок действия: Бессрочно
@@ -495,16 +630,16 @@ func sendSubscriptionMessage(bot *bot.Bot, chatID int64) {
 • Расширенные возможности редактирования
 • Доступ к эксклюзивным функциям
 
-💳 Стоимость: 990₽/месяц`
+💳 Стоимость: %s`, bot.StartingPriceText())
 
-		keyboard = tgbotapi.NewInlineKeyboardMarkup(
+		keyboard = bot.WithTrialRow(userID, tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("💰 Купить подписку", "buy_premium"),
 			),
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("🔙 Назад в меню", "main_menu"),
 			),
-		)
+		))
 	}
 
 	msg := tgbotapi.NewMessage(chatID, text)
@@ -520,22 +655,32 @@ func sendUnknownCommandMessage(bot *bot.Bot, chatID int64) {
 	bot.Send(msg)
 }
 
-func handleAdminCommand(bot *bot.Bot, message *tgbotapi.Message) {
-	// Проверяем права администратора
-	isAdmin, err := bot.DB.IsAdmin(message.From.ID)
-	if err != nil {
-		log.Printf("Ошибка проверки прав администратора: %v", err)
-		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Произошла ошибка при проверке прав доступа")
-		bot.Send(msg)
-		return
+// isBannedUpdate проверяет, заблокирован ли автор обновления администратором
+func isBannedUpdate(bot *bot.Bot, update tgbotapi.Update) bool {
+	var userID, chatID int64
+	switch {
+	case update.Message != nil && update.Message.From != nil:
+		userID, chatID = update.Message.From.ID, update.Message.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.From != nil:
+		userID = update.CallbackQuery.From.ID
+	default:
+		return false
 	}
 
-	if !isAdmin {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "⛔ У вас нет прав администратора")
-		bot.Send(msg)
-		return
+	banned, err := bot.DB.IsUserBanned(userID)
+	if err != nil {
+		log.Printf("Ошибка проверки блокировки пользователя %d: %v", userID, err)
+		return false
+	}
+	if !banned {
+		return false
 	}
 
-	msg := tgbotapi.NewMessage(message.Chat.ID, "🛠 Админ-панель\n\nДоступные команды:\n/reset_limits [user_id] - Сбросить лимиты пользователя\n/add_admin [user_id] - Добавить администратора")
-	bot.Send(msg)
+	log.Printf("⛔ Обновление от заблокированного пользователя %d проигнорировано", userID)
+	if update.CallbackQuery != nil {
+		bot.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, "⛔ Доступ к боту ограничен"))
+	} else {
+		bot.Send(tgbotapi.NewMessage(chatID, "⛔ Доступ к боту ограничен администратором"))
+	}
+	return true
 }
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"log"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -10,7 +13,40 @@ type Config struct {
 	Mode                 string
 	SubscriptionInterval time.Duration
 	WorkerCheckInterval  time.Duration
-	GracePeriodDays      int // Количество дней grace period для подписок
+	GracePeriodDays      int // Сколько дней после неудачного продления сохраняется доступ подписчика
+
+	// Настройки продлений
+	DunningRetryHours   []int // Интервалы повторных списаний по умолчанию, в часах (в режиме разработки — в минутах)
+	RenewalReminderDays []int // За сколько дней до списания напоминать о продлении (в режиме разработки — в минутах)
+
+	// Настройки паузы подписки
+	PauseOptionDays  []int // Варианты длительности паузы в днях (в режиме разработки — в минутах)
+	MaxPausesPerYear int   // Сколько раз за 12 месяцев можно поставить подписку на паузу
+
+	// Настройки пробного периода
+	TrialDays          int     // Длительность пробного периода в днях (в режиме разработки — в минутах); 0 — пробный период выключен
+	TrialTariffID      string  // Тариф, который действует в пробный период и оплачивается после него
+	TrialBindingAmount float64 // Сумма привязки карты; YooKassa не принимает нулевые платежи, поэтому не меньше 1 ₽
+
+	// Настройки подарочных подписок
+	GiftMonthOptions []int  // Варианты подарка в месяцах Premium; пусто — подарки не продаются
+	GiftTariffID     string // Месячный тариф, по цене которого продается подарок
+
+	// Настройки реферальной программы
+	ReferralReward       string // Награда приглашающему за первую оплату приглашенного: "generations" или "premium_days"; пусто или none — без награды
+	ReferralRewardAmount int    // Сколько созданий или дней Premium начисляется за приглашение
+
+	// Настройки получения обновлений Telegram
+	UpdatesMode           string // Режим получения обновлений: "polling" или "webhook"
+	WebhookURL            string // Публичный URL, который регистрируется в setWebhook
+	WebhookPath           string // Путь HTTP-сервера, на который Telegram присылает обновления
+	WebhookSecret         string // Секрет для заголовка X-Telegram-Bot-Api-Secret-Token
+	WebhookCertPath       string // Путь к самоподписанному сертификату (необязательно)
+	WebhookMaxConnections int    // Максимум одновременных соединений от Telegram
+
+	// TrustedProxies адреса обратных прокси (CIDR или IP), от которых принимаются X-Forwarded-For и X-Real-IP;
+	// пусто — заголовкам не доверяем и адресом отправителя считаем адрес соединения
+	TrustedProxies []*net.IPNet
 }
 
 // NewConfig создает новую конфигурацию на основе переменных окружения
@@ -35,6 +71,31 @@ func NewConfig() *Config {
 		SubscriptionInterval: subscriptionInterval,
 		WorkerCheckInterval:  workerCheckInterval,
 		GracePeriodDays:      gracePeriodDays,
+
+		DunningRetryHours:   getenvIntList("DUNNING_RETRY_HOURS", []int{1, 1}),
+		RenewalReminderDays: getenvIntList("RENEWAL_REMINDER_DAYS", []int{3, 1}),
+
+		PauseOptionDays:  getenvIntList("PAUSE_OPTION_DAYS", []int{7, 14, 30}),
+		MaxPausesPerYear: getenvInt("MAX_PAUSES_PER_YEAR", 2),
+
+		TrialDays:          getenvInt("TRIAL_DAYS", 0),
+		TrialTariffID:      getenv("TRIAL_TARIFF", "premium"),
+		TrialBindingAmount: getenvFloat("TRIAL_BINDING_AMOUNT", 1),
+
+		GiftMonthOptions: getenvIntList("GIFT_MONTH_OPTIONS", []int{1, 3, 12}),
+		GiftTariffID:     getenv("GIFT_TARIFF", "premium"),
+
+		ReferralReward:       getenv("REFERRAL_REWARD", "premium_days"),
+		ReferralRewardAmount: getenvInt("REFERRAL_REWARD_AMOUNT", 7),
+
+		UpdatesMode:           getenv("TELEGRAM_UPDATES_MODE", "polling"),
+		WebhookURL:            getenv("TELEGRAM_WEBHOOK_URL", ""),
+		WebhookPath:           getenv("TELEGRAM_WEBHOOK_PATH", "/telegram/webhook"),
+		WebhookSecret:         getenv("TELEGRAM_WEBHOOK_SECRET", ""),
+		WebhookCertPath:       getenv("TELEGRAM_WEBHOOK_CERT", ""),
+		WebhookMaxConnections: getenvInt("TELEGRAM_WEBHOOK_MAX_CONNECTIONS", 40),
+
+		TrustedProxies: getenvCIDRList("TRUSTED_PROXIES"),
 	}
 }
 
@@ -43,6 +104,27 @@ func (c *Config) IsDevMode() bool {
 	return c.Mode == "dev" || c.Mode == "development"
 }
 
+// GracePeriod возвращает grace period подписки: дни, в режиме разработки — минуты
+func (c *Config) GracePeriod() time.Duration {
+	if c.IsDevMode() {
+		return time.Duration(c.GracePeriodDays) * time.Minute
+	}
+	return time.Duration(c.GracePeriodDays) * 24 * time.Hour
+}
+
+// TrialPeriod возвращает длительность пробного периода: дни, в режиме разработки — минуты
+func (c *Config) TrialPeriod() time.Duration {
+	if c.IsDevMode() {
+		return time.Duration(c.TrialDays) * time.Minute
+	}
+	return time.Duration(c.TrialDays) * 24 * time.Hour
+}
+
+// IsWebhookMode проверяет, получает ли бот обновления через webhook
+func (c *Config) IsWebhookMode() bool {
+	return c.UpdatesMode == "webhook"
+}
+
 // GetSubscriptionIntervalDays возвращает интервал подписки в днях
 func (c *Config) GetSubscriptionIntervalDays() int {
 	return int(c.SubscriptionInterval.Hours() / 24)
@@ -65,3 +147,60 @@ func getenvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getenvFloat возвращает дробное значение переменной окружения
+func getenvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getenvIntList возвращает список положительных чисел из переменной окружения через запятую.
+// Значение none задает пустой список; некорректное значение заменяется значением по умолчанию.
+func getenvIntList(key string, defaultValue []int) []int {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+	if value == "none" {
+		return []int{}
+	}
+	var result []int
+	for _, part := range strings.Split(value, ",") {
+		number, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || number <= 0 {
+			return defaultValue
+		}
+		result = append(result, number)
+	}
+	return result
+}
+
+// getenvCIDRList возвращает список сетей из переменной окружения через запятую.
+// Отдельный IP считается сетью из одного адреса; некорректные значения пропускаются.
+func getenvCIDRList(key string) []*net.IPNet {
+	var result []*net.IPNet
+	for _, part := range strings.Split(os.Getenv(key), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			if ip := net.ParseIP(part); ip != nil && ip.To4() != nil {
+				part += "/32"
+			} else {
+				part += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(part)
+		if err != nil {
+			log.Printf("⚠️ %s: некорректный адрес %q пропущен", key, part)
+			continue
+		}
+		result = append(result, network)
+	}
+	return result
+}
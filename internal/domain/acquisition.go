@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Источники привлечения: по какой ссылке пользователь впервые открыл бота
+const (
+	AcquisitionOrganic  = "organic"  // /start без параметра, с нераспознанным параметром или несуществующим реферальным кодом
+	AcquisitionCampaign = "campaign" // Рекламная кампания: /start c_<кампания>
+	AcquisitionReferral = "referral" // Реферальная ссылка: /start <реферальный код>, код принадлежит пользователю
+	AcquisitionGift     = "gift"     // Ссылка на подарок: /start gift_<код>
+)
+
+// CampaignStartPrefix префикс параметра /start в ссылке рекламной кампании: /start c_<кампания>
+const CampaignStartPrefix = "c_"
+
+// MaxCampaignLength максимальная длина названия кампании (параметр /start ограничен 64 символами)
+const MaxCampaignLength = 62
+
+// Acquisition первое касание пользователя: источник и кампания
+type Acquisition struct {
+	Source   string `json:"source"`
+	Campaign string `json:"campaign,omitempty"` // Только для AcquisitionCampaign
+}
+
+// StartPayload разобранный параметр команды /start
+type StartPayload struct {
+	Acquisition
+	Code string // Код подарка или возможный реферальный код
+}
+
+// ParseStartPayload разбирает параметр /start. Название кампании приводится к нижнему регистру;
+// кампания с недопустимым названием считается органическим переходом. Параметр без префикса
+// остается органическим с кодом в Code, пока ResolveReferral не найдет владельца кода.
+func ParseStartPayload(payload string) StartPayload {
+	payload = strings.TrimSpace(payload)
+	switch {
+	case payload == "":
+		return StartPayload{Acquisition: Acquisition{Source: AcquisitionOrganic}}
+	case strings.HasPrefix(payload, GiftStartPrefix):
+		return StartPayload{Acquisition: Acquisition{Source: AcquisitionGift}, Code: strings.TrimPrefix(payload, GiftStartPrefix)}
+	case strings.HasPrefix(payload, CampaignStartPrefix):
+		campaign := strings.ToLower(strings.TrimPrefix(payload, CampaignStartPrefix))
+		if !IsValidCampaign(campaign) {
+			return StartPayload{Acquisition: Acquisition{Source: AcquisitionOrganic}}
+		}
+		return StartPayload{Acquisition: Acquisition{Source: AcquisitionCampaign, Campaign: campaign}}
+	}
+	return StartPayload{Acquisition: Acquisition{Source: AcquisitionOrganic}, Code: payload}
+}
+
+// ResolveReferral помечает переход реферальным, если referrerByCode нашел владельца кода
+// из параметра без префикса. Нераспознанный параметр остается органическим переходом.
+func (p StartPayload) ResolveReferral(referrerByCode func(code string) (int64, error)) (StartPayload, error) {
+	if p.Source != AcquisitionOrganic || p.Code == "" {
+		return p, nil
+	}
+	referrerID, err := referrerByCode(p.Code)
+	if err != nil {
+		return p, err
+	}
+	if referrerID != 0 {
+		p.Source = AcquisitionReferral
+	}
+	return p, nil
+}
+
+// IsValidCampaign проверяет название кампании: латиница в нижнем регистре, цифры, '_' и '-'
+func IsValidCampaign(campaign string) bool {
+	if campaign == "" || len(campaign) > MaxCampaignLength {
+		return false
+	}
+	for _, c := range campaign {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '_' && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// AcquisitionStats воронка пользователей, привлеченных из одного источника
+type AcquisitionStats struct {
+	Acquisition
+	Signups         int     `json:"signups"`          // Впервые открыли бота
+	FirstGeneration int     `json:"first_generation"` // Создали хотя бы один пост
+	FirstPayment    int     `json:"first_payment"`    // Оплатили хотя бы раз (без привязки карты для пробного периода)
+	Revenue         float64 `json:"revenue"`          // Сумма успешных оплат этих пользователей
+}
+
+// AcquisitionRepository интерфейс для работы с источниками привлечения пользователей
+type AcquisitionRepository interface {
+	// Record сохраняет первое касание пользователя. Возвращает false, если источник уже записан.
+	Record(userID int64, acquisition Acquisition, at time.Time) (bool, error)
+	// GetFunnel воронка по источникам для пользователей, пришедших начиная с since;
+	// пользователи без записанного источника считаются органическими
+	GetFunnel(since time.Time) ([]*AcquisitionStats, error)
+}
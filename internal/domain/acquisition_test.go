@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseStartPayload(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload string
+		want    StartPayload
+	}{
+		{"без параметра", "", StartPayload{Acquisition: Acquisition{Source: AcquisitionOrganic}}},
+		{"кампания", "c_Spring-Promo_2026", StartPayload{Acquisition: Acquisition{Source: AcquisitionCampaign, Campaign: "spring-promo_2026"}}},
+		{"кампания без названия", "c_", StartPayload{Acquisition: Acquisition{Source: AcquisitionOrganic}}},
+		{"недопустимые символы", "c_promo.tg", StartPayload{Acquisition: Acquisition{Source: AcquisitionOrganic}}},
+		{"подарок", "gift_ABCD2345", StartPayload{Acquisition: Acquisition{Source: AcquisitionGift}, Code: "ABCD2345"}},
+		{"параметр без префикса", "K7Q2M9XA", StartPayload{Acquisition: Acquisition{Source: AcquisitionOrganic}, Code: "K7Q2M9XA"}},
+	}
+	for _, tc := range cases {
+		if got := ParseStartPayload(tc.payload); got != tc.want {
+			t.Errorf("%s: ожидалось %+v, получено %+v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestResolveReferral(t *testing.T) {
+	codes := map[string]int64{"K7Q2M9XA": 7}
+	lookup := func(code string) (int64, error) { return codes[code], nil }
+
+	cases := []struct {
+		name    string
+		payload string
+		want    StartPayload
+	}{
+		{"существующий код", "K7Q2M9XA", StartPayload{Acquisition: Acquisition{Source: AcquisitionReferral}, Code: "K7Q2M9XA"}},
+		{"неизвестный параметр", "from_channel", StartPayload{Acquisition: Acquisition{Source: AcquisitionOrganic}, Code: "from_channel"}},
+		{"кампания", "c_spring", StartPayload{Acquisition: Acquisition{Source: AcquisitionCampaign, Campaign: "spring"}}},
+		{"без параметра", "", StartPayload{Acquisition: Acquisition{Source: AcquisitionOrganic}}},
+	}
+	for _, tc := range cases {
+		got, err := ParseStartPayload(tc.payload).ResolveReferral(lookup)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: ожидалось %+v, получено %+v (%v)", tc.name, tc.want, got, err)
+		}
+	}
+
+	// Ошибка поиска кода не делает переход реферальным
+	failing := func(code string) (int64, error) { return 0, errors.New("db is down") }
+	got, err := ParseStartPayload("K7Q2M9XA").ResolveReferral(failing)
+	if err == nil || got.Source != AcquisitionOrganic {
+		t.Errorf("При ошибке поиска ожидался органический переход с ошибкой, получено %+v (%v)", got, err)
+	}
+}
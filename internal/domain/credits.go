@@ -0,0 +1,82 @@
+package domain
+
+import "time"
+
+// CreditPack пакет кредитов, покупаемый разовым платежом без подписки
+type CreditPack struct {
+	ID           string  `json:"id"`
+	Name         string  `json:"name"`
+	Credits      int     `json:"credits"`
+	Price        float64 `json:"price"`
+	Currency     string  `json:"currency"`
+	ValidityDays int     `json:"validity_days"` // Срок действия кредитов с момента оплаты
+	Visible      bool    `json:"visible"`
+	SortOrder    int     `json:"sort_order"`
+}
+
+// Статусы покупки пакета кредитов
+const (
+	CreditPurchasePending = "pending"
+	CreditPurchasePaid    = "paid"
+)
+
+// CreditPurchase покупка пакета кредитов; остаток расходуется до истечения срока действия
+type CreditPurchase struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	PackID    string     `json:"pack_id"`
+	Credits   int        `json:"credits"`
+	Remaining int        `json:"remaining"`
+	Amount    float64    `json:"amount"`
+	Currency  string     `json:"currency"`
+	Status    string     `json:"status"`
+	PaymentID *string    `json:"payment_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	PaidAt    *time.Time `json:"paid_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// IsExpired проверяет, истек ли срок действия кредитов покупки
+func (p *CreditPurchase) IsExpired(now time.Time) bool {
+	return p.ExpiresAt != nil && !now.Before(*p.ExpiresAt)
+}
+
+// CreditBalance действующий остаток кредитов пользователя
+type CreditBalance struct {
+	Credits        int        `json:"credits"`
+	NextExpiry     *time.Time `json:"next_expiry,omitempty"`     // Когда сгорит ближайшая часть остатка
+	ExpiringAmount int        `json:"expiring_amount,omitempty"` // Сколько кредитов сгорит в NextExpiry
+}
+
+// CreditResources ресурсы, операции с которыми можно оплатить кредитом: один кредит — одна операция
+var CreditResources = []QuotaResource{QuotaGenerations, QuotaRewrites, QuotaEdits}
+
+// IsCreditResource проверяет, оплачивается ли ресурс кредитами
+func IsCreditResource(resource QuotaResource) bool {
+	for _, r := range CreditResources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// CreditRepository интерфейс для работы с пакетами и балансом кредитов
+type CreditRepository interface {
+	GetVisiblePacks() ([]*CreditPack, error)
+	GetPack(id string) (*CreditPack, error)
+	CreatePurchase(purchase *CreditPurchase) error
+	SetPurchasePaymentID(purchaseID int64, paymentID string) error
+	// MarkPurchasePaid зачисляет кредиты по платежу. Повторный вызов для оплаченной покупки возвращает false.
+	MarkPurchasePaid(paymentID string, paidAt time.Time) (*CreditPurchase, bool, error)
+	GetBalance(userID int64, now time.Time) (*CreditBalance, error)
+	// ConsumeCredits списывает кредиты из покупки с ближайшим сроком действия.
+	// Возвращает ID покупки или 0, если действующих кредитов не хватает.
+	ConsumeCredits(userID int64, amount int, now time.Time) (int64, error)
+	RefundCredits(purchaseID int64, amount int) error
+	GetPurchaseByPaymentID(paymentID string) (*CreditPurchase, error)
+	// RevokeCredits списывает до amount неизрасходованных кредитов покупки после возврата оплаты.
+	// Возвращает, сколько кредитов списано: израсходованные кредиты не отзываются.
+	RevokeCredits(purchaseID int64, amount int) (int, error)
+	GetUserPurchases(userID int64, limit int) ([]*CreditPurchase, error)
+}
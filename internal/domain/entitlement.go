@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// EntitlementSource основание, по которому у пользователя есть доступ
+type EntitlementSource string
+
+const (
+	EntitlementFree         EntitlementSource = "free"         // Лимиты бесплатного тарифа
+	EntitlementSubscription EntitlementSource = "subscription" // Оплаченный период подписки
+	EntitlementGrace        EntitlementSource = "grace"        // Период оплачен до NextPayment, продление еще списывается
+	EntitlementGranted      EntitlementSource = "granted"      // Premium, выданный администратором
+	EntitlementTrial        EntitlementSource = "trial"        // Пробный период, после него спишется цена тарифа
+)
+
+// Entitlement права пользователя на текущий момент: что ему доступно и до какого времени
+type Entitlement struct {
+	Source    EntitlementSource
+	TariffID  string             // Тариф, лимиты которого действуют сейчас
+	Status    SubscriptionStatus // Статус подписки; пусто — подписки нет
+	Since     *time.Time         // Начало оплаченного периода подписки: от него отсчитываются периоды квот
+	Until     *time.Time         // Момент окончания доступа; nil — бессрочно
+	AutoRenew bool               // Доступ продлится автоматически списанием с привязанной карты
+	// Subscription текущая подписка пользователя, даже если доступа по ней уже нет
+	Subscription *Subscription
+}
+
+// IsPremium проверяет, действуют ли для пользователя платные возможности
+func (e *Entitlement) IsPremium() bool {
+	return e != nil && e.Source != EntitlementFree
+}
+
+// HasSubscriptionAccess проверяет, что платные возможности дает подписка, а не выданный Premium
+func (e *Entitlement) HasSubscriptionAccess() bool {
+	return e != nil && (e.Source == EntitlementSubscription || e.Source == EntitlementGrace || e.Source == EntitlementTrial)
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Ошибки активации подарка: бот показывает получателю причину отказа
+var (
+	ErrGiftNotFound = errors.New("gift not found")
+	ErrGiftRedeemed = errors.New("gift already redeemed")
+)
+
+// GiftStartPrefix префикс параметра /start в ссылке на подарок: /start gift_<код>
+const GiftStartPrefix = "gift_"
+
+// Статусы подарочной подписки
+const (
+	GiftStatusPending   = "pending"   // Ожидает оплаты
+	GiftStatusPaid      = "paid"      // Оплачен, код можно активировать
+	GiftStatusRedeemed  = "redeemed"  // Активирован получателем
+	GiftStatusCancelled = "cancelled" // Оплата возвращена до активации
+)
+
+// Gift подарочный Premium: покупатель оплачивает разовым платежом и передает код получателю.
+// Активация продлевает выданный Premium получателя на Months месяцев без автопродления.
+type Gift struct {
+	ID          int64      `json:"id"`
+	Code        string     `json:"code"`
+	BuyerID     int64      `json:"buyer_id"`
+	Months      int        `json:"months"`
+	Amount      float64    `json:"amount"`
+	Currency    string     `json:"currency"`
+	Status      string     `json:"status"`
+	PaymentID   *string    `json:"payment_id,omitempty"`
+	RecipientID *int64     `json:"recipient_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	PaidAt      *time.Time `json:"paid_at,omitempty"`
+	RedeemedAt  *time.Time `json:"redeemed_at,omitempty"`
+}
+
+// GiftRepository интерфейс для работы с подарочными подписками
+type GiftRepository interface {
+	Create(gift *Gift) error
+	SetPaymentID(giftID int64, paymentID string) error
+	// MarkPaid отмечает подарок оплаченным. Повторный вызов для оплаченного подарка возвращает false.
+	MarkPaid(paymentID string, paidAt time.Time) (*Gift, bool, error)
+	// Redeem активирует оплаченный подарок и продлевает выданный Premium получателя.
+	// Возвращает подарок и новую дату окончания Premium; ErrGiftNotFound или ErrGiftRedeemed, если код не активировать.
+	Redeem(code string, recipientID int64, at time.Time) (*Gift, time.Time, error)
+	// Cancel аннулирует оплаченный, но еще не активированный подарок. Возвращает false, если подарок уже активирован.
+	Cancel(paymentID string) (bool, error)
+}
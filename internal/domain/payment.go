@@ -0,0 +1,77 @@
+package domain
+
+import "time"
+
+// PaymentStatus статус попытки оплаты в журнале платежей
+type PaymentStatus string
+
+const (
+	PaymentStatusPending   PaymentStatus = "pending"
+	PaymentStatusSucceeded PaymentStatus = "succeeded"
+	PaymentStatusFailed    PaymentStatus = "failed"
+	PaymentStatusRefunded  PaymentStatus = "refunded" // Возвращен полностью
+)
+
+// Платежные провайдеры
+const (
+	PaymentProviderYooKassa = "yookassa"
+	PaymentProviderProdamus = "prodamus"
+)
+
+// Назначение платежа
+const (
+	PaymentKindInitial    = "initial"     // Первая оплата подписки с привязкой карты
+	PaymentKindRecurring  = "recurring"   // Автопродление подписки
+	PaymentKindCreditPack = "credit_pack" // Разовая покупка пакета кредитов
+	PaymentKindTrial      = "trial"       // Привязка карты символической суммой для пробного периода
+	PaymentKindGift       = "gift"        // Разовая покупка подарочного Premium для другого пользователя
+)
+
+// Payment попытка оплаты у провайдера: одна запись на платеж, статус обновляется по вебхукам
+type Payment struct {
+	ID                int64         `json:"id"`
+	UserID            int64         `json:"user_id"`
+	SubscriptionID    *int64        `json:"subscription_id,omitempty"`
+	Provider          string        `json:"provider"`
+	ProviderPaymentID *string       `json:"provider_payment_id,omitempty"`
+	Kind              string        `json:"kind"`
+	Amount            float64       `json:"amount"`
+	Currency          string        `json:"currency"`
+	Status            PaymentStatus `json:"status"`
+	FailureReason     string        `json:"failure_reason,omitempty"`
+	ReceiptStatus     string        `json:"receipt_status,omitempty"` // Регистрация чека 54-ФЗ: pending, succeeded, canceled
+	IdempotencyKey    string        `json:"idempotency_key,omitempty"`
+	PromoCodeID       *int64        `json:"promo_code_id,omitempty"` // Промокод, по которому дана скидка
+	Discount          float64       `json:"discount,omitempty"`      // Размер скидки: Amount уже уменьшен на нее
+	CreatedAt         time.Time     `json:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+}
+
+// PaymentTotals итоги по платежам одного провайдера и назначения за период
+type PaymentTotals struct {
+	Provider  string  `json:"provider"`
+	Kind      string  `json:"kind"`
+	Succeeded int     `json:"succeeded"`
+	Failed    int     `json:"failed"`
+	Pending   int     `json:"pending"`
+	Refunded  int     `json:"refunded"`
+	Revenue   float64 `json:"revenue"` // Сумма успешных платежей
+	// ReceiptsFailed платежи, чек по которым не зарегистрирован: их нужно пробить вручную
+	ReceiptsFailed int `json:"receipts_failed"`
+}
+
+// PaymentRepository интерфейс журнала платежей
+type PaymentRepository interface {
+	// Record сохраняет попытку оплаты. Повторная запись платежа с тем же ID провайдера
+	// или ключом идемпотентности обновляет его статус, а не создает новую строку.
+	Record(payment *Payment) error
+	GetByID(id int64) (*Payment, error)
+	GetByProviderPaymentID(provider, providerPaymentID string) (*Payment, error)
+	GetUserPayments(userID int64, limit int) ([]*Payment, error)
+	GetTotals(since time.Time) ([]*PaymentTotals, error)
+}
+
+// UserContactRepository контакты пользователя для фискальных чеков
+type UserContactRepository interface {
+	GetUserEmail(userID int64) (string, error) // Пустая строка, если email не указан
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"errors"
+	"net"
+	"net/url"
+)
+
+// ErrProviderUnsupported операция не поддерживается платежным провайдером
+// (например, Prodamus сам списывает продления и не дает вернуть платеж через API)
+var ErrProviderUnsupported = errors.New("operation is not supported by payment provider")
+
+// PaymentRequest новая оплата по ссылке: первая оплата подписки или разовая покупка
+type PaymentRequest struct {
+	UserID         int64
+	SubscriptionID *int64
+	Kind           string // PaymentKindInitial сохраняет способ оплаты для автопродления
+	Amount         float64
+	Currency       string
+	Description    string // Наименование услуги, оно же позиция в чеке
+	IdempotencyKey string
+	ReturnURL      string
+	Email          string            // Адрес для чека; пусто — адрес магазина по умолчанию
+	Metadata       map[string]string // Дополнительные данные, которые вернутся в уведомлении
+}
+
+// ChargeRequest списание по способу оплаты, сохраненному при первой оплате
+type ChargeRequest struct {
+	UserID          int64
+	SubscriptionID  int64
+	Amount          float64
+	Currency        string
+	Description     string
+	IdempotencyKey  string
+	CustomerID      string
+	PaymentMethodID string
+	Email           string
+}
+
+// RefundRequest возврат полной или частичной суммы платежа
+type RefundRequest struct {
+	ProviderPaymentID string
+	Amount            float64
+	Currency          string
+	Reason            string
+	Description       string // Наименование услуги в чеке возврата
+	Email             string
+	IdempotencyKey    string
+}
+
+// ProviderPayment состояние платежа у провайдера
+type ProviderPayment struct {
+	// Payment запись для журнала платежей; nil, если платеж не удалось сопоставить с пользователем
+	Payment         *Payment
+	ConfirmationURL string // Страница оплаты для перехода пользователя
+	PaymentMethodID string // Сохраненный способ оплаты для автопродления
+}
+
+// ProviderRefund состояние возврата у провайдера
+type ProviderRefund struct {
+	ID                string
+	ProviderPaymentID string
+	Amount            float64
+	Currency          string
+	Status            RefundStatus
+	Reason            string
+}
+
+// WebhookRequest данные входящего уведомления, по которым провайдер проверяет его подлинность
+type WebhookRequest struct {
+	SourceIP  net.IP
+	Form      url.Values
+	Signature string
+}
+
+// PaymentProvider платежный провайдер. Сервисы работают с оплатами только через этот
+// интерфейс и не знают о формате запросов и ответов конкретного провайдера.
+type PaymentProvider interface {
+	Name() string
+	// CreatePayment создает платеж и возвращает ссылку на страницу оплаты
+	CreatePayment(req *PaymentRequest) (*ProviderPayment, error)
+	// ChargeSaved списывает оплату по сохраненному способу без участия пользователя
+	ChargeSaved(req *ChargeRequest) (*ProviderPayment, error)
+	// GetPayment возвращает актуальное состояние платежа
+	GetPayment(providerPaymentID string) (*ProviderPayment, error)
+	Refund(req *RefundRequest) (*ProviderRefund, error)
+	// VerifyWebhook возвращает ошибку, если уведомление пришло не от провайдера
+	VerifyWebhook(req *WebhookRequest) error
+}
+
+// PaymentProviderRepository выбор провайдера, закрепленный за пользователем администратором
+type PaymentProviderRepository interface {
+	GetUserPaymentProvider(userID int64) (string, error) // Пустая строка — провайдер не закреплен
+	SetUserPaymentProvider(userID int64, provider string) error
+}
+
+// IsKnownPaymentProvider проверяет, что имя провайдера поддерживается ботом
+func IsKnownPaymentProvider(name string) bool {
+	return name == PaymentProviderYooKassa || name == PaymentProviderProdamus
+}
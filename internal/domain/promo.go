@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// Ошибки применения промокода: бот показывает пользователю причину отказа
+var (
+	ErrPromoNotFound    = errors.New("promo code not found")
+	ErrPromoExpired     = errors.New("promo code expired")
+	ErrPromoExhausted   = errors.New("promo code usage limit reached")
+	ErrPromoAlreadyUsed = errors.New("promo code already used by user")
+)
+
+// Виды скидки по промокоду
+const (
+	PromoDiscountPercent = "percent" // Процент от цены тарифа
+	PromoDiscountFixed   = "fixed"   // Фиксированная сумма в рублях
+)
+
+// MinPaymentAmount минимальная сумма платежа: скидка не уменьшает цену ниже нее
+const MinPaymentAmount = 1.0
+
+// PromoCode промокод на скидку при оформлении подписки
+type PromoCode struct {
+	ID            int64      `json:"id"`
+	Code          string     `json:"code"`
+	DiscountType  string     `json:"discount_type"`
+	DiscountValue float64    `json:"discount_value"` // Процент или сумма скидки в зависимости от DiscountType
+	Periods       int        `json:"periods"`        // Сколько оплат со скидкой, включая первую; 0 — все оплаты
+	MaxUses       int        `json:"max_uses"`       // Сколько пользователей могут применить код; 0 — без ограничений
+	UsedCount     int        `json:"used_count"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"` // После этой даты код не применяется; уже примененные скидки сохраняются
+	Active        bool       `json:"active"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// NormalizePromoCode приводит введенный код к виду, в котором он хранится
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// IsValidPromoDiscountType проверяет вид скидки
+func IsValidPromoDiscountType(discountType string) bool {
+	return discountType == PromoDiscountPercent || discountType == PromoDiscountFixed
+}
+
+// CheckAvailable проверяет, можно ли применить промокод сейчас.
+// Однократность применения пользователем проверяет репозиторий.
+func (p *PromoCode) CheckAvailable(now time.Time) error {
+	if !p.Active {
+		return ErrPromoNotFound
+	}
+	if p.ExpiresAt != nil && !now.Before(*p.ExpiresAt) {
+		return ErrPromoExpired
+	}
+	if p.MaxUses > 0 && p.UsedCount >= p.MaxUses {
+		return ErrPromoExhausted
+	}
+	return nil
+}
+
+// Discount возвращает размер скидки для цены price, округленный до копеек.
+// Цена со скидкой не опускается ниже MinPaymentAmount.
+func (p *PromoCode) Discount(price float64) float64 {
+	var discount float64
+	switch p.DiscountType {
+	case PromoDiscountPercent:
+		discount = price * p.DiscountValue / 100
+	case PromoDiscountFixed:
+		discount = p.DiscountValue
+	}
+	discount = math.Round(discount*100) / 100
+	if discount > price-MinPaymentAmount {
+		discount = math.Max(price-MinPaymentAmount, 0)
+	}
+	return math.Max(discount, 0)
+}
+
+// PromoRedemption применение промокода пользователем к подписке
+type PromoRedemption struct {
+	ID             int64      `json:"id"`
+	PromoCodeID    int64      `json:"promo_code_id"`
+	UserID         int64      `json:"user_id"`
+	SubscriptionID *int64     `json:"subscription_id,omitempty"`
+	RenewalsLeft   *int       `json:"renewals_left,omitempty"` // Сколько продлений еще со скидкой; nil — все продления
+	CreatedAt      time.Time  `json:"created_at"`
+	Promo          *PromoCode `json:"promo,omitempty"` // Промокод, по которому считается скидка продления
+}
+
+// PromoStats итоги по промокоду для отчета маркетингу
+type PromoStats struct {
+	Promo    PromoCode `json:"promo"`
+	Payments int       `json:"payments"` // Успешные оплаты со скидкой по коду
+	Revenue  float64   `json:"revenue"`  // Сумма этих оплат
+	Discount float64   `json:"discount"` // Сумма предоставленных скидок
+}
+
+// PromoRepository интерфейс для работы с промокодами
+type PromoRepository interface {
+	Create(promo *PromoCode) error
+	// GetByCode возвращает промокод по нормализованному коду или nil, если его нет
+	GetByCode(code string) (*PromoCode, error)
+	SetActive(code string, active bool) error
+	HasRedeemed(promoID, userID int64) (bool, error)
+	// Redeem сохраняет применение промокода и увеличивает счетчик использований;
+	// число продлений со скидкой берется из промокода. Возвращает false, если пользователь уже применял этот код, и ErrPromoExhausted, если лимит исчерпан.
+	Redeem(redemption *PromoRedemption) (bool, error)
+	// GetSubscriptionDiscount действующая скидка на продление подписки или nil
+	GetSubscriptionDiscount(subscriptionID int64) (*PromoRedemption, error)
+	// ConsumeRenewal уменьшает число продлений со скидкой после успешного списания
+	ConsumeRenewal(redemptionID int64) error
+	GetStats() ([]*PromoStats, error)
+}
@@ -0,0 +1,23 @@
+package domain
+
+import "testing"
+
+func TestPromoCodeDiscount(t *testing.T) {
+	cases := []struct {
+		name     string
+		promo    PromoCode
+		price    float64
+		discount float64
+	}{
+		{"процент", PromoCode{DiscountType: PromoDiscountPercent, DiscountValue: 15}, 990, 148.5},
+		{"фиксированная сумма", PromoCode{DiscountType: PromoDiscountFixed, DiscountValue: 300}, 990, 300},
+		{"не ниже минимального платежа", PromoCode{DiscountType: PromoDiscountFixed, DiscountValue: 2000}, 990, 989},
+		{"100% оставляет минимальный платеж", PromoCode{DiscountType: PromoDiscountPercent, DiscountValue: 100}, 990, 989},
+		{"копейки округляются", PromoCode{DiscountType: PromoDiscountPercent, DiscountValue: 33}, 299, 98.67},
+	}
+	for _, tc := range cases {
+		if got := tc.promo.Discount(tc.price); got != tc.discount {
+			t.Errorf("%s: ожидалась скидка %.2f, получено %.2f", tc.name, tc.discount, got)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// QuotaResource ресурс, расход которого ограничивается тарифом
+type QuotaResource string
+
+const (
+	QuotaGenerations  QuotaResource = "generations"   // Создание поста или сценария
+	QuotaRewrites     QuotaResource = "rewrites"      // Рерайт готового поста
+	QuotaEdits        QuotaResource = "edits"         // Применение голосовых правок
+	QuotaAudioMinutes QuotaResource = "audio_minutes" // Минуты распознанного аудио
+)
+
+// QuotaResources все учитываемые ресурсы в порядке отображения
+var QuotaResources = []QuotaResource{QuotaGenerations, QuotaRewrites, QuotaEdits, QuotaAudioMinutes}
+
+// IsValidQuotaResource проверяет, учитывается ли ресурс движком квот
+func IsValidQuotaResource(resource QuotaResource) bool {
+	for _, r := range QuotaResources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrQuotaExceeded лимит ресурса в текущем периоде исчерпан
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// UnlimitedQuota значение лимита и остатка для ресурса без ограничений
+const UnlimitedQuota = -1
+
+// QuotaStatus состояние квоты пользователя по одному ресурсу
+type QuotaStatus struct {
+	Resource    QuotaResource `json:"resource"`
+	TariffID    string        `json:"tariff_id"`
+	PeriodStart time.Time     `json:"period_start"`
+	PeriodEnd   time.Time     `json:"period_end"` // Дата обновления лимитов в часовом поясе пользователя
+	Limit       int           `json:"limit"`      // С учетом выданного администратором; UnlimitedQuota — без ограничений
+	Granted     int           `json:"granted"`
+	Used        int           `json:"used"`
+	Remaining   int           `json:"remaining"` // UnlimitedQuota — без ограничений
+	Credits     int           `json:"credits"`   // Купленные кредиты, которые расходуются раньше бесплатного лимита
+}
+
+// IsUnlimited проверяет, ограничен ли ресурс
+func (s *QuotaStatus) IsUnlimited() bool {
+	return s.Limit == UnlimitedQuota
+}
+
+// Allows проверяет, хватает ли остатка или кредитов на amount единиц ресурса
+func (s *QuotaStatus) Allows(amount int) bool {
+	return s.IsUnlimited() || s.Remaining >= amount || s.Credits >= amount
+}
+
+// QuotaConsumption списание квоты: передается в Refund, чтобы вернуть единицы в тот же период
+type QuotaConsumption struct {
+	UserID           int64
+	Resource         QuotaResource
+	Amount           int
+	PeriodStart      time.Time
+	CreditPurchaseID int64 // Не 0, если операция оплачена кредитами этой покупки
+}
+
+// PaidByCredits проверяет, оплачена ли операция купленными кредитами
+func (c *QuotaConsumption) PaidByCredits() bool {
+	return c != nil && c.CreditPurchaseID != 0
+}
+
+// QuotaRepository интерфейс для учета расхода квот
+type QuotaRepository interface {
+	GetUsed(userID int64, resource QuotaResource, periodStart time.Time) (int, error)
+	// Consume атомарно списывает amount единиц, если расход не превысит limit (UnlimitedQuota — без проверки).
+	// Возвращает false, если лимита не хватило.
+	Consume(userID int64, resource QuotaResource, periodStart time.Time, amount, limit int) (bool, error)
+	Refund(userID int64, resource QuotaResource, periodStart time.Time, amount int) error
+	GetGranted(userID int64, resource QuotaResource, periodStart time.Time) (int, error)
+	ResetUsage(userID int64, periodStart time.Time) error
+	// GetUserAnchor возвращает дату регистрации и часовой пояс пользователя
+	GetUserAnchor(userID int64) (time.Time, string, error)
+}
+
+// DefaultUserTimezone часовой пояс пользователя, пока он не выбрал свой
+const DefaultUserTimezone = "Europe/Moscow"
+
+// QuotaPeriod возвращает границы периода учета квот, содержащего now.
+// Периоды длиной в period (day, week, month, year) отсчитываются от полуночи дня anchor в его часовом поясе;
+// если в месяце нет дня привязки (31-е), период начинается в последний день месяца.
+func QuotaPeriod(anchor time.Time, period string, now time.Time) (time.Time, time.Time) {
+	now = now.In(anchor.Location())
+	anchor = time.Date(anchor.Year(), anchor.Month(), anchor.Day(), 0, 0, 0, 0, anchor.Location())
+
+	switch period {
+	case TariffPeriodDay, TariffPeriodWeek:
+		days := 1
+		if period == TariffPeriodWeek {
+			days = 7
+		}
+		// Считаем по календарным дням, чтобы переход на летнее время не сдвигал границы
+		elapsed := int(now.Sub(anchor).Hours()/24) / days * days
+		start := anchor.AddDate(0, 0, elapsed)
+		for start.After(now) {
+			start = start.AddDate(0, 0, -days)
+		}
+		for !start.AddDate(0, 0, days).After(now) {
+			start = start.AddDate(0, 0, days)
+		}
+		return start, start.AddDate(0, 0, days)
+	}
+
+	months := 1
+	if period == TariffPeriodYear {
+		months = 12
+	}
+	elapsed := ((now.Year()-anchor.Year())*12 + int(now.Month()-anchor.Month())) / months * months
+	start := AddMonths(anchor, elapsed)
+	if start.After(now) {
+		elapsed -= months
+		start = AddMonths(anchor, elapsed)
+	}
+	return start, AddMonths(anchor, elapsed+months)
+}
@@ -0,0 +1,57 @@
+package domain
+
+import "time"
+
+// Награды реферальной программы: приглашающий получает их, когда приглашенный впервые оплачивает
+const (
+	ReferralRewardGenerations = "generations"  // Дополнительные создания постов на текущий период
+	ReferralRewardPremiumDays = "premium_days" // Дни Premium без автопродления
+)
+
+// IsValidReferralReward проверяет, поддерживается ли вид награды
+func IsValidReferralReward(reward string) bool {
+	return reward == ReferralRewardGenerations || reward == ReferralRewardPremiumDays
+}
+
+// Статусы приглашения
+const (
+	ReferralStatusPending  = "pending"  // Приглашенный еще не оплачивал
+	ReferralStatusRewarded = "rewarded" // Награда за первую оплату начислена
+)
+
+// Referral приглашение: пользователь пришел по ссылке /start <реферальный код>
+type Referral struct {
+	ID           int64      `json:"id"`
+	ReferrerID   int64      `json:"referrer_id"`
+	ReferredID   int64      `json:"referred_id"`
+	Status       string     `json:"status"`
+	PaymentID    *int64     `json:"payment_id,omitempty"` // Первая оплата приглашенного из журнала платежей
+	RewardType   string     `json:"reward_type,omitempty"`
+	RewardAmount int        `json:"reward_amount"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RewardedAt   *time.Time `json:"rewarded_at,omitempty"`
+}
+
+// ReferralStats статистика приглашений пользователя
+type ReferralStats struct {
+	Invited          int `json:"invited"`           // Пришли по ссылке
+	Paid             int `json:"paid"`              // Оплатили хотя бы раз
+	BonusGenerations int `json:"bonus_generations"` // Получено дополнительных созданий
+	PremiumDays      int `json:"premium_days"`      // Получено дней Premium
+}
+
+// ReferralRepository интерфейс для работы с реферальной программой
+type ReferralRepository interface {
+	// GetReferrerByCode возвращает пользователя с реферальным кодом или 0, если код не найден
+	GetReferrerByCode(code string) (int64, error)
+	// Attach закрепляет пользователя за приглашающим. Возвращает false, если у пользователя
+	// уже есть приглашающий или он уже оплачивал: награда положена только за новых клиентов.
+	Attach(referredID, referrerID int64) (bool, error)
+	// GetPendingRewards возвращает приглашения без награды, приглашенные по которым уже оплатили;
+	// PaymentID — первая успешная оплата без учета привязки карты для пробного периода
+	GetPendingRewards(limit int) ([]*Referral, error)
+	// Reward отмечает награду и начисляет ее приглашающему в одной транзакции.
+	// Повторный вызов для того же приглашения возвращает false.
+	Reward(referral *Referral, rewardType string, amount int, at time.Time) (bool, error)
+	GetStats(referrerID int64) (*ReferralStats, error)
+}
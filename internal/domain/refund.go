@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRefundNotAllowed платеж нельзя вернуть: он не оплачен, уже возвращен или сумма больше остатка
+var ErrRefundNotAllowed = errors.New("refund not allowed")
+
+// RefundStatus статус возврата у провайдера
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	RefundStatusCanceled  RefundStatus = "canceled"
+)
+
+// Refund возврат полной или частичной суммы платежа из журнала
+type Refund struct {
+	ID               int64        `json:"id"`
+	PaymentID        int64        `json:"payment_id"` // ID платежа в журнале payments
+	UserID           int64        `json:"user_id"`
+	Provider         string       `json:"provider"`
+	ProviderRefundID *string      `json:"provider_refund_id,omitempty"`
+	Amount           float64      `json:"amount"`
+	Currency         string       `json:"currency"`
+	Status           RefundStatus `json:"status"`
+	Reason           string       `json:"reason,omitempty"`
+	AdminID          *int64       `json:"admin_id,omitempty"` // nil — возврат оформлен у провайдера или автоматически
+	CreatedAt        time.Time    `json:"created_at"`
+	AppliedAt        *time.Time   `json:"applied_at,omitempty"` // Когда применены последствия: понижение подписки, списание кредитов
+}
+
+// RefundRepository интерфейс для работы с возвратами
+type RefundRepository interface {
+	// Record сохраняет возврат. Возврат с уже известным ID провайдера обновляет статус существующей записи.
+	// Успешный возврат не становится снова ожидающим или отмененным: актуальный статус записывается в refund.
+	Record(refund *Refund) error
+	// AdoptPending присваивает ID провайдера ожидающему возврату, созданному ботом, на ту же сумму того же платежа,
+	// если провайдер еще не вернул ID в ответе. Так уведомление, пришедшее раньше ответа API,
+	// не создает вторую запись. Возвращает false, если подходящей записи нет.
+	AdoptPending(refund *Refund) (bool, error)
+	// MarkApplied отмечает успешный возврат примененным. Повторный вызов возвращает false:
+	// последствия возврата применяются ровно один раз, даже если о нем сообщили и API, и вебхук.
+	MarkApplied(id int64, appliedAt time.Time) (bool, error)
+	// GetRefundedTotal возвращает сумму успешных и ожидающих возвратов по платежу
+	GetRefundedTotal(paymentID int64) (float64, error)
+}
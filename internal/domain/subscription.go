@@ -1,6 +1,9 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 // Subscription представляет подписку пользователя
 type Subscription struct {
@@ -18,9 +21,34 @@ type Subscription struct {
 	YKCustomerID      *string    `json:"yk_customer_id"`
 	YKPaymentMethodID *string    `json:"yk_payment_method_id"`
 	YKLastPaymentID   *string    `json:"yk_last_payment_id"`
+	PaymentProvider   string     `json:"payment_provider"` // Провайдер, у которого сохранен способ оплаты для автопродления
 	FailedAttempts    int        `json:"failed_attempts"`
 	NextRetry         *time.Time `json:"next_retry,omitempty"`
 	SuspendedAt       *time.Time `json:"suspended_at,omitempty"`
+	PausedAt          *time.Time `json:"paused_at,omitempty"`     // Когда пользователь поставил подписку на паузу
+	PauseUntil        *time.Time `json:"pause_until,omitempty"`   // Когда пауза закончится и подписка возобновится
+	TrialEndsAt       *time.Time `json:"trial_ends_at,omitempty"` // Конец пробного периода; nil — подписка оформлена без него
+	StatusReason      string     `json:"-"`                       // Причина последнего перехода статуса для истории (см. Transition)
+
+	loaded *Subscription // Состояние на момент чтения из базы (см. MarkLoaded)
+}
+
+// MarkLoaded запоминает состояние подписки, прочитанное из базы. Репозиторий при сохранении
+// записывает только поля, измененные после этого, и не затирает параллельные изменения.
+func (s *Subscription) MarkLoaded() {
+	loaded := *s
+	loaded.loaded = nil
+	s.loaded = &loaded
+}
+
+// Loaded возвращает состояние подписки на момент чтения из базы; nil — подписка не читалась из базы
+func (s *Subscription) Loaded() *Subscription {
+	return s.loaded
+}
+
+// InTrial проверяет, идет ли пробный период: после его начала еще не было оплаты продления
+func (s *Subscription) InTrial() bool {
+	return s.TrialEndsAt != nil && s.LastPayment.Before(*s.TrialEndsAt)
 }
 
 // SubscriptionStatus представляет статусы подписки
@@ -32,16 +60,76 @@ const (
 	SubscriptionStatusCancelled SubscriptionStatus = "cancelled"
 	SubscriptionStatusExpired   SubscriptionStatus = "expired"
 	SubscriptionStatusSuspended SubscriptionStatus = "suspended"
+	SubscriptionStatusPaused    SubscriptionStatus = "paused" // Пользователь взял паузу: списаний и Premium нет до PauseUntil
 )
 
 // Tariff представляет тариф подписки
 type Tariff struct {
-	ID          string   `json:"id"`
-	Name        string   `json:"name"`
-	Price       float64  `json:"price"`
-	Period      string   `json:"period"` // "month", "year", "week", "day", "hour", "minute"
-	Description string   `json:"description"`
-	Features    []string `json:"features"`
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	Price       float64               `json:"price"`
+	Currency    string                `json:"currency"`
+	Period      string                `json:"period"` // "month", "year", "week", "day"
+	Description string                `json:"description"`
+	Features    []string              `json:"features"`
+	Quotas      map[QuotaResource]int `json:"quotas"`  // Лимиты на период; отсутствующий ресурс не ограничен
+	Visible     bool                  `json:"visible"` // Показывается ли тариф при покупке
+	SortOrder   int                   `json:"sort_order"`
+	Provider    string                `json:"provider,omitempty"` // Платежный провайдер тарифа; пусто — провайдер по умолчанию
+	// RetryHours интервалы в часах между повторными списаниями после неудачного продления;
+	// после последнего интервала подписка приостанавливается. Пусто — расписание по умолчанию.
+	RetryHours []int `json:"retry_hours,omitempty"`
+}
+
+// FreeTariffID тариф пользователей без подписки: задает бесплатные лимиты
+const FreeTariffID = "free"
+
+// PremiumTariffID тариф, лимиты которого действуют для Premium, выданного администратором
+const PremiumTariffID = "premium"
+
+// Периоды оплаты тарифов
+const (
+	TariffPeriodDay   = "day"
+	TariffPeriodWeek  = "week"
+	TariffPeriodMonth = "month"
+	TariffPeriodYear  = "year"
+)
+
+// IsValidTariffPeriod проверяет, поддерживается ли период оплаты
+func IsValidTariffPeriod(period string) bool {
+	switch period {
+	case TariffPeriodDay, TariffPeriodWeek, TariffPeriodMonth, TariffPeriodYear:
+		return true
+	}
+	return false
+}
+
+// AddMonths сдвигает дату на months месяцев, сохраняя время суток. Если в месяце нет такого дня
+// (31-е, 29 февраля), берется последний день месяца, а не первые дни следующего, как у time.AddDate.
+// По этим правилам считаются и даты списаний, и периоды квот, чтобы они не расходились.
+func AddMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	day := t.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// QuotaLimit возвращает лимит ресурса на период или UnlimitedQuota, если ресурс не ограничен
+func (t *Tariff) QuotaLimit(resource QuotaResource) int {
+	if limit, ok := t.Quotas[resource]; ok {
+		return limit
+	}
+	return UnlimitedQuota
+}
+
+// TariffRepository интерфейс для работы с каталогом тарифов
+type TariffRepository interface {
+	GetAll() ([]*Tariff, error)
+	GetVisible() ([]*Tariff, error) // Тарифы, доступные для покупки, в порядке sort_order
+	GetByID(id string) (*Tariff, error)
+	Save(tariff *Tariff) error // Создает тариф или обновляет существующий
 }
 
 // SubscriptionRepository интерфейс для работы с подписками
@@ -55,14 +143,40 @@ type SubscriptionRepository interface {
 	UpdateNextPayment(userID int64, nextPayment time.Time) error
 	Cancel(userID int64) error
 	GetActiveSubscriptions() ([]*Subscription, error)
-	UpdateYooKassaBindings(userID int64, customerID, paymentMethodID, lastPaymentID string) error
-	GetSubscriptionsDueForRenewal() ([]*Subscription, error)     // Получает подписки для продления
-	GetSubscriptionsDueForRetry() ([]*Subscription, error)       // Получает подписки для повторной попытки
-	IncrementFailedAttempts(userID int64) error                  // Увеличивает счетчик неудачных попыток
-	SuspendSubscription(userID int64) error                      // Приостанавливает подписку
-	GetAllActiveSubscriptions() ([]*Subscription, error)         // Получает все активные подписки для диагностики
-	GetUserPaymentHistory(userID int64) ([]*Subscription, error) // Получает историю всех платежей пользователя
-	CancelExpired(userID int64) error                            // Полностью отменяет подписку когда период истек
+	UpdatePaymentBinding(userID int64, provider, customerID, paymentMethodID, lastPaymentID string) error
+	GetSubscriptionsDueForRenewal() ([]*Subscription, error) // Получает подписки для продления
+	GetSubscriptionsDueForRetry() ([]*Subscription, error)   // Получает подписки для повторной попытки
+	IncrementFailedAttempts(userID int64) error              // Увеличивает счетчик неудачных попыток
+	SuspendSubscription(userID int64) error                  // Приостанавливает подписку
+	GetAllActiveSubscriptions() ([]*Subscription, error)     // Получает все активные подписки для диагностики
+	CancelExpired(userID int64) error                        // Полностью отменяет подписку когда период истек
+	Revoke(userID int64) error                               // Немедленно завершает подписку после возврата оплаты
+	// GetSubscriptionsRenewingBefore подписки с автопродлением, списание по которым наступит до until
+	GetSubscriptionsRenewingBefore(until time.Time) ([]*Subscription, error)
+	// GetSubscriptionEvents последние переходы статусов подписок пользователя, новые первыми
+	GetSubscriptionEvents(userID int64, limit int) ([]*SubscriptionEvent, error)
+	// GetSubscriptionsPausedUntil подписки на паузе, которая заканчивается не позже until
+	GetSubscriptionsPausedUntil(until time.Time) ([]*Subscription, error)
+	// CountPausesSince сколько раз пользователь ставил подписку на паузу начиная с since
+	CountPausesSince(userID int64, since time.Time) (int, error)
+	// HasSubscriptionHistory была ли у пользователя подписка или пробный период (кроме неоплаченных pending)
+	HasSubscriptionHistory(userID int64) (bool, error)
+	// ClaimRenewal захватывает подписку для списания на ttl и возвращает номер попытки списания.
+	// Возвращает false, если подписку списывает другой экземпляр воркера или ее уже продлили.
+	ClaimRenewal(subscription *Subscription, ttl time.Duration) (int, bool, error)
+	// ReleaseRenewal освобождает захват после списания
+	ReleaseRenewal(subscriptionID int64) error
+}
+
+// ErrRenewalInProgress возвращается, если подписку уже списывает другой экземпляр воркера
+// или списание по прочитанному состоянию подписки уже проведено
+var ErrRenewalInProgress = errors.New("subscription renewal is already in progress")
+
+// SubscriptionNoticeRepository журнал напоминаний о продлении, отправленных пользователям
+type SubscriptionNoticeRepository interface {
+	// MarkNoticeSent отмечает напоминание вида kind о списании dueAt.
+	// Возвращает false, если такое напоминание уже отправлялось.
+	MarkNoticeSent(subscriptionID int64, kind string, dueAt time.Time) (bool, error)
 }
 
 // SubscriptionService интерфейс для бизнес-логики подписок
@@ -74,14 +188,15 @@ type SubscriptionService interface {
 	IsUserSubscribed(userID int64) (bool, error)
 	GetUserTariff(userID int64) (string, error)
 	CreateSubscriptionLink(userID int64, tariff string, amount float64) (string, error)
-	SaveYooKassaBindingAndActivate(userID int64, customerID, paymentMethodID, paymentID string, amount float64) error
+	SavePaymentBindingAndActivate(userID int64, provider, customerID, paymentMethodID, paymentID string, amount float64) error
 	GetSubscriptionsDueForRenewal() ([]*Subscription, error)
 	ProcessRecurringPayment(subscription *Subscription) error
 	GetAvailableTariffs() []Tariff
+	GetTariff(id string) (*Tariff, error)
 	GetSubscriptionsDueForRetry() ([]*Subscription, error)
-	GetAllActiveSubscriptions() ([]*Subscription, error)         // Получает все активные подписки для диагностики
-	GetUserPaymentHistory(userID int64) ([]*Subscription, error) // Получает историю всех платежей пользователя
-	RetryPayment(userID int64) error                             // Повторная попытка списания с текущего метода
-	ChangePaymentMethod(userID int64) (string, error)            // Смена метода оплаты
-	CancelExpiredSubscription(userID int64) error                // Полная отмена истекшей отмененной подписки
+	GetAllActiveSubscriptions() ([]*Subscription, error)    // Получает все активные подписки для диагностики
+	GetUserPaymentHistory(userID int64) ([]*Payment, error) // Последние платежи пользователя из журнала
+	RetryPayment(userID int64) error                        // Повторная попытка списания с текущего метода
+	ChangePaymentMethod(userID int64) (string, error)       // Смена метода оплаты
+	CancelExpiredSubscription(userID int64) error           // Полная отмена подписки, доступ по которой закончился
 }
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidTransition переход между статусами подписки запрещен машиной состояний
+var ErrInvalidTransition = errors.New("invalid subscription status transition")
+
+// ErrSubscriptionConflict статус подписки изменился с момента чтения: переход рассчитан по устаревшему состоянию
+var ErrSubscriptionConflict = errors.New("subscription changed concurrently")
+
+// ErrPauseNotAllowed подписку сейчас нельзя поставить на паузу: нет автопродления, идут повторные списания или она не активна
+var ErrPauseNotAllowed = errors.New("subscription pause not allowed")
+
+// ErrPauseLimitReached исчерпан лимит пауз за последние 12 месяцев
+var ErrPauseLimitReached = errors.New("subscription pause limit reached")
+
+// subscriptionTransitions разрешенные переходы между статусами подписки:
+//
+//	pending   → active (первая оплата), expired (оплата не состоялась)
+//	active    → cancelled (отмена автопродления), suspended (не прошли повторные списания),
+//	            paused (пауза по просьбе пользователя), expired
+//	cancelled → active (повторная оплата), expired (закончился оплаченный период)
+//	suspended → active (оплата новой картой), expired
+//	paused    → active (пауза закончилась или снята досрочно), expired
+//
+// expired — конечный статус: новая подписка оформляется новой записью.
+var subscriptionTransitions = map[SubscriptionStatus][]SubscriptionStatus{
+	SubscriptionStatusPending:   {SubscriptionStatusActive, SubscriptionStatusExpired},
+	SubscriptionStatusActive:    {SubscriptionStatusCancelled, SubscriptionStatusSuspended, SubscriptionStatusPaused, SubscriptionStatusExpired},
+	SubscriptionStatusCancelled: {SubscriptionStatusActive, SubscriptionStatusExpired},
+	SubscriptionStatusSuspended: {SubscriptionStatusActive, SubscriptionStatusExpired},
+	SubscriptionStatusPaused:    {SubscriptionStatusActive, SubscriptionStatusExpired},
+}
+
+// IsLive проверяет, действует ли подписка в этом статусе (поле Subscription.Active).
+// Подписка на паузе действует, но доступа к Premium не дает.
+func (s SubscriptionStatus) IsLive() bool {
+	return s == SubscriptionStatusActive || s == SubscriptionStatusCancelled || s == SubscriptionStatusPaused
+}
+
+// ValidateTransition проверяет, что подписку можно перевести из статуса from в статус to.
+// Переход в тот же статус (например, продление активной подписки) разрешен.
+func ValidateTransition(from, to SubscriptionStatus) error {
+	if from == to {
+		return nil
+	}
+	for _, allowed := range subscriptionTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s → %s", ErrInvalidTransition, from, to)
+}
+
+// SubscriptionEvent запись истории статусов подписки
+type SubscriptionEvent struct {
+	ID             int64              `json:"id"`
+	SubscriptionID int64              `json:"subscription_id"`
+	UserID         int64              `json:"user_id"`
+	FromStatus     SubscriptionStatus `json:"from_status"` // Пусто — подписка создана
+	ToStatus       SubscriptionStatus `json:"to_status"`
+	Reason         string             `json:"reason"`
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+// Transition переводит подписку в статус to, если переход разрешен машиной состояний:
+// выставляет Active и отметки времени статуса и запоминает причину для истории.
+// Для перехода в тот же статус ничего не меняет и возвращает nil.
+func (s *Subscription) Transition(to SubscriptionStatus, reason string, at time.Time) (*SubscriptionEvent, error) {
+	from := SubscriptionStatus(s.Status)
+	if from == to {
+		return nil, nil
+	}
+	if err := ValidateTransition(from, to); err != nil {
+		return nil, err
+	}
+
+	s.Status = string(to)
+	s.Active = to.IsLive()
+	s.StatusReason = reason
+	switch to {
+	case SubscriptionStatusActive:
+		s.SuspendedAt = nil
+		s.PausedAt = nil
+		s.PauseUntil = nil
+	case SubscriptionStatusCancelled:
+		s.CancelledAt = &at
+	case SubscriptionStatusSuspended:
+		s.SuspendedAt = &at
+	case SubscriptionStatusPaused:
+		s.PausedAt = &at
+	}
+
+	return &SubscriptionEvent{
+		SubscriptionID: s.ID,
+		UserID:         s.UserID,
+		FromStatus:     from,
+		ToStatus:       to,
+		Reason:         reason,
+		CreatedAt:      at,
+	}, nil
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateTransition(t *testing.T) {
+	cases := []struct {
+		from, to SubscriptionStatus
+		allowed  bool
+	}{
+		{SubscriptionStatusPending, SubscriptionStatusActive, true},
+		{SubscriptionStatusPending, SubscriptionStatusCancelled, false},
+		{SubscriptionStatusActive, SubscriptionStatusActive, true},
+		{SubscriptionStatusActive, SubscriptionStatusCancelled, true},
+		{SubscriptionStatusActive, SubscriptionStatusSuspended, true},
+		{SubscriptionStatusActive, SubscriptionStatusPending, false},
+		{SubscriptionStatusCancelled, SubscriptionStatusActive, true},
+		{SubscriptionStatusCancelled, SubscriptionStatusSuspended, false},
+		{SubscriptionStatusSuspended, SubscriptionStatusActive, true},
+		{SubscriptionStatusSuspended, SubscriptionStatusCancelled, false},
+		{SubscriptionStatusExpired, SubscriptionStatusActive, false},
+	}
+	for _, tc := range cases {
+		err := ValidateTransition(tc.from, tc.to)
+		if tc.allowed && err != nil {
+			t.Errorf("%s → %s: переход должен быть разрешен: %v", tc.from, tc.to, err)
+		}
+		if !tc.allowed && !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("%s → %s: ожидался ErrInvalidTransition, получено %v", tc.from, tc.to, err)
+		}
+	}
+}
+
+func TestSubscriptionTransition(t *testing.T) {
+	at := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	subscription := &Subscription{ID: 7, UserID: 42, Status: string(SubscriptionStatusActive), Active: true}
+
+	event, err := subscription.Transition(SubscriptionStatusSuspended, "retries exhausted", at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subscription.Active || subscription.SuspendedAt == nil || !subscription.SuspendedAt.Equal(at) {
+		t.Errorf("Приостановленная подписка не действует и хранит время приостановки: %+v", subscription)
+	}
+	if event.FromStatus != SubscriptionStatusActive || event.ToStatus != SubscriptionStatusSuspended || event.SubscriptionID != 7 {
+		t.Errorf("Неверная запись истории: %+v", event)
+	}
+
+	if _, err := subscription.Transition(SubscriptionStatusCancelled, "", at); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Приостановленную подписку нельзя отменить: %v", err)
+	}
+	if subscription.Status != string(SubscriptionStatusSuspended) {
+		t.Errorf("Запрещенный переход не должен менять статус: %s", subscription.Status)
+	}
+
+	if event, err := subscription.Transition(SubscriptionStatusActive, "paid", at); err != nil || event == nil {
+		t.Fatalf("Оплата восстанавливает приостановленную подписку: %v", err)
+	}
+	if !subscription.Active || subscription.SuspendedAt != nil {
+		t.Errorf("Восстановленная подписка действует: %+v", subscription)
+	}
+}
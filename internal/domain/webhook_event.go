@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+// WebhookEventStatus состояние обработки входящего уведомления провайдера.
+// Переходы: received → processing → processed | ignored | failed; failed → processing при повторной доставке.
+type WebhookEventStatus string
+
+const (
+	WebhookEventReceived   WebhookEventStatus = "received"
+	WebhookEventProcessing WebhookEventStatus = "processing"
+	WebhookEventProcessed  WebhookEventStatus = "processed"
+	WebhookEventIgnored    WebhookEventStatus = "ignored" // Событие не требует действий
+	WebhookEventFailed     WebhookEventStatus = "failed"  // Провайдер повторит доставку
+)
+
+// IsFinal проверяет, завершена ли обработка события: повторная доставка ничего не меняет
+func (s WebhookEventStatus) IsFinal() bool {
+	return s == WebhookEventProcessed || s == WebhookEventIgnored
+}
+
+// WebhookEvent входящее уведомление провайдера; EventKey уникален для пары событие+объект
+type WebhookEvent struct {
+	ID          int64              `json:"id"`
+	Provider    string             `json:"provider"`
+	EventKey    string             `json:"event_key"`
+	EventType   string             `json:"event_type"`
+	ObjectID    string             `json:"object_id"`
+	Payload     []byte             `json:"-"`
+	Status      WebhookEventStatus `json:"status"`
+	Attempts    int                `json:"attempts"`
+	LastError   string             `json:"last_error,omitempty"`
+	ReceivedAt  time.Time          `json:"received_at"`
+	ProcessedAt *time.Time         `json:"processed_at,omitempty"`
+}
+
+// WebhookEventRepository интерфейс журнала входящих уведомлений
+type WebhookEventRepository interface {
+	// Receive сохраняет событие или возвращает уже сохраненное с тем же ключом
+	Receive(event *WebhookEvent) (*WebhookEvent, error)
+	// Claim переводит событие в processing. Возвращает false, если событие уже обработано
+	// или его обрабатывает другой запрос.
+	Claim(id int64) (bool, error)
+	Finish(id int64, status WebhookEventStatus, lastError string) error
+}
@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"log"
+
+	"ai_tg_writer/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// RecordAcquisition сохраняет источник, по которому пользователь впервые открыл бота.
+// Пользователь создается здесь, чтобы первое касание записалось до любых других действий.
+func (b *Bot) RecordAcquisition(from *tgbotapi.User, acquisition domain.Acquisition) {
+	if b.Acquisitions == nil {
+		return
+	}
+	if _, err := b.DB.GetOrCreateUser(from.ID, from.UserName, from.FirstName, from.LastName); err != nil {
+		log.Printf("❌ Ошибка создания пользователя %d: %v", from.ID, err)
+		return
+	}
+	if _, err := b.Acquisitions.Record(from.ID, acquisition); err != nil {
+		log.Printf("❌ Ошибка сохранения источника пользователя %d: %v", from.ID, err)
+	}
+}
+
+// ParseStartPayload разбирает параметр /start. Параметр без префикса считается реферальной
+// ссылкой, только если такой реферальный код существует; иначе переход органический.
+func (b *Bot) ParseStartPayload(arg string) domain.StartPayload {
+	payload := domain.ParseStartPayload(arg)
+	if b.Referrals == nil {
+		return payload
+	}
+	resolved, err := payload.ResolveReferral(b.Referrals.ReferrerByCode)
+	if err != nil {
+		log.Printf("❌ Ошибка проверки реферального кода %q: %v", payload.Code, err)
+	}
+	return resolved
+}
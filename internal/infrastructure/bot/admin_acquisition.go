@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/service"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultAcquisitionReportDays период отчета /acquisition по умолчанию
+const defaultAcquisitionReportDays = 30
+
+// registerAcquisitionCommands добавляет команды отчетов по источникам привлечения
+func (ah *AdminHandler) registerAcquisitionCommands() {
+	ah.commands["acquisition"] = adminCommand{"/acquisition [дни]",
+		"Источники привлечения: регистрации, первое создание и первая оплата по кампаниям (CSV)", 0, ah.handleAcquisitionReport}
+}
+
+func (ah *AdminHandler) handleAcquisitionReport(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	if bot.Acquisitions == nil {
+		return nil, fmt.Errorf("источники привлечения не настроены")
+	}
+	days := defaultAcquisitionReportDays
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("некорректное количество дней: %s", args[0])
+		}
+		days = n
+	}
+	now := time.Now()
+	funnel, err := bot.Acquisitions.Funnel(now.AddDate(0, 0, -days))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения источников: %w", err)
+	}
+	if len(funnel) == 0 {
+		return &adminResult{text: fmt.Sprintf("📭 За %d дн. новых пользователей не было", days)}, nil
+	}
+
+	var csv bytes.Buffer
+	if err := service.WriteAcquisitionCSV(&csv, funnel); err != nil {
+		return nil, fmt.Errorf("ошибка выгрузки CSV: %w", err)
+	}
+	document := tgbotapi.NewDocument(message.Chat.ID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("acquisition_%s_%dd.csv", now.Format("2006-01-02"), days),
+		Bytes: csv.Bytes(),
+	})
+	if _, err := bot.Send(document); err != nil {
+		return nil, fmt.Errorf("ошибка отправки CSV: %w", err)
+	}
+
+	// В сообщении — итоги по источникам, детализация по кампаниям в CSV
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📣 Источники привлечения за %d дн.:\n", days))
+	var totals []*domain.AcquisitionStats
+	bySource := make(map[string]*domain.AcquisitionStats)
+	for _, s := range funnel {
+		total, ok := bySource[s.Source]
+		if !ok {
+			total = &domain.AcquisitionStats{Acquisition: domain.Acquisition{Source: s.Source}}
+			bySource[s.Source] = total
+			totals = append(totals, total)
+		}
+		total.Signups += s.Signups
+		total.FirstGeneration += s.FirstGeneration
+		total.FirstPayment += s.FirstPayment
+		total.Revenue += s.Revenue
+	}
+	for _, t := range totals {
+		sb.WriteString(fmt.Sprintf("\n%s: 👤 %d, ✍️ %d, 💳 %d — %.0f₽", t.Source, t.Signups, t.FirstGeneration, t.FirstPayment, t.Revenue))
+	}
+	sb.WriteString("\n\n👤 новые пользователи, ✍️ создали пост, 💳 оплатили")
+	return &adminResult{text: sb.String(), details: fmt.Sprintf("days=%d", days)}, nil
+}
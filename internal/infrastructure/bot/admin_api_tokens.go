@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ai_tg_writer/internal/infrastructure/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// registerAPITokenCommands добавляет команды управления токенами административного API
+func (ah *AdminHandler) registerAPITokenCommands() {
+	ah.commands["api_token"] = adminCommand{"/api_token <название> <viewer|operator|admin>",
+		"Выпустить токен для REST API", 2, ah.handleAPITokenCreate}
+	ah.commands["api_tokens"] = adminCommand{"/api_tokens",
+		"Список токенов REST API", 0, ah.handleAPITokenList}
+	ah.commands["api_token_revoke"] = adminCommand{"/api_token_revoke <id>",
+		"Отозвать токен REST API", 1, ah.handleAPITokenRevoke}
+}
+
+func (ah *AdminHandler) handleAPITokenCreate(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	name := args[0]
+	role, err := database.ParseAdminRole(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("неизвестная роль: %s", args[1])
+	}
+
+	token, hash, err := database.GenerateAdminAPIToken()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+	id, err := bot.DB.CreateAdminAPIToken(name, role, hash, message.From.ID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сохранения токена: %w", err)
+	}
+
+	// Сам токен не сохраняется и не попадает в журнал: показываем его один раз
+	return &adminResult{
+		text: fmt.Sprintf("🔑 Токен #%d «%s» (%s):\n\n%s\n\nСохраните его: повторно показать токен нельзя.\n"+
+			"Использование: Authorization: Bearer <токен>", id, name, role, token),
+		details: fmt.Sprintf("token=%d name=%s role=%s", id, name, role),
+	}, nil
+}
+
+func (ah *AdminHandler) handleAPITokenList(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	tokens, err := bot.DB.ListAdminAPITokens()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения токенов: %w", err)
+	}
+	if len(tokens) == 0 {
+		return &adminResult{text: "📭 Токенов REST API нет"}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🔑 Токены REST API:\n")
+	for _, token := range tokens {
+		sb.WriteString(fmt.Sprintf("\n#%d %s (%s), выпустил %d", token.ID, token.Name, token.Role, token.CreatedBy))
+		if token.LastUsedAt != nil {
+			sb.WriteString(", использован " + token.LastUsedAt.Format("02.01.2006 15:04"))
+		}
+		if token.RevokedAt != nil {
+			sb.WriteString(" — отозван")
+		}
+	}
+	return &adminResult{text: sb.String()}, nil
+}
+
+func (ah *AdminHandler) handleAPITokenRevoke(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный ID токена: %s", args[0])
+	}
+	revoked, err := bot.DB.RevokeAdminAPIToken(id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка отзыва токена: %w", err)
+	}
+	if !revoked {
+		return nil, fmt.Errorf("действующий токен #%d не найден", id)
+	}
+	return &adminResult{text: fmt.Sprintf("✅ Токен #%d отозван", id), details: fmt.Sprintf("token=%d", id)}, nil
+}
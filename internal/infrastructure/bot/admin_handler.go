@@ -0,0 +1,591 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/infrastructure/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	defaultAdminListLimit    = 5
+	maxAdminListLimit        = 50
+	defaultPaymentReportDays = 30
+	adminSubscriptionEvents  = 5    // Сколько последних переходов статуса показывать в /user
+	maxAdminMessageLength    = 3800 // Длинный ответ разбивается на части меньше лимита Telegram в 4096 символов
+)
+
+// adminResult результат выполнения админ-команды
+type adminResult struct {
+	text    string
+	target  *int64 // пользователь, над которым выполнено действие
+	details string // подробности для журнала
+}
+
+// adminCommand описание админ-команды
+type adminCommand struct {
+	usage       string
+	description string
+	minArgs     int
+	run         func(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error)
+}
+
+// AdminHandler обрабатывает команды администраторов
+type AdminHandler struct {
+	postHistoryRepo *database.PostHistoryRepository
+	broadcastRepo   *database.BroadcastRepository
+	commands        map[string]adminCommand
+}
+
+// NewAdminHandler создает новый обработчик админ-команд
+func NewAdminHandler(postHistoryRepo *database.PostHistoryRepository, broadcastRepo *database.BroadcastRepository) *AdminHandler {
+	ah := &AdminHandler{postHistoryRepo: postHistoryRepo, broadcastRepo: broadcastRepo}
+	ah.commands = map[string]adminCommand{
+		"user":           {"/user <id|@username>", "Информация о пользователе и подписке", 1, ah.handleUserInfo},
+		"posts":          {"/posts <id|@username> [N]", "Последние посты пользователя", 1, ah.handleUserPosts},
+		"reset_limits":   {"/reset_limits <id|@username>", "Сбросить лимиты за текущий месяц", 1, ah.handleResetLimits},
+		"grant_quota":    {"/grant_quota <id|@username> <N> [generations|rewrites|edits|audio_minutes]", "Выдать N единиц ресурса на месяц", 2, ah.handleGrantQuota},
+		"grant_premium":  {"/grant_premium <id|@username> <дни>", "Выдать Premium на N дней", 2, ah.handleGrantPremium},
+		"revoke_premium": {"/revoke_premium <id|@username>", "Отозвать выданный Premium", 1, ah.handleRevokePremium},
+		"add_admin":      {"/add_admin <id|@username>", "Назначить администратора", 1, ah.handleAddAdmin},
+		"remove_admin":   {"/remove_admin <id|@username>", "Снять права администратора", 1, ah.handleRemoveAdmin},
+		"ban":            {"/ban <id|@username> [причина]", "Заблокировать доступ к боту", 1, ah.handleBan},
+		"unban":          {"/unban <id|@username>", "Разблокировать доступ к боту", 1, ah.handleUnban},
+		"audit":          {"/audit [N]", "Журнал действий администраторов", 0, ah.handleAudit},
+		"payments":       {"/payments [дни]", "Платежи и выручка за N дней (по умолчанию 30)", 0, ah.handlePaymentReport},
+		"refund":         {"/refund <ID платежа> [сумма] [причина]", "Вернуть платеж полностью или частично", 1, ah.handleRefund},
+		"user_provider":  {"/user_provider <id|@username> <yookassa|prodamus|default>", "Закрепить платежного провайдера за пользователем", 2, ah.handleUserProvider},
+	}
+	ah.registerBroadcastCommands()
+	ah.registerAPITokenCommands()
+	ah.registerTariffCommands()
+	ah.registerPromoCommands()
+	ah.registerAcquisitionCommands()
+	return ah
+}
+
+// HandleCommand обрабатывает админ-команду.
+// Возвращает true, если команда относится к админ-панели.
+func (ah *AdminHandler) HandleCommand(bot *Bot, message *tgbotapi.Message) bool {
+	name := message.Command()
+	command, ok := ah.commands[name]
+	if !ok && name != "admin" {
+		return false
+	}
+
+	adminID := message.From.ID
+	isAdmin, err := bot.DB.IsAdmin(adminID)
+	if err != nil {
+		log.Printf("Ошибка проверки прав администратора: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "❌ Произошла ошибка при проверке прав доступа"))
+		return true
+	}
+	if !isAdmin {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "⛔ У вас нет прав администратора"))
+		return true
+	}
+
+	if name == "admin" {
+		ah.reply(bot, message.Chat.ID, ah.helpText())
+		return true
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) < command.minArgs {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "ℹ️ Использование: "+command.usage))
+		return true
+	}
+
+	result, err := command.run(bot, message, args)
+	if err != nil {
+		log.Printf("⚠️ Админ %d: /%s %s: %v", adminID, name, strings.Join(args, " "), err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "❌ "+err.Error()))
+		return true
+	}
+
+	if err := bot.DB.LogAdminAction(adminID, name, result.target, result.details); err != nil {
+		log.Printf("❌ Ошибка записи в журнал действий администратора %d: %v", adminID, err)
+	}
+	log.Printf("🛠 Админ %d выполнил /%s %s", adminID, name, strings.Join(args, " "))
+
+	ah.reply(bot, message.Chat.ID, result.text)
+	return true
+}
+
+// reply отправляет ответ на админ-команду; длинные списки (/posts, /audit) отправляются частями
+func (ah *AdminHandler) reply(bot *Bot, chatID int64, text string) {
+	for _, part := range splitText(text, maxAdminMessageLength) {
+		if _, err := bot.Send(tgbotapi.NewMessage(chatID, part)); err != nil {
+			log.Printf("❌ Ошибка отправки ответа администратору %d: %v", chatID, err)
+			return
+		}
+	}
+}
+
+// helpText возвращает список доступных админ-команд
+func (ah *AdminHandler) helpText() string {
+	names := make([]string, 0, len(ah.commands))
+	for name := range ah.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("🛠 Админ-панель\n\nДоступные команды:\n")
+	for _, name := range names {
+		command := ah.commands[name]
+		sb.WriteString(fmt.Sprintf("%s - %s\n", command.usage, command.description))
+	}
+	return sb.String()
+}
+
+// findUser ищет пользователя по ID или @username
+func (ah *AdminHandler) findUser(bot *Bot, ref string) (*database.AdminUserInfo, error) {
+	user, err := bot.DB.FindUser(ref)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска пользователя: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("пользователь %s не найден", ref)
+	}
+	return user, nil
+}
+
+// parseLimit разбирает необязательный аргумент количества записей
+func parseLimit(args []string, index int) (int, error) {
+	if len(args) <= index {
+		return defaultAdminListLimit, nil
+	}
+	limit, err := strconv.Atoi(args[index])
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("некорректное количество: %s", args[index])
+	}
+	if limit > maxAdminListLimit {
+		limit = maxAdminListLimit
+	}
+	return limit, nil
+}
+
+// userLabel возвращает читаемое имя пользователя
+func userLabel(user *database.AdminUserInfo) string {
+	if user.Username != "" {
+		return fmt.Sprintf("%d (@%s)", user.ID, user.Username)
+	}
+	return strconv.FormatInt(user.ID, 10)
+}
+
+func (ah *AdminHandler) handleUserInfo(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	user, err := ah.findUser(bot, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("👤 Пользователь %s\n", userLabel(user)))
+	sb.WriteString(fmt.Sprintf("Имя: %s %s\n", user.FirstName, user.LastName))
+	if user.Email != "" {
+		sb.WriteString(fmt.Sprintf("E-mail: %s\n", user.Email))
+	}
+	sb.WriteString(fmt.Sprintf("Зарегистрирован: %s\n", user.CreatedAt.Format("02.01.2006 15:04")))
+	sb.WriteString(fmt.Sprintf("Администратор: %v\n", user.IsAdmin || database.IsEnvAdmin(user.ID)))
+	if user.IsBanned {
+		sb.WriteString("Доступ: ⛔ заблокирован")
+		if user.BannedAt != nil {
+			sb.WriteString(" с " + user.BannedAt.Format("02.01.2006 15:04"))
+		}
+		sb.WriteString("\n")
+	}
+	if user.BotBlocked {
+		sb.WriteString("Бот заблокирован пользователем: да\n")
+	}
+	if user.PremiumUntil != nil && time.Now().UTC().Before(*user.PremiumUntil) {
+		sb.WriteString(fmt.Sprintf("Выданный Premium до: %s\n", user.PremiumUntil.Format("02.01.2006 15:04")))
+	}
+
+	if bot.QuotaService != nil {
+		quotas, err := bot.QuotaService.Usage(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения квот: %w", err)
+		}
+		if len(quotas) > 0 {
+			sb.WriteString(fmt.Sprintf("\n📊 Расход за период %s — %s (тариф %s):\n",
+				quotas[0].PeriodStart.Format("02.01.2006"), quotas[0].PeriodEnd.Format("02.01.2006"), quotas[0].TariffID))
+			sb.WriteString(formatQuotaUsage(quotas))
+		}
+	}
+
+	if bot.SubscriptionService != nil {
+		sub, err := bot.SubscriptionService.GetUserSubscription(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения подписки: %w", err)
+		}
+		if sub == nil {
+			sb.WriteString("\n💳 Подписка: нет")
+		} else {
+			sb.WriteString(fmt.Sprintf("\n💳 Подписка: %s, статус %s, %.2f ₽\n", sub.Tariff, sub.Status, sub.Amount))
+			sb.WriteString(fmt.Sprintf("Следующий платеж: %s\n", sub.NextPayment.Format("02.01.2006 15:04")))
+			if sub.FailedAttempts > 0 {
+				sb.WriteString(fmt.Sprintf("Неудачных попыток: %d\n", sub.FailedAttempts))
+			}
+			if sub.CancelledAt != nil {
+				sb.WriteString(fmt.Sprintf("Отменена: %s\n", sub.CancelledAt.Format("02.01.2006 15:04")))
+			}
+		}
+
+		events, err := bot.SubscriptionService.GetSubscriptionHistory(user.ID, adminSubscriptionEvents)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения истории подписки: %w", err)
+		}
+		if len(events) > 0 {
+			sb.WriteString("\n🗂 История статусов:\n")
+			for _, event := range events {
+				from := string(event.FromStatus)
+				if from == "" {
+					from = "—"
+				}
+				sb.WriteString(fmt.Sprintf("%s %s → %s", event.CreatedAt.Format("02.01.2006 15:04"), from, event.ToStatus))
+				if event.Reason != "" {
+					sb.WriteString(" (" + event.Reason + ")")
+				}
+				sb.WriteString("\n")
+			}
+		}
+	}
+
+	return &adminResult{text: sb.String(), target: &user.ID}, nil
+}
+
+func (ah *AdminHandler) handleUserPosts(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	user, err := ah.findUser(bot, args[0])
+	if err != nil {
+		return nil, err
+	}
+	limit, err := parseLimit(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	posts, err := ah.postHistoryRepo.GetUserPostHistory(user.ID, limit, 0)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения истории постов: %w", err)
+	}
+	if len(posts) == 0 {
+		return &adminResult{text: fmt.Sprintf("📭 У пользователя %s нет постов", userLabel(user)), target: &user.ID}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📝 Последние посты пользователя %s:\n", userLabel(user)))
+	for _, post := range posts {
+		saved := ""
+		if post.IsSaved {
+			saved = " 💾"
+		}
+		sb.WriteString(fmt.Sprintf("\n#%d %s (%s)%s\n%s\n", post.ID, post.CreatedAt.Format("02.01.2006 15:04"),
+			post.AIModel, saved, truncateText(post.AIResponse, 200)))
+	}
+
+	return &adminResult{text: sb.String(), target: &user.ID, details: fmt.Sprintf("limit=%d", limit)}, nil
+}
+
+func (ah *AdminHandler) handleResetLimits(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	user, err := ah.findUser(bot, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := bot.QuotaService.ResetUsage(user.ID); err != nil {
+		return nil, fmt.Errorf("ошибка сброса лимитов: %w", err)
+	}
+	return &adminResult{text: fmt.Sprintf("✅ Лимиты пользователя %s сброшены", userLabel(user)), target: &user.ID}, nil
+}
+
+func (ah *AdminHandler) handleGrantQuota(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	user, err := ah.findUser(bot, args[0])
+	if err != nil {
+		return nil, err
+	}
+	amount, err := strconv.Atoi(args[1])
+	if err != nil || amount <= 0 {
+		return nil, fmt.Errorf("некорректное количество: %s", args[1])
+	}
+	resource := domain.QuotaGenerations
+	if len(args) > 2 {
+		resource = domain.QuotaResource(strings.ToLower(args[2]))
+		if !domain.IsValidQuotaResource(resource) {
+			return nil, fmt.Errorf("неизвестный ресурс: %s", args[2])
+		}
+	}
+	if err := bot.DB.GrantQuota(user.ID, resource, amount, message.From.ID); err != nil {
+		return nil, fmt.Errorf("ошибка выдачи квоты: %w", err)
+	}
+
+	label := quotaResourceLabel(resource)
+	ah.notifyUser(bot, user.ID, fmt.Sprintf("🎁 Вам начислено %d дополнительных %s в этом месяце!", amount, label))
+	return &adminResult{
+		text:    fmt.Sprintf("✅ Пользователю %s выдано %d %s на текущий месяц", userLabel(user), amount, label),
+		target:  &user.ID,
+		details: fmt.Sprintf("resource=%s amount=%d", resource, amount),
+	}, nil
+}
+
+func (ah *AdminHandler) handleGrantPremium(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	user, err := ah.findUser(bot, args[0])
+	if err != nil {
+		return nil, err
+	}
+	days, err := strconv.Atoi(args[1])
+	if err != nil || days <= 0 || days > database.MaxPremiumGrantDays {
+		return nil, fmt.Errorf("некорректное количество дней: %s", args[1])
+	}
+
+	// Продлеваем уже выданный Premium, а не перезаписываем его
+	until, err := bot.DB.ExtendPremium(user.ID, days)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выдачи Premium: %w", err)
+	}
+
+	ah.notifyUser(bot, user.ID, fmt.Sprintf("🎁 Вам выдан Premium до %s!", until.Format("02.01.2006")))
+	return &adminResult{
+		text:    fmt.Sprintf("✅ Пользователю %s выдан Premium до %s", userLabel(user), until.Format("02.01.2006 15:04")),
+		target:  &user.ID,
+		details: fmt.Sprintf("days=%d until=%s", days, until.Format(time.RFC3339)),
+	}, nil
+}
+
+func (ah *AdminHandler) handleUserProvider(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	user, err := ah.findUser(bot, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if bot.PaymentProviders == nil {
+		return nil, fmt.Errorf("платежные провайдеры не настроены")
+	}
+	// default снимает закрепление: провайдер снова выбирается по тарифу
+	provider := strings.ToLower(args[1])
+	if provider == "default" {
+		provider = ""
+	}
+	if err := bot.PaymentProviders.SetUserProvider(user.ID, provider); err != nil {
+		return nil, fmt.Errorf("ошибка выбора провайдера (подключены: %s): %w",
+			strings.Join(bot.PaymentProviders.Names(), ", "), err)
+	}
+
+	text := fmt.Sprintf("✅ Пользователь %s оплачивает через %s", userLabel(user), provider)
+	if provider == "" {
+		text = fmt.Sprintf("✅ Провайдер пользователя %s выбирается по тарифу", userLabel(user))
+	}
+	return &adminResult{text: text, target: &user.ID, details: "provider=" + args[1]}, nil
+}
+
+func (ah *AdminHandler) handleRevokePremium(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	user, err := ah.findUser(bot, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := bot.DB.SetPremiumUntil(user.ID, nil); err != nil {
+		return nil, fmt.Errorf("ошибка отзыва Premium: %w", err)
+	}
+	return &adminResult{text: fmt.Sprintf("✅ Выданный Premium пользователя %s отозван", userLabel(user)), target: &user.ID}, nil
+}
+
+func (ah *AdminHandler) handleAddAdmin(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	user, err := ah.findUser(bot, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := bot.DB.SetUserAdmin(user.ID, true); err != nil {
+		return nil, fmt.Errorf("ошибка назначения администратора: %w", err)
+	}
+	return &adminResult{text: fmt.Sprintf("✅ Пользователь %s назначен администратором", userLabel(user)), target: &user.ID}, nil
+}
+
+func (ah *AdminHandler) handleRemoveAdmin(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	user, err := ah.findUser(bot, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if database.IsEnvAdmin(user.ID) {
+		return nil, fmt.Errorf("пользователь %s указан в ADMIN_TELEGRAM_IDS, права можно снять только через конфигурацию", userLabel(user))
+	}
+	if err := bot.DB.SetUserAdmin(user.ID, false); err != nil {
+		return nil, fmt.Errorf("ошибка снятия прав администратора: %w", err)
+	}
+	// Токены REST API, выпущенные бывшим администратором, перестают действовать вместе с его правами
+	revoked, err := bot.DB.RevokeAdminAPITokensCreatedBy(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("права сняты, но токены REST API не отозваны: %w", err)
+	}
+	text := fmt.Sprintf("✅ Пользователь %s больше не администратор", userLabel(user))
+	if revoked > 0 {
+		text += fmt.Sprintf("\n🔑 Отозвано токенов REST API: %d", revoked)
+	}
+	return &adminResult{text: text, target: &user.ID, details: fmt.Sprintf("revoked_tokens=%d", revoked)}, nil
+}
+
+func (ah *AdminHandler) handleBan(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	user, err := ah.findUser(bot, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == message.From.ID || user.IsAdmin || database.IsEnvAdmin(user.ID) {
+		return nil, fmt.Errorf("нельзя заблокировать администратора")
+	}
+	if err := bot.DB.SetUserBanned(user.ID, true); err != nil {
+		return nil, fmt.Errorf("ошибка блокировки пользователя: %w", err)
+	}
+	reason := strings.Join(args[1:], " ")
+	return &adminResult{
+		text:    fmt.Sprintf("⛔ Пользователь %s заблокирован", userLabel(user)),
+		target:  &user.ID,
+		details: reason,
+	}, nil
+}
+
+func (ah *AdminHandler) handleUnban(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	user, err := ah.findUser(bot, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := bot.DB.SetUserBanned(user.ID, false); err != nil {
+		return nil, fmt.Errorf("ошибка разблокировки пользователя: %w", err)
+	}
+	return &adminResult{text: fmt.Sprintf("✅ Пользователь %s разблокирован", userLabel(user)), target: &user.ID}, nil
+}
+
+func (ah *AdminHandler) handleAudit(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	limit, err := parseLimit(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := bot.DB.GetAdminAuditLog(limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения журнала: %w", err)
+	}
+	if len(entries) == 0 {
+		return &adminResult{text: "📭 Журнал действий пуст"}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📜 Журнал действий администраторов:\n")
+	for _, entry := range entries {
+		sb.WriteString(fmt.Sprintf("\n%s админ %d: /%s", entry.CreatedAt.Format("02.01.2006 15:04"), entry.AdminID, entry.Action))
+		if entry.TargetUserID != nil {
+			sb.WriteString(fmt.Sprintf(" → %d", *entry.TargetUserID))
+		}
+		if entry.Details != "" {
+			sb.WriteString(" (" + entry.Details + ")")
+		}
+	}
+	return &adminResult{text: sb.String(), details: fmt.Sprintf("limit=%d", limit)}, nil
+}
+
+func (ah *AdminHandler) handlePaymentReport(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	days := defaultPaymentReportDays
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("некорректное количество дней: %s", args[0])
+		}
+		days = n
+	}
+	totals, err := bot.SubscriptionService.GetPaymentTotals(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения платежей: %w", err)
+	}
+	if len(totals) == 0 {
+		return &adminResult{text: fmt.Sprintf("📭 За %d дн. платежей не было", days)}, nil
+	}
+
+	var sb strings.Builder
+	var revenue float64
+	var receiptsFailed int
+	sb.WriteString(fmt.Sprintf("💰 Платежи за %d дн.:\n", days))
+	for _, t := range totals {
+		kind, ok := paymentKindLabels[t.Kind]
+		if !ok {
+			kind = t.Kind
+		}
+		sb.WriteString(fmt.Sprintf("\n%s, %s: ✅ %d, ❌ %d, ⏳ %d, ↩️ %d — %.0f₽",
+			t.Provider, kind, t.Succeeded, t.Failed, t.Pending, t.Refunded, t.Revenue))
+		revenue += t.Revenue
+		receiptsFailed += t.ReceiptsFailed
+	}
+	sb.WriteString(fmt.Sprintf("\n\nВыручка: %.0f₽", revenue))
+	if receiptsFailed > 0 {
+		sb.WriteString(fmt.Sprintf("\n🧾 Чеков не зарегистрировано: %d — пробейте их вручную в личном кабинете YooKassa", receiptsFailed))
+	}
+	return &adminResult{text: sb.String(), details: fmt.Sprintf("days=%d", days)}, nil
+}
+
+func (ah *AdminHandler) handleRefund(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	if bot.RefundService == nil {
+		return nil, fmt.Errorf("возвраты не настроены")
+	}
+	payment, err := bot.RefundService.FindPayment(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска платежа: %w", err)
+	}
+	if payment == nil {
+		return nil, fmt.Errorf("платеж %s не найден", args[0])
+	}
+
+	// Сумма необязательна: без нее возвращается весь невозвращенный остаток
+	var amount float64
+	reasonArgs := args[1:]
+	if len(reasonArgs) > 0 {
+		if n, err := strconv.ParseFloat(strings.Replace(reasonArgs[0], ",", ".", 1), 64); err == nil {
+			if n <= 0 {
+				return nil, fmt.Errorf("некорректная сумма: %s", reasonArgs[0])
+			}
+			amount = n
+			reasonArgs = reasonArgs[1:]
+		}
+	}
+	reason := strings.Join(reasonArgs, " ")
+
+	outcome, err := bot.RefundService.Refund(payment, amount, message.From.ID, reason)
+	if err != nil {
+		return nil, fmt.Errorf("возврат не выполнен: %w", err)
+	}
+
+	text := fmt.Sprintf("↩️ Возврат %.2f₽ по платежу %d пользователя %d", outcome.Refund.Amount, payment.ID, payment.UserID)
+	switch {
+	case outcome.Refund.Status != domain.RefundStatusSucceeded:
+		text += " создан и ожидает подтверждения " + payment.Provider
+	case outcome.Downgraded:
+		text += " выполнен, подписка завершена"
+	case outcome.RevokedCredits > 0:
+		text += fmt.Sprintf(" выполнен, списано кредитов: %d", outcome.RevokedCredits)
+	case outcome.GiftCancelled:
+		text += " выполнен, подарок аннулирован"
+	default:
+		text += " выполнен"
+	}
+	return &adminResult{
+		text:    text,
+		target:  &payment.UserID,
+		details: fmt.Sprintf("payment=%d amount=%.2f refund=%d reason=%s", payment.ID, outcome.Refund.Amount, outcome.Refund.ID, reason),
+	}, nil
+}
+
+// notifyUser уведомляет пользователя о действии администратора
+func (ah *AdminHandler) notifyUser(bot *Bot, userID int64, text string) {
+	if _, err := bot.SendBulk(tgbotapi.NewMessage(userID, text)); err != nil {
+		log.Printf("⚠️ Не удалось уведомить пользователя %d: %v", userID, err)
+	}
+}
+
+// truncateText обрезает текст до указанного количества символов
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
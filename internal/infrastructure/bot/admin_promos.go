@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// promoOptionsUsage необязательные параметры команды /promo_create
+const promoOptionsUsage = "periods=N (оплат со скидкой, 0 — все; по умолчанию 1), uses=N (лимит использований), until=ДД.ММ.ГГГГ"
+
+// registerPromoCommands добавляет команды управления промокодами
+func (ah *AdminHandler) registerPromoCommands() {
+	ah.commands["promos"] = adminCommand{"/promos",
+		"Промокоды: использования, оплаты со скидкой и сумма скидок", 0, ah.handlePromoReport}
+	ah.commands["promo_create"] = adminCommand{"/promo_create <код> <percent|fixed> <скидка> [параметры]",
+		"Создать промокод, параметры: " + promoOptionsUsage, 3, ah.handlePromoCreate}
+	ah.commands["promo_off"] = adminCommand{"/promo_off <код>",
+		"Выключить промокод (примененные скидки сохраняются)", 1, ah.handlePromoOff}
+}
+
+func (ah *AdminHandler) handlePromoReport(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	stats, err := bot.SubscriptionService.GetPromoStats()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения промокодов: %w", err)
+	}
+	if len(stats) == 0 {
+		return &adminResult{text: "📭 Промокодов пока нет"}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🎟 Промокоды:\n")
+	for _, s := range stats {
+		promo := &s.Promo
+		sb.WriteString(fmt.Sprintf("\n%s — %s %s (%s)", promo.Code, formatPromoDiscount(promo),
+			formatPromoPeriods(promo.Periods), formatPromoState(promo, time.Now())))
+		uses := strconv.Itoa(promo.UsedCount)
+		if promo.MaxUses > 0 {
+			uses += "/" + strconv.Itoa(promo.MaxUses)
+		}
+		sb.WriteString(fmt.Sprintf("\n  применений: %s, оплат: %d на %.2f ₽, скидок: %.2f ₽",
+			uses, s.Payments, s.Revenue, s.Discount))
+	}
+	return &adminResult{text: sb.String()}, nil
+}
+
+func (ah *AdminHandler) handlePromoCreate(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	value, err := strconv.ParseFloat(args[2], 64)
+	if err != nil {
+		return nil, fmt.Errorf("некорректная скидка: %s", args[2])
+	}
+	promo := &domain.PromoCode{
+		Code:          args[0],
+		DiscountType:  strings.ToLower(args[1]),
+		DiscountValue: value,
+		Periods:       1,
+		Active:        true,
+	}
+	for _, option := range args[3:] {
+		if err := applyPromoOption(promo, option); err != nil {
+			return nil, err
+		}
+	}
+	if err := bot.SubscriptionService.CreatePromoCode(promo); err != nil {
+		return nil, err
+	}
+	return &adminResult{
+		text: fmt.Sprintf("✅ Промокод %s создан: %s %s", promo.Code, formatPromoDiscount(promo), formatPromoPeriods(promo.Periods)),
+		details: fmt.Sprintf("promo=%s %s=%.2f periods=%d uses=%d",
+			promo.Code, promo.DiscountType, promo.DiscountValue, promo.Periods, promo.MaxUses),
+	}, nil
+}
+
+func (ah *AdminHandler) handlePromoOff(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	code := domain.NormalizePromoCode(args[0])
+	if err := bot.SubscriptionService.SetPromoCodeActive(code, false); err != nil {
+		return nil, fmt.Errorf("промокод %s не выключен: %w", code, err)
+	}
+	return &adminResult{
+		text:    fmt.Sprintf("✅ Промокод %s выключен", code),
+		details: "promo=" + code,
+	}, nil
+}
+
+// applyPromoOption применяет параметр вида ключ=значение из команды /promo_create
+func applyPromoOption(promo *domain.PromoCode, option string) error {
+	key, value, ok := strings.Cut(option, "=")
+	if !ok {
+		return fmt.Errorf("ожидается параметр вида ключ=значение: %s", option)
+	}
+	switch strings.ToLower(key) {
+	case "periods":
+		periods, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("некорректное число оплат: %s", value)
+		}
+		promo.Periods = periods
+	case "uses":
+		uses, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("некорректный лимит использований: %s", value)
+		}
+		promo.MaxUses = uses
+	case "until":
+		until, err := time.ParseInLocation("02.01.2006", value, moscowZone)
+		if err != nil {
+			return fmt.Errorf("некорректная дата: %s, ожидается ДД.ММ.ГГГГ", value)
+		}
+		// Промокод действует до конца указанного дня
+		expiresAt := until.AddDate(0, 0, 1)
+		promo.ExpiresAt = &expiresAt
+	default:
+		return fmt.Errorf("неизвестный параметр %s, доступны: %s", key, promoOptionsUsage)
+	}
+	return nil
+}
+
+// formatPromoState описывает, действует ли промокод
+func formatPromoState(promo *domain.PromoCode, now time.Time) string {
+	switch err := promo.CheckAvailable(now); err {
+	case nil:
+		if promo.ExpiresAt != nil {
+			return "действует до " + formatMoscowTime(promo.ExpiresAt.Add(-time.Second))
+		}
+		return "действует"
+	case domain.ErrPromoExpired:
+		return "истек"
+	case domain.ErrPromoExhausted:
+		return "лимит исчерпан"
+	default:
+		return "выключен"
+	}
+}
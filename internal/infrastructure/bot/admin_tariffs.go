@@ -0,0 +1,208 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ai_tg_writer/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// tariffFieldsUsage поля тарифа, которые можно изменить командой /tariff_set
+const tariffFieldsUsage = "name, description, price, currency, period (day|week|month|year), " +
+	"limit <generations|rewrites|edits|audio_minutes> <N|unlimited>, visible (on|off), order, features (через ;), " +
+	"provider (yookassa|prodamus|default), retries (часы через запятую|default)"
+
+// registerTariffCommands добавляет команды управления каталогом тарифов
+func (ah *AdminHandler) registerTariffCommands() {
+	ah.commands["tariffs"] = adminCommand{"/tariffs",
+		"Каталог тарифов", 0, ah.handleTariffList}
+	ah.commands["tariff_create"] = adminCommand{"/tariff_create <id> <цена> <период> <название>",
+		"Создать скрытый тариф", 4, ah.handleTariffCreate}
+	ah.commands["tariff_set"] = adminCommand{"/tariff_set <id> <поле> <значение>",
+		"Изменить тариф: " + tariffFieldsUsage, 3, ah.handleTariffSet}
+}
+
+func (ah *AdminHandler) handleTariffList(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	tariffs, err := bot.SubscriptionService.GetAllTariffs()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения тарифов: %w", err)
+	}
+	if len(tariffs) == 0 {
+		return &adminResult{text: "📭 Каталог тарифов пуст"}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("💎 Каталог тарифов:\n")
+	for _, tariff := range tariffs {
+		visibility := "скрыт"
+		if tariff.Visible {
+			visibility = "в продаже"
+		}
+		sb.WriteString(fmt.Sprintf("\n%s — %s, %s, порядок %d (%s)\n  лимиты: %s",
+			tariff.ID, tariff.Name, formatTariffPrice(tariff), tariff.SortOrder, visibility, formatTariffQuotas(tariff)))
+		if tariff.Provider != "" {
+			sb.WriteString("\n  провайдер: " + tariff.Provider)
+		}
+		if len(tariff.RetryHours) > 0 {
+			sb.WriteString("\n  повторные списания через: " + formatRetryHours(tariff.RetryHours))
+		}
+	}
+	return &adminResult{text: sb.String()}, nil
+}
+
+func (ah *AdminHandler) handleTariffCreate(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	id := args[0]
+	existing, err := bot.SubscriptionService.GetTariff(id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения тарифа: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("тариф %s уже существует", id)
+	}
+	price, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("некорректная цена: %s", args[1])
+	}
+
+	// Новый тариф создается скрытым, чтобы его можно было настроить до начала продаж
+	tariff := &domain.Tariff{
+		ID:       id,
+		Name:     strings.Join(args[3:], " "),
+		Price:    price,
+		Currency: "RUB",
+		Period:   args[2],
+		Features: []string{},
+	}
+	if err := bot.SubscriptionService.SaveTariff(tariff); err != nil {
+		return nil, err
+	}
+	return &adminResult{
+		text: fmt.Sprintf("✅ Тариф %s создан (скрыт): %s\nВключите продажи: /tariff_set %s visible on",
+			id, formatTariffPrice(tariff), id),
+		details: fmt.Sprintf("tariff=%s price=%.2f period=%s", id, price, tariff.Period),
+	}, nil
+}
+
+func (ah *AdminHandler) handleTariffSet(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	id, field := args[0], strings.ToLower(args[1])
+	value := strings.Join(args[2:], " ")
+
+	tariff, err := bot.SubscriptionService.GetTariff(id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения тарифа: %w", err)
+	}
+	if tariff == nil {
+		return nil, fmt.Errorf("тариф %s не найден", id)
+	}
+	if err := applyTariffField(tariff, field, value); err != nil {
+		return nil, err
+	}
+	if err := bot.SubscriptionService.SaveTariff(tariff); err != nil {
+		return nil, err
+	}
+	return &adminResult{
+		text:    fmt.Sprintf("✅ Тариф %s: %s = %s", id, field, value),
+		details: fmt.Sprintf("tariff=%s %s=%s", id, field, value),
+	}, nil
+}
+
+// applyTariffField изменяет одно поле тарифа по его названию в команде /tariff_set
+func applyTariffField(tariff *domain.Tariff, field, value string) error {
+	switch field {
+	case "name":
+		tariff.Name = value
+	case "description":
+		tariff.Description = value
+	case "price":
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("некорректная цена: %s", value)
+		}
+		tariff.Price = price
+	case "currency":
+		tariff.Currency = strings.ToUpper(value)
+	case "period":
+		tariff.Period = strings.ToLower(value)
+	case "limit":
+		parts := strings.Fields(value)
+		if len(parts) != 2 {
+			return fmt.Errorf("ожидается: limit <ресурс> <N|unlimited>")
+		}
+		resource := domain.QuotaResource(strings.ToLower(parts[0]))
+		if !domain.IsValidQuotaResource(resource) {
+			return fmt.Errorf("неизвестный ресурс: %s", parts[0])
+		}
+		quotas := make(map[domain.QuotaResource]int, len(tariff.Quotas)+1)
+		for r, l := range tariff.Quotas {
+			quotas[r] = l
+		}
+		if strings.EqualFold(parts[1], "unlimited") {
+			delete(quotas, resource)
+		} else {
+			limit, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return fmt.Errorf("некорректный лимит: %s", parts[1])
+			}
+			quotas[resource] = limit
+		}
+		tariff.Quotas = quotas
+	case "visible":
+		switch strings.ToLower(value) {
+		case "on", "yes", "true":
+			tariff.Visible = true
+		case "off", "no", "false":
+			tariff.Visible = false
+		default:
+			return fmt.Errorf("ожидается on или off: %s", value)
+		}
+	case "order":
+		order, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("некорректный порядок: %s", value)
+		}
+		tariff.SortOrder = order
+	case "provider":
+		provider := strings.ToLower(value)
+		if provider == "default" {
+			provider = ""
+		}
+		tariff.Provider = provider
+	case "retries":
+		if strings.ToLower(value) == "default" {
+			tariff.RetryHours = nil
+			return nil
+		}
+		var hours []int
+		for _, part := range strings.Split(value, ",") {
+			interval, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || interval <= 0 {
+				return fmt.Errorf("некорректный интервал повторного списания: %s", part)
+			}
+			hours = append(hours, interval)
+		}
+		tariff.RetryHours = hours
+	case "features":
+		var features []string
+		for _, feature := range strings.Split(value, ";") {
+			if feature = strings.TrimSpace(feature); feature != "" {
+				features = append(features, feature)
+			}
+		}
+		tariff.Features = features
+	default:
+		return fmt.Errorf("неизвестное поле %s, доступны: %s", field, tariffFieldsUsage)
+	}
+	return nil
+}
+
+// formatRetryHours выводит расписание повторных списаний, например «1 ч, 24 ч, 72 ч»
+func formatRetryHours(hours []int) string {
+	parts := make([]string, len(hours))
+	for i, interval := range hours {
+		parts[i] = fmt.Sprintf("%d ч", interval)
+	}
+	return strings.Join(parts, ", ")
+}
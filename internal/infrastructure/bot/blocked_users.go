@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"ai_tg_writer/internal/monitoring"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ErrUserBlocked возвращается при попытке отправить уведомление пользователю, заблокировавшему бота
+var ErrUserBlocked = errors.New("user blocked the bot")
+
+// isBlockedError проверяет, что Telegram отказал в отправке из-за блокировки бота (403)
+func isBlockedError(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == 403
+}
+
+// HandleMyChatMember обрабатывает изменение статуса бота в личном чате пользователя
+func (b *Bot) HandleMyChatMember(update *tgbotapi.ChatMemberUpdated) {
+	if update == nil || update.Chat.Type != "private" {
+		return
+	}
+
+	userID := update.From.ID
+	switch update.NewChatMember.Status {
+	case "kicked":
+		b.setUserBlocked(userID, true, "update")
+	case "member":
+		if b.setUserBlocked(userID, false, "update") {
+			b.sendWelcomeBack(userID)
+		}
+	}
+}
+
+// HandleUserActivity снимает отметку о блокировке, когда пользователь пишет боту или нажимает кнопку:
+// заблокировавший бота пользователь этого сделать не может, а обновление my_chat_member могло потеряться
+func (b *Bot) HandleUserActivity(update *tgbotapi.Update) {
+	switch {
+	case update.Message != nil && update.Message.From != nil && update.Message.Chat != nil && update.Message.Chat.IsPrivate():
+		b.setUserBlocked(update.Message.From.ID, false, "message")
+	case update.CallbackQuery != nil && update.CallbackQuery.From != nil:
+		b.setUserBlocked(update.CallbackQuery.From.ID, false, "message")
+	}
+}
+
+// setUserBlocked сохраняет статус блокировки и возвращает true, если он изменился
+func (b *Bot) setUserBlocked(userID int64, blocked bool, source string) bool {
+	if b.DB == nil {
+		return false
+	}
+
+	changed, err := b.DB.SetUserBotBlocked(userID, blocked)
+	if err != nil {
+		log.Printf("❌ Ошибка сохранения статуса блокировки для пользователя %d: %v", userID, err)
+		return false
+	}
+	if !changed {
+		return false
+	}
+
+	event := "unblocked"
+	if blocked {
+		event = "blocked"
+	}
+	monitoring.RecordBotBlockEvent(event, source)
+	log.Printf("🚫 Пользователь %d: %s (источник: %s)", userID, event, source)
+	return true
+}
+
+// isUserBlocked проверяет статус блокировки перед отправкой фоновых уведомлений
+func (b *Bot) isUserBlocked(chatID int64) bool {
+	if b.DB == nil || chatID <= 0 {
+		return false
+	}
+	blocked, err := b.DB.IsUserBotBlocked(chatID)
+	if err != nil {
+		log.Printf("⚠️ Ошибка проверки блокировки пользователя %d: %v", chatID, err)
+		return false
+	}
+	return blocked
+}
+
+// sendWelcomeBack сообщает вернувшемуся пользователю о состоянии подписки,
+// чтобы уведомления, пропущенные за время блокировки, не терялись
+func (b *Bot) sendWelcomeBack(userID int64) {
+	text := "👋 С возвращением! Отправьте голосовое сообщение или выберите действие в меню."
+
+	if b.SubscriptionService != nil {
+		entitlement := b.Entitlement(userID)
+		if entitlement.HasSubscriptionAccess() && !entitlement.AutoRenew && entitlement.Until != nil {
+			text += fmt.Sprintf("\n\n⚠️ Автопродление подписки отключено. Доступ к Premium сохранится до %s.",
+				entitlement.Until.Format("02.01.2006"))
+		}
+	}
+
+	keyboard := b.CreateMainKeyboard()
+	msg := tgbotapi.NewMessage(userID, text)
+	msg.ReplyMarkup = &keyboard
+	if _, err := b.Send(msg); err != nil {
+		log.Printf("❌ Ошибка отправки приветствия пользователю %d: %v", userID, err)
+	}
+}
@@ -1,9 +1,11 @@
 package bot
 
 import (
+	"ai_tg_writer/internal/domain"
 	"ai_tg_writer/internal/infrastructure/database"
 	"ai_tg_writer/internal/service"
 	"fmt"
+	"log"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -14,12 +16,22 @@ type Bot struct {
 	StateManager        *StateManager
 	DB                  *database.DB
 	SubscriptionService *service.SubscriptionService
+	QuotaService        *service.QuotaService
+	CreditService       *service.CreditService      // nil — пакеты кредитов не продаются
+	GiftService         *service.GiftService        // nil — подарочный Premium не продается
+	Referrals           *service.ReferralService    // nil — реферальная программа выключена
+	Acquisitions        *service.AcquisitionService // nil — источники привлечения не записываются
+	RefundService       *service.RefundService      // nil — возвраты из админки недоступны
+	PaymentProviders    *service.PaymentProviders   // Выбор провайдера пользователя из админки
+	Entitlements        *service.EntitlementService // Доступ пользователя к платным возможностям
+	Scheduler           *SendScheduler              // Ограничитель исходящих запросов к Telegram
 }
 
 func NewBot(api *tgbotapi.BotAPI, db *database.DB) *Bot {
 	return &Bot{
-		API: api,
-		DB:  db,
+		API:       api,
+		DB:        db,
+		Scheduler: NewSendScheduler(api),
 	}
 }
 
@@ -28,12 +40,69 @@ func NewBotWithSubscriptionService(api *tgbotapi.BotAPI, db *database.DB, subscr
 		API:                 api,
 		DB:                  db,
 		SubscriptionService: subscriptionService,
+		Scheduler:           NewSendScheduler(api),
 	}
 }
 
-// Send отправляет сообщение через API бота
+// Entitlement возвращает права пользователя на текущий момент. Если права определить
+// не удалось, пользователь получает бесплатный тариф, а экраны бота — подписку из базы.
+func (b *Bot) Entitlement(userID int64) *domain.Entitlement {
+	if b.Entitlements != nil {
+		entitlement, err := b.Entitlements.Get(userID)
+		if err == nil {
+			return entitlement
+		}
+		log.Printf("⚠️ Ошибка получения прав пользователя %d: %v", userID, err)
+	}
+	free := &domain.Entitlement{Source: domain.EntitlementFree, TariffID: domain.FreeTariffID}
+	if b.SubscriptionService != nil {
+		free.Subscription, _ = b.SubscriptionService.GetUserSubscription(userID)
+	}
+	return free
+}
+
+// Send отправляет сообщение через API бота с приоритетом интерактивного ответа
 func (b *Bot) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
-	return b.API.Send(c)
+	return b.SendWithPriority(c, PriorityInteractive)
+}
+
+// SendBulk отправляет массовое или фоновое уведомление, уступая очередь интерактивным ответам
+func (b *Bot) SendBulk(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return b.SendWithPriority(c, PriorityBulk)
+}
+
+// SendWithPriority отправляет сообщение через планировщик с указанным приоритетом
+func (b *Bot) SendWithPriority(c tgbotapi.Chattable, priority SendPriority) (tgbotapi.Message, error) {
+	chatID := chattableChatID(c)
+
+	// Фоновые уведомления не отправляем пользователям, заблокировавшим бота
+	if priority == PriorityBulk && b.isUserBlocked(chatID) {
+		return tgbotapi.Message{}, ErrUserBlocked
+	}
+
+	var (
+		message tgbotapi.Message
+		err     error
+	)
+	if b.Scheduler == nil {
+		message, err = b.API.Send(c)
+	} else {
+		message, err = b.Scheduler.Send(c, priority)
+	}
+
+	if chatID > 0 && isBlockedError(err) {
+		b.setUserBlocked(chatID, true, "send_error")
+		return message, fmt.Errorf("%w: %v", ErrUserBlocked, err)
+	}
+	return message, err
+}
+
+// Request выполняет запрос к API, не возвращающий сообщение
+func (b *Bot) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	if b.Scheduler == nil {
+		return b.API.Request(c)
+	}
+	return b.Scheduler.Request(c, PriorityInteractive)
 }
 
 // CreateApprovalKeyboard создает клавиатуру для согласования результата
@@ -371,11 +440,11 @@ func (b *Bot) SendHTMLMessage(chatID int64, htmlText string) (int, error) {
 	return b.SendFormattedMessage(chatID, cleanText, entities)
 }
 
-// CreateSubscriptionLink создает ссылку на оплату подписки
-func (b *Bot) CreateSubscriptionLink(userID int64, tariff string, amount float64) (string, error) {
+// CreateSubscriptionLink создает ссылку на оплату подписки; пустой promoCode — без скидки
+func (b *Bot) CreateSubscriptionLink(userID int64, tariff string, amount float64, promoCode string) (string, error) {
 	if b.SubscriptionService == nil {
 		return "", fmt.Errorf("subscription service not initialized")
 	}
 
-	return b.SubscriptionService.CreateSubscriptionLink(userID, tariff, amount)
+	return b.SubscriptionService.CreateSubscriptionLinkWithPromo(userID, tariff, amount, promoCode)
 }
@@ -0,0 +1,306 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"ai_tg_writer/internal/infrastructure/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// marketingOptOutCallback callback кнопки отписки от маркетинговых рассылок
+const marketingOptOutCallback = "marketing_opt_out"
+
+// registerBroadcastCommands добавляет команды управления рассылками
+func (ah *AdminHandler) registerBroadcastCommands() {
+	ah.commands["broadcast"] = adminCommand{"/broadcast <all|free|premium|churned|inactive> [дни] [service]",
+		"Создать рассылку из сообщения, на которое вы отвечаете", 1, ah.handleBroadcastCreate}
+	ah.commands["broadcast_button"] = adminCommand{"/broadcast_button <id> <текст> | <url>",
+		"Добавить кнопку-ссылку к черновику", 2, ah.handleBroadcastButton}
+	ah.commands["broadcast_preview"] = adminCommand{"/broadcast_preview <id>",
+		"Прислать рассылку себе для проверки", 1, ah.handleBroadcastPreview}
+	ah.commands["broadcast_start"] = adminCommand{"/broadcast_start <id>",
+		"Запустить рассылку", 1, ah.handleBroadcastStart}
+	ah.commands["broadcast_pause"] = adminCommand{"/broadcast_pause <id>",
+		"Приостановить рассылку", 1, ah.handleBroadcastPause}
+	ah.commands["broadcast_resume"] = adminCommand{"/broadcast_resume <id>",
+		"Возобновить рассылку", 1, ah.handleBroadcastResume}
+	ah.commands["broadcast_cancel"] = adminCommand{"/broadcast_cancel <id>",
+		"Отменить рассылку", 1, ah.handleBroadcastCancel}
+	ah.commands["broadcast_status"] = adminCommand{"/broadcast_status <id>",
+		"Прогресс и статусы доставки", 1, ah.handleBroadcastStatus}
+}
+
+// buildBroadcastMessage собирает сообщение рассылки для получателя
+func buildBroadcastMessage(broadcast *database.Broadcast, chatID int64) (tgbotapi.MessageConfig, error) {
+	msg := tgbotapi.NewMessage(chatID, broadcast.Text)
+	if len(broadcast.Entities) > 0 {
+		if err := json.Unmarshal(broadcast.Entities, &msg.Entities); err != nil {
+			return msg, fmt.Errorf("entities: %w", err)
+		}
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, button := range broadcast.Buttons {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(button.Text, button.URL),
+		))
+	}
+	if broadcast.IsMarketing {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔕 Отписаться от рассылок", marketingOptOutCallback),
+		))
+	}
+	if len(rows) > 0 {
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+		msg.ReplyMarkup = &keyboard
+	}
+	return msg, nil
+}
+
+// SendBroadcast отправляет сообщение рассылки получателю с фоновым приоритетом
+func (b *Bot) SendBroadcast(broadcast *database.Broadcast, userID int64) error {
+	msg, err := buildBroadcastMessage(broadcast, userID)
+	if err != nil {
+		return err
+	}
+	_, err = b.SendBulk(msg)
+	return err
+}
+
+// SetMarketingOptOut сохраняет согласие пользователя на маркетинговые рассылки и подтверждает его
+func (b *Bot) SetMarketingOptOut(chatID, userID int64, optOut bool) {
+	if err := b.DB.SetMarketingOptOut(userID, optOut); err != nil {
+		log.Printf("❌ Ошибка сохранения подписки на рассылки для пользователя %d: %v", userID, err)
+		b.Send(tgbotapi.NewMessage(chatID, "❌ Не удалось сохранить настройку. Попробуйте позже."))
+		return
+	}
+
+	text := "🔔 Вы снова подписаны на новости и акции. Отписаться: /news_off"
+	if optOut {
+		text = "🔕 Вы отписались от новостей и акций. Важные уведомления о подписке продолжат приходить.\n\nВернуть рассылку: /news_on"
+	}
+	b.Send(tgbotapi.NewMessage(chatID, text))
+}
+
+// getBroadcast находит рассылку по ID из аргумента команды
+func (ah *AdminHandler) getBroadcast(arg string) (*database.Broadcast, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный ID рассылки: %s", arg)
+	}
+	broadcast, err := ah.broadcastRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения рассылки: %w", err)
+	}
+	if broadcast == nil {
+		return nil, fmt.Errorf("рассылка #%d не найдена", id)
+	}
+	return broadcast, nil
+}
+
+func (ah *AdminHandler) handleBroadcastCreate(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	source := message.ReplyToMessage
+	if source == nil || source.Text == "" {
+		return nil, fmt.Errorf("ответьте этой командой на текстовое сообщение, которое нужно разослать")
+	}
+
+	segment, err := database.ParseBroadcastSegment(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный сегмент: %s", args[0])
+	}
+
+	broadcast := &database.Broadcast{
+		AdminID:     message.From.ID,
+		Text:        source.Text,
+		Segment:     segment,
+		IsMarketing: true,
+	}
+	for _, arg := range args[1:] {
+		if arg == "service" {
+			// Служебные сообщения (например, о сбоях) получают и отписавшиеся
+			broadcast.IsMarketing = false
+			continue
+		}
+		days, err := strconv.Atoi(arg)
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("некорректный аргумент: %s", arg)
+		}
+		broadcast.InactiveDays = days
+	}
+	if segment == database.SegmentInactive && broadcast.InactiveDays == 0 {
+		return nil, fmt.Errorf("для сегмента inactive укажите количество дней")
+	}
+
+	if len(source.Entities) > 0 {
+		entities, err := json.Marshal(source.Entities)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сохранения форматирования: %w", err)
+		}
+		broadcast.Entities = entities
+	}
+
+	if err := ah.broadcastRepo.Create(broadcast); err != nil {
+		return nil, fmt.Errorf("ошибка создания рассылки: %w", err)
+	}
+	recipients, err := ah.broadcastRepo.CountSegment(broadcast.Segment, broadcast.InactiveDays, broadcast.IsMarketing)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подсчета получателей: %w", err)
+	}
+
+	kind := "маркетинговая"
+	if !broadcast.IsMarketing {
+		kind = "служебная"
+	}
+	text := fmt.Sprintf("📝 Черновик рассылки #%d создан\nСегмент: %s\nТип: %s\nПолучателей сейчас: %d\n\n"+
+		"Кнопка: /broadcast_button %d Текст | https://...\nПроверка: /broadcast_preview %d\nЗапуск: /broadcast_start %d",
+		broadcast.ID, segmentLabel(broadcast), kind, recipients, broadcast.ID, broadcast.ID, broadcast.ID)
+	return &adminResult{text: text, details: fmt.Sprintf("broadcast=%d segment=%s", broadcast.ID, segmentLabel(broadcast))}, nil
+}
+
+func (ah *AdminHandler) handleBroadcastButton(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	broadcast, err := ah.getBroadcast(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// Текст кнопки может содержать пробелы, поэтому разбираем исходную строку
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(message.CommandArguments()), args[0]))
+	parts := strings.SplitN(rest, "|", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("укажите кнопку в формате: Текст | https://...")
+	}
+	button := database.BroadcastButton{Text: strings.TrimSpace(parts[0]), URL: strings.TrimSpace(parts[1])}
+	if button.Text == "" || !(strings.HasPrefix(button.URL, "https://") || strings.HasPrefix(button.URL, "http://")) {
+		return nil, fmt.Errorf("укажите текст кнопки и ссылку, начинающуюся с http:// или https://")
+	}
+
+	added, err := ah.broadcastRepo.AddButton(broadcast.ID, button)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка добавления кнопки: %w", err)
+	}
+	if !added {
+		return nil, fmt.Errorf("кнопки можно добавлять только к черновику")
+	}
+	return &adminResult{
+		text:    fmt.Sprintf("✅ Кнопка «%s» добавлена к рассылке #%d", button.Text, broadcast.ID),
+		details: fmt.Sprintf("broadcast=%d url=%s", broadcast.ID, button.URL),
+	}, nil
+}
+
+func (ah *AdminHandler) handleBroadcastPreview(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	broadcast, err := ah.getBroadcast(args[0])
+	if err != nil {
+		return nil, err
+	}
+	msg, err := buildBroadcastMessage(broadcast, message.Chat.ID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сборки сообщения: %w", err)
+	}
+	if _, err := bot.Send(msg); err != nil {
+		return nil, fmt.Errorf("ошибка отправки превью: %w", err)
+	}
+	return &adminResult{
+		text:    fmt.Sprintf("👆 Так рассылку #%d увидят получатели", broadcast.ID),
+		details: fmt.Sprintf("broadcast=%d", broadcast.ID),
+	}, nil
+}
+
+func (ah *AdminHandler) handleBroadcastStart(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	broadcast, err := ah.getBroadcast(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if broadcast.Status != database.BroadcastStatusDraft {
+		return nil, fmt.Errorf("рассылка #%d уже запущена (статус %s)", broadcast.ID, broadcast.Status)
+	}
+	total, err := ah.broadcastRepo.Start(broadcast)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запуска рассылки: %w", err)
+	}
+	return &adminResult{
+		text: fmt.Sprintf("🚀 Рассылка #%d запущена: %d получателей\nПрогресс: /broadcast_status %d\nПауза: /broadcast_pause %d",
+			broadcast.ID, total, broadcast.ID, broadcast.ID),
+		details: fmt.Sprintf("broadcast=%d total=%d", broadcast.ID, total),
+	}, nil
+}
+
+func (ah *AdminHandler) handleBroadcastPause(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	return ah.transitionBroadcast(args[0], database.BroadcastStatusPaused, "⏸ Рассылка #%d приостановлена", database.BroadcastStatusRunning)
+}
+
+func (ah *AdminHandler) handleBroadcastResume(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	return ah.transitionBroadcast(args[0], database.BroadcastStatusRunning, "▶️ Рассылка #%d возобновлена", database.BroadcastStatusPaused)
+}
+
+func (ah *AdminHandler) handleBroadcastCancel(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	return ah.transitionBroadcast(args[0], database.BroadcastStatusCancelled, "🛑 Рассылка #%d отменена",
+		database.BroadcastStatusDraft, database.BroadcastStatusRunning, database.BroadcastStatusPaused)
+}
+
+// transitionBroadcast меняет статус рассылки, если это допустимо
+func (ah *AdminHandler) transitionBroadcast(arg string, to database.BroadcastStatus, format string, from ...database.BroadcastStatus) (*adminResult, error) {
+	broadcast, err := ah.getBroadcast(arg)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := ah.broadcastRepo.Transition(broadcast.ID, to, from...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка изменения статуса рассылки: %w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("рассылку #%d в статусе %s нельзя перевести в %s", broadcast.ID, broadcast.Status, to)
+	}
+	return &adminResult{text: fmt.Sprintf(format, broadcast.ID), details: fmt.Sprintf("broadcast=%d", broadcast.ID)}, nil
+}
+
+func (ah *AdminHandler) handleBroadcastStatus(bot *Bot, message *tgbotapi.Message, args []string) (*adminResult, error) {
+	broadcast, err := ah.getBroadcast(args[0])
+	if err != nil {
+		return nil, err
+	}
+	stats, err := ah.broadcastRepo.GetDeliveryStats(broadcast.ID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения статистики: %w", err)
+	}
+	return &adminResult{text: formatBroadcastReport(broadcast, stats), details: fmt.Sprintf("broadcast=%d", broadcast.ID)}, nil
+}
+
+// formatBroadcastReport формирует отчет о прогрессе рассылки
+func formatBroadcastReport(broadcast *database.Broadcast, stats map[string]int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📣 Рассылка #%d (%s)\n", broadcast.ID, segmentLabel(broadcast)))
+	sb.WriteString(fmt.Sprintf("Статус: %s\n", broadcast.Status))
+	processed := broadcast.Total - stats[database.RecipientStatusPending] - stats[database.RecipientStatusSending]
+	if broadcast.Status == database.BroadcastStatusDraft {
+		processed = 0
+	}
+	sb.WriteString(fmt.Sprintf("Обработано: %d из %d\n", processed, broadcast.Total))
+	sb.WriteString(fmt.Sprintf("✅ Доставлено: %d\n", stats[database.RecipientStatusSent]))
+	sb.WriteString(fmt.Sprintf("🚫 Бот заблокирован: %d\n", stats[database.RecipientStatusBlocked]))
+	sb.WriteString(fmt.Sprintf("❌ Ошибки: %d\n", stats[database.RecipientStatusFailed]))
+	if broadcast.FinishedAt != nil {
+		sb.WriteString(fmt.Sprintf("Завершена: %s\n", broadcast.FinishedAt.Format("02.01.2006 15:04")))
+	}
+	return sb.String()
+}
+
+// segmentLabel возвращает название сегмента с параметрами
+func segmentLabel(broadcast *database.Broadcast) string {
+	if broadcast.Segment == database.SegmentInactive {
+		return fmt.Sprintf("%s %d дн.", broadcast.Segment, broadcast.InactiveDays)
+	}
+	return string(broadcast.Segment)
+}
+
+// SendBroadcastReport отправляет автору итог завершенной рассылки
+func (b *Bot) SendBroadcastReport(broadcast *database.Broadcast, stats map[string]int) {
+	msg := tgbotapi.NewMessage(broadcast.AdminID, formatBroadcastReport(broadcast, stats))
+	if _, err := b.Send(msg); err != nil {
+		log.Printf("❌ Ошибка отправки отчета о рассылке #%d: %v", broadcast.ID, err)
+	}
+}
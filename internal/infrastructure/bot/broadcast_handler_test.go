@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"testing"
+
+	"ai_tg_writer/internal/infrastructure/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestBuildBroadcastMessage(t *testing.T) {
+	broadcast := &database.Broadcast{
+		Text:        "Новая функция!",
+		Entities:    []byte(`[{"type":"bold","offset":0,"length":5}]`),
+		Buttons:     []database.BroadcastButton{{Text: "Подробнее", URL: "https://example.com"}},
+		IsMarketing: true,
+	}
+
+	msg, err := buildBroadcastMessage(broadcast, 42)
+	if err != nil {
+		t.Fatalf("Ошибка сборки сообщения: %v", err)
+	}
+	if msg.ChatID != 42 || msg.Text != broadcast.Text {
+		t.Errorf("Неверный получатель или текст: %d %q", msg.ChatID, msg.Text)
+	}
+	if len(msg.Entities) != 1 || msg.Entities[0].Type != "bold" {
+		t.Errorf("Форматирование не восстановлено: %+v", msg.Entities)
+	}
+
+	keyboard, ok := msg.ReplyMarkup.(*tgbotapi.InlineKeyboardMarkup)
+	if !ok || len(keyboard.InlineKeyboard) != 2 {
+		t.Fatalf("Ожидалось 2 ряда кнопок, получено %+v", msg.ReplyMarkup)
+	}
+	if data := keyboard.InlineKeyboard[1][0].CallbackData; data == nil || *data != marketingOptOutCallback {
+		t.Errorf("Маркетинговая рассылка должна содержать кнопку отписки")
+	}
+
+	// Служебная рассылка без кнопок отправляется без клавиатуры
+	broadcast.IsMarketing = false
+	broadcast.Buttons = nil
+	msg, err = buildBroadcastMessage(broadcast, 42)
+	if err != nil {
+		t.Fatalf("Ошибка сборки сообщения: %v", err)
+	}
+	if msg.ReplyMarkup != nil {
+		t.Errorf("Служебная рассылка без кнопок не должна иметь клавиатуру")
+	}
+}
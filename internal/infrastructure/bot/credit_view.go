@@ -0,0 +1,117 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"ai_tg_writer/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Callback-и пакетов кредитов: список пакетов и покупка, после префикса идет ID пакета
+const (
+	creditPacksCallback      = "credit_packs"
+	buyCreditsCallbackPrefix = "buy_credits:"
+)
+
+// creditHistoryLimit сколько последних покупок пакетов показывать в истории оплат
+const creditHistoryLimit = 10
+
+// formatCreditPackPrice форматирует цену пакета: «199₽»
+func formatCreditPackPrice(pack *domain.CreditPack) string {
+	if pack.Currency != "" && pack.Currency != "RUB" {
+		return fmt.Sprintf("%.0f %s", pack.Price, pack.Currency)
+	}
+	return fmt.Sprintf("%.0f₽", pack.Price)
+}
+
+// buildCreditPacksView формирует экран выбора пакета кредитов
+func buildCreditPacksView(packs []*domain.CreditPack, balance *domain.CreditBalance) (string, tgbotapi.InlineKeyboardMarkup) {
+	back := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "subscription"),
+	)
+	if len(packs) == 0 {
+		return "❌ Пакеты временно недоступны. Попробуйте позже.", tgbotapi.NewInlineKeyboardMarkup(back)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🎟 *Пакеты постов*\n\n")
+	sb.WriteString("Разовая покупка без подписки и автопродления. Один кредит — одно создание, рерайт или правка поста; кредиты расходуются раньше бесплатного лимита.\n")
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, pack := range packs {
+		sb.WriteString(fmt.Sprintf("\n*%s* — %s, действует %d дн.", pack.Name, formatCreditPackPrice(pack), pack.ValidityDays))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s — %s", pack.Name, formatCreditPackPrice(pack)),
+				buyCreditsCallbackPrefix+pack.ID,
+			),
+		))
+	}
+	if text := formatCreditBalance(balance); text != "" {
+		sb.WriteString("\n\n" + text)
+	}
+	rows = append(rows, back)
+	return sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// formatCreditBalance форматирует остаток кредитов и ближайшую дату сгорания
+func formatCreditBalance(balance *domain.CreditBalance) string {
+	if balance == nil || balance.Credits == 0 {
+		return ""
+	}
+	text := fmt.Sprintf("🎟 Кредитов: %d", balance.Credits)
+	if balance.NextExpiry != nil {
+		text += fmt.Sprintf(" (%d сгорят %s)", balance.ExpiringAmount, balance.NextExpiry.Format("02.01.2006"))
+	}
+	return text
+}
+
+// formatCreditPurchases форматирует историю покупок пакетов для раздела истории оплат
+func formatCreditPurchases(purchases []*domain.CreditPurchase) string {
+	if len(purchases) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("🎟 Пакеты постов\n\n")
+	for i, purchase := range purchases {
+		sb.WriteString(fmt.Sprintf("%d. ✅ %d кредитов, осталось %d\n", i+1, purchase.Credits, purchase.Remaining))
+		sb.WriteString(fmt.Sprintf("   💰 Сумма: %.0f₽\n", purchase.Amount))
+		if purchase.PaidAt != nil {
+			sb.WriteString(fmt.Sprintf("   📅 Дата: %s\n", purchase.PaidAt.Format("02.01.2006 15:04")))
+		}
+		if purchase.ExpiresAt != nil {
+			sb.WriteString(fmt.Sprintf("   ⏳ Действуют до: %s\n", purchase.ExpiresAt.Format("02.01.2006")))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// withCreditPacksRow добавляет кнопку пакетов кредитов перед последней строкой клавиатуры (кнопкой «Назад»)
+func (b *Bot) withCreditPacksRow(keyboard tgbotapi.InlineKeyboardMarkup) tgbotapi.InlineKeyboardMarkup {
+	if b.CreditService == nil || len(keyboard.InlineKeyboard) == 0 {
+		return keyboard
+	}
+	row := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🎟 Купить пакет постов", creditPacksCallback),
+	)
+	last := len(keyboard.InlineKeyboard) - 1
+	rows := append([][]tgbotapi.InlineKeyboardButton{}, keyboard.InlineKeyboard[:last]...)
+	rows = append(rows, row, keyboard.InlineKeyboard[last])
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// CreditBalanceText возвращает строку профиля с остатком кредитов или пустую строку
+func (b *Bot) CreditBalanceText(userID int64) string {
+	if b.CreditService == nil {
+		return ""
+	}
+	balance, err := b.CreditService.Balance(userID)
+	if err != nil {
+		log.Printf("Ошибка получения баланса кредитов пользователя %d: %v", userID, err)
+		return ""
+	}
+	return formatCreditBalance(balance)
+}
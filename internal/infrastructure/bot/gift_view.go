@@ -0,0 +1,196 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/service"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Callback-и подарочного Premium: выбор срока и покупка, после префикса идет число месяцев
+const (
+	giftPremiumCallback   = "gift_premium"
+	buyGiftCallbackPrefix = "buy_gift:"
+)
+
+// formatGiftMonths форматирует срок подарка: «1 месяц», «3 месяца», «12 месяцев»
+func formatGiftMonths(months int) string {
+	switch {
+	case months%10 == 1 && months%100 != 11:
+		return fmt.Sprintf("%d месяц", months)
+	case months%10 >= 2 && months%10 <= 4 && (months%100 < 12 || months%100 > 14):
+		return fmt.Sprintf("%d месяца", months)
+	}
+	return fmt.Sprintf("%d месяцев", months)
+}
+
+// formatGiftPrice форматирует цену подарка: «2970₽»
+func formatGiftPrice(price float64, currency string) string {
+	if currency != "" && currency != "RUB" {
+		return fmt.Sprintf("%.0f %s", price, currency)
+	}
+	return fmt.Sprintf("%.0f₽", price)
+}
+
+// parseGiftMonths извлекает срок подарка из callback-а buy_gift:<месяцы>
+func parseGiftMonths(data string) (int, bool) {
+	value, ok := strings.CutPrefix(data, buyGiftCallbackPrefix)
+	if !ok {
+		return 0, false
+	}
+	months, err := strconv.Atoi(value)
+	return months, err == nil && months > 0
+}
+
+// buildGiftOptionsView формирует экран выбора срока подарка
+func buildGiftOptionsView(options []service.GiftOption) (string, tgbotapi.InlineKeyboardMarkup) {
+	back := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "subscription"),
+	)
+	if len(options) == 0 {
+		return "❌ Подарки временно недоступны. Попробуйте позже.", tgbotapi.NewInlineKeyboardMarkup(back)
+	}
+
+	text := "🎁 *Подарить Premium*\n\n" +
+		"Оплатите подарок — мы пришлем код и ссылку, которые нужно переслать другу. " +
+		"Premium начнет действовать, когда друг откроет ссылку. Подарок оплачивается один раз, без автопродления."
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, option := range options {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s — %s", formatGiftMonths(option.Months), formatGiftPrice(option.Price, option.Currency)),
+				buyGiftCallbackPrefix+strconv.Itoa(option.Months),
+			),
+		))
+	}
+	rows = append(rows, back)
+	return text, tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// giftLink ссылка, по которой получатель активирует подарок: https://t.me/<бот>?start=gift_<код>
+func (b *Bot) giftLink(code string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%s", b.API.Self.UserName, domain.GiftStartPrefix, code)
+}
+
+// withGiftRow добавляет кнопку подарка перед последней строкой клавиатуры (кнопкой «Назад»)
+func (b *Bot) withGiftRow(keyboard tgbotapi.InlineKeyboardMarkup) tgbotapi.InlineKeyboardMarkup {
+	if b.GiftService == nil || len(keyboard.InlineKeyboard) == 0 {
+		return keyboard
+	}
+	options, err := b.GiftService.GiftOptions()
+	if err != nil {
+		log.Printf("Ошибка получения вариантов подарка: %v", err)
+		return keyboard
+	}
+	if len(options) == 0 {
+		return keyboard
+	}
+	row := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🎁 Подарить Premium", giftPremiumCallback),
+	)
+	last := len(keyboard.InlineKeyboard) - 1
+	rows := append([][]tgbotapi.InlineKeyboardButton{}, keyboard.InlineKeyboard[:last]...)
+	rows = append(rows, row, keyboard.InlineKeyboard[last])
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// SendGiftPurchasedMessage отправляет покупателю код оплаченного подарка и ссылку для получателя
+func (b *Bot) SendGiftPurchasedMessage(gift *domain.Gift) {
+	link := b.giftLink(gift.Code)
+	text := fmt.Sprintf("🎁 Подарок оплачен!\n\n"+
+		"Premium на %s ждет получателя. Перешлите другу ссылку:\n%s\n\n"+
+		"Или код: %s — его можно ввести командой /start %s%s\n\n"+
+		"Подарок активируется один раз, без автопродления. Мы сообщим, когда друг его получит.",
+		formatGiftMonths(gift.Months), link, gift.Code, domain.GiftStartPrefix, gift.Code)
+
+	share := "https://t.me/share/url?url=" + url.QueryEscape(link) +
+		"&text=" + url.QueryEscape("🎁 Дарю тебе Premium в AI TG Writer!")
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("📤 Отправить подарок", share),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад в меню", "main_menu"),
+		),
+	)
+	msg := tgbotapi.NewMessage(gift.BuyerID, text)
+	msg.ReplyMarkup = &keyboard
+	if _, err := b.Send(msg); err != nil {
+		log.Printf("❌ Error sending gift purchased message to user %d: %v", gift.BuyerID, err)
+	}
+}
+
+// RedeemGift активирует подарок из ссылки /start gift_<код> и уведомляет получателя и покупателя
+func (b *Bot) RedeemGift(chatID int64, from *tgbotapi.User, code string) {
+	if b.GiftService == nil {
+		b.Send(tgbotapi.NewMessage(chatID, "❌ Подарки временно недоступны. Попробуйте позже."))
+		return
+	}
+	// Получатель мог впервые открыть бота по ссылке: Premium выдается существующему пользователю
+	if _, err := b.DB.GetOrCreateUser(from.ID, from.UserName, from.FirstName, from.LastName); err != nil {
+		log.Printf("❌ Ошибка создания пользователя %d: %v", from.ID, err)
+		b.Send(tgbotapi.NewMessage(chatID, "❌ Не удалось активировать подарок. Попробуйте позже."))
+		return
+	}
+
+	gift, until, err := b.GiftService.RedeemGift(code, from.ID)
+	switch {
+	case errors.Is(err, domain.ErrGiftRedeemed):
+		text := "❌ Этот подарок уже активирован."
+		if gift != nil && gift.RecipientID != nil && *gift.RecipientID == from.ID {
+			text = "✅ Вы уже активировали этот подарок."
+		}
+		b.Send(tgbotapi.NewMessage(chatID, text))
+		return
+	case errors.Is(err, domain.ErrGiftNotFound):
+		b.Send(tgbotapi.NewMessage(chatID, "❌ Подарок не найден. Проверьте ссылку или код."))
+		return
+	case err != nil:
+		log.Printf("❌ Ошибка активации подарка %q пользователем %d: %v", code, from.ID, err)
+		b.Send(tgbotapi.NewMessage(chatID, "❌ Не удалось активировать подарок. Попробуйте позже."))
+		return
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📝 Создать пост", "create_post"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👤 Мой профиль", "profile"),
+		),
+	)
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🎁 Вам подарили Premium на %s!\n\n"+
+		"Все возможности Premium доступны до %s. Подписка не продлевается автоматически — ничего отменять не нужно.",
+		formatGiftMonths(gift.Months), formatMoscowTime(until)))
+	msg.ReplyMarkup = &keyboard
+	b.Send(msg)
+
+	b.notifyGiftBuyer(gift, from, until)
+}
+
+// notifyGiftBuyer сообщает покупателю, что подарок активирован
+func (b *Bot) notifyGiftBuyer(gift *domain.Gift, recipient *tgbotapi.User, until time.Time) {
+	if gift.BuyerID == recipient.ID {
+		return
+	}
+	name := recipient.FirstName
+	if recipient.UserName != "" {
+		name = "@" + recipient.UserName
+	}
+	if name == "" {
+		name = "Получатель"
+	}
+	text := fmt.Sprintf("🎉 Ваш подарок активирован! %s получает Premium на %s, до %s.",
+		name, formatGiftMonths(gift.Months), formatMoscowTime(until))
+	if _, err := b.Send(tgbotapi.NewMessage(gift.BuyerID, text)); err != nil {
+		log.Printf("❌ Error sending gift redeemed message to user %d: %v", gift.BuyerID, err)
+	}
+}
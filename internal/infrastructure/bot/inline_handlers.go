@@ -7,6 +7,7 @@ import (
 	"ai_tg_writer/internal/monitoring"
 	"ai_tg_writer/internal/service"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -22,12 +23,14 @@ type InlineHandler struct {
 	stateManager        *StateManager
 	voiceHandler        *voice.VoiceHandler
 	subscriptionService *service.SubscriptionService
+	quotaService        *service.QuotaService
+	entitlements        *service.EntitlementService
 	postHistoryRepo     *database.PostHistoryRepository
 	prompts             map[string]Prompt
 }
 
 // NewInlineHandler создает новый обработчик inline-команд
-func NewInlineHandler(stateManager *StateManager, voiceHandler *voice.VoiceHandler, subscriptionService *service.SubscriptionService, postHistoryRepo *database.PostHistoryRepository) *InlineHandler {
+func NewInlineHandler(stateManager *StateManager, voiceHandler *voice.VoiceHandler, subscriptionService *service.SubscriptionService, quotaService *service.QuotaService, entitlements *service.EntitlementService, postHistoryRepo *database.PostHistoryRepository) *InlineHandler {
 	// Загружаем промпты
 	promptsFile, err := os.ReadFile("internal/infrastructure/prompts/prompts.json")
 	if err != nil {
@@ -45,6 +48,8 @@ func NewInlineHandler(stateManager *StateManager, voiceHandler *voice.VoiceHandl
 		stateManager:        stateManager,
 		voiceHandler:        voiceHandler,
 		subscriptionService: subscriptionService,
+		quotaService:        quotaService,
+		entitlements:        entitlements,
 		postHistoryRepo:     postHistoryRepo,
 		prompts:             prompts,
 	}
@@ -106,11 +111,23 @@ func (ih *InlineHandler) HandleCallback(bot *Bot, callback *tgbotapi.CallbackQue
 	case "buy_premium":
 		ih.handleBuyPremium(bot, callback)
 	case "confirm_purchase":
-		ih.handleConfirmPurchase(bot, callback)
+		ih.handleConfirmPurchase(bot, callback, "", "")
+	case startTrialCallback:
+		ih.handleStartTrial(bot, callback)
+	case creditPacksCallback:
+		ih.handleCreditPacks(bot, callback)
+	case giftPremiumCallback:
+		ih.handleGiftOptions(bot, callback)
+	case referralCallback:
+		ih.handleReferral(bot, callback)
 	case "cancel_subscription":
 		ih.handleCancelSubscription(bot, callback)
 	case "confirm_cancel_subscription":
 		ih.handleConfirmCancelSubscription(bot, callback)
+	case pauseSubscriptionCallback:
+		ih.handlePauseOptions(bot, callback)
+	case resumeSubscriptionCallback:
+		ih.handleResumeSubscription(bot, callback)
 	case "retry_payment":
 		ih.handleRetryPayment(bot, callback)
 	case "change_payment_method":
@@ -128,6 +145,9 @@ func (ih *InlineHandler) HandleCallback(bot *Bot, callback *tgbotapi.CallbackQue
 	case "no_action":
 		// Игнорируем нажатие на пробел-заглушку
 		return
+	case marketingOptOutCallback:
+		bot.Request(tgbotapi.NewCallback(callback.ID, "🔕 Вы отписались от рассылок"))
+		bot.SetMarketingOptOut(callback.Message.Chat.ID, callback.From.ID, true)
 	default:
 		// Проверяем, не является ли это callback для страниц истории или просмотра постов
 		if strings.HasPrefix(callback.Data, "post_history_") {
@@ -144,6 +164,34 @@ func (ih *InlineHandler) HandleCallback(bot *Bot, callback *tgbotapi.CallbackQue
 				return
 			}
 		}
+		if tariffID, ok := strings.CutPrefix(callback.Data, buyTariffCallbackPrefix); ok {
+			ih.handleBuyTariff(bot, callback, tariffID)
+			return
+		}
+		if tariffID, ok := strings.CutPrefix(callback.Data, confirmPurchaseCallbackPrefix); ok {
+			ih.handleConfirmPurchase(bot, callback, tariffID, "")
+			return
+		}
+		if tariffID, ok := strings.CutPrefix(callback.Data, enterPromoCallbackPrefix); ok {
+			ih.handleEnterPromo(bot, callback, tariffID)
+			return
+		}
+		if tariffID, ok := strings.CutPrefix(callback.Data, confirmPromoPurchaseCallbackPrefix); ok {
+			ih.handleConfirmPurchase(bot, callback, tariffID, ih.stateManager.GetState(callback.From.ID).PromoCode)
+			return
+		}
+		if packID, ok := strings.CutPrefix(callback.Data, buyCreditsCallbackPrefix); ok {
+			ih.handleBuyCredits(bot, callback, packID)
+			return
+		}
+		if months, ok := parseGiftMonths(callback.Data); ok {
+			ih.handleBuyGift(bot, callback, months)
+			return
+		}
+		if days, ok := parsePauseDays(callback.Data); ok {
+			ih.handlePauseSubscription(bot, callback, days)
+			return
+		}
 		ih.handleUnknownCallback(bot, callback)
 	}
 }
@@ -152,16 +200,14 @@ func (ih *InlineHandler) HandleCallback(bot *Bot, callback *tgbotapi.CallbackQue
 func (ih *InlineHandler) handleCreatePost(bot *Bot, callback *tgbotapi.CallbackQuery) {
 	userID := callback.From.ID
 
-	// Проверяем подписку пользователя
-	subscriptionStatus, canCreate, remainingFree, err := ih.checkUserSubscriptionStatus(userID)
+	// Проверяем квоту созданий постов
+	subscriptionStatus, quota, err := ih.checkUserQuota(userID, domain.QuotaGenerations, 1)
 	if err != nil {
-		log.Printf("Ошибка проверки подписки: %v", err)
-		// В случае ошибки разрешаем создание
-		subscriptionStatus = "error"
-		canCreate = true
+		log.Printf("Ошибка проверки квоты: %v", err)
 	}
 
-	if canCreate {
+	// В случае ошибки разрешаем создание: квота все равно проверяется при списании
+	if quota == nil || quota.Allows(1) {
 		// Обновляем состояние
 		ih.stateManager.UpdateStep(userID, "selecting_content_type")
 		ih.stateManager.SetContentType(userID, "telegram_post")
@@ -181,27 +227,7 @@ func (ih *InlineHandler) handleCreatePost(bot *Bot, callback *tgbotapi.CallbackQ
 		bot.Send(msg)
 	} else {
 		// Показываем информацию о подписке и предлагаем оформить
-		keyboard := ih.createSubscriptionKeyboard(userID, subscriptionStatus, remainingFree)
-
-		var messageText string
-		switch subscriptionStatus {
-		case "cancelled":
-			messageText = "❌ Ваша подписка была отменена.\n\n"
-		case "expired":
-			messageText = "⏰ Срок действия подписки истек.\n\n"
-		case "no_subscription":
-			messageText = "💎 У вас нет активной подписки.\n\n"
-		default:
-			messageText = "💎 Требуется подписка для создания контента.\n\n"
-		}
-
-		if remainingFree > 0 {
-			messageText += fmt.Sprintf("🎁 У вас осталось %d бесплатных созданий в этом месяце.\n\n", remainingFree)
-		} else {
-			messageText += "🎁 Бесплатные создания на этот месяц закончились.\n\n"
-		}
-
-		messageText += "💳 Оформите подписку для неограниченного создания контента!"
+		messageText, keyboard := ih.quotaExceededMessage(userID, subscriptionStatus, quota)
 
 		msg := tgbotapi.NewEditMessageText(
 			callback.Message.Chat.ID,
@@ -293,6 +319,20 @@ func (ih *InlineHandler) handleStartCreation(bot *Bot, callback *tgbotapi.Callba
 		return
 	}
 
+	// Списываем квоты до распознавания: создание (или рерайт) и минуты аудио
+	resource := domain.QuotaGenerations
+	if state.RewriteMode == "voice" {
+		resource = domain.QuotaRewrites
+	}
+	pendingSeconds := 0
+	for _, voice := range state.PendingVoices {
+		pendingSeconds += voice.Duration
+	}
+	consumptions, ok := ih.consumeQuotas(bot, callback.Message.Chat.ID, userID, resource, pendingSeconds)
+	if !ok {
+		return
+	}
+
 	// Отправляем сообщение о начале обработки
 	msg := tgbotapi.NewEditMessageText(
 		callback.Message.Chat.ID,
@@ -353,6 +393,8 @@ func (ih *InlineHandler) handleStartCreation(bot *Bot, callback *tgbotapi.Callba
 
 	// Проверяем результаты
 	if len(results) == 0 {
+		// Ни одно сообщение не распознано — возвращаем все списанное
+		ih.refundQuotas(consumptions...)
 		msg := tgbotapi.NewMessage(
 			callback.Message.Chat.ID,
 			"❌ Не удалось обработать голосовые сообщения. Попробуйте еще раз.",
@@ -381,6 +423,7 @@ func (ih *InlineHandler) handleStartCreation(bot *Bot, callback *tgbotapi.Callba
 		// Получаем исходный текст поста
 		originalText := ih.stateManager.GetRewritingPost(userID)
 		if originalText == "" {
+			ih.refundQuotas(consumptions[0])
 			msg := tgbotapi.NewMessage(
 				callback.Message.Chat.ID,
 				"❌ Ошибка: исходный текст поста не найден.",
@@ -397,6 +440,8 @@ func (ih *InlineHandler) handleStartCreation(bot *Bot, callback *tgbotapi.Callba
 	}
 	if err != nil {
 		log.Printf("Ошибка генерации поста: %v", err)
+		// Аудио уже распознано, возвращаем только создание
+		ih.refundQuotas(consumptions[0])
 		msg := tgbotapi.NewMessage(
 			callback.Message.Chat.ID,
 			"❌ Не удалось сгенерировать пост. Попробуйте еще раз.",
@@ -522,21 +567,19 @@ func (ih *InlineHandler) handleMainMenu(bot *Bot, callback *tgbotapi.CallbackQue
 		log.Printf("Пост сохранен в БД (заглушка) при выходе в меню: %s", state.CurrentPost.ContentType)
 	}
 
-	// Получаем информацию о подписке
-	sub, _ := bot.SubscriptionService.GetUserSubscription(userID)
-	// Получаем общее использование (лимит 5 запросов навсегда)
-	used, _ := bot.DB.GetUserUsageTotal(userID)
-	const freeLimit = 5 // Общий лимит запросов навсегда
+	// Права пользователя: Premium по подписке, в grace period или выданный администратором
+	entitlement := bot.Entitlement(userID)
+	// Остаток созданий постов в текущем месяце
+	quota, err := ih.quotaService.Check(userID, domain.QuotaGenerations)
+	if err != nil {
+		log.Printf("Ошибка получения квоты: %v", err)
+	}
 
 	var subLabel string
-	if sub != nil && sub.Active {
+	if entitlement.IsPremium() || quota == nil || quota.IsUnlimited() {
 		subLabel = "💎 Подписка: Premium"
 	} else {
-		remaining := freeLimit - used
-		if remaining < 0 {
-			remaining = 0
-		}
-		subLabel = fmt.Sprintf("💎 Подписка (%d/%d)", remaining, freeLimit)
+		subLabel = fmt.Sprintf("💎 Подписка (%d/%d)", quota.Remaining, quota.Limit)
 	}
 
 	// Полностью очищаем состояние
@@ -625,9 +668,6 @@ func (ih *InlineHandler) handleSavePost(bot *Bot, callback *tgbotapi.CallbackQue
 		return
 	}
 
-	// Безопасно увеличиваем счетчик использований с проверкой подписки
-	ih.incrementUsageIfNeeded(userID)
-
 	// Сохраняем пост в БД (заглушка)
 	ih.stateManager.SavePost(userID, *state.CurrentPost)
 	log.Printf("Пост сохранен в БД (заглушка): %s", state.CurrentPost.ContentType)
@@ -682,9 +722,6 @@ func (ih *InlineHandler) handleSavePost(bot *Bot, callback *tgbotapi.CallbackQue
 func (ih *InlineHandler) handleApprove(bot *Bot, callback *tgbotapi.CallbackQuery) {
 	userID := callback.From.ID
 
-	// Безопасно увеличиваем счетчик использований с проверкой подписки
-	ih.incrementUsageIfNeeded(userID)
-
 	// Сохраняем пост в БД (заглушка)
 	state := ih.stateManager.GetState(userID)
 	var postContent string
@@ -778,6 +815,16 @@ func (ih *InlineHandler) handleEditStartCreation(bot *Bot, callback *tgbotapi.Ca
 		return
 	}
 
+	// Списываем квоты до распознавания: правку и минуты аудио
+	pendingSeconds := 0
+	for _, voice := range state.PendingEdits {
+		pendingSeconds += voice.Duration
+	}
+	consumptions, ok := ih.consumeQuotas(bot, callback.Message.Chat.ID, userID, domain.QuotaEdits, pendingSeconds)
+	if !ok {
+		return
+	}
+
 	// Отправляем сообщение о начале обработки
 	msg := tgbotapi.NewEditMessageText(
 		callback.Message.Chat.ID,
@@ -837,6 +884,7 @@ func (ih *InlineHandler) handleEditStartCreation(bot *Bot, callback *tgbotapi.Ca
 
 	// Проверяем результаты
 	if len(results) == 0 {
+		ih.refundQuotas(consumptions...)
 		msg := tgbotapi.NewMessage(
 			callback.Message.Chat.ID,
 			"❌ Не удалось обработать голосовые сообщения с правками. Попробуйте еще раз.",
@@ -865,6 +913,7 @@ func (ih *InlineHandler) handleEditStartCreation(bot *Bot, callback *tgbotapi.Ca
 	updatedText, err := ih.voiceHandler.GenerateContent(contentType, prompt, userID, firstHistoryID)
 	if err != nil {
 		log.Printf("Ошибка генерации обновленного поста: %v", err)
+		ih.refundQuotas(consumptions[0])
 		msg := tgbotapi.NewMessage(
 			callback.Message.Chat.ID,
 			"❌ Не удалось сгенерировать обновленный пост. Попробуйте еще раз.",
@@ -1031,17 +1080,19 @@ func (ih *InlineHandler) handleProfile(bot *Bot, callback *tgbotapi.CallbackQuer
 	userID := callback.From.ID
 
 	// Получаем информацию о подписке пользователя
-	sub, _ := bot.SubscriptionService.GetUserSubscription(userID)
+	entitlement := bot.Entitlement(userID)
+	sub := entitlement.Subscription
 	available := bot.SubscriptionService.GetAvailableTariffs()
 	var premium domain.Tariff
 	if len(available) > 0 {
 		premium = available[0]
 	}
+	price := formatStartingPrice(available)
 
 	var messageText string
 	var keyboard tgbotapi.InlineKeyboardMarkup
 
-	if sub == nil || !sub.Active {
+	if !entitlement.HasSubscriptionAccess() {
 		// Нет подписки
 		messageText = fmt.Sprintf(`👤 Ваш профиль
 
@@ -1056,22 +1107,29 @@ func (ih *InlineHandler) handleProfile(bot *Bot, callback *tgbotapi.CallbackQuer
 — Быстрая скорость генерации постов
 — Рерайтинг посто по ссылке в Телеграм
 
-💰 Стоимость: %.0f₽/месяц`, userID, premium.Description, premium.Price)
+💰 Стоимость: %s`, userID, premium.Description, price)
+		if quotaText := bot.QuotaProfileText(userID); quotaText != "" {
+			messageText += "\n\n" + quotaText
+		}
+
+		subscriptionButton := tgbotapi.NewInlineKeyboardButtonData("💳 Приобрести подписку", "buy_premium")
+		if entitlement.Status == domain.SubscriptionStatusPaused {
+			// Подписка на паузе: ведем на экран подписки с возобновлением, а не к покупке
+			subscriptionButton = tgbotapi.NewInlineKeyboardButtonData("⏸ Подписка на паузе", "subscription")
+		}
 
-		keyboard = tgbotapi.NewInlineKeyboardMarkup(
+		keyboard = bot.withReferralRow(tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("📚 История постов", "post_history"),
 			),
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("💰 История оплат", "payment_history"),
 			),
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("💳 Приобрести подписку", "buy_premium"),
-			),
+			tgbotapi.NewInlineKeyboardRow(subscriptionButton),
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("🔙 Назад в меню", "main_menu"),
 			),
-		)
+		))
 	} else {
 		// Есть активная подписка
 		nextPay := sub.NextPayment.Format("02.01.2006")
@@ -1081,8 +1139,11 @@ func (ih *InlineHandler) handleProfile(bot *Bot, callback *tgbotapi.CallbackQuer
 💎 Подписка: Premium
 📅 Следующий платеж: %s
 ✅ Статус: активна`, userID, nextPay)
+		if quotaText := bot.QuotaProfileText(userID); quotaText != "" {
+			messageText += "\n\n" + quotaText
+		}
 
-		keyboard = tgbotapi.NewInlineKeyboardMarkup(
+		keyboard = bot.withReferralRow(tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("📚 История постов", "post_history"),
 			),
@@ -1095,7 +1156,7 @@ func (ih *InlineHandler) handleProfile(bot *Bot, callback *tgbotapi.CallbackQuer
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("🔙 Назад в меню", "main_menu"),
 			),
-		)
+		))
 	}
 
 	msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, messageText)
@@ -1109,24 +1170,27 @@ func (ih *InlineHandler) handleSubscription(bot *Bot, callback *tgbotapi.Callbac
 	userID := callback.From.ID
 
 	// Информация о подписке
-	sub, _ := bot.SubscriptionService.GetUserSubscription(userID)
-	// Лимит бесплатных запросов
-	used, _ := bot.DB.GetUserUsageToday(userID)
-	const freeLimit = 5
+	entitlement := bot.Entitlement(userID)
+	sub := entitlement.Subscription
+	// Остаток бесплатных созданий
+	remaining, freeLimit := 0, 0
+	if quota, err := ih.quotaService.Check(userID, domain.QuotaGenerations); err != nil {
+		log.Printf("Ошибка получения квоты: %v", err)
+	} else if !quota.IsUnlimited() {
+		remaining, freeLimit = quota.Remaining, quota.Limit
+	}
 
 	var text string
 	var keyboard tgbotapi.InlineKeyboardMarkup
 
-	if sub == nil || !sub.Active {
-		remaining := freeLimit - used
-		if remaining < 0 {
-			remaining = 0
-		}
+	if sub != nil && sub.Status == string(domain.SubscriptionStatusPaused) {
+		text, keyboard = buildPausedSubscriptionView(sub)
+	} else if !entitlement.HasSubscriptionAccess() {
 		text = fmt.Sprintf(`💎 Подписка
 
 📊 Текущий тариф: *Бесплатный*
 ⏰ Срок действия: бессрочно
-📈 Осталось бесплатных постов в этом месяце: *%d/%d*
+📈 Осталось бесплатных постов в текущем периоде: *%d/%d*
 
 Подключи Premium тариф и получи:
 
@@ -1135,25 +1199,34 @@ func (ih *InlineHandler) handleSubscription(bot *Bot, callback *tgbotapi.Callbac
 — Быстрая скорость генерации постов
 — Рерайтинг посто по ссылке в Телеграм
 
-💳 Стоимость: 990₽/месяц`, remaining, freeLimit)
+💳 Стоимость: %s`, remaining, freeLimit, formatStartingPrice(bot.SubscriptionService.GetAvailableTariffs()))
 
-		keyboard = tgbotapi.NewInlineKeyboardMarkup(
+		keyboard = bot.WithTrialRow(userID, bot.withGiftRow(tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("💰 Купить подписку", "buy_premium"),
 			),
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("🔙 Назад в меню", "main_menu"),
 			),
-		)
+		)))
 	} else {
 		var subStatus string
-		if sub.Status == string(domain.SubscriptionStatusCancelled) {
+		nextPayment := sub.NextPayment
+		switch {
+		case entitlement.Source == domain.EntitlementTrial:
+			// После пробного периода с привязанной карты спишется цена тарифа
+			subStatus = fmt.Sprintf("Пробный период, списание %.2f ₽", sub.Amount)
+		case entitlement.Source == domain.EntitlementGrace:
+			// Продление не прошло: доступ сохраняется на grace period
+			subStatus = "Продление не оплачено, доступ сохранится до"
+			nextPayment = *entitlement.Until
+		case !entitlement.AutoRenew:
 			subStatus = "Подписка активна до"
-		} else {
+		default:
 			subStatus = "Следующий платеж"
 		}
 		// надо поставить московское время
-		nextPay := sub.NextPayment.In(time.FixedZone("UTC+3", 3*60*60)).Format("02.01.2006 15:04 МСК")
+		nextPay := formatMoscowTime(nextPayment)
 		text = fmt.Sprintf(`💎 Подписка
 
 📊 Текущий тариф: *Premium*
@@ -1161,6 +1234,11 @@ func (ih *InlineHandler) handleSubscription(bot *Bot, callback *tgbotapi.Callbac
 ✅ Статус: активна`, subStatus, nextPay)
 
 		var rows [][]tgbotapi.InlineKeyboardButton
+		if service.CanPause(sub, time.Now().UTC()) && len(bot.SubscriptionService.PauseOptions()) > 0 {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("⏸ Поставить на паузу", pauseSubscriptionCallback),
+			))
+		}
 		if sub.Status == string(domain.SubscriptionStatusActive) && sub.YKPaymentMethodID != nil && sub.YKLastPaymentID != nil {
 			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("❌ Отменить подписку и отвязать карту", "cancel_subscription"),
@@ -1171,7 +1249,7 @@ func (ih *InlineHandler) handleSubscription(bot *Bot, callback *tgbotapi.Callbac
 			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад в меню", "main_menu"),
 		))
 
-		keyboard = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		keyboard = bot.withGiftRow(tgbotapi.NewInlineKeyboardMarkup(rows...))
 	}
 
 	msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
@@ -1286,67 +1364,228 @@ func (ih *InlineHandler) handleBuyPremium(bot *Bot, callback *tgbotapi.CallbackQ
 		return
 	}
 
-	// Показываем экран оформления подписки с преимуществами
-	// Рассчитываем дату окончания подписки (текущая дата + 1 месяц)
-	subscriptionEndDate := time.Now().AddDate(0, 1, 0)
-	formattedDate := subscriptionEndDate.Format("02.01.2006")
-
-	text := "💎 *Оформление Premium подписки*\n\n" +
-		"✨ *Преимущества Premium:*\n" +
-		"• 🚀 Неограниченное количество постов\n" +
-		"• ⚡ Приоритетная обработка запросов\n" +
-		"• 🎨 Расширенные настройки стилизации\n" +
-		"• 📈 Детальная аналитика использования\n" +
-		"• 🔧 Эксклюзивные функции и шаблоны\n" +
-		"• 💬 Приоритетная техподдержка\n\n" +
-		"💰 *Стоимость:* 990₽/месяц\n" +
-		"📅 *Период:* 1 месяц (до " + formattedDate + ")\n" +
-		"♻️ *Автопродление:* включено\n\n" +
-		// "📋 *Оферта:* [Пользовательское соглашение](#)\n\n" +
-		"Нажмите «Подтвердить покупку» для перехода к оплате:"
+	// Показываем единственный тариф сразу, а при нескольких — список на выбор
+	text, keyboard := buildTariffPurchaseView(bot.SubscriptionService.GetAvailableTariffs(), time.Now())
+	keyboard = bot.withCreditPacksRow(keyboard)
+	keyboard = bot.withGiftRow(keyboard)
+	keyboard = bot.WithTrialRow(userID, keyboard)
+
+	msg := tgbotapi.NewEditMessageText(
+		callback.Message.Chat.ID,
+		callback.Message.MessageID,
+		text,
+	)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = &keyboard
+	bot.Send(msg)
+}
+
+// handleBuyTariff показывает экран оформления выбранного тарифа
+func (ih *InlineHandler) handleBuyTariff(bot *Bot, callback *tgbotapi.CallbackQuery, tariffID string) {
+	tariff := findAvailableTariff(bot.SubscriptionService.GetAvailableTariffs(), tariffID)
+	if tariff == nil {
+		bot.Request(tgbotapi.NewCallback(callback.ID, "❌ Тариф недоступен"))
+		ih.handleBuyPremium(bot, callback)
+		return
+	}
+
+	text, keyboard := buildTariffOfferView(tariff, time.Now(), "buy_premium")
+	msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = &keyboard
+	bot.Send(msg)
+}
+
+// handleEnterPromo просит ввести промокод для оформления тарифа
+func (ih *InlineHandler) handleEnterPromo(bot *Bot, callback *tgbotapi.CallbackQuery, tariffID string) {
+	state := ih.stateManager.GetState(callback.From.ID)
+	state.WaitingForPromo = tariffID
+	state.PromoCode = ""
+
+	text, keyboard := buildEnterPromoView(tariffID)
+	msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = &keyboard
+	bot.Send(msg)
+}
+
+// handleConfirmPurchase обрабатывает подтверждение покупки тарифа.
+// Пустой tariffID (кнопки старых сообщений) означает первый доступный тариф.
+// Непустой promoCode уменьшает сумму первой оплаты и заданного промокодом числа продлений.
+func (ih *InlineHandler) handleConfirmPurchase(bot *Bot, callback *tgbotapi.CallbackQuery, tariffID, promoCode string) {
+	userID := callback.From.ID
 
+	available := bot.SubscriptionService.GetAvailableTariffs()
+	var tariff *domain.Tariff
+	if tariffID == "" && len(available) > 0 {
+		tariff = &available[0]
+	} else {
+		tariff = findAvailableTariff(available, tariffID)
+	}
+	if tariff == nil {
+		msg := tgbotapi.NewEditMessageText(
+			callback.Message.Chat.ID,
+			callback.Message.MessageID,
+			"❌ Этот тариф больше недоступен. Выберите другой тариф.",
+		)
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("💎 Тарифы", "buy_premium"),
+			),
+		)
+		msg.ReplyMarkup = &keyboard
+		bot.Send(msg)
+		return
+	}
+
+	// Создаем ссылку на оплату подписки
+	paymentURL, err := bot.CreateSubscriptionLink(userID, tariff.ID, tariff.Price, promoCode)
+	if err != nil && promoCode != "" {
+		// Промокод мог перестать действовать, пока пользователь был на экране оплаты
+		log.Printf("Промокод %s не применен для пользователя %d: %v", promoCode, userID, err)
+		ih.stateManager.GetState(userID).PromoCode = ""
+		msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, promoErrorText(err))
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("💎 Оформить без промокода", buyTariffCallbackPrefix+tariff.ID),
+			),
+		)
+		msg.ReplyMarkup = &keyboard
+		bot.Send(msg)
+		return
+	}
+	if err != nil {
+		msg := tgbotapi.NewEditMessageText(
+			callback.Message.Chat.ID,
+			callback.Message.MessageID,
+			"❌ Ошибка создания ссылки на оплату. Попробуйте позже.",
+		)
+		bot.Send(msg)
+		return
+	}
+	ih.stateManager.GetState(userID).PromoCode = ""
+
+	// Создаем кнопку для перехода к оплате
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("✅ Подтвердить покупку", "confirm_purchase"),
+			tgbotapi.NewInlineKeyboardButtonURL("💳 Перейти к оплате", paymentURL),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "subscription"),
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", buyTariffCallbackPrefix+tariff.ID),
 		),
 	)
 
 	msg := tgbotapi.NewEditMessageText(
 		callback.Message.Chat.ID,
 		callback.Message.MessageID,
-		text,
+		"💳 *Переход к оплате*\n\n"+
+			"Нажмите кнопку ниже для перехода к оплате.\n"+
+			"После успешной оплаты ваша подписка будет активирована автоматически.",
 	)
 	msg.ParseMode = "Markdown"
 	msg.ReplyMarkup = &keyboard
 	bot.Send(msg)
 }
 
-// handleConfirmPurchase обрабатывает подтверждение покупки
-func (ih *InlineHandler) handleConfirmPurchase(bot *Bot, callback *tgbotapi.CallbackQuery) {
+// handleStartTrial создает ссылку на привязку карты для пробного периода
+func (ih *InlineHandler) handleStartTrial(bot *Bot, callback *tgbotapi.CallbackQuery) {
 	userID := callback.From.ID
 
-	// Создаем ссылку на оплату подписки
-	paymentURL, err := bot.CreateSubscriptionLink(userID, "premium", 1.0)
+	user, _ := bot.DB.GetOrCreateUser(userID, callback.From.UserName, callback.From.FirstName, callback.From.LastName)
+	if user.Email == "" {
+		// Для чека за привязку карты тоже нужен e-mail
+		ih.handleBuyPremium(bot, callback)
+		return
+	}
+
+	offer := bot.trialOffer(userID)
+	if offer == nil {
+		bot.Request(tgbotapi.NewCallback(callback.ID, "❌ Пробный период недоступен"))
+		ih.handleBuyPremium(bot, callback)
+		return
+	}
+
+	paymentURL, err := bot.SubscriptionService.CreateTrialLink(userID)
 	if err != nil {
+		log.Printf("Ошибка создания ссылки на пробный период для пользователя %d: %v", userID, err)
+		msg := tgbotapi.NewEditMessageText(
+			callback.Message.Chat.ID,
+			callback.Message.MessageID,
+			"❌ Ошибка создания ссылки на привязку карты. Попробуйте позже.",
+		)
+		bot.Send(msg)
+		return
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("💳 Привязать карту", paymentURL),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "buy_premium"),
+		),
+	)
+
+	msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, buildTrialLinkText(offer))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = &keyboard
+	bot.Send(msg)
+}
+
+// handleCreditPacks показывает пакеты кредитов, доступные для разовой покупки
+func (ih *InlineHandler) handleCreditPacks(bot *Bot, callback *tgbotapi.CallbackQuery) {
+	if bot.CreditService == nil {
+		bot.Request(tgbotapi.NewCallback(callback.ID, "❌ Пакеты временно недоступны"))
+		return
+	}
+
+	packs, err := bot.CreditService.GetPacks()
+	if err != nil {
+		log.Printf("Ошибка получения пакетов кредитов: %v", err)
+	}
+	balance, err := bot.CreditService.Balance(callback.From.ID)
+	if err != nil {
+		log.Printf("Ошибка получения баланса кредитов: %v", err)
+	}
+
+	text, keyboard := buildCreditPacksView(packs, balance)
+	msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = &keyboard
+	bot.Send(msg)
+}
+
+// handleBuyCredits создает разовый платеж за пакет кредитов и показывает ссылку на оплату
+func (ih *InlineHandler) handleBuyCredits(bot *Bot, callback *tgbotapi.CallbackQuery, packID string) {
+	if bot.CreditService == nil {
+		bot.Request(tgbotapi.NewCallback(callback.ID, "❌ Пакеты временно недоступны"))
+		return
+	}
+
+	paymentURL, err := bot.CreditService.CreatePurchaseLink(callback.From.ID, packID)
+	if err != nil {
+		log.Printf("Ошибка создания оплаты пакета %s: %v", packID, err)
 		msg := tgbotapi.NewEditMessageText(
 			callback.Message.Chat.ID,
 			callback.Message.MessageID,
 			"❌ Ошибка создания ссылки на оплату. Попробуйте позже.",
 		)
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🎟 Пакеты", creditPacksCallback),
+			),
+		)
+		msg.ReplyMarkup = &keyboard
 		bot.Send(msg)
 		return
 	}
 
-	// Создаем кнопку для перехода к оплате
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonURL("💳 Перейти к оплате", paymentURL),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "buy_premium"),
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", creditPacksCallback),
 		),
 	)
 
@@ -1355,13 +1594,178 @@ func (ih *InlineHandler) handleConfirmPurchase(bot *Bot, callback *tgbotapi.Call
 		callback.Message.MessageID,
 		"💳 *Переход к оплате*\n\n"+
 			"Нажмите кнопку ниже для перехода к оплате.\n"+
-			"После успешной оплаты ваша подписка будет активирована автоматически.",
+			"Кредиты будут зачислены автоматически после успешной оплаты.",
 	)
 	msg.ParseMode = "Markdown"
 	msg.ReplyMarkup = &keyboard
 	bot.Send(msg)
 }
 
+// handleGiftOptions показывает варианты подарочного Premium
+func (ih *InlineHandler) handleGiftOptions(bot *Bot, callback *tgbotapi.CallbackQuery) {
+	if bot.GiftService == nil {
+		bot.Request(tgbotapi.NewCallback(callback.ID, "❌ Подарки временно недоступны"))
+		return
+	}
+	userID := callback.From.ID
+
+	user, _ := bot.DB.GetOrCreateUser(userID, callback.From.UserName, callback.From.FirstName, callback.From.LastName)
+	if user.Email == "" {
+		// Чек за подарок тоже отправляется на e-mail покупателя
+		ih.stateManager.GetState(userID).WaitingForEmail = true
+		msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID,
+			"📧 *Введите ваш e-mail*\n\n"+
+				"Для получения кассового чека нужен e-mail адрес.\n"+
+				"Пример: user@example.com\n\n"+
+				"💡 Для отмены используйте /start")
+		msg.ParseMode = "Markdown"
+		bot.Send(msg)
+		return
+	}
+
+	options, err := bot.GiftService.GiftOptions()
+	if err != nil {
+		log.Printf("Ошибка получения вариантов подарка: %v", err)
+	}
+	text, keyboard := buildGiftOptionsView(options)
+	msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = &keyboard
+	bot.Send(msg)
+}
+
+// handleBuyGift создает разовый платеж за подарок и показывает ссылку на оплату
+func (ih *InlineHandler) handleBuyGift(bot *Bot, callback *tgbotapi.CallbackQuery, months int) {
+	if bot.GiftService == nil {
+		bot.Request(tgbotapi.NewCallback(callback.ID, "❌ Подарки временно недоступны"))
+		return
+	}
+
+	paymentURL, err := bot.GiftService.CreateGiftLink(callback.From.ID, months)
+	if err != nil {
+		log.Printf("Ошибка создания оплаты подарка на %d мес.: %v", months, err)
+		msg := tgbotapi.NewEditMessageText(
+			callback.Message.Chat.ID,
+			callback.Message.MessageID,
+			"❌ Ошибка создания ссылки на оплату. Попробуйте позже.",
+		)
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🎁 Подарки", giftPremiumCallback),
+			),
+		)
+		msg.ReplyMarkup = &keyboard
+		bot.Send(msg)
+		return
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("💳 Перейти к оплате", paymentURL),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", giftPremiumCallback),
+		),
+	)
+
+	msg := tgbotapi.NewEditMessageText(
+		callback.Message.Chat.ID,
+		callback.Message.MessageID,
+		"💳 *Переход к оплате*\n\n"+
+			"Нажмите кнопку ниже для перехода к оплате.\n"+
+			"После оплаты мы пришлем код подарка и ссылку для получателя.",
+	)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = &keyboard
+	bot.Send(msg)
+}
+
+// handleReferral показывает реферальную ссылку пользователя и статистику приглашений
+func (ih *InlineHandler) handleReferral(bot *Bot, callback *tgbotapi.CallbackQuery) {
+	if bot.Referrals == nil {
+		bot.Request(tgbotapi.NewCallback(callback.ID, "❌ Реферальная программа недоступна"))
+		return
+	}
+	userID := callback.From.ID
+
+	user, err := bot.DB.GetOrCreateUser(userID, callback.From.UserName, callback.From.FirstName, callback.From.LastName)
+	if err != nil {
+		log.Printf("Ошибка получения пользователя %d: %v", userID, err)
+		bot.Request(tgbotapi.NewCallback(callback.ID, "❌ Не удалось загрузить ссылку, попробуйте позже"))
+		return
+	}
+	stats, err := bot.Referrals.Stats(userID)
+	if err != nil {
+		log.Printf("Ошибка получения статистики приглашений пользователя %d: %v", userID, err)
+	}
+
+	text, keyboard := buildReferralView(bot.referralLink(user.ReferralCode), stats, bot.Referrals.Reward())
+	msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	msg.ReplyMarkup = &keyboard
+	bot.Send(msg)
+}
+
+// handlePauseOptions показывает варианты длительности паузы подписки
+func (ih *InlineHandler) handlePauseOptions(bot *Bot, callback *tgbotapi.CallbackQuery) {
+	pausesLeft, err := bot.SubscriptionService.PausesLeft(callback.From.ID)
+	if err != nil {
+		log.Printf("❌ Error counting pauses for user %d: %v", callback.From.ID, err)
+		bot.Send(tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID,
+			"❌ Не удалось загрузить настройки паузы. Попробуйте позже."))
+		return
+	}
+
+	text, keyboard := buildPauseOptionsView(bot.SubscriptionService.PauseOptions(), pausesLeft)
+	msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = &keyboard
+	bot.Send(msg)
+}
+
+// handlePauseSubscription ставит подписку на паузу на выбранное количество дней
+func (ih *InlineHandler) handlePauseSubscription(bot *Bot, callback *tgbotapi.CallbackQuery, days int) {
+	userID := callback.From.ID
+
+	sub, err := bot.SubscriptionService.PauseSubscription(userID, days)
+	if err != nil {
+		log.Printf("❌ Error pausing subscription for user %d: %v", userID, err)
+		text := "❌ Не удалось поставить подписку на паузу. Попробуйте позже."
+		switch {
+		case errors.Is(err, domain.ErrPauseLimitReached):
+			text = "⏸ Лимит пауз на ближайшие 12 месяцев исчерпан."
+		case errors.Is(err, domain.ErrPauseNotAllowed):
+			text = "⏸ Сейчас подписку нельзя поставить на паузу: пауза доступна для активной подписки с автопродлением и без неоплаченных списаний."
+		}
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "subscription"),
+		))
+		msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+		msg.ReplyMarkup = &keyboard
+		bot.Send(msg)
+		return
+	}
+
+	bot.Request(tgbotapi.NewCallback(callback.ID, "⏸ Подписка на паузе"))
+	text, keyboard := buildPausedSubscriptionView(sub)
+	msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	msg.ReplyMarkup = &keyboard
+	bot.Send(msg)
+}
+
+// handleResumeSubscription досрочно снимает подписку с паузы
+func (ih *InlineHandler) handleResumeSubscription(bot *Bot, callback *tgbotapi.CallbackQuery) {
+	userID := callback.From.ID
+
+	if _, err := bot.SubscriptionService.ResumeSubscription(userID); err != nil {
+		log.Printf("❌ Error resuming subscription for user %d: %v", userID, err)
+		bot.Request(tgbotapi.NewCallback(callback.ID, "❌ Подписка не на паузе"))
+		return
+	}
+
+	bot.Request(tgbotapi.NewCallback(callback.ID, "▶️ Подписка возобновлена"))
+	ih.handleSubscription(bot, callback)
+}
+
 // handleCancelSubscription обрабатывает отмену подписки
 func (ih *InlineHandler) handleCancelSubscription(bot *Bot, callback *tgbotapi.CallbackQuery) {
 	// Создаем кнопки подтверждения
@@ -1449,7 +1853,7 @@ func (ih *InlineHandler) handleRetryPayment(bot *Bot, callback *tgbotapi.Callbac
 	if subscription != nil && subscription.Status == "suspended" {
 		// Подписка приостановлена - предлагаем восстановить
 		messageText = "🔄 *Восстановление подписки*\n\n" +
-			"Ваша подписка была приостановлена после 3 неудачных попыток списания.\n\n" +
+			"Ваша подписка была приостановлена после неудачных попыток списания.\n\n" +
 			"Для восстановления доступа используйте новую карту:"
 
 		keyboard = tgbotapi.NewInlineKeyboardMarkup(
@@ -1531,7 +1935,7 @@ func (ih *InlineHandler) handleChangePaymentMethod(bot *Bot, callback *tgbotapi.
 	if subscription != nil && subscription.Status == "suspended" {
 		// Подписка приостановлена - предлагаем восстановить
 		messageText = "💳 *Восстановление подписки*\n\n" +
-			"Ваша подписка была приостановлена после 3 неудачных попыток списания.\n\n" +
+			"Ваша подписка была приостановлена после неудачных попыток списания.\n\n" +
 			"Для восстановления доступа используйте новую карту:"
 
 		// Получаем новую ссылку для оплаты
@@ -1594,78 +1998,139 @@ func (ih *InlineHandler) handleUnknownCallback(bot *Bot, callback *tgbotapi.Call
 	bot.Send(msg)
 }
 
-// checkUserSubscriptionStatus проверяет статус подписки пользователя
-func (ih *InlineHandler) checkUserSubscriptionStatus(userID int64) (string, bool, int, error) {
-	// Получаем подписку пользователя
-	subscription, err := ih.subscriptionService.GetUserSubscription(userID)
+// checkUserQuota проверяет, хватает ли пользователю amount единиц ресурса.
+// Возвращает статус подписки для экрана оформления и состояние квоты.
+func (ih *InlineHandler) checkUserQuota(userID int64, resource domain.QuotaResource, amount int) (string, *domain.QuotaStatus, error) {
+	quota, err := ih.quotaService.Check(userID, resource)
 	if err != nil {
-		return "error", false, 0, err
+		return "error", nil, err
 	}
-
-	// Если нет подписки
-	if subscription == nil {
-		// Проверяем бесплатные создания
-		remainingFree, err := ih.getRemainingFreeCreations(userID)
-		if err != nil {
-			return "no_subscription", false, 0, err
-		}
-		return "no_subscription", remainingFree > 0, remainingFree, nil
+	if quota.Allows(amount) {
+		return "active", quota, nil
 	}
+	return ih.subscriptionStatusLabel(userID), quota, nil
+}
 
-	// Если подписка активна
-	if subscription.Status == "active" && subscription.Active {
-		return "active", true, 0, nil
+// subscriptionStatusLabel возвращает статус подписки для сообщения об исчерпанном лимите
+func (ih *InlineHandler) subscriptionStatusLabel(userID int64) string {
+	entitlement, err := ih.entitlements.Get(userID)
+	if err != nil {
+		log.Printf("Ошибка получения прав пользователя: %v", err)
+		return "error"
+	}
+	switch {
+	case entitlement.IsPremium():
+		return "active"
+	case entitlement.Subscription == nil:
+		return "no_subscription"
+	case entitlement.Status == domain.SubscriptionStatusCancelled:
+		return "cancelled"
+	default:
+		return "expired"
 	}
+}
 
-	// Если подписка отменена, проверяем grace period (30 дней)
-	if subscription.Status == "cancelled" {
-		if subscription.CancelledAt != nil {
-			gracePeriodEnd := subscription.CancelledAt.AddDate(0, 0, 30)
-			if time.Now().Before(gracePeriodEnd) {
-				return "cancelled", true, 0, nil
-			}
-		}
-		// Grace period истек, проверяем бесплатные создания
-		remainingFree, err := ih.getRemainingFreeCreations(userID)
-		if err != nil {
-			return "cancelled", false, 0, err
-		}
-		return "cancelled", remainingFree > 0, remainingFree, nil
+// quotaExceededMessage формирует сообщение об исчерпанном лимите и клавиатуру оформления подписки
+func (ih *InlineHandler) quotaExceededMessage(userID int64, subscriptionStatus string, quota *domain.QuotaStatus) (string, tgbotapi.InlineKeyboardMarkup) {
+	var messageText string
+	switch subscriptionStatus {
+	case "active":
+		messageText = "💎 Лимит вашего тарифа исчерпан.\n\n"
+	case "cancelled":
+		messageText = "❌ Ваша подписка была отменена.\n\n"
+	case "expired":
+		messageText = "⏰ Срок действия подписки истек.\n\n"
+	case "no_subscription":
+		messageText = "💎 У вас нет активной подписки.\n\n"
+	default:
+		messageText = "💎 Требуется подписка для создания контента.\n\n"
 	}
 
-	// Если подписка истекла или имеет статус, который не дает неограниченного доступа
-	// Проверяем бесплатные создания
-	remainingFree, err := ih.getRemainingFreeCreations(userID)
-	if err != nil {
-		return "expired", false, 0, err
+	messageText += quotaExceededText(quota)
+	if subscriptionStatus == "active" {
+		messageText += "💳 Выберите тариф с большим лимитом или дождитесь обновления лимитов."
+	} else {
+		messageText += "💳 Оформите подписку для неограниченного создания контента!"
 	}
-	return "expired", remainingFree > 0, remainingFree, nil
+
+	return messageText, ih.createSubscriptionKeyboard(userID, subscriptionStatus)
 }
 
-// getRemainingFreeCreations возвращает количество оставшихся бесплатных созданий
-func (ih *InlineHandler) getRemainingFreeCreations(userID int64) (int, error) {
-	// Получаем количество использований за текущий месяц из usage_stats
-	usageThisMonth, err := ih.stateManager.db.GetUserUsageThisMonth(userID)
-	if err != nil {
-		log.Printf("Ошибка получения количества использований за месяц: %v", err)
-		return 0, err
+// voiceQuotaResource возвращает ресурс, который расходует обработка голосовых в текущем режиме
+func voiceQuotaResource(state *UserState) domain.QuotaResource {
+	switch {
+	case state.ApprovalStatus == "editing":
+		return domain.QuotaEdits
+	case state.RewriteMode == "voice":
+		return domain.QuotaRewrites
+	default:
+		return domain.QuotaGenerations
 	}
+}
 
-	// Максимум бесплатных созданий в месяц - 5
-	const maxFreePostsPerMonth = 5
-	remaining := maxFreePostsPerMonth - usageThisMonth
+// audioMinutes переводит длительность голосовых в минуты с округлением вверх
+func audioMinutes(totalSeconds int) int {
+	return (totalSeconds + 59) / 60
+}
 
-	if remaining < 0 {
-		remaining = 0
-	}
+// consumeQuotas списывает квоты для одной операции: resource и минуты распознаваемого аудио.
+// Если лимита не хватает, отправляет сообщение об исчерпании и возвращает false.
+func (ih *InlineHandler) consumeQuotas(bot *Bot, chatID, userID int64, resource domain.QuotaResource, audioSeconds int) ([]*domain.QuotaConsumption, bool) {
+	requests := []struct {
+		resource domain.QuotaResource
+		amount   int
+	}{
+		{resource, 1},
+		{domain.QuotaAudioMinutes, audioMinutes(audioSeconds)},
+	}
+
+	var consumptions []*domain.QuotaConsumption
+	for _, req := range requests {
+		// Операция, оплаченная кредитом, не расходует минуты аудио бесплатного лимита
+		if req.resource == domain.QuotaAudioMinutes && len(consumptions) > 0 && consumptions[0].PaidByCredits() {
+			continue
+		}
+		consumption, err := ih.quotaService.Consume(userID, req.resource, req.amount)
+		if err == nil {
+			if consumption != nil {
+				consumptions = append(consumptions, consumption)
+			}
+			continue
+		}
+
+		ih.refundQuotas(consumptions...)
+		if !errors.Is(err, domain.ErrQuotaExceeded) {
+			log.Printf("Ошибка списания квоты %s: %v", req.resource, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Произошла ошибка при проверке лимита. Попробуйте позже."))
+			return nil, false
+		}
 
-	log.Printf("[DEBUG] У пользователя %d использований за месяц: %d, осталось бесплатных: %d", userID, usageThisMonth, remaining)
+		subscriptionStatus, quota, err := ih.checkUserQuota(userID, req.resource, req.amount)
+		if err != nil {
+			log.Printf("Ошибка проверки квоты %s: %v", req.resource, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Произошла ошибка при проверке лимита. Попробуйте позже."))
+			return nil, false
+		}
+		text, keyboard := ih.quotaExceededMessage(userID, subscriptionStatus, quota)
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ReplyMarkup = keyboard
+		bot.Send(msg)
+		return nil, false
+	}
+	return consumptions, true
+}
 
-	return remaining, nil
+// refundQuotas возвращает списанные квоты, если операция не удалась
+func (ih *InlineHandler) refundQuotas(consumptions ...*domain.QuotaConsumption) {
+	for _, consumption := range consumptions {
+		if err := ih.quotaService.Refund(consumption); err != nil {
+			log.Printf("Ошибка возврата квоты: %v", err)
+		}
+	}
 }
 
 // createSubscriptionKeyboard создает клавиатуру для подписки
-func (ih *InlineHandler) createSubscriptionKeyboard(userID int64, subscriptionStatus string, remainingFree int) tgbotapi.InlineKeyboardMarkup {
+func (ih *InlineHandler) createSubscriptionKeyboard(userID int64, subscriptionStatus string) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 
 	// Кнопка оформления подписки
@@ -1673,10 +2138,10 @@ func (ih *InlineHandler) createSubscriptionKeyboard(userID int64, subscriptionSt
 		tgbotapi.NewInlineKeyboardButtonData("💳 Оформить подписку", "buy_premium"),
 	))
 
-	// Если есть бесплатные создания, добавляем кнопку продолжить
-	if remainingFree > 0 {
+	// Разовая покупка кредитов для тех, кому не нужна подписка
+	if subscriptionStatus != "active" {
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🎁 Продолжить создание", "create_post"),
+			tgbotapi.NewInlineKeyboardButtonData("🎟 Купить пакет постов", creditPacksCallback),
 		))
 	}
 
@@ -1884,8 +2349,8 @@ func (ih *InlineHandler) handleViewPost(bot *Bot, callback *tgbotapi.CallbackQue
 func (ih *InlineHandler) handlePaymentHistory(bot *Bot, callback *tgbotapi.CallbackQuery) {
 	userID := callback.From.ID
 
-	// Получаем историю всех платежей пользователя
-	subscriptions, err := ih.subscriptionService.GetUserPaymentHistory(userID)
+	// Получаем последние платежи пользователя из журнала
+	payments, err := ih.subscriptionService.GetUserPaymentHistory(userID)
 	if err != nil {
 		log.Printf("Ошибка получения истории оплат: %v", err)
 		msg := tgbotapi.NewEditMessageText(
@@ -1898,65 +2363,19 @@ func (ih *InlineHandler) handlePaymentHistory(bot *Bot, callback *tgbotapi.Callb
 	}
 
 	var messageText string
-	if len(subscriptions) == 0 {
+	if len(payments) == 0 {
 		messageText = "💰 История оплат\n\nУ вас пока нет истории платежей."
 	} else {
-		messageText = "💰 История оплат\n\n"
-		for i, sub := range subscriptions {
-			// Форматируем дату создания
-			createdDate := sub.CreatedAt.Format("02.01.2006 15:04")
-
-			// Форматируем статус
-			var statusEmoji, statusText string
-			switch sub.Status {
-			case "active":
-				statusEmoji = "✅"
-				statusText = "Активна"
-			case "pending":
-				statusEmoji = "⏳"
-				statusText = "Ожидает оплаты"
-			case "cancelled":
-				statusEmoji = "❌"
-				statusText = "Отменена"
-			case "expired":
-				statusEmoji = "⏰"
-				statusText = "Истекла"
-			case "suspended":
-				statusEmoji = "🚫"
-				statusText = "Приостановлена"
-			default:
-				statusEmoji = "❓"
-				statusText = sub.Status
-			}
-
-			// Форматируем сумму
-			amountText := fmt.Sprintf("%.0f₽", sub.Amount)
-
-			// Форматируем тариф
-			tariffText := sub.Tariff
-			if tariffText == "" {
-				tariffText = "Не указан"
-			}
-
-			messageText += fmt.Sprintf("%d. %s %s\n", i+1, statusEmoji, statusText)
-			messageText += fmt.Sprintf("   💰 Сумма: %s\n", amountText)
-			messageText += fmt.Sprintf("   📅 Дата: %s\n", createdDate)
-			messageText += fmt.Sprintf("   🏷️ Тариф: %s\n", tariffText)
-
-			// Добавляем дополнительную информацию в зависимости от статуса
-			if sub.Status == "active" && !sub.NextPayment.IsZero() {
-				nextPay := sub.NextPayment.Format("02.01.2006")
-				messageText += fmt.Sprintf("   ⏰ Следующий платеж: %s\n", nextPay)
-			}
-			if sub.CancelledAt != nil {
-				cancelledDate := sub.CancelledAt.Format("02.01.2006")
-				messageText += fmt.Sprintf("   🚫 Отменена: %s\n", cancelledDate)
-			}
-			if sub.FailedAttempts > 0 {
-				messageText += fmt.Sprintf("   ⚠️ Неудачных попыток: %d\n", sub.FailedAttempts)
-			}
+		messageText = "💰 История оплат\n\n" + formatPaymentHistory(payments)
+	}
 
-			messageText += "\n"
+	// Покупки пакетов кредитов показываем отдельным разделом после подписок
+	if bot.CreditService != nil {
+		purchases, err := bot.CreditService.History(userID, creditHistoryLimit)
+		if err != nil {
+			log.Printf("Ошибка получения истории покупок пакетов: %v", err)
+		} else if text := formatCreditPurchases(purchases); text != "" {
+			messageText += "\n\n" + text
 		}
 	}
 
@@ -1972,94 +2391,17 @@ func (ih *InlineHandler) handlePaymentHistory(bot *Bot, callback *tgbotapi.Callb
 	bot.Send(msg)
 }
 
-// shouldIncrementUsage проверяет, нужно ли увеличивать счетчик использований
-// Возвращает true, если счетчик НЕ нужно увеличивать (есть активная подписка или grace period)
-func (ih *InlineHandler) shouldIncrementUsage(userID int64) (bool, error) {
-	// Получаем подписку пользователя
-	subscription, err := ih.subscriptionService.GetUserSubscription(userID)
-	if err != nil {
-		log.Printf("Ошибка получения подписки для проверки счетчика: %v", err)
-		return false, err
-	}
-
-	// Если нет подписки, нужно увеличивать счетчик
-	if subscription == nil {
-		log.Printf("[DEBUG] У пользователя %d нет подписки - увеличиваем счетчик", userID)
-		return false, nil
-	}
-
-	log.Printf("[DEBUG] Проверка счетчика для пользователя %d: status=%s, active=%v, cancelled_at=%v, next_payment=%v",
-		userID, subscription.Status, subscription.Active, subscription.CancelledAt, subscription.NextPayment)
-
-	// Если подписка активна, НЕ нужно увеличивать счетчик
-	if subscription.Status == "active" && subscription.Active {
-		log.Printf("[DEBUG] У пользователя %d активная подписка - НЕ увеличиваем счетчик", userID)
-		return true, nil
-	}
-
-	// Если подписка отменена, проверяем grace period
-	if subscription.Status == "cancelled" {
-		if subscription.CancelledAt != nil {
-			gracePeriodEnd := subscription.CancelledAt.AddDate(0, 0, 30)
-			now := time.Now()
-
-			// Если grace period активен И подписка еще не истекла, НЕ нужно увеличивать счетчик
-			if now.Before(gracePeriodEnd) && now.Before(subscription.NextPayment) {
-				log.Printf("[DEBUG] У пользователя %d активный grace period - НЕ увеличиваем счетчик", userID)
-				return true, nil
-			} else {
-				log.Printf("[DEBUG] У пользователя %d grace period истек или подписка истекла - увеличиваем счетчик", userID)
-			}
-		}
-	}
-
-	// Во всех остальных случаях нужно увеличивать счетчик
-	log.Printf("[DEBUG] У пользователя %d нет активной подписки или grace period - увеличиваем счетчик", userID)
-	return false, nil
-}
-
-// safeIncrementUsage безопасно увеличивает счетчик использований с проверкой подписки
-func (ih *InlineHandler) safeIncrementUsage(userID int64) error {
-	// Проверяем, нужно ли увеличивать счетчик использований
-	shouldNotIncrement, err := ih.shouldIncrementUsage(userID)
-	if err != nil {
-		log.Printf("Ошибка проверки необходимости увеличения счетчика: %v", err)
-		// В случае ошибки увеличиваем счетчик для безопасности
-		return ih.stateManager.IncrementUsage(userID)
-	}
-
-	if !shouldNotIncrement {
-		// Увеличиваем счетчик только если нет активной подписки или grace period
-		return ih.stateManager.IncrementUsage(userID)
-	} else {
-		log.Printf("Счетчик не увеличен - у пользователя активная подписка или grace period")
-		return nil
-	}
-}
-
-// incrementUsageIfNeeded увеличивает счетчик использований только если это необходимо
-// Используется для замены прямых вызовов stateManager.IncrementUsage
-func (ih *InlineHandler) incrementUsageIfNeeded(userID int64) {
-	err := ih.safeIncrementUsage(userID)
-	if err != nil {
-		log.Printf("Ошибка увеличения счетчика: %v", err)
-	}
-}
-
 // handleRewritePostStart обрабатывает начало процесса рерайта поста
 func (ih *InlineHandler) handleRewritePostStart(bot *Bot, callback *tgbotapi.CallbackQuery) {
 	userID := callback.From.ID
 
-	// Проверяем подписку пользователя (так же как в handleCreatePost)
-	subscriptionStatus, canCreate, remainingFree, err := ih.checkUserSubscriptionStatus(userID)
+	// Проверяем квоту рерайтов (так же как в handleCreatePost)
+	subscriptionStatus, quota, err := ih.checkUserQuota(userID, domain.QuotaRewrites, 1)
 	if err != nil {
-		log.Printf("Ошибка проверки подписки для рерайта: %v", err)
-		// В случае ошибки разрешаем создание
-		subscriptionStatus = "error"
-		canCreate = true
+		log.Printf("Ошибка проверки квоты для рерайта: %v", err)
 	}
 
-	if canCreate {
+	if quota == nil || quota.Allows(1) {
 		// Устанавливаем состояние ожидания текста поста
 		ih.stateManager.SetWaitingForPostText(userID, true)
 		ih.stateManager.UpdateStep(userID, "waiting_for_post_text")
@@ -2074,27 +2416,7 @@ func (ih *InlineHandler) handleRewritePostStart(bot *Bot, callback *tgbotapi.Cal
 		bot.Send(msg)
 	} else {
 		// Показываем информацию о подписке и предлагаем оформить
-		keyboard := ih.createSubscriptionKeyboard(userID, subscriptionStatus, remainingFree)
-
-		var messageText string
-		switch subscriptionStatus {
-		case "cancelled":
-			messageText = "❌ Ваша подписка была отменена.\n\n"
-		case "expired":
-			messageText = "⏰ Срок действия подписки истек.\n\n"
-		case "no_subscription":
-			messageText = "💎 У вас нет активной подписки.\n\n"
-		default:
-			messageText = "💎 Требуется подписка для создания контента.\n\n"
-		}
-
-		if remainingFree > 0 {
-			messageText += fmt.Sprintf("🎁 У вас осталось %d бесплатных созданий в этом месяце.\n\n", remainingFree)
-		} else {
-			messageText += "🎁 Бесплатные создания на этот месяц закончились.\n\n"
-		}
-
-		messageText += "💳 Оформите подписку для неограниченного создания контента!"
+		messageText, keyboard := ih.quotaExceededMessage(userID, subscriptionStatus, quota)
 
 		msg := tgbotapi.NewEditMessageText(
 			callback.Message.Chat.ID,
@@ -2124,6 +2446,12 @@ func (ih *InlineHandler) handleRewritePostDirect(bot *Bot, callback *tgbotapi.Ca
 		return
 	}
 
+	// Списываем рерайт; аудио в этом режиме не распознается
+	consumptions, ok := ih.consumeQuotas(bot, callback.Message.Chat.ID, userID, domain.QuotaRewrites, 0)
+	if !ok {
+		return
+	}
+
 	// Устанавливаем режим рерайта
 	ih.stateManager.SetRewriteMode(userID, "direct")
 
@@ -2139,6 +2467,7 @@ func (ih *InlineHandler) handleRewritePostDirect(bot *Bot, callback *tgbotapi.Ca
 	rewrittenText, err := ih.voiceHandler.GenerateContent("rewrite_post", originalText, userID, 0)
 	if err != nil {
 		log.Printf("Ошибка рерайта поста: %v", err)
+		ih.refundQuotas(consumptions...)
 		msg := tgbotapi.NewMessage(
 			callback.Message.Chat.ID,
 			"❌ Не удалось переписать пост. Попробуйте еще раз.",
@@ -3,7 +3,6 @@ package bot
 import (
 	"ai_tg_writer/internal/infrastructure/voice"
 	"ai_tg_writer/internal/monitoring"
-	"fmt"
 	"log"
 	"regexp"
 	"strings"
@@ -64,6 +63,11 @@ func (mh *MessageHandler) HandleMessage(bot *Bot, message *tgbotapi.Message) boo
 		return true // сообщение обработано
 	}
 
+	if state.WaitingForPromo != "" && message.Text != "" {
+		mh.handlePromoCode(bot, message, state)
+		return true // сообщение обработано
+	}
+
 	// Проверяем, ожидаем ли текст поста для рерайта
 	if state.WaitingForPostText && (message.Text != "" || message.Caption != "") {
 		mh.handlePostTextForRewrite(bot, message)
@@ -75,38 +79,17 @@ func (mh *MessageHandler) HandleMessage(bot *Bot, message *tgbotapi.Message) boo
 		return false // сообщение не обработано
 	}
 
-	// Проверяем статус подписки с учетом grace period
-	subscriptionStatus, canCreate, remainingFree, err := mh.inlineHandler.checkUserSubscriptionStatus(userID)
+	// Проверяем квоту ресурса, который расходует голосовое в текущем режиме
+	subscriptionStatus, quota, err := mh.inlineHandler.checkUserQuota(userID, voiceQuotaResource(state), 1)
 	if err != nil {
-		log.Printf("Ошибка проверки подписки: %v", err)
+		log.Printf("Ошибка проверки квоты: %v", err)
 		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Произошла ошибка при проверке лимита. Попробуйте позже.")
 		bot.Send(msg)
 		return true
 	}
-	if !canCreate {
+	if !quota.Allows(1) {
 		// Показываем информацию о подписке и предлагаем оформить
-		keyboard := mh.inlineHandler.createSubscriptionKeyboard(userID, subscriptionStatus, remainingFree)
-
-		var messageText string
-		switch subscriptionStatus {
-		case "cancelled":
-			// Если canCreate = false, значит grace period истек
-			messageText = "❌ Ваша подписка была отменена.\n\n"
-		case "expired":
-			messageText = "⏰ Срок действия подписки истек.\n\n"
-		case "no_subscription":
-			messageText = "💎 У вас нет активной подписки.\n\n"
-		default:
-			messageText = "💎 Требуется подписка для создания контента.\n\n"
-		}
-
-		if remainingFree > 0 {
-			messageText += fmt.Sprintf("🎁 У вас осталось %d бесплатных созданий в этом месяце.\n\n", remainingFree)
-		} else {
-			messageText += "🎁 Бесплатные создания на этот месяц закончились.\n\n"
-		}
-
-		messageText += "💳 Оформите подписку для неограниченного создания контента!"
+		messageText, keyboard := mh.inlineHandler.quotaExceededMessage(userID, subscriptionStatus, quota)
 
 		msg := tgbotapi.NewMessage(message.Chat.ID, messageText)
 		msg.ReplyMarkup = &keyboard
@@ -127,38 +110,17 @@ func (mh *MessageHandler) handleVoiceMessage(bot *Bot, message *tgbotapi.Message
 	// Получаем состояние пользователя
 	state := mh.stateManager.GetState(userID)
 
-	// Проверяем статус подписки с учетом grace period
-	subscriptionStatus, canCreate, remainingFree, err := mh.inlineHandler.checkUserSubscriptionStatus(userID)
+	// Проверяем квоту ресурса, который расходует голосовое в текущем режиме
+	subscriptionStatus, quota, err := mh.inlineHandler.checkUserQuota(userID, voiceQuotaResource(state), 1)
 	if err != nil {
-		log.Printf("Ошибка проверки подписки: %v", err)
+		log.Printf("Ошибка проверки квоты: %v", err)
 		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Произошла ошибка при проверке лимита. Попробуйте позже.")
 		bot.Send(msg)
 		return
 	}
-	if !canCreate {
+	if !quota.Allows(1) {
 		// Показываем информацию о подписке и предлагаем оформить
-		keyboard := mh.inlineHandler.createSubscriptionKeyboard(userID, subscriptionStatus, remainingFree)
-
-		var messageText string
-		switch subscriptionStatus {
-		case "cancelled":
-			// Если canCreate = false, значит grace period истек
-			messageText = "❌ Ваша подписка была отменена.\n\n"
-		case "expired":
-			messageText = "⏰ Срок действия подписки истек.\n\n"
-		case "no_subscription":
-			messageText = "💎 У вас нет активной подписки.\n\n"
-		default:
-			messageText = "💎 Требуется подписка для создания контента.\n\n"
-		}
-
-		if remainingFree > 0 {
-			messageText += fmt.Sprintf("🎁 У вас осталось %d бесплатных созданий в этом месяце.\n\n", remainingFree)
-		} else {
-			messageText += "🎁 Бесплатные создания на этот месяц закончились.\n\n"
-		}
-
-		messageText += "💳 Оформите подписку для неограниченного создания контента!"
+		messageText, keyboard := mh.inlineHandler.quotaExceededMessage(userID, subscriptionStatus, quota)
 
 		msg := tgbotapi.NewMessage(message.Chat.ID, messageText)
 		msg.ReplyMarkup = &keyboard
@@ -264,34 +226,48 @@ func (mh *MessageHandler) handlePostTextForRewrite(bot *Bot, message *tgbotapi.M
 	bot.Send(msg)
 }
 
+// handlePromoCode проверяет введенный промокод и показывает тариф со скидкой
+func (mh *MessageHandler) handlePromoCode(bot *Bot, message *tgbotapi.Message, state *UserState) {
+	userID := message.From.ID
+	tariffID := state.WaitingForPromo
+	state.WaitingForPromo = ""
+
+	tariff := findAvailableTariff(bot.SubscriptionService.GetAvailableTariffs(), tariffID)
+	if tariff == nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "❌ Этот тариф больше недоступен. Выберите другой тариф."))
+		mh.showSubscriptionPurchaseScreen(bot, message.Chat.ID, userID)
+		return
+	}
+
+	promo, err := bot.SubscriptionService.CheckPromoCode(userID, message.Text)
+	if err != nil {
+		log.Printf("Промокод %q не принят для пользователя %d: %v", message.Text, userID, err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, promoErrorText(err))
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🎟 Ввести другой промокод", enterPromoCallbackPrefix+tariff.ID),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("💎 Оформить без промокода", buyTariffCallbackPrefix+tariff.ID),
+			),
+		)
+		msg.ReplyMarkup = &keyboard
+		bot.Send(msg)
+		return
+	}
+
+	state.PromoCode = promo.Code
+	text, keyboard := buildPromoOfferView(tariff, promo, time.Now())
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = &keyboard
+	bot.Send(msg)
+}
+
 // showSubscriptionPurchaseScreen показывает экран оформления подписки
 func (mh *MessageHandler) showSubscriptionPurchaseScreen(bot *Bot, chatID int64, userID int64) {
-	// Рассчитываем дату окончания подписки (текущая дата + 1 месяц)
-	subscriptionEndDate := time.Now().AddDate(0, 1, 0)
-	formattedDate := subscriptionEndDate.Format("02.01.2006")
-
-	text := "💎 *Оформление Premium подписки*\n\n" +
-		"✨ *Преимущества Premium:*\n" +
-		"• 🚀 Неограниченное количество постов\n" +
-		"• ⚡ Приоритетная обработка запросов\n" +
-		"• 🎨 Расширенные настройки стилизации\n" +
-		"• 📈 Детальная аналитика использования\n" +
-		"• 🔧 Эксклюзивные функции и шаблоны\n" +
-		"• 💬 Приоритетная техподдержка\n\n" +
-		"💰 *Стоимость:* 990₽/месяц\n" +
-		"📅 *Период:* 1 месяц (до " + formattedDate + ")\n" +
-		"♻️ *Автопродление:* включено\n\n" +
-		// "📋 *Оферта:* [Пользовательское соглашение](#)\n\n" +
-		"Нажмите «Подтвердить покупку» для перехода к оплате:"
-
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("✅ Подтвердить покупку", "confirm_purchase"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "subscription"),
-		),
-	)
+	text, keyboard := buildTariffPurchaseView(bot.SubscriptionService.GetAvailableTariffs(), time.Now())
+	keyboard = bot.WithTrialRow(userID, keyboard)
 
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = "Markdown"
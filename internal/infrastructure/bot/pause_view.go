@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Callback-и паузы подписки: выбор длительности, пауза на N дней (после префикса идет N) и возобновление
+const (
+	pauseSubscriptionCallback       = "pause_subscription"
+	pauseSubscriptionCallbackPrefix = "pause_subscription:"
+	resumeSubscriptionCallback      = "resume_subscription"
+)
+
+// moscowZone часовой пояс дат, которые бот показывает и принимает от администраторов
+var moscowZone = time.FixedZone("UTC+3", 3*60*60)
+
+// formatMoscowTime форматирует время для экранов подписки по Москве: «02.01.2006 15:04 МСК»
+func formatMoscowTime(t time.Time) string {
+	return t.In(moscowZone).Format("02.01.2006 15:04 МСК")
+}
+
+// parsePauseDays извлекает длительность паузы из callback-а pause_subscription:<дни>
+func parsePauseDays(data string) (int, bool) {
+	value, ok := strings.CutPrefix(data, pauseSubscriptionCallbackPrefix)
+	if !ok {
+		return 0, false
+	}
+	days, err := strconv.Atoi(value)
+	return days, err == nil && days > 0
+}
+
+// buildPauseOptionsView формирует экран выбора длительности паузы
+func buildPauseOptionsView(options []int, pausesLeft int) (string, tgbotapi.InlineKeyboardMarkup) {
+	back := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "subscription"),
+	)
+	if pausesLeft == 0 || len(options) == 0 {
+		return "⏸ *Пауза подписки*\n\nЛимит пауз на ближайшие 12 месяцев исчерпан.", tgbotapi.NewInlineKeyboardMarkup(back)
+	}
+
+	text := fmt.Sprintf("⏸ *Пауза подписки*\n\n"+
+		"Уезжаете или просто нужен перерыв? Поставьте подписку на паузу вместо отмены.\n\n"+
+		"— На время паузы Premium недоступен и списаний нет.\n"+
+		"— Дата следующего списания сдвинется на длительность паузы.\n"+
+		"— Подписка возобновится сама, мы напомним об этом за день.\n\n"+
+		"Осталось пауз на 12 месяцев: *%d*", pausesLeft)
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	for _, days := range options {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("%d дн.", days), fmt.Sprintf("%s%d", pauseSubscriptionCallbackPrefix, days)))
+	}
+	return text, tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(buttons...), back)
+}
+
+// buildPausedSubscriptionView формирует экран подписки, стоящей на паузе
+func buildPausedSubscriptionView(sub *domain.Subscription) (string, tgbotapi.InlineKeyboardMarkup) {
+	text := "💎 Подписка\n\n⏸ Статус: на паузе"
+	if sub.PauseUntil != nil {
+		text += fmt.Sprintf("\n▶️ Возобновится: %s", formatMoscowTime(*sub.PauseUntil))
+	}
+	text += fmt.Sprintf("\n📅 Следующий платеж: %s\n\nНа время паузы действуют лимиты бесплатного тарифа.",
+		formatMoscowTime(sub.NextPayment))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("▶️ Возобновить сейчас", resumeSubscriptionCallback),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад в меню", "main_menu"),
+		),
+	)
+	return text, keyboard
+}
+
+// PausedSubscriptionView возвращает экран подписки на паузе для команды /subscription
+func (b *Bot) PausedSubscriptionView(sub *domain.Subscription) (string, tgbotapi.InlineKeyboardMarkup) {
+	return buildPausedSubscriptionView(sub)
+}
@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/service"
+)
+
+// paymentKindLabels названия назначений платежа в истории оплат
+var paymentKindLabels = map[string]string{
+	domain.PaymentKindInitial:    "Оформление подписки",
+	domain.PaymentKindRecurring:  "Продление подписки",
+	domain.PaymentKindCreditPack: "Пакет постов",
+	domain.PaymentKindTrial:      "Привязка карты для пробного периода",
+	domain.PaymentKindGift:       "Подарочный Premium",
+}
+
+// paymentFailureLabels понятные пользователю причины отказа YooKassa;
+// остальные причины (ошибки запроса и т.п.) видны только в журнале администраторам
+var paymentFailureLabels = map[string]string{
+	"insufficient_funds":      "недостаточно средств",
+	"card_expired":            "истек срок действия карты",
+	"expired_on_confirmation": "оплата не подтверждена вовремя",
+	"permission_revoked":      "автосписания отключены",
+	"canceled_by_merchant":    "платеж отменен",
+	"general_decline":         "отказ банка",
+	"3d_secure_failed":        "не пройдена проверка 3-D Secure",
+}
+
+// paymentStatusLabel возвращает значок и название статуса платежа
+func paymentStatusLabel(status domain.PaymentStatus) (string, string) {
+	switch status {
+	case domain.PaymentStatusSucceeded:
+		return "✅", "Оплачен"
+	case domain.PaymentStatusPending:
+		return "⏳", "Ожидает оплаты"
+	case domain.PaymentStatusFailed:
+		return "❌", "Не прошел"
+	case domain.PaymentStatusRefunded:
+		return "↩️", "Возвращен"
+	default:
+		return "❓", string(status)
+	}
+}
+
+// formatPaymentAmount форматирует сумму платежа: «990₽»
+func formatPaymentAmount(payment *domain.Payment) string {
+	if payment.Currency != "" && payment.Currency != "RUB" {
+		return fmt.Sprintf("%.0f %s", payment.Amount, payment.Currency)
+	}
+	return fmt.Sprintf("%.0f₽", payment.Amount)
+}
+
+// formatPaymentHistory форматирует платежи из журнала для экрана истории оплат
+func formatPaymentHistory(payments []*domain.Payment) string {
+	var sb strings.Builder
+	for i, payment := range payments {
+		emoji, status := paymentStatusLabel(payment.Status)
+		kind, ok := paymentKindLabels[payment.Kind]
+		if !ok {
+			kind = payment.Kind
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s %s — %s\n", i+1, emoji, kind, status))
+		sb.WriteString(fmt.Sprintf("   💰 Сумма: %s\n", formatPaymentAmount(payment)))
+		sb.WriteString(fmt.Sprintf("   📅 Дата: %s\n", payment.CreatedAt.Format("02.01.2006 15:04")))
+		if reason, ok := paymentFailureLabels[payment.FailureReason]; ok && payment.Status == domain.PaymentStatusFailed {
+			sb.WriteString(fmt.Sprintf("   ⚠️ Причина: %s\n", reason))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// refundMessageText уведомление пользователя о возврате и его последствиях
+func refundMessageText(outcome *service.RefundOutcome) string {
+	kind, ok := paymentKindLabels[outcome.Payment.Kind]
+	if !ok {
+		kind = outcome.Payment.Kind
+	}
+	amount := fmt.Sprintf("%.2f₽", outcome.Refund.Amount)
+	if outcome.Refund.Currency != "" && outcome.Refund.Currency != "RUB" {
+		amount = fmt.Sprintf("%.2f %s", outcome.Refund.Amount, outcome.Refund.Currency)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("↩️ Оформлен возврат\n\n")
+	sb.WriteString(fmt.Sprintf("Платеж: %s от %s\n", kind, outcome.Payment.CreatedAt.Format("02.01.2006")))
+	sb.WriteString(fmt.Sprintf("Сумма возврата: %s\n\n", amount))
+	if outcome.Downgraded {
+		sb.WriteString("Подписка завершена, действуют лимиты бесплатного тарифа.\n")
+	}
+	if outcome.RevokedCredits > 0 {
+		sb.WriteString(fmt.Sprintf("С баланса списано кредитов: %d.\n", outcome.RevokedCredits))
+	}
+	if outcome.GiftCancelled {
+		sb.WriteString("Подарочный код аннулирован и больше не активируется.\n")
+	}
+	sb.WriteString("Деньги поступят на карту в течение нескольких рабочих дней — срок зависит от банка.")
+	return sb.String()
+}
@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Callback-и промокода: после префикса идет ID тарифа
+const (
+	enterPromoCallbackPrefix           = "promo_code:"
+	confirmPromoPurchaseCallbackPrefix = "confirm_promo:"
+)
+
+// formatPromoDiscount форматирует размер скидки: «−20%» или «−100₽»
+func formatPromoDiscount(promo *domain.PromoCode) string {
+	if promo.DiscountType == domain.PromoDiscountPercent {
+		return fmt.Sprintf("−%g%%", promo.DiscountValue)
+	}
+	return fmt.Sprintf("−%g₽", promo.DiscountValue)
+}
+
+// formatPromoPeriods описывает, на какие оплаты действует скидка
+func formatPromoPeriods(periods int) string {
+	switch {
+	case periods == 0:
+		return "на все оплаты"
+	case periods == 1:
+		return "на первую оплату"
+	case periods%10 >= 2 && periods%10 <= 4 && (periods%100 < 12 || periods%100 > 14):
+		return fmt.Sprintf("на первые %d оплаты", periods)
+	default:
+		return fmt.Sprintf("на первые %d оплат", periods)
+	}
+}
+
+// promoErrorText объясняет пользователю, почему промокод не применился
+func promoErrorText(err error) string {
+	switch {
+	case errors.Is(err, domain.ErrPromoNotFound):
+		return "❌ Такого промокода нет. Проверьте написание."
+	case errors.Is(err, domain.ErrPromoExpired):
+		return "⌛ Срок действия промокода закончился."
+	case errors.Is(err, domain.ErrPromoExhausted):
+		return "😔 Промокод больше недоступен: лимит использований исчерпан."
+	case errors.Is(err, domain.ErrPromoAlreadyUsed):
+		return "ℹ️ Вы уже использовали этот промокод."
+	case errors.Is(err, domain.ErrProviderUnsupported):
+		return "❌ Промокоды не действуют для вашего способа оплаты."
+	default:
+		return "❌ Не удалось проверить промокод. Попробуйте позже."
+	}
+}
+
+// buildEnterPromoView формирует экран ввода промокода для тарифа
+func buildEnterPromoView(tariffID string) (string, tgbotapi.InlineKeyboardMarkup) {
+	return "🎟 *Введите промокод*\n\nОтправьте промокод сообщением.", tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", buyTariffCallbackPrefix+tariffID),
+		),
+	)
+}
+
+// buildPromoOfferView формирует экран оформления тарифа с примененным промокодом
+func buildPromoOfferView(tariff *domain.Tariff, promo *domain.PromoCode, now time.Time) (string, tgbotapi.InlineKeyboardMarkup) {
+	discounted := tariff.Price - promo.Discount(tariff.Price)
+
+	var sb strings.Builder
+	sb.WriteString(buildTariffOfferText(tariff, now))
+	sb.WriteString(fmt.Sprintf("\n\n🎟 *Промокод %s:* %s %s", promo.Code, formatPromoDiscount(promo), formatPromoPeriods(promo.Periods)))
+	sb.WriteString(fmt.Sprintf("\n💳 *К оплате сейчас:* %.0f₽ вместо %.0f₽", discounted, tariff.Price))
+	if promo.Periods != 1 {
+		sb.WriteString("\nПосле окончания скидки подписка продлевается по полной цене.")
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Подтвердить покупку", confirmPromoPurchaseCallbackPrefix+tariff.ID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Убрать промокод", buyTariffCallbackPrefix+tariff.ID),
+		),
+	)
+	return sb.String(), keyboard
+}
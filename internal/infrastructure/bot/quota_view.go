@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// quotaResourceLabels названия ресурсов в сообщениях об исчерпании лимита
+var quotaResourceLabels = map[domain.QuotaResource]string{
+	domain.QuotaGenerations:  "созданий постов",
+	domain.QuotaRewrites:     "рерайтов",
+	domain.QuotaEdits:        "правок",
+	domain.QuotaAudioMinutes: "минут аудио",
+}
+
+// quotaResourceLabel возвращает название ресурса для пользователя
+func quotaResourceLabel(resource domain.QuotaResource) string {
+	if label, ok := quotaResourceLabels[resource]; ok {
+		return label
+	}
+	return string(resource)
+}
+
+// formatQuotaLimit форматирует лимит ресурса
+func formatQuotaLimit(limit int) string {
+	if limit == domain.UnlimitedQuota {
+		return "∞"
+	}
+	return strconv.Itoa(limit)
+}
+
+// formatTariffQuotas форматирует лимиты тарифа по всем ресурсам
+func formatTariffQuotas(tariff *domain.Tariff) string {
+	parts := make([]string, 0, len(domain.QuotaResources))
+	for _, resource := range domain.QuotaResources {
+		parts = append(parts, fmt.Sprintf("%s %s", resource, formatQuotaLimit(tariff.QuotaLimit(resource))))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatQuotaUsage форматирует расход квот пользователя: по строке на ресурс
+func formatQuotaUsage(statuses []*domain.QuotaStatus) string {
+	var sb strings.Builder
+	for _, status := range statuses {
+		sb.WriteString(fmt.Sprintf("• %s: %d из %s", quotaResourceLabel(status.Resource), status.Used, formatQuotaLimit(status.Limit)))
+		if status.Granted > 0 {
+			sb.WriteString(fmt.Sprintf(" (выдано %d)", status.Granted))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// quotaExceededText сообщение об исчерпанном лимите ресурса в текущем периоде
+func quotaExceededText(status *domain.QuotaStatus) string {
+	return fmt.Sprintf("🎁 Лимит %s исчерпан (%d из %d). Обновится %s.\n\n",
+		quotaResourceLabel(status.Resource), status.Used, status.Limit, formatQuotaReset(status))
+}
+
+// formatQuotaReset форматирует дату обновления лимитов в часовом поясе пользователя
+func formatQuotaReset(status *domain.QuotaStatus) string {
+	return status.PeriodEnd.Format("02.01.2006")
+}
+
+// QuotaProfileText возвращает блок профиля с расходом квот и датой их обновления
+func (b *Bot) QuotaProfileText(userID int64) string {
+	if b.QuotaService == nil {
+		return ""
+	}
+	quotas, err := b.QuotaService.Usage(userID)
+	if err != nil {
+		log.Printf("Ошибка получения квот пользователя %d: %v", userID, err)
+		return ""
+	}
+	if len(quotas) == 0 {
+		return ""
+	}
+	text := fmt.Sprintf("📈 Использовано в текущем периоде:\n%s🔄 Лимиты обновятся: %s",
+		formatQuotaUsage(quotas), formatQuotaReset(quotas[0]))
+	if credits := b.CreditBalanceText(userID); credits != "" {
+		text += "\n" + credits
+	}
+	return text
+}
+
+// SetTimezone сохраняет часовой пояс пользователя, в котором считаются периоды квот
+func (b *Bot) SetTimezone(chatID, userID int64, name string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		b.Send(tgbotapi.NewMessage(chatID, "🕐 Укажите часовой пояс, например: /timezone Europe/Moscow или /timezone Asia/Yekaterinburg"))
+		return
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		b.Send(tgbotapi.NewMessage(chatID, "❌ Неизвестный часовой пояс. Пример: /timezone Europe/Moscow"))
+		return
+	}
+	if err := b.DB.SetUserTimezone(userID, loc.String()); err != nil {
+		log.Printf("❌ Ошибка сохранения часового пояса пользователя %d: %v", userID, err)
+		b.Send(tgbotapi.NewMessage(chatID, "❌ Не удалось сохранить настройку. Попробуйте позже."))
+		return
+	}
+
+	text := fmt.Sprintf("🕐 Часовой пояс сохранен: %s", loc.String())
+	if b.QuotaService != nil {
+		if status, err := b.QuotaService.Check(userID, domain.QuotaGenerations); err == nil {
+			text += fmt.Sprintf("\n🔄 Лимиты обновятся: %s", formatQuotaReset(status))
+		}
+	}
+	b.Send(tgbotapi.NewMessage(chatID, text))
+}
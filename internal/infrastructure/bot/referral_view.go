@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/service"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// referralCallback callback экрана реферальной программы в профиле
+const referralCallback = "referral"
+
+// formatReferralReward форматирует награду за приглашение: «7 дн. Premium»
+func formatReferralReward(rewardType string, amount int) string {
+	if rewardType == domain.ReferralRewardGenerations {
+		return fmt.Sprintf("%d доп. созданий постов", amount)
+	}
+	return fmt.Sprintf("%d дн. Premium", amount)
+}
+
+// buildReferralView формирует экран реферальной программы со ссылкой и статистикой.
+// Текст без Markdown: в ссылке на бота бывают подчеркивания.
+func buildReferralView(link string, stats *domain.ReferralStats, reward *service.ReferralReward) (string, tgbotapi.InlineKeyboardMarkup) {
+	var sb strings.Builder
+	sb.WriteString("🤝 Пригласите друзей\n\n")
+	if reward != nil {
+		sb.WriteString(fmt.Sprintf("Когда друг, пришедший по вашей ссылке, впервые оплатит подписку, пакет постов или подарок, вы получите %s.\n\n",
+			formatReferralReward(reward.Type, reward.Amount)))
+	} else {
+		sb.WriteString("Поделитесь ссылкой на бота с друзьями.\n\n")
+	}
+	sb.WriteString("🔗 Ваша ссылка:\n" + link + "\n")
+	if stats != nil {
+		sb.WriteString(fmt.Sprintf("\n👥 Приглашено: %d\n💳 Оплатили: %d\n", stats.Invited, stats.Paid))
+		if stats.PremiumDays > 0 {
+			sb.WriteString(fmt.Sprintf("🎁 Получено: %s\n", formatReferralReward(domain.ReferralRewardPremiumDays, stats.PremiumDays)))
+		}
+		if stats.BonusGenerations > 0 {
+			sb.WriteString(fmt.Sprintf("🎁 Получено: %s\n", formatReferralReward(domain.ReferralRewardGenerations, stats.BonusGenerations)))
+		}
+	}
+
+	share := "https://t.me/share/url?url=" + url.QueryEscape(link) +
+		"&text=" + url.QueryEscape("✍️ Пишу посты голосом с AI TG Writer — попробуй!")
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("📤 Поделиться ссылкой", share),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "profile"),
+		),
+	)
+	return sb.String(), keyboard
+}
+
+// referralLink ссылка приглашения: https://t.me/<бот>?start=<реферальный код>
+func (b *Bot) referralLink(code string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", b.API.Self.UserName, code)
+}
+
+// withReferralRow добавляет кнопку реферальной программы перед последней строкой клавиатуры (кнопкой «Назад»)
+func (b *Bot) withReferralRow(keyboard tgbotapi.InlineKeyboardMarkup) tgbotapi.InlineKeyboardMarkup {
+	if b.Referrals == nil || len(keyboard.InlineKeyboard) == 0 {
+		return keyboard
+	}
+	row := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🤝 Пригласить друга", referralCallback),
+	)
+	last := len(keyboard.InlineKeyboard) - 1
+	rows := append([][]tgbotapi.InlineKeyboardButton{}, keyboard.InlineKeyboard[:last]...)
+	rows = append(rows, row, keyboard.InlineKeyboard[last])
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// ReferralKeyboard клавиатура профиля из команды /profile с кнопкой реферальной программы
+func (b *Bot) ReferralKeyboard() *tgbotapi.InlineKeyboardMarkup {
+	if b.Referrals == nil {
+		return nil
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🤝 Пригласить друга", referralCallback),
+		),
+	)
+	return &keyboard
+}
+
+// ApplyReferral закрепляет пользователя, открывшего бота по ссылке /start <код>, за приглашающим
+// и сообщает приглашающему о новом друге. Неизвестный код молча игнорируется.
+func (b *Bot) ApplyReferral(from *tgbotapi.User, code string) {
+	if b.Referrals == nil {
+		return
+	}
+	if _, err := b.DB.GetOrCreateUser(from.ID, from.UserName, from.FirstName, from.LastName); err != nil {
+		log.Printf("❌ Ошибка создания пользователя %d: %v", from.ID, err)
+		return
+	}
+	referrerID, attached, err := b.Referrals.Attach(from.ID, code)
+	if err != nil {
+		log.Printf("❌ Ошибка привязки пользователя %d по реферальному коду %q: %v", from.ID, code, err)
+		return
+	}
+	if !attached {
+		return
+	}
+
+	text := "🤝 По вашей ссылке присоединился новый пользователь."
+	if reward := b.Referrals.Reward(); reward != nil {
+		text += fmt.Sprintf(" За первую оплату нового пользователя вы получите %s.", formatReferralReward(reward.Type, reward.Amount))
+	}
+	if _, err := b.Send(tgbotapi.NewMessage(referrerID, text)); err != nil {
+		log.Printf("❌ Error sending referral joined message to user %d: %v", referrerID, err)
+	}
+}
@@ -0,0 +1,254 @@
+package bot
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"ai_tg_writer/internal/monitoring"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SendPriority определяет очередность отправки сообщений
+type SendPriority int
+
+const (
+	PriorityInteractive SendPriority = iota // ответы пользователю на его действия
+	PriorityBulk                            // уведомления воркеров и рассылки
+)
+
+const (
+	// Лимиты Telegram: ~30 сообщений в секунду всего и ~1 сообщение в секунду в один чат
+	defaultGlobalRate      = 30
+	defaultPerChatInterval = time.Second
+	maxSendAttempts        = 3
+	// Как часто удалять слоты чатов, в которые давно не писали, чтобы карта не росла без ограничений
+	chatSlotSweepInterval = time.Minute
+)
+
+// SendScheduler ограничивает исходящие запросы к Telegram и повторяет их после 429
+type SendScheduler struct {
+	api             *tgbotapi.BotAPI
+	globalInterval  time.Duration
+	perChatInterval time.Duration
+
+	interactive chan chan struct{}
+	bulk        chan chan struct{}
+
+	mu          sync.Mutex
+	chats       map[int64]*chatSlot
+	lastSweep   time.Time
+	pausedUntil time.Time
+}
+
+// chatSlot сериализует отправку в один чат и хранит время следующего разрешенного запроса
+type chatSlot struct {
+	mu    sync.Mutex
+	next  time.Time
+	users int // Сколько отправок ждут слот или держат его; меняется под SendScheduler.mu
+}
+
+// NewSendScheduler создает планировщик с лимитами Telegram по умолчанию
+func NewSendScheduler(api *tgbotapi.BotAPI) *SendScheduler {
+	s := &SendScheduler{
+		api:             api,
+		globalInterval:  time.Second / defaultGlobalRate,
+		perChatInterval: defaultPerChatInterval,
+		interactive:     make(chan chan struct{}),
+		bulk:            make(chan chan struct{}),
+		chats:           make(map[int64]*chatSlot),
+	}
+	go s.dispatch()
+	return s
+}
+
+// dispatch выдает разрешения на отправку не чаще globalInterval,
+// интерактивные запросы всегда обслуживаются раньше массовых
+func (s *SendScheduler) dispatch() {
+	for {
+		var grant chan struct{}
+		select {
+		case grant = <-s.interactive:
+		default:
+			select {
+			case grant = <-s.interactive:
+			case grant = <-s.bulk:
+			}
+		}
+
+		s.mu.Lock()
+		wait := time.Until(s.pausedUntil)
+		s.mu.Unlock()
+		if wait > 0 {
+			time.Sleep(wait)
+		}
+
+		close(grant)
+		time.Sleep(s.globalInterval)
+	}
+}
+
+// acquire ждет глобального разрешения на отправку с учетом приоритета
+func (s *SendScheduler) acquire(priority SendPriority) {
+	grant := make(chan struct{})
+	if priority == PriorityInteractive {
+		s.interactive <- grant
+	} else {
+		s.bulk <- grant
+	}
+	<-grant
+}
+
+// chat занимает слот чата, создавая его при необходимости; освобождается через releaseChat
+func (s *SendScheduler) chat(chatID int64) *chatSlot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := time.Now(); now.Sub(s.lastSweep) >= chatSlotSweepInterval {
+		s.sweepChats(now)
+	}
+	slot, ok := s.chats[chatID]
+	if !ok {
+		slot = &chatSlot{}
+		s.chats[chatID] = slot
+	}
+	slot.users++
+	return slot
+}
+
+// releaseChat освобождает слот чата после отправки
+func (s *SendScheduler) releaseChat(slot *chatSlot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slot.users--
+}
+
+// sweepChats удаляет свободные слоты, интервал которых уже истек: такой слот
+// ничем не отличается от нового. Вызывается под s.mu.
+func (s *SendScheduler) sweepChats(now time.Time) {
+	for chatID, slot := range s.chats {
+		if slot.users == 0 && !slot.next.After(now) {
+			delete(s.chats, chatID)
+		}
+	}
+	s.lastSweep = now
+}
+
+// pause приостанавливает все отправки после глобального 429
+func (s *SendScheduler) pause(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if until := time.Now().Add(d); until.After(s.pausedUntil) {
+		s.pausedUntil = until
+	}
+}
+
+// Send отправляет сообщение с соблюдением лимитов
+func (s *SendScheduler) Send(c tgbotapi.Chattable, priority SendPriority) (tgbotapi.Message, error) {
+	var msg tgbotapi.Message
+	err := s.do(c, priority, func() error {
+		var err error
+		msg, err = s.api.Send(c)
+		return err
+	})
+	return msg, err
+}
+
+// Request выполняет запрос без ответа-сообщения (удаление, ответ на callback и т.п.)
+func (s *SendScheduler) Request(c tgbotapi.Chattable, priority SendPriority) (*tgbotapi.APIResponse, error) {
+	var resp *tgbotapi.APIResponse
+	err := s.do(c, priority, func() error {
+		var err error
+		resp, err = s.api.Request(c)
+		return err
+	})
+	return resp, err
+}
+
+func (s *SendScheduler) do(c tgbotapi.Chattable, priority SendPriority, send func() error) error {
+	chatID := chattableChatID(c)
+
+	var slot *chatSlot
+	if chatID != 0 {
+		slot = s.chat(chatID)
+		defer s.releaseChat(slot)
+		slot.mu.Lock()
+		defer slot.mu.Unlock()
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxSendAttempts; attempt++ {
+		if slot != nil {
+			if wait := time.Until(slot.next); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		s.acquire(priority)
+		err = send()
+		if slot != nil {
+			slot.next = time.Now().Add(s.perChatInterval)
+		}
+
+		retryAfter, ok := retryAfterFromError(err)
+		if !ok {
+			return err
+		}
+
+		monitoring.RecordTelegramRateLimited(priorityLabel(priority))
+		log.Printf("⏳ [Telegram] 429 для чата %d, повтор через %v (попытка %d/%d)",
+			chatID, retryAfter, attempt, maxSendAttempts)
+
+		if chatID == 0 {
+			s.pause(retryAfter)
+		} else if slot != nil {
+			slot.next = time.Now().Add(retryAfter)
+		}
+	}
+	return err
+}
+
+// retryAfterFromError извлекает retry_after из ответа Telegram с кодом 429
+func retryAfterFromError(err error) (time.Duration, bool) {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != 429 {
+		return 0, false
+	}
+	retryAfter := time.Duration(apiErr.RetryAfter) * time.Second
+	if retryAfter <= 0 {
+		retryAfter = time.Second
+	}
+	return retryAfter, true
+}
+
+// chattableChatID определяет чат назначения для распространенных типов запросов
+func chattableChatID(c tgbotapi.Chattable) int64 {
+	switch v := c.(type) {
+	case tgbotapi.MessageConfig:
+		return v.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return v.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return v.ChatID
+	case tgbotapi.DeleteMessageConfig:
+		return v.ChatID
+	case tgbotapi.PhotoConfig:
+		return v.ChatID
+	case tgbotapi.DocumentConfig:
+		return v.ChatID
+	case tgbotapi.ChatActionConfig:
+		return v.ChatID
+	case tgbotapi.CopyMessageConfig:
+		return v.ChatID
+	default:
+		return 0
+	}
+}
+
+func priorityLabel(priority SendPriority) string {
+	if priority == PriorityInteractive {
+		return "interactive"
+	}
+	return "bulk"
+}
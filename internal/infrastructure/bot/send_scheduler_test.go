@@ -0,0 +1,126 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newTestAPI создает BotAPI, направленный на тестовый сервер
+func newTestAPI(t *testing.T, handler http.HandlerFunc) *tgbotapi.BotAPI {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	api := &tgbotapi.BotAPI{
+		Token:  "test",
+		Client: server.Client(),
+		Buffer: 100,
+	}
+	api.SetAPIEndpoint(server.URL + "/bot%s/%s")
+	return api
+}
+
+func TestSendScheduler_RetriesAfter429(t *testing.T) {
+	var calls int32
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			fmt.Fprint(w, `{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":1}}`)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true,"result":{"message_id":42,"chat":{"id":1}}}`)
+	})
+
+	scheduler := NewSendScheduler(api)
+	start := time.Now()
+	msg, err := scheduler.Send(tgbotapi.NewMessage(1, "hello"), PriorityInteractive)
+	if err != nil {
+		t.Fatalf("Ожидалась успешная отправка после 429, получена ошибка: %v", err)
+	}
+	if msg.MessageID != 42 {
+		t.Errorf("Ожидался message_id 42, получен %d", msg.MessageID)
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("Ожидалось 2 запроса, выполнено %d", calls)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Повтор должен ждать retry_after, прошло %v", elapsed)
+	}
+}
+
+func TestSendScheduler_PerChatInterval(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`)
+	})
+
+	scheduler := NewSendScheduler(api)
+	scheduler.perChatInterval = 200 * time.Millisecond
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := scheduler.Send(tgbotapi.NewMessage(1, "part"), PriorityInteractive); err != nil {
+			t.Fatalf("Ошибка отправки: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("Три сообщения в один чат должны занять не меньше 400ms, прошло %v", elapsed)
+	}
+}
+
+func TestSendScheduler_EvictsIdleChats(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`)
+	})
+
+	scheduler := NewSendScheduler(api)
+	scheduler.perChatInterval = 10 * time.Millisecond
+	for chatID := int64(1); chatID <= 3; chatID++ {
+		if _, err := scheduler.Send(tgbotapi.NewMessage(chatID, "hello"), PriorityBulk); err != nil {
+			t.Fatalf("Ошибка отправки: %v", err)
+		}
+	}
+
+	// Интервал чатов истек, следующая отправка после очередного прохода удаляет их слоты
+	time.Sleep(20 * time.Millisecond)
+	scheduler.mu.Lock()
+	scheduler.lastSweep = time.Time{}
+	scheduler.mu.Unlock()
+	if _, err := scheduler.Send(tgbotapi.NewMessage(4, "hello"), PriorityBulk); err != nil {
+		t.Fatalf("Ошибка отправки: %v", err)
+	}
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if _, ok := scheduler.chats[4]; len(scheduler.chats) != 1 || !ok {
+		t.Errorf("Должен остаться только слот последнего чата, осталось %d", len(scheduler.chats))
+	}
+}
+
+func TestRetryAfterFromError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected time.Duration
+		ok       bool
+	}{
+		{"nil", nil, 0, false},
+		{"other error", errors.New("network"), 0, false},
+		{"403", &tgbotapi.Error{Code: 403, Message: "Forbidden"}, 0, false},
+		{"429 with retry_after", &tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 5}}, 5 * time.Second, true},
+		{"429 without retry_after", &tgbotapi.Error{Code: 429}, time.Second, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfterFromError(tt.err)
+			if ok != tt.ok || got != tt.expected {
+				t.Errorf("retryAfterFromError() = %v, %v, want %v, %v", got, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
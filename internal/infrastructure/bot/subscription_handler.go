@@ -1,9 +1,11 @@
 package bot
 
 import (
+	"ai_tg_writer/internal/domain"
 	"ai_tg_writer/internal/service"
 	"fmt"
 	"log"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -40,26 +42,29 @@ func (h *SubscriptionHandler) HandleSubscriptionCommand(bot *Bot, message *tgbot
 
 	tariff := tariffs[0] // Берем первый тариф
 
-	// Проверяем текущую подписку пользователя
-	subscription, err := h.subscriptionService.GetUserSubscription(userID)
-	if err != nil {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Ошибка при получении информации о подписке")
-		bot.Send(msg)
-		return
-	}
+	// Проверяем, дает ли текущая подписка пользователю доступ
+	entitlement := bot.Entitlement(userID)
+	subscription := entitlement.Subscription
 
 	var messageText string
 
-	if subscription != nil && subscription.Active {
-		// У пользователя есть активная подписка
+	if entitlement.HasSubscriptionAccess() {
+		// У пользователя есть активная подписка: показываем ее тариф, а не первый из доступных
+		if current, err := h.subscriptionService.GetTariff(subscription.Tariff); err != nil {
+			log.Printf("❌ Ошибка получения тарифа %s: %v", subscription.Tariff, err)
+		} else if current != nil {
+			tariff = *current
+		}
+		tariff.Price = subscription.Amount
+
 		messageText = fmt.Sprintf(
 			"🎉 *У вас активна подписка %s*\n\n"+
-				"💰 Стоимость: %.0f₽/месяц\n"+
+				"💰 Стоимость: %s\n"+
 				"📅 Следующий платеж: %s\n"+
 				"✅ Статус: Активна\n\n"+
 				"Хотите отменить подписку?",
 			tariff.Name,
-			tariff.Price,
+			formatTariffPrice(&tariff),
 			subscription.NextPayment.Format("02.01.2006"),
 		)
 
@@ -79,11 +84,11 @@ func (h *SubscriptionHandler) HandleSubscriptionCommand(bot *Bot, message *tgbot
 		// У пользователя нет подписки
 		messageText = fmt.Sprintf(
 			"💎 *Подписка %s*\n\n"+
-				"💰 Стоимость: %.0f₽/месяц\n"+
+				"💰 Стоимость: %s\n"+
 				"📝 Описание: %s\n\n"+
 				"✨ Преимущества:\n",
 			tariff.Name,
-			tariff.Price,
+			formatTariffPrice(&tariff),
 			tariff.Description,
 		)
 
@@ -266,7 +271,7 @@ func (h *SubscriptionHandler) CheckSubscriptionStatus(userID int64) (bool, error
 }
 
 // SendPaymentFailedMessage отправляет сообщение о неудачной попытке оплаты
-func (h *SubscriptionHandler) SendPaymentFailedMessage(userID int64, attempt int) error {
+func (h *SubscriptionHandler) SendPaymentFailedMessage(userID int64, attempt, maxAttempts int, nextRetry time.Time) error {
 	if h.bot == nil {
 		log.Printf("📨 [BOT] Cannot send message - bot not set for user %d (attempt %d)", userID, attempt)
 		return fmt.Errorf("bot not set")
@@ -274,13 +279,13 @@ func (h *SubscriptionHandler) SendPaymentFailedMessage(userID int64, attempt int
 
 	messageText := fmt.Sprintf(
 		"❌ *Не удалось списать деньги*\n\n"+
-			"Попытка %d из 3\n\n"+
+			"Попытка %d из %d. Следующая попытка: %s (UTC)\n\n"+
 			"Возможные причины:\n"+
 			"• Недостаточно средств на карте\n"+
 			"• Карта заблокирована\n"+
 			"• Истек срок действия карты\n\n"+
 			"Выберите действие:",
-		attempt,
+		attempt, maxAttempts, nextRetry.Format("02.01.2006 15:04"),
 	)
 
 	// Создаем кнопки для управления подпиской
@@ -300,7 +305,7 @@ func (h *SubscriptionHandler) SendPaymentFailedMessage(userID int64, attempt int
 	msg.ParseMode = "Markdown"
 	msg.ReplyMarkup = keyboard
 
-	_, err := h.bot.Send(msg)
+	_, err := h.bot.SendBulk(msg)
 	if err != nil {
 		log.Printf("❌ [BOT] Failed to send payment failed message to user %d: %v", userID, err)
 		return err
@@ -311,19 +316,19 @@ func (h *SubscriptionHandler) SendPaymentFailedMessage(userID int64, attempt int
 }
 
 // SendSubscriptionSuspendedMessage отправляет сообщение о приостановке подписки
-func (h *SubscriptionHandler) SendSubscriptionSuspendedMessage(userID int64) error {
+func (h *SubscriptionHandler) SendSubscriptionSuspendedMessage(userID int64, attempts int) error {
 	if h.bot == nil {
 		log.Printf("📨 [BOT] Cannot send message - bot not set for user %d", userID)
 		return fmt.Errorf("bot not set")
 	}
 
-	messageText := "🚫 *Подписка приостановлена*\n\n" +
-		"После 3 неудачных попыток списания ваша подписка была приостановлена.\n\n" +
-		"Для восстановления доступа:\n" +
-		"• Пополните баланс карты\n" +
-		"• Используйте другую карту\n" +
-		"• Обратитесь в поддержку\n\n" +
-		"Выберите действие:"
+	messageText := fmt.Sprintf("🚫 *Подписка приостановлена*\n\n"+
+		"После %d неудачных попыток списания ваша подписка была приостановлена.\n\n"+
+		"Для восстановления доступа:\n"+
+		"• Пополните баланс карты\n"+
+		"• Используйте другую карту\n"+
+		"• Обратитесь в поддержку\n\n"+
+		"Выберите действие:", attempts)
 
 	// Создаем кнопки для восстановления подписки
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
@@ -342,7 +347,7 @@ func (h *SubscriptionHandler) SendSubscriptionSuspendedMessage(userID int64) err
 	msg.ParseMode = "Markdown"
 	msg.ReplyMarkup = keyboard
 
-	_, err := h.bot.Send(msg)
+	_, err := h.bot.SendBulk(msg)
 	if err != nil {
 		log.Printf("❌ [BOT] Failed to send subscription suspended message to user %d: %v", userID, err)
 		return err
@@ -351,3 +356,225 @@ func (h *SubscriptionHandler) SendSubscriptionSuspendedMessage(userID int64) err
 	log.Printf("📨 [BOT] Subscription suspended message sent to user %d", userID)
 	return nil
 }
+
+// SendPaymentFinalNoticeMessage предупреждает, что следующая неудачная попытка списания приостановит подписку
+func (h *SubscriptionHandler) SendPaymentFinalNoticeMessage(userID int64, nextRetry time.Time) error {
+	if h.bot == nil {
+		log.Printf("📨 [BOT] Cannot send message - bot not set for user %d", userID)
+		return fmt.Errorf("bot not set")
+	}
+
+	messageText := fmt.Sprintf(
+		"⚠️ *Последняя попытка списания*\n\n"+
+			"Нам снова не удалось списать оплату подписки.\n"+
+			"Последняя попытка будет %s (UTC). Если она не пройдет, подписка будет приостановлена.\n\n"+
+			"Пополните карту или привяжите другую, чтобы не потерять доступ:",
+		nextRetry.Format("02.01.2006 15:04"),
+	)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Попробовать снова", "retry_payment"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💳 Использовать новую карту", "change_payment_method"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отменить подписку и отвязать карту", "cancel_subscription"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(userID, messageText)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+
+	if _, err := h.bot.SendBulk(msg); err != nil {
+		log.Printf("❌ [BOT] Failed to send final payment notice to user %d: %v", userID, err)
+		return err
+	}
+
+	log.Printf("📨 [BOT] Final payment notice sent to user %d", userID)
+	return nil
+}
+
+// SendRenewalReminderMessage напоминает о предстоящем списании. За день до списания
+// сообщение предупреждает о завтрашнем списании и предлагает отменить подписку.
+func (h *SubscriptionHandler) SendRenewalReminderMessage(userID int64, amount float64, chargeAt time.Time, daysLeft int) error {
+	if h.bot == nil {
+		log.Printf("📨 [BOT] Cannot send message - bot not set for user %d", userID)
+		return fmt.Errorf("bot not set")
+	}
+
+	var messageText string
+	if daysLeft <= 1 {
+		messageText = fmt.Sprintf(
+			"💳 *Завтра продление подписки*\n\n"+
+				"%s (UTC) с привязанной карты будет списано %.2f ₽.\n\n"+
+				"Если продление не нужно, отмените подписку до списания:",
+			chargeAt.Format("02.01.2006 15:04"), amount,
+		)
+	} else {
+		messageText = fmt.Sprintf(
+			"🔔 *Скоро продление подписки*\n\n"+
+				"Через %d дн., %s (UTC), с привязанной карты будет списано %.2f ₽.\n\n"+
+				"Ничего делать не нужно — подписка продлится автоматически.",
+			daysLeft, chargeAt.Format("02.01.2006 15:04"), amount,
+		)
+	}
+
+	msg := tgbotapi.NewMessage(userID, messageText)
+	msg.ParseMode = "Markdown"
+	if daysLeft <= 1 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("❌ Отменить подписку и отвязать карту", "cancel_subscription"),
+			),
+		)
+	}
+
+	if _, err := h.bot.SendBulk(msg); err != nil {
+		log.Printf("❌ [BOT] Failed to send renewal reminder to user %d: %v", userID, err)
+		return err
+	}
+
+	log.Printf("📨 [BOT] Renewal reminder sent to user %d (%d days before charge)", userID, daysLeft)
+	return nil
+}
+
+// SendPauseEndingMessage напоминает, что пауза скоро закончится и подписка возобновится
+func (h *SubscriptionHandler) SendPauseEndingMessage(userID int64, resumeAt, chargeAt time.Time) error {
+	if h.bot == nil {
+		log.Printf("📨 [BOT] Cannot send message - bot not set for user %d", userID)
+		return fmt.Errorf("bot not set")
+	}
+
+	msg := tgbotapi.NewMessage(userID, fmt.Sprintf(
+		"⏰ *Пауза скоро закончится*\n\n"+
+			"%s (UTC) подписка возобновится и Premium снова станет доступен.\n"+
+			"Следующее списание — %s (UTC).\n\n"+
+			"Можно не ждать и вернуться к Premium прямо сейчас:",
+		resumeAt.Format("02.01.2006 15:04"), chargeAt.Format("02.01.2006 15:04"),
+	))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("▶️ Возобновить сейчас", resumeSubscriptionCallback),
+		),
+	)
+
+	if _, err := h.bot.SendBulk(msg); err != nil {
+		log.Printf("❌ [BOT] Failed to send pause ending reminder to user %d: %v", userID, err)
+		return err
+	}
+
+	log.Printf("📨 [BOT] Pause ending reminder sent to user %d", userID)
+	return nil
+}
+
+// SendSubscriptionResumedMessage сообщает, что пауза закончилась и подписка снова активна
+func (h *SubscriptionHandler) SendSubscriptionResumedMessage(userID int64, nextPayment time.Time) error {
+	if h.bot == nil {
+		log.Printf("📨 [BOT] Cannot send message - bot not set for user %d", userID)
+		return fmt.Errorf("bot not set")
+	}
+
+	msg := tgbotapi.NewMessage(userID, fmt.Sprintf(
+		"▶️ *Подписка возобновлена*\n\n"+
+			"Пауза закончилась — Premium снова доступен.\n"+
+			"Следующее списание — %s (UTC).",
+		nextPayment.Format("02.01.2006 15:04"),
+	))
+	msg.ParseMode = "Markdown"
+
+	if _, err := h.bot.SendBulk(msg); err != nil {
+		log.Printf("❌ [BOT] Failed to send subscription resumed message to user %d: %v", userID, err)
+		return err
+	}
+
+	log.Printf("📨 [BOT] Subscription resumed message sent to user %d", userID)
+	return nil
+}
+
+// SendTrialEndingMessage напоминает, что пробный период заканчивается и с карты спишется цена тарифа
+func (h *SubscriptionHandler) SendTrialEndingMessage(userID int64, amount float64, chargeAt time.Time) error {
+	if h.bot == nil {
+		log.Printf("📨 [BOT] Cannot send message - bot not set for user %d", userID)
+		return fmt.Errorf("bot not set")
+	}
+
+	msg := tgbotapi.NewMessage(userID, fmt.Sprintf(
+		"🎁 *Пробный период заканчивается*\n\n"+
+			"%s (UTC) с привязанной карты будет списано %.2f ₽ и подписка продлится автоматически.\n\n"+
+			"Если подписка не нужна, отмените ее до списания:",
+		chargeAt.Format("02.01.2006 15:04"), amount,
+	))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отменить подписку и отвязать карту", "cancel_subscription"),
+		),
+	)
+
+	if _, err := h.bot.SendBulk(msg); err != nil {
+		log.Printf("❌ [BOT] Failed to send trial ending reminder to user %d: %v", userID, err)
+		return err
+	}
+
+	log.Printf("📨 [BOT] Trial ending reminder sent to user %d", userID)
+	return nil
+}
+
+// SendRefundMessage уведомляет пользователя о возврате платежа
+func (h *SubscriptionHandler) SendRefundMessage(outcome *service.RefundOutcome) error {
+	userID := outcome.Payment.UserID
+	if h.bot == nil {
+		log.Printf("📨 [BOT] Cannot send message - bot not set for user %d", userID)
+		return fmt.Errorf("bot not set")
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💰 История оплат", "payment_history"),
+		),
+	)
+	msg := tgbotapi.NewMessage(userID, refundMessageText(outcome))
+	msg.ReplyMarkup = keyboard
+
+	if _, err := h.bot.SendBulk(msg); err != nil {
+		log.Printf("❌ [BOT] Failed to send refund message to user %d: %v", userID, err)
+		return err
+	}
+
+	log.Printf("📨 [BOT] Refund message sent to user %d (refund %d)", userID, outcome.Refund.ID)
+	return nil
+}
+
+// SendReferralRewardMessage сообщает приглашающему о награде за первую оплату приглашенного
+func (h *SubscriptionHandler) SendReferralRewardMessage(referral *domain.Referral) error {
+	userID := referral.ReferrerID
+	if h.bot == nil {
+		log.Printf("📨 [BOT] Cannot send message - bot not set for user %d", userID)
+		return fmt.Errorf("bot not set")
+	}
+
+	text := fmt.Sprintf("🎉 Приглашенный вами друг оформил первую оплату!\n\nВам начислено: %s.",
+		formatReferralReward(referral.RewardType, referral.RewardAmount))
+	if referral.RewardType == domain.ReferralRewardGenerations {
+		text += "\nДополнительные создания действуют в текущем периоде лимитов."
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🤝 Пригласить еще", referralCallback),
+		),
+	)
+	msg := tgbotapi.NewMessage(userID, text)
+	msg.ReplyMarkup = keyboard
+
+	if _, err := h.bot.SendBulk(msg); err != nil {
+		log.Printf("❌ [BOT] Failed to send referral reward message to user %d: %v", userID, err)
+		return err
+	}
+
+	log.Printf("📨 [BOT] Referral reward message sent to user %d (referral %d)", userID, referral.ID)
+	return nil
+}
@@ -0,0 +1,160 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Callback-и выбора и покупки тарифа: после префикса идет ID тарифа
+const (
+	buyTariffCallbackPrefix       = "buy_tariff:"
+	confirmPurchaseCallbackPrefix = "confirm_purchase:"
+)
+
+// tariffPeriodLabel возвращает период оплаты в винительном падеже: «990₽/месяц», «на месяц»
+func tariffPeriodLabel(period string) string {
+	switch period {
+	case domain.TariffPeriodDay:
+		return "день"
+	case domain.TariffPeriodWeek:
+		return "неделю"
+	case domain.TariffPeriodYear:
+		return "год"
+	default:
+		return "месяц"
+	}
+}
+
+// tariffPeriodEnd возвращает дату окончания оплаченного периода
+func tariffPeriodEnd(period string, from time.Time) time.Time {
+	switch period {
+	case domain.TariffPeriodDay:
+		return from.AddDate(0, 0, 1)
+	case domain.TariffPeriodWeek:
+		return from.AddDate(0, 0, 7)
+	case domain.TariffPeriodYear:
+		return from.AddDate(1, 0, 0)
+	default:
+		return from.AddDate(0, 1, 0)
+	}
+}
+
+// formatTariffPrice форматирует цену тарифа: «990₽/месяц»
+func formatTariffPrice(tariff *domain.Tariff) string {
+	currency := "₽"
+	if tariff.Currency != "" && tariff.Currency != "RUB" {
+		currency = " " + tariff.Currency
+	}
+	return fmt.Sprintf("%.0f%s/%s", tariff.Price, currency, tariffPeriodLabel(tariff.Period))
+}
+
+// formatStartingPrice форматирует цену самого дешевого из доступных тарифов: «от 990₽/месяц»
+func formatStartingPrice(tariffs []domain.Tariff) string {
+	if len(tariffs) == 0 {
+		return "тарифы временно недоступны"
+	}
+	cheapest := tariffs[0]
+	for _, tariff := range tariffs[1:] {
+		if tariff.Price < cheapest.Price {
+			cheapest = tariff
+		}
+	}
+	if len(tariffs) == 1 {
+		return formatTariffPrice(&cheapest)
+	}
+	return "от " + formatTariffPrice(&cheapest)
+}
+
+// buildTariffOfferText формирует экран оформления подписки на тариф
+func buildTariffOfferText(tariff *domain.Tariff, now time.Time) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("💎 *Оформление подписки %s*\n\n", tariff.Name))
+	if tariff.Description != "" {
+		sb.WriteString(tariff.Description + "\n\n")
+	}
+	if len(tariff.Features) > 0 {
+		sb.WriteString(fmt.Sprintf("✨ *Преимущества %s:*\n", tariff.Name))
+		for _, feature := range tariff.Features {
+			sb.WriteString("• " + feature + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	period := tariffPeriodLabel(tariff.Period)
+	sb.WriteString(fmt.Sprintf("💰 *Стоимость:* %s\n", formatTariffPrice(tariff)))
+	sb.WriteString(fmt.Sprintf("📅 *Период:* 1 %s (до %s)\n", period, tariffPeriodEnd(tariff.Period, now).Format("02.01.2006")))
+	sb.WriteString("♻️ *Автопродление:* включено\n\n")
+	sb.WriteString("Нажмите «Подтвердить покупку» для перехода к оплате:")
+	return sb.String()
+}
+
+// buildTariffPurchaseView формирует экран покупки: описание единственного тарифа или список тарифов на выбор
+func buildTariffPurchaseView(tariffs []domain.Tariff, now time.Time) (string, tgbotapi.InlineKeyboardMarkup) {
+	if len(tariffs) == 0 {
+		return "❌ Тарифы временно недоступны. Попробуйте позже.", tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "subscription"),
+			),
+		)
+	}
+	if len(tariffs) == 1 {
+		return buildTariffOfferView(&tariffs[0], now, "subscription")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("💎 *Выберите тариф*\n")
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := range tariffs {
+		tariff := &tariffs[i]
+		sb.WriteString(fmt.Sprintf("\n*%s* — %s", tariff.Name, formatTariffPrice(tariff)))
+		if tariff.Description != "" {
+			sb.WriteString("\n" + tariff.Description)
+		}
+		sb.WriteString("\n")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s — %s", tariff.Name, formatTariffPrice(tariff)),
+				buyTariffCallbackPrefix+tariff.ID,
+			),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "subscription"),
+	))
+	return sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// buildTariffOfferView формирует экран оформления тарифа с кнопкой подтверждения
+func buildTariffOfferView(tariff *domain.Tariff, now time.Time, backCallback string) (string, tgbotapi.InlineKeyboardMarkup) {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Подтвердить покупку", confirmPurchaseCallbackPrefix+tariff.ID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🎟 Ввести промокод", enterPromoCallbackPrefix+tariff.ID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", backCallback),
+		),
+	)
+	return buildTariffOfferText(tariff, now), keyboard
+}
+
+// findAvailableTariff ищет тариф среди доступных для покупки
+func findAvailableTariff(tariffs []domain.Tariff, id string) *domain.Tariff {
+	for i := range tariffs {
+		if tariffs[i].ID == id {
+			return &tariffs[i]
+		}
+	}
+	return nil
+}
+
+// StartingPriceText возвращает цену подписки для текстов бота: «990₽/месяц» или «от 990₽/месяц»
+func (b *Bot) StartingPriceText() string {
+	return formatStartingPrice(b.SubscriptionService.GetAvailableTariffs())
+}
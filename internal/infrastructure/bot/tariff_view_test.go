@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+)
+
+func TestBuildTariffPurchaseView(t *testing.T) {
+	now := time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC)
+	monthly := domain.Tariff{ID: "premium", Name: "Premium", Price: 990, Currency: "RUB", Period: domain.TariffPeriodMonth}
+	yearly := domain.Tariff{ID: "premium_year", Name: "Premium на год", Price: 9900, Currency: "RUB", Period: domain.TariffPeriodYear}
+
+	// Единственный тариф показывается сразу с кнопкой подтверждения
+	text, keyboard := buildTariffPurchaseView([]domain.Tariff{monthly}, now)
+	if !strings.Contains(text, "990₽/месяц") {
+		t.Errorf("В описании нет цены: %q", text)
+	}
+	if data := keyboard.InlineKeyboard[0][0].CallbackData; data == nil || *data != confirmPurchaseCallbackPrefix+"premium" {
+		t.Errorf("Ожидалась кнопка подтверждения покупки premium, получено %+v", keyboard.InlineKeyboard[0][0])
+	}
+
+	// Несколько тарифов — список на выбор и кнопка «Назад»
+	text, keyboard = buildTariffPurchaseView([]domain.Tariff{monthly, yearly}, now)
+	if !strings.Contains(text, "9900₽/год") {
+		t.Errorf("В списке нет годового тарифа: %q", text)
+	}
+	if len(keyboard.InlineKeyboard) != 3 {
+		t.Fatalf("Ожидалось 3 ряда кнопок, получено %d", len(keyboard.InlineKeyboard))
+	}
+	if data := keyboard.InlineKeyboard[1][0].CallbackData; data == nil || *data != buyTariffCallbackPrefix+"premium_year" {
+		t.Errorf("Ожидалась кнопка выбора premium_year, получено %+v", keyboard.InlineKeyboard[1][0])
+	}
+
+	// Годовой тариф оплачивается до той же даты следующего года
+	if offer := buildTariffOfferText(&yearly, now); !strings.Contains(offer, "до 31.01.2026") {
+		t.Errorf("Неверная дата окончания годового периода: %q", offer)
+	}
+
+	if price := formatStartingPrice([]domain.Tariff{yearly, monthly}); price != "от 990₽/месяц" {
+		t.Errorf("Ожидалась минимальная цена «от 990₽/месяц», получено %q", price)
+	}
+}
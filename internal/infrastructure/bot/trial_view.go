@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+
+	"ai_tg_writer/internal/service"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// startTrialCallback callback оформления пробного периода
+const startTrialCallback = "start_trial"
+
+// trialButtonLabel подпись кнопки пробного периода: «🎁 7 дней Premium бесплатно»
+func trialButtonLabel(offer *service.TrialOffer) string {
+	return fmt.Sprintf("🎁 %d дн. Premium бесплатно", offer.Days)
+}
+
+// buildTrialLinkText формирует текст перед переходом к привязке карты
+func buildTrialLinkText(offer *service.TrialOffer) string {
+	return fmt.Sprintf("🎁 *Пробный период %d дн.*\n\n"+
+		"Привяжите карту — для проверки спишем %.0f ₽ и сразу вернем их. Premium станет доступен сразу после привязки.\n\n"+
+		"После пробного периода подписка %s продлится автоматически по цене %s. "+
+		"За день до списания мы напомним, а отменить подписку можно в любой момент.",
+		offer.Days, offer.BindingAmount, offer.Tariff.Name, formatTariffPrice(offer.Tariff))
+}
+
+// trialOffer возвращает пробный период, доступный пользователю, или nil
+func (b *Bot) trialOffer(userID int64) *service.TrialOffer {
+	if b.SubscriptionService == nil {
+		return nil
+	}
+	offer, err := b.SubscriptionService.TrialOffer(userID)
+	if err != nil {
+		log.Printf("Ошибка проверки пробного периода пользователя %d: %v", userID, err)
+		return nil
+	}
+	return offer
+}
+
+// WithTrialRow добавляет первой строкой кнопку пробного периода, если он доступен пользователю
+func (b *Bot) WithTrialRow(userID int64, keyboard tgbotapi.InlineKeyboardMarkup) tgbotapi.InlineKeyboardMarkup {
+	offer := b.trialOffer(userID)
+	if offer == nil {
+		return keyboard
+	}
+	row := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(trialButtonLabel(offer), startTrialCallback),
+	)
+	return tgbotapi.NewInlineKeyboardMarkup(append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)...)
+}
@@ -35,6 +35,8 @@ type UserState struct {
 	UsageCount        int                            // количество использований
 	LastUsage         time.Time                      // дата последнего использования
 	WaitingForEmail   bool                           // ожидаем ввод email
+	WaitingForPromo   string                         // ID тарифа, для которого ожидаем ввод промокода
+	PromoCode         string                         // промокод, примененный к оформлению подписки
 	ReferralCode      string                         // реферальный код пользователя
 	ReferredBy        *int64                         // ID пользователя, который пригласил
 	// Поля для рерайта постов
@@ -126,45 +128,6 @@ func (sm *StateManager) UpdateStep(userID int64, step string) {
 	state.CurrentStep = step
 }
 
-// IncrementUsage увеличивает счетчик использований
-// Вызывается только когда пользователь принял готовый пост от LLM
-func (sm *StateManager) IncrementUsage(userID int64) error {
-	state := sm.GetState(userID)
-	state.UsageCount++
-
-	// Обновляем БД
-	err := sm.db.IncrementUsage(userID)
-	if err != nil {
-		log.Printf("Ошибка обновления счетчика: %v", err)
-		return err
-	}
-	return nil
-}
-
-// CheckLimit проверяет лимит использования
-func (sm *StateManager) CheckLimit(userID int64) (bool, error) {
-	state := sm.GetState(userID)
-
-	// Проверяем лимит в зависимости от тарифа
-	var dailyLimit int
-	switch state.Tariff {
-	case "free":
-		dailyLimit = 5
-	case "premium":
-		dailyLimit = 999999
-	default:
-		dailyLimit = 5
-	}
-
-	// Получаем текущее использование за сегодня
-	usage, err := sm.db.GetUserUsageToday(userID)
-	if err != nil {
-		return false, err
-	}
-
-	return usage < dailyLimit, nil
-}
-
 // SetContentType устанавливает тип контента
 func (sm *StateManager) SetContentType(userID int64, contentType string) {
 	state := sm.GetState(userID)
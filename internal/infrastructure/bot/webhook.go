@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// AllowedUpdates список типов обновлений, которые бот обрабатывает
+var AllowedUpdates = []string{"message", "callback_query", "my_chat_member"}
+
+// SetWebhook регистрирует webhook в Telegram.
+// tgbotapi.WebhookConfig не поддерживает secret_token, поэтому параметры собираются вручную.
+func (b *Bot) SetWebhook(webhookURL, secretToken, certPath string, maxConnections int) error {
+	if webhookURL == "" {
+		return fmt.Errorf("webhook url is empty")
+	}
+
+	params := make(tgbotapi.Params)
+	params["url"] = webhookURL
+	params.AddNonEmpty("secret_token", secretToken)
+	params.AddNonZero("max_connections", maxConnections)
+	if err := params.AddInterface("allowed_updates", AllowedUpdates); err != nil {
+		return fmt.Errorf("allowed_updates: %w", err)
+	}
+
+	var files []tgbotapi.RequestFile
+	if certPath != "" {
+		// Самоподписанный сертификат загружается вместе с запросом setWebhook
+		files = append(files, tgbotapi.RequestFile{
+			Name: "certificate",
+			Data: tgbotapi.FilePath(certPath),
+		})
+	}
+
+	var (
+		resp *tgbotapi.APIResponse
+		err  error
+	)
+	if len(files) > 0 {
+		resp, err = b.API.UploadFiles("setWebhook", params, files)
+	} else {
+		resp, err = b.API.MakeRequest("setWebhook", params)
+	}
+	if err != nil {
+		return fmt.Errorf("setWebhook: %w", err)
+	}
+	if !resp.Ok {
+		return fmt.Errorf("setWebhook: %s", resp.Description)
+	}
+
+	log.Printf("🔗 Webhook зарегистрирован: %s (сертификат: %v)", webhookURL, certPath != "")
+	return nil
+}
+
+// DeleteWebhook удаляет webhook, чтобы можно было получать обновления через long polling
+func (b *Bot) DeleteWebhook() error {
+	if _, err := b.API.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return fmt.Errorf("deleteWebhook: %w", err)
+	}
+	return nil
+}
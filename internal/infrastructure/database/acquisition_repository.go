@@ -0,0 +1,68 @@
+package database
+
+import (
+	"time"
+
+	"ai_tg_writer/internal/domain"
+)
+
+// AcquisitionRepository работает с источниками привлечения пользователей
+type AcquisitionRepository struct {
+	db *DB
+}
+
+// NewAcquisitionRepository создает новый репозиторий источников привлечения
+func NewAcquisitionRepository(db *DB) *AcquisitionRepository {
+	return &AcquisitionRepository{db: db}
+}
+
+// Record сохраняет источник, только если он еще не записан: учитывается первое касание
+func (r *AcquisitionRepository) Record(userID int64, acquisition domain.Acquisition, at time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE users SET acquisition_source = $2, acquisition_campaign = NULLIF($3, ''), acquired_at = $4
+		WHERE id = $1 AND acquisition_source IS NULL`,
+		userID, acquisition.Source, acquisition.Campaign, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// GetFunnel считает воронку по источникам для пользователей, пришедших начиная с since.
+// Первое создание и первая оплата учитываются за все время, а не только за период.
+func (r *AcquisitionRepository) GetFunnel(since time.Time) ([]*domain.AcquisitionStats, error) {
+	rows, err := r.db.Query(`
+		SELECT COALESCE(u.acquisition_source, $2), COALESCE(u.acquisition_campaign, ''),
+		       COUNT(*),
+		       COUNT(h.user_id),
+		       COUNT(p.user_id),
+		       COALESCE(SUM(p.revenue), 0)
+		FROM users u
+		LEFT JOIN LATERAL (
+		    SELECT user_id FROM post_history WHERE user_id = u.id LIMIT 1
+		) h ON TRUE
+		LEFT JOIN LATERAL (
+		    SELECT user_id, SUM(amount) AS revenue FROM payments
+		    WHERE user_id = u.id AND status = $3 AND kind <> $4
+		    GROUP BY user_id
+		) p ON TRUE
+		WHERE COALESCE(u.acquired_at, u.created_at) >= $1
+		GROUP BY 1, 2
+		ORDER BY 3 DESC, 1, 2`,
+		since, domain.AcquisitionOrganic, domain.PaymentStatusSucceeded, domain.PaymentKindTrial)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var funnel []*domain.AcquisitionStats
+	for rows.Next() {
+		s := &domain.AcquisitionStats{}
+		if err := rows.Scan(&s.Source, &s.Campaign, &s.Signups, &s.FirstGeneration, &s.FirstPayment, &s.Revenue); err != nil {
+			return nil, err
+		}
+		funnel = append(funnel, s)
+	}
+	return funnel, rows.Err()
+}
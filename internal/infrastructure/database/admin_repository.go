@@ -0,0 +1,192 @@
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+)
+
+// MaxPremiumGrantDays максимальный срок Premium, выдаваемого администратором за раз
+const MaxPremiumGrantDays = 3650
+
+// AdminUserInfo сведения о пользователе для админ-панели
+type AdminUserInfo struct {
+	ID           int64      `json:"id"`
+	Username     string     `json:"username"`
+	FirstName    string     `json:"first_name"`
+	LastName     string     `json:"last_name"`
+	Email        string     `json:"email,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	IsAdmin      bool       `json:"is_admin"`
+	IsBanned     bool       `json:"is_banned"`
+	BannedAt     *time.Time `json:"banned_at,omitempty"`
+	BotBlocked   bool       `json:"bot_blocked"`
+	PremiumUntil *time.Time `json:"premium_until,omitempty"`
+}
+
+// AdminAuditEntry запись журнала действий администраторов
+type AdminAuditEntry struct {
+	ID           int64
+	AdminID      int64
+	Action       string
+	TargetUserID *int64
+	Details      string
+	CreatedAt    time.Time
+}
+
+// FindUser ищет пользователя по ID или @username. Возвращает nil, если пользователь не найден.
+func (db *DB) FindUser(ref string) (*AdminUserInfo, error) {
+	ref = strings.TrimSpace(ref)
+	query := `
+		SELECT id, COALESCE(username, ''), COALESCE(first_name, ''), COALESCE(last_name, ''), COALESCE(email, ''),
+		       created_at, COALESCE(is_admin, FALSE), COALESCE(is_banned, FALSE), banned_at,
+		       COALESCE(bot_blocked, FALSE), premium_until
+		FROM users`
+
+	var row *sql.Row
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		row = db.QueryRow(query+` WHERE id = $1`, id)
+	} else {
+		username := strings.TrimPrefix(ref, "@")
+		row = db.QueryRow(query+` WHERE LOWER(username) = LOWER($1) ORDER BY created_at DESC LIMIT 1`, username)
+	}
+
+	info := &AdminUserInfo{}
+	err := row.Scan(&info.ID, &info.Username, &info.FirstName, &info.LastName, &info.Email,
+		&info.CreatedAt, &info.IsAdmin, &info.IsBanned, &info.BannedAt,
+		&info.BotBlocked, &info.PremiumUntil)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// GrantQuota выдает пользователю дополнительные единицы ресурса на текущий период
+func (db *DB) GrantQuota(userID int64, resource domain.QuotaResource, amount int, adminID int64) error {
+	_, err := db.Exec(`
+		INSERT INTO quota_grants (user_id, resource, amount, granted_by)
+		VALUES ($1, $2, $3, $4)`, userID, resource, amount, adminID)
+	return err
+}
+
+// SetPremiumUntil выдает Premium до указанной даты; nil отзывает выданный Premium
+func (db *DB) SetPremiumUntil(userID int64, until *time.Time) error {
+	_, err := db.Exec(`UPDATE users SET premium_until = $1 WHERE id = $2`, until, userID)
+	return err
+}
+
+// ExtendPremium продлевает выданный Premium на указанное количество дней и возвращает новую дату окончания
+func (db *DB) ExtendPremium(userID int64, days int) (time.Time, error) {
+	var until time.Time
+	err := db.QueryRow(`
+		UPDATE users
+		SET premium_until = GREATEST(COALESCE(premium_until, $1), $1) + make_interval(days => $2)
+		WHERE id = $3
+		RETURNING premium_until`, time.Now().UTC(), days, userID).Scan(&until)
+	return until, err
+}
+
+// GetPremiumUntil возвращает дату окончания выданного администратором Premium
+func (db *DB) GetPremiumUntil(userID int64) (*time.Time, error) {
+	var until *time.Time
+	err := db.QueryRow(`SELECT premium_until FROM users WHERE id = $1`, userID).Scan(&until)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return until, err
+}
+
+// SetUserAdmin назначает или снимает права администратора
+func (db *DB) SetUserAdmin(userID int64, isAdmin bool) error {
+	_, err := db.Exec(`UPDATE users SET is_admin = $1 WHERE id = $2`, isAdmin, userID)
+	return err
+}
+
+// SetUserBanned блокирует или разблокирует доступ пользователя к боту
+func (db *DB) SetUserBanned(userID int64, banned bool) error {
+	var bannedAt *time.Time
+	if banned {
+		now := time.Now().UTC()
+		bannedAt = &now
+	}
+	_, err := db.Exec(`UPDATE users SET is_banned = $1, banned_at = $2 WHERE id = $3`, banned, bannedAt, userID)
+	return err
+}
+
+// IsUserBanned проверяет, заблокирован ли пользователь администратором
+func (db *DB) IsUserBanned(userID int64) (bool, error) {
+	var banned bool
+	err := db.QueryRow(`SELECT COALESCE(is_banned, FALSE) FROM users WHERE id = $1`, userID).Scan(&banned)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return banned, err
+}
+
+// LogAdminAction записывает действие администратора в журнал
+func (db *DB) LogAdminAction(adminID int64, action string, targetUserID *int64, details string) error {
+	_, err := db.Exec(`
+		INSERT INTO admin_audit_log (admin_id, action, target_user_id, details)
+		VALUES ($1, $2, $3, $4)`, adminID, action, targetUserID, details)
+	return err
+}
+
+// GetAdminAuditLog возвращает последние записи журнала действий администраторов
+func (db *DB) GetAdminAuditLog(limit int) ([]*AdminAuditEntry, error) {
+	rows, err := db.Query(`
+		SELECT id, admin_id, action, target_user_id, COALESCE(details, ''), created_at
+		FROM admin_audit_log
+		ORDER BY created_at DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*AdminAuditEntry
+	for rows.Next() {
+		entry := &AdminAuditEntry{}
+		if err := rows.Scan(&entry.ID, &entry.AdminID, &entry.Action, &entry.TargetUserID, &entry.Details, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// UsageSummary сводная статистика использования бота
+type UsageSummary struct {
+	TotalUsers         int `json:"total_users"`
+	ActiveThisMonth    int `json:"active_this_month"`
+	CreationsThisMonth int `json:"creations_this_month"`
+	BotBlockedUsers    int `json:"bot_blocked_users"`
+	BannedUsers        int `json:"banned_users"`
+}
+
+// GetUsageSummary возвращает сводную статистику использования за текущий месяц
+func (db *DB) GetUsageSummary() (*UsageSummary, error) {
+	summary := &UsageSummary{}
+	err := db.QueryRow(`
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE COALESCE(bot_blocked, FALSE)),
+		       COUNT(*) FILTER (WHERE COALESCE(is_banned, FALSE))
+		FROM users`).Scan(&summary.TotalUsers, &summary.BotBlockedUsers, &summary.BannedUsers)
+	if err != nil {
+		return nil, err
+	}
+	// Периоды квот у каждого пользователя свои, поэтому учитываем периоды с расходом в этом месяце
+	err = db.QueryRow(`
+		SELECT COUNT(DISTINCT user_id), COALESCE(SUM(used) FILTER (WHERE resource = $1), 0)
+		FROM quota_usage
+		WHERE updated_at >= date_trunc('month', CURRENT_DATE)`, domain.QuotaGenerations).Scan(&summary.ActiveThisMonth, &summary.CreationsThisMonth)
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// AdminRole роль токена административного API
+type AdminRole string
+
+const (
+	AdminRoleViewer   AdminRole = "viewer"   // Только чтение
+	AdminRoleOperator AdminRole = "operator" // Чтение и управление пользователями
+	AdminRoleAdmin    AdminRole = "admin"    // Полный доступ, включая платежные операции
+)
+
+var adminRoleRanks = map[AdminRole]int{
+	AdminRoleViewer:   1,
+	AdminRoleOperator: 2,
+	AdminRoleAdmin:    3,
+}
+
+// ParseAdminRole проверяет название роли
+func ParseAdminRole(value string) (AdminRole, error) {
+	role := AdminRole(value)
+	if _, ok := adminRoleRanks[role]; !ok {
+		return "", fmt.Errorf("unknown role: %s", value)
+	}
+	return role, nil
+}
+
+// Allows проверяет, что роль дает права не ниже required
+func (r AdminRole) Allows(required AdminRole) bool {
+	return adminRoleRanks[r] >= adminRoleRanks[required] && adminRoleRanks[required] > 0
+}
+
+// AdminAPIToken токен административного API
+type AdminAPIToken struct {
+	ID         int64
+	Name       string
+	Role       AdminRole
+	CreatedBy  int64
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// GenerateAdminAPIToken создает случайный токен и его хеш для хранения
+func GenerateAdminAPIToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = "atw_" + hex.EncodeToString(buf)
+	return token, HashAdminAPIToken(token), nil
+}
+
+// HashAdminAPIToken возвращает SHA-256 хеш токена
+func HashAdminAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAdminAPIToken сохраняет новый токен
+func (db *DB) CreateAdminAPIToken(name string, role AdminRole, hash string, createdBy int64) (int64, error) {
+	var id int64
+	err := db.QueryRow(`
+		INSERT INTO admin_api_tokens (name, token_hash, role, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`, name, hash, role, createdBy).Scan(&id)
+	return id, err
+}
+
+// GetAdminAPITokenByHash возвращает действующий токен по хешу или nil
+func (db *DB) GetAdminAPITokenByHash(hash string) (*AdminAPIToken, error) {
+	token := &AdminAPIToken{}
+	err := db.QueryRow(`
+		SELECT id, name, role, created_by, created_at, last_used_at, revoked_at
+		FROM admin_api_tokens
+		WHERE token_hash = $1 AND revoked_at IS NULL`, hash).Scan(
+		&token.ID, &token.Name, &token.Role, &token.CreatedBy, &token.CreatedAt, &token.LastUsedAt, &token.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// TouchAdminAPIToken обновляет время последнего использования токена
+func (db *DB) TouchAdminAPIToken(id int64) error {
+	_, err := db.Exec(`UPDATE admin_api_tokens SET last_used_at = $1 WHERE id = $2`, time.Now().UTC(), id)
+	return err
+}
+
+// RevokeAdminAPIToken отзывает токен
+func (db *DB) RevokeAdminAPIToken(id int64) (bool, error) {
+	res, err := db.Exec(`
+		UPDATE admin_api_tokens SET revoked_at = $1
+		WHERE id = $2 AND revoked_at IS NULL`, time.Now().UTC(), id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// RevokeAdminAPITokensCreatedBy отзывает все действующие токены, выпущенные администратором
+func (db *DB) RevokeAdminAPITokensCreatedBy(createdBy int64) (int, error) {
+	res, err := db.Exec(`
+		UPDATE admin_api_tokens SET revoked_at = $1
+		WHERE created_by = $2 AND revoked_at IS NULL`, time.Now().UTC(), createdBy)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// ListAdminAPITokens возвращает все токены, включая отозванные
+func (db *DB) ListAdminAPITokens() ([]*AdminAPIToken, error) {
+	rows, err := db.Query(`
+		SELECT id, name, role, created_by, created_at, last_used_at, revoked_at
+		FROM admin_api_tokens
+		ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*AdminAPIToken
+	for rows.Next() {
+		token := &AdminAPIToken{}
+		if err := rows.Scan(&token.ID, &token.Name, &token.Role, &token.CreatedBy,
+			&token.CreatedAt, &token.LastUsedAt, &token.RevokedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
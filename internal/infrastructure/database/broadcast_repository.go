@@ -0,0 +1,387 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// BroadcastSegment сегмент получателей рассылки
+type BroadcastSegment string
+
+const (
+	SegmentAll      BroadcastSegment = "all"      // Все пользователи
+	SegmentFree     BroadcastSegment = "free"     // Без активной подписки
+	SegmentPremium  BroadcastSegment = "premium"  // С активной подпиской или выданным Premium
+	SegmentChurned  BroadcastSegment = "churned"  // Подписка была, но закончилась
+	SegmentInactive BroadcastSegment = "inactive" // Не пользовались ботом N дней
+)
+
+// BroadcastStatus статус рассылки
+type BroadcastStatus string
+
+const (
+	BroadcastStatusDraft     BroadcastStatus = "draft"
+	BroadcastStatusRunning   BroadcastStatus = "running"
+	BroadcastStatusPaused    BroadcastStatus = "paused"
+	BroadcastStatusCompleted BroadcastStatus = "completed"
+	BroadcastStatusCancelled BroadcastStatus = "cancelled"
+)
+
+// Статусы доставки рассылки получателю
+const (
+	RecipientStatusPending = "pending"
+	RecipientStatusSending = "sending" // Захвачен экземпляром воркера до claimed_until
+	RecipientStatusSent    = "sent"
+	RecipientStatusFailed  = "failed"
+	RecipientStatusBlocked = "blocked"
+)
+
+// BroadcastButton кнопка-ссылка под сообщением рассылки
+type BroadcastButton struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+// Broadcast рассылка администратора
+type Broadcast struct {
+	ID           int64
+	AdminID      int64
+	Text         string
+	Entities     json.RawMessage // []tgbotapi.MessageEntity
+	Buttons      []BroadcastButton
+	Segment      BroadcastSegment
+	InactiveDays int
+	IsMarketing  bool
+	Status       BroadcastStatus
+	Total        int
+	Sent         int
+	Failed       int
+	CreatedAt    time.Time
+	StartedAt    *time.Time
+	FinishedAt   *time.Time
+}
+
+// BroadcastRepository работает с рассылками и статусами их доставки
+type BroadcastRepository struct {
+	db *DB
+}
+
+func NewBroadcastRepository(db *DB) *BroadcastRepository {
+	return &BroadcastRepository{db: db}
+}
+
+// ParseBroadcastSegment проверяет название сегмента
+func ParseBroadcastSegment(value string) (BroadcastSegment, error) {
+	switch segment := BroadcastSegment(value); segment {
+	case SegmentAll, SegmentFree, SegmentPremium, SegmentChurned, SegmentInactive:
+		return segment, nil
+	default:
+		return "", fmt.Errorf("unknown segment: %s", value)
+	}
+}
+
+// segmentCondition возвращает условие выборки пользователей сегмента и его параметры.
+// argOffset — количество параметров запроса, предшествующих условию.
+func segmentCondition(segment BroadcastSegment, isMarketing bool, inactiveDays, argOffset int) (string, []interface{}, error) {
+	const hasPremium = `(EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = u.id AND s.active = TRUE AND s.status IN ('active', 'cancelled'))
+		OR u.premium_until > NOW())`
+
+	// Пользователям, заблокировавшим бота или забаненным, рассылки не отправляем
+	condition := `COALESCE(u.bot_blocked, FALSE) = FALSE AND COALESCE(u.is_banned, FALSE) = FALSE`
+	if isMarketing {
+		condition += ` AND COALESCE(u.marketing_opt_out, FALSE) = FALSE`
+	}
+
+	switch segment {
+	case SegmentAll:
+	case SegmentFree:
+		condition += ` AND NOT ` + hasPremium
+	case SegmentPremium:
+		condition += ` AND ` + hasPremium
+	case SegmentChurned:
+		condition += ` AND NOT ` + hasPremium + `
+		AND EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = u.id AND s.status IN ('cancelled', 'expired', 'suspended'))`
+	case SegmentInactive:
+		condition += fmt.Sprintf(` AND COALESCE(u.last_usage, u.created_at) < NOW() - make_interval(days => $%d)`, argOffset+1)
+		return condition, []interface{}{inactiveDays}, nil
+	default:
+		return "", nil, fmt.Errorf("unknown segment: %s", segment)
+	}
+
+	return condition, nil, nil
+}
+
+// CountSegment возвращает количество получателей сегмента
+func (r *BroadcastRepository) CountSegment(segment BroadcastSegment, inactiveDays int, isMarketing bool) (int, error) {
+	condition, args, err := segmentCondition(segment, isMarketing, inactiveDays, 0)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = r.db.QueryRow(`SELECT COUNT(*) FROM users u WHERE `+condition, args...).Scan(&count)
+	return count, err
+}
+
+// Create сохраняет черновик рассылки
+func (r *BroadcastRepository) Create(broadcast *Broadcast) error {
+	if broadcast.Buttons == nil {
+		broadcast.Buttons = []BroadcastButton{}
+	}
+	buttons, err := json.Marshal(broadcast.Buttons)
+	if err != nil {
+		return err
+	}
+	// JSONB передаем строкой: []byte драйвер отправляет как bytea
+	var entities interface{}
+	if len(broadcast.Entities) > 0 {
+		entities = string(broadcast.Entities)
+	}
+
+	broadcast.Status = BroadcastStatusDraft
+	return r.db.QueryRow(`
+		INSERT INTO broadcasts (admin_id, text, entities, buttons, segment, inactive_days, is_marketing, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`,
+		broadcast.AdminID, broadcast.Text, entities, string(buttons), broadcast.Segment,
+		broadcast.InactiveDays, broadcast.IsMarketing, broadcast.Status,
+	).Scan(&broadcast.ID, &broadcast.CreatedAt)
+}
+
+const broadcastColumns = `id, admin_id, text, entities, buttons, segment, inactive_days, is_marketing, status,
+	total, sent, failed, created_at, started_at, finished_at`
+
+// scanBroadcast читает рассылку из строки результата
+func scanBroadcast(scanner interface{ Scan(...interface{}) error }) (*Broadcast, error) {
+	broadcast := &Broadcast{}
+	var entities, buttons []byte
+	err := scanner.Scan(&broadcast.ID, &broadcast.AdminID, &broadcast.Text, &entities, &buttons,
+		&broadcast.Segment, &broadcast.InactiveDays, &broadcast.IsMarketing, &broadcast.Status,
+		&broadcast.Total, &broadcast.Sent, &broadcast.Failed,
+		&broadcast.CreatedAt, &broadcast.StartedAt, &broadcast.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	broadcast.Entities = entities
+	if len(buttons) > 0 {
+		if err := json.Unmarshal(buttons, &broadcast.Buttons); err != nil {
+			return nil, fmt.Errorf("buttons: %w", err)
+		}
+	}
+	return broadcast, nil
+}
+
+// GetByID возвращает рассылку по ID или nil, если она не найдена
+func (r *BroadcastRepository) GetByID(id int64) (*Broadcast, error) {
+	broadcast, err := scanBroadcast(r.db.QueryRow(`SELECT `+broadcastColumns+` FROM broadcasts WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return broadcast, err
+}
+
+// GetRunning возвращает рассылки, которые нужно отправлять
+func (r *BroadcastRepository) GetRunning() ([]*Broadcast, error) {
+	rows, err := r.db.Query(`SELECT `+broadcastColumns+` FROM broadcasts WHERE status = $1 ORDER BY id`, BroadcastStatusRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var broadcasts []*Broadcast
+	for rows.Next() {
+		broadcast, err := scanBroadcast(rows)
+		if err != nil {
+			return nil, err
+		}
+		broadcasts = append(broadcasts, broadcast)
+	}
+	return broadcasts, rows.Err()
+}
+
+// AddButton добавляет кнопку к черновику рассылки
+func (r *BroadcastRepository) AddButton(id int64, button BroadcastButton) (bool, error) {
+	data, err := json.Marshal([]BroadcastButton{button})
+	if err != nil {
+		return false, err
+	}
+	res, err := r.db.Exec(`
+		UPDATE broadcasts SET buttons = COALESCE(buttons, '[]'::jsonb) || $1::jsonb
+		WHERE id = $2 AND status = $3`, string(data), id, BroadcastStatusDraft)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// Start формирует список получателей черновика и запускает рассылку.
+// Возвращает количество получателей.
+func (r *BroadcastRepository) Start(broadcast *Broadcast) (int, error) {
+	condition, args, err := segmentCondition(broadcast.Segment, broadcast.IsMarketing, broadcast.InactiveDays, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Блокируем черновик, чтобы рассылку нельзя было запустить дважды
+	var status BroadcastStatus
+	if err := tx.QueryRow(`SELECT status FROM broadcasts WHERE id = $1 FOR UPDATE`, broadcast.ID).Scan(&status); err != nil {
+		return 0, err
+	}
+	if status != BroadcastStatusDraft {
+		return 0, fmt.Errorf("broadcast %d is %s, not draft", broadcast.ID, status)
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO broadcast_recipients (broadcast_id, user_id)
+		SELECT $1, u.id FROM users u WHERE `+condition+`
+		ON CONFLICT DO NOTHING`, append([]interface{}{broadcast.ID}, args...)...)
+	if err != nil {
+		return 0, fmt.Errorf("insert recipients: %w", err)
+	}
+	total, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE broadcasts SET status = $1, total = $2, started_at = $3
+		WHERE id = $4`, BroadcastStatusRunning, total, time.Now().UTC(), broadcast.ID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(total), nil
+}
+
+// Transition переводит рассылку в статус to, если текущий статус входит в from
+func (r *BroadcastRepository) Transition(id int64, to BroadcastStatus, from ...BroadcastStatus) (bool, error) {
+	fromValues := make([]string, len(from))
+	for i, status := range from {
+		fromValues[i] = string(status)
+	}
+
+	var finishedAt *time.Time
+	if to == BroadcastStatusCompleted || to == BroadcastStatusCancelled {
+		now := time.Now().UTC()
+		finishedAt = &now
+	}
+
+	res, err := r.db.Exec(`
+		UPDATE broadcasts SET status = $1, finished_at = COALESCE($2, finished_at)
+		WHERE id = $3 AND status = ANY($4)`, to, finishedAt, id, pq.Array(fromValues))
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// ClaimRecipients захватывает до limit получателей, которым рассылка еще не отправлена, на ttl.
+// Строки, захваченные другим экземпляром воркера, пропускаются, поэтому каждый получатель достается
+// одному экземпляру. Захват, не завершенный к claimed_until (воркер упал), можно захватить снова.
+func (r *BroadcastRepository) ClaimRecipients(id int64, limit int, ttl time.Duration) ([]int64, error) {
+	rows, err := r.db.Query(`
+		UPDATE broadcast_recipients SET status = $2, claimed_until = NOW() + make_interval(secs => $5)
+		WHERE (broadcast_id, user_id) IN (
+		    SELECT broadcast_id, user_id FROM broadcast_recipients
+		    WHERE broadcast_id = $1
+		      AND (status = $3 OR (status = $2 AND claimed_until < NOW()))
+		    ORDER BY user_id
+		    LIMIT $4
+		    FOR UPDATE SKIP LOCKED
+		)
+		RETURNING user_id`, id, RecipientStatusSending, RecipientStatusPending, limit, ttl.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// CountUnfinishedRecipients считает получателей, которым рассылка еще не отправлена или отправляется сейчас
+func (r *BroadcastRepository) CountUnfinishedRecipients(id int64) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM broadcast_recipients
+		WHERE broadcast_id = $1 AND status IN ($2, $3)`,
+		id, RecipientStatusPending, RecipientStatusSending).Scan(&count)
+	return count, err
+}
+
+// MarkRecipient сохраняет статус доставки захваченному получателю и обновляет счетчики рассылки
+func (r *BroadcastRepository) MarkRecipient(id, userID int64, status, errorText string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var sentAt *time.Time
+	if status == RecipientStatusSent {
+		now := time.Now().UTC()
+		sentAt = &now
+	}
+	res, err := tx.Exec(`
+		UPDATE broadcast_recipients SET status = $1, error = NULLIF($2, ''), sent_at = $3, claimed_until = NULL
+		WHERE broadcast_id = $4 AND user_id = $5 AND status = $6`,
+		status, errorText, sentAt, id, userID, RecipientStatusSending)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return err
+	}
+
+	counter := "failed"
+	if status == RecipientStatusSent {
+		counter = "sent"
+	}
+	if _, err := tx.Exec(`UPDATE broadcasts SET `+counter+` = `+counter+` + 1 WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetDeliveryStats возвращает количество получателей по статусам доставки
+func (r *BroadcastRepository) GetDeliveryStats(id int64) (map[string]int, error) {
+	rows, err := r.db.Query(`
+		SELECT status, COUNT(*) FROM broadcast_recipients
+		WHERE broadcast_id = $1
+		GROUP BY status`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		stats[status] = count
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,221 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+)
+
+// CreditRepository работает с пакетами кредитов и их покупками
+type CreditRepository struct {
+	db *DB
+}
+
+// NewCreditRepository создает новый репозиторий кредитов
+func NewCreditRepository(db *DB) *CreditRepository {
+	return &CreditRepository{db: db}
+}
+
+const creditPackColumns = `id, name, credits, price, currency, validity_days, visible, sort_order`
+
+const creditPurchaseColumns = `id, user_id, pack_id, credits, remaining, amount, currency, status, payment_id, created_at, paid_at, expires_at`
+
+// GetVisiblePacks возвращает пакеты, доступные для покупки
+func (r *CreditRepository) GetVisiblePacks() ([]*domain.CreditPack, error) {
+	rows, err := r.db.Query(`SELECT ` + creditPackColumns + ` FROM credit_packs WHERE visible = TRUE ORDER BY sort_order, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var packs []*domain.CreditPack
+	for rows.Next() {
+		pack, err := scanCreditPack(rows)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	return packs, rows.Err()
+}
+
+// GetPack возвращает пакет по ID. Возвращает nil, если пакет не найден.
+func (r *CreditRepository) GetPack(id string) (*domain.CreditPack, error) {
+	pack, err := scanCreditPack(r.db.QueryRow(`SELECT `+creditPackColumns+` FROM credit_packs WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return pack, err
+}
+
+// CreatePurchase сохраняет неоплаченную покупку пакета
+func (r *CreditRepository) CreatePurchase(purchase *domain.CreditPurchase) error {
+	return r.db.QueryRow(`
+		INSERT INTO credit_purchases (user_id, pack_id, credits, amount, currency, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		purchase.UserID, purchase.PackID, purchase.Credits, purchase.Amount, purchase.Currency, purchase.Status,
+	).Scan(&purchase.ID, &purchase.CreatedAt)
+}
+
+// SetPurchasePaymentID привязывает платеж YooKassa к покупке
+func (r *CreditRepository) SetPurchasePaymentID(purchaseID int64, paymentID string) error {
+	_, err := r.db.Exec(`UPDATE credit_purchases SET payment_id = $2 WHERE id = $1`, purchaseID, paymentID)
+	return err
+}
+
+// MarkPurchasePaid зачисляет кредиты покупки и задает срок их действия.
+// Статус меняется только у неоплаченной покупки, поэтому повторный вебхук не удваивает баланс.
+func (r *CreditRepository) MarkPurchasePaid(paymentID string, paidAt time.Time) (*domain.CreditPurchase, bool, error) {
+	purchase, err := scanCreditPurchase(r.db.QueryRow(`
+		UPDATE credit_purchases p
+		SET status = $2, remaining = p.credits, paid_at = $3,
+			expires_at = $3 + (SELECT validity_days FROM credit_packs WHERE id = p.pack_id) * INTERVAL '1 day'
+		WHERE p.payment_id = $1 AND p.status = $4
+		RETURNING `+creditPurchaseColumns,
+		paymentID, domain.CreditPurchasePaid, paidAt, domain.CreditPurchasePending))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return purchase, true, nil
+}
+
+// GetBalance возвращает действующий остаток кредитов и ближайшую дату сгорания
+func (r *CreditRepository) GetBalance(userID int64, now time.Time) (*domain.CreditBalance, error) {
+	balance := &domain.CreditBalance{}
+	err := r.db.QueryRow(`
+		SELECT COALESCE(SUM(remaining), 0) FROM credit_purchases
+		WHERE user_id = $1 AND status = $2 AND remaining > 0 AND expires_at > $3`,
+		userID, domain.CreditPurchasePaid, now).Scan(&balance.Credits)
+	if err != nil || balance.Credits == 0 {
+		return balance, err
+	}
+
+	var expiry time.Time
+	err = r.db.QueryRow(`
+		SELECT expires_at, SUM(remaining) FROM credit_purchases
+		WHERE user_id = $1 AND status = $2 AND remaining > 0 AND expires_at > $3
+		GROUP BY expires_at ORDER BY expires_at LIMIT 1`,
+		userID, domain.CreditPurchasePaid, now).Scan(&expiry, &balance.ExpiringAmount)
+	if err != nil {
+		return nil, err
+	}
+	balance.NextExpiry = &expiry
+	return balance, nil
+}
+
+// ConsumeCredits атомарно списывает amount кредитов из действующей покупки, которая сгорит раньше остальных.
+// Возвращает 0, если ни в одной покупке не хватает остатка.
+func (r *CreditRepository) ConsumeCredits(userID int64, amount int, now time.Time) (int64, error) {
+	var purchaseID int64
+	err := r.db.QueryRow(`
+		UPDATE credit_purchases SET remaining = remaining - $2
+		WHERE id = (
+			SELECT id FROM credit_purchases
+			WHERE user_id = $1 AND status = $3 AND remaining >= $2 AND expires_at > $4
+			ORDER BY expires_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id`,
+		userID, amount, domain.CreditPurchasePaid, now).Scan(&purchaseID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return purchaseID, err
+}
+
+// RefundCredits возвращает кредиты в покупку, из которой они были списаны
+func (r *CreditRepository) RefundCredits(purchaseID int64, amount int) error {
+	_, err := r.db.Exec(`
+		UPDATE credit_purchases SET remaining = LEAST(remaining + $2, credits)
+		WHERE id = $1`, purchaseID, amount)
+	return err
+}
+
+// GetPurchaseByPaymentID возвращает покупку по ID платежа или nil, если ее нет
+func (r *CreditRepository) GetPurchaseByPaymentID(paymentID string) (*domain.CreditPurchase, error) {
+	purchase, err := scanCreditPurchase(r.db.QueryRow(`
+		SELECT `+creditPurchaseColumns+` FROM credit_purchases WHERE payment_id = $1`, paymentID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return purchase, err
+}
+
+// RevokeCredits списывает до amount неизрасходованных кредитов покупки и возвращает, сколько списано
+func (r *CreditRepository) RevokeCredits(purchaseID int64, amount int) (int, error) {
+	var revoked int
+	err := r.db.QueryRow(`
+		UPDATE credit_purchases p SET remaining = p.remaining - LEAST(p.remaining, $2)
+		FROM (SELECT id, remaining FROM credit_purchases WHERE id = $1 FOR UPDATE) old
+		WHERE p.id = old.id
+		RETURNING old.remaining - p.remaining`, purchaseID, amount).Scan(&revoked)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return revoked, err
+}
+
+// GetUserPurchases возвращает последние оплаченные покупки пакетов пользователя
+func (r *CreditRepository) GetUserPurchases(userID int64, limit int) ([]*domain.CreditPurchase, error) {
+	rows, err := r.db.Query(`
+		SELECT `+creditPurchaseColumns+` FROM credit_purchases
+		WHERE user_id = $1 AND status = $2
+		ORDER BY paid_at DESC
+		LIMIT $3`, userID, domain.CreditPurchasePaid, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var purchases []*domain.CreditPurchase
+	for rows.Next() {
+		purchase, err := scanCreditPurchase(rows)
+		if err != nil {
+			return nil, err
+		}
+		purchases = append(purchases, purchase)
+	}
+	return purchases, rows.Err()
+}
+
+// creditScanner общий интерфейс sql.Row и sql.Rows
+type creditScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCreditPack(row creditScanner) (*domain.CreditPack, error) {
+	pack := &domain.CreditPack{}
+	err := row.Scan(&pack.ID, &pack.Name, &pack.Credits, &pack.Price, &pack.Currency,
+		&pack.ValidityDays, &pack.Visible, &pack.SortOrder)
+	if err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+func scanCreditPurchase(row creditScanner) (*domain.CreditPurchase, error) {
+	purchase := &domain.CreditPurchase{}
+	var paymentID sql.NullString
+	var paidAt, expiresAt sql.NullTime
+	err := row.Scan(&purchase.ID, &purchase.UserID, &purchase.PackID, &purchase.Credits, &purchase.Remaining,
+		&purchase.Amount, &purchase.Currency, &purchase.Status, &paymentID, &purchase.CreatedAt, &paidAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if paymentID.Valid {
+		purchase.PaymentID = &paymentID.String
+	}
+	if paidAt.Valid {
+		purchase.PaidAt = &paidAt.Time
+	}
+	if expiresAt.Valid {
+		purchase.ExpiresAt = &expiresAt.Time
+	}
+	return purchase, nil
+}
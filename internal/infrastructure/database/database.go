@@ -1,9 +1,11 @@
 package database
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"fmt"
 	"log"
+	"math/big"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -150,7 +152,10 @@ func (db *DB) GetOrCreateUser(userID int64, username, firstName, lastName string
 
 	if err == sql.ErrNoRows {
 		// Создаем нового пользователя
-		referralCode := generateReferralCode()
+		referralCode, codeErr := generateReferralCode()
+		if codeErr != nil {
+			return nil, fmt.Errorf("generate referral code: %w", codeErr)
+		}
 		_, err = db.Exec(`
 			INSERT INTO users (id, username, first_name, last_name, referral_code)
 			VALUES ($1, $2, $3, $4, $5)`,
@@ -170,60 +175,15 @@ func (db *DB) GetOrCreateUser(userID int64, username, firstName, lastName string
 	return user, err
 }
 
-// GetUserUsageTotal получает общее количество использований пользователя
+// GetUserUsageTotal получает общее количество созданных пользователем постов
 func (db *DB) GetUserUsageTotal(userID int64) (int, error) {
 	var count int
 	err := db.QueryRow(`
-		SELECT COALESCE(SUM(usage_count), 0) FROM usage_stats 
-		WHERE user_id = $1`, userID).Scan(&count)
-
-	if err != nil {
-		return 0, nil
-	}
+		SELECT COALESCE(SUM(used), 0) FROM quota_usage
+		WHERE user_id = $1 AND resource = 'generations'`, userID).Scan(&count)
 	return count, err
 }
 
-// GetUserUsageThisMonth получает количество использований пользователя за текущий месяц
-func (db *DB) GetUserUsageThisMonth(userID int64) (int, error) {
-	var count int
-	err := db.QueryRow(`
-		SELECT COALESCE(SUM(usage_count), 0) FROM usage_stats 
-		WHERE user_id = $1 
-		AND date >= date_trunc('month', CURRENT_DATE)
-		AND date < date_trunc('month', CURRENT_DATE) + INTERVAL '1 month'`, userID).Scan(&count)
-
-	if err != nil {
-		return 0, nil
-	}
-	return count, err
-}
-
-// GetUserUsageToday получает количество использований пользователя сегодня
-func (db *DB) GetUserUsageToday(userID int64) (int, error) {
-	return db.GetUserUsageTotal(userID)
-}
-
-// IncrementUsage увеличивает счетчик использований
-func (db *DB) IncrementUsage(userID int64) error {
-	// Обновляем или создаем запись в статистике
-	_, err := db.Exec(`
-		INSERT INTO usage_stats (user_id, usage_count) 
-		VALUES ($1, 1)
-		ON CONFLICT (user_id, date) 
-		DO UPDATE SET usage_count = usage_stats.usage_count + 1`, userID)
-
-	if err != nil {
-		return err
-	}
-
-	// Обновляем последнее использование
-	_, err = db.Exec(`
-		UPDATE users SET last_usage = CURRENT_TIMESTAMP 
-		WHERE id = $1`, userID)
-
-	return err
-}
-
 // SaveVoiceMessage сохраняет информацию о голосовом сообщении
 func (db *DB) SaveVoiceMessage(userID int64, fileID string, duration, fileSize int, text, rewritten string) error {
 	_, err := db.Exec(`
@@ -252,12 +212,103 @@ func (db *DB) UpdateUserEmail(userID int64, email string) error {
 	return err
 }
 
+// GetUserEmail возвращает email пользователя или пустую строку, если он не указан
+func (db *DB) GetUserEmail(userID int64) (string, error) {
+	var email sql.NullString
+	err := db.QueryRow(`SELECT email FROM users WHERE id = $1`, userID).Scan(&email)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return email.String, err
+}
+
+// GetUserPaymentProvider возвращает провайдера, закрепленного за пользователем, или пустую строку
+func (db *DB) GetUserPaymentProvider(userID int64) (string, error) {
+	var provider string
+	err := db.QueryRow(`SELECT payment_provider FROM users WHERE id = $1`, userID).Scan(&provider)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return provider, err
+}
+
+// SetUserPaymentProvider закрепляет провайдера за пользователем; пустая строка снимает выбор
+func (db *DB) SetUserPaymentProvider(userID int64, provider string) error {
+	res, err := db.Exec(`UPDATE users SET payment_provider = $1 WHERE id = $2`, provider, userID)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("user %d not found", userID)
+	}
+	return nil
+}
+
+// SetUserBotBlocked отмечает, что пользователь заблокировал или разблокировал бота.
+// Возвращает true, если статус действительно изменился.
+func (db *DB) SetUserBotBlocked(userID int64, blocked bool) (bool, error) {
+	var blockedAt *time.Time
+	if blocked {
+		now := time.Now().UTC()
+		blockedAt = &now
+	}
+	res, err := db.Exec(`
+		UPDATE users SET bot_blocked = $1, bot_blocked_at = $2
+		WHERE id = $3 AND COALESCE(bot_blocked, FALSE) <> $1`,
+		blocked, blockedAt, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// IsUserBotBlocked проверяет, заблокировал ли пользователь бота
+func (db *DB) IsUserBotBlocked(userID int64) (bool, error) {
+	var blocked bool
+	err := db.QueryRow(`SELECT COALESCE(bot_blocked, FALSE) FROM users WHERE id = $1`, userID).Scan(&blocked)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return blocked, err
+}
+
+// SetMarketingOptOut включает или отключает маркетинговые рассылки для пользователя
+func (db *DB) SetMarketingOptOut(userID int64, optOut bool) error {
+	_, err := db.Exec(`UPDATE users SET marketing_opt_out = $1 WHERE id = $2`, optOut, userID)
+	return err
+}
+
+// SetUserTimezone сохраняет часовой пояс пользователя
+func (db *DB) SetUserTimezone(userID int64, timezone string) error {
+	_, err := db.Exec(`UPDATE users SET timezone = $1 WHERE id = $2`, timezone, userID)
+	return err
+}
+
 // IsAdmin проверяет, является ли пользователь администратором
 func (db *DB) IsAdmin(userID int64) (bool, error) {
+	if IsEnvAdmin(userID) {
+		return true, nil
+	}
+
+	// Администраторы, назначенные через /add_admin
+	var isAdmin bool
+	err := db.QueryRow(`SELECT COALESCE(is_admin, FALSE) FROM users WHERE id = $1`, userID).Scan(&isAdmin)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return isAdmin, err
+}
+
+// IsEnvAdmin проверяет, указан ли пользователь в ADMIN_TELEGRAM_IDS
+func IsEnvAdmin(userID int64) bool {
 	// Получаем список ID администраторов из переменной окружения
 	adminIDs := os.Getenv("ADMIN_TELEGRAM_IDS")
 	if adminIDs == "" {
-		return false, nil
+		return false
 	}
 
 	// Разбиваем строку на отдельные ID
@@ -274,11 +325,11 @@ func (db *DB) IsAdmin(userID int64) (bool, error) {
 			continue
 		}
 		if id == userID {
-			return true, nil
+			return true
 		}
 	}
 
-	return false, nil
+	return false
 }
 
 // Вспомогательные функции
@@ -289,12 +340,20 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func generateReferralCode() string {
-	// Простая генерация кода из 8 символов
+// generateReferralCode генерирует случайный реферальный код из 8 символов. Код попадает
+// в ссылку приглашения, поэтому символы берутся из crypto/rand, а не из текущего времени:
+// коды, созданные подряд, не должны совпадать. Каждый символ выбирается через rand.Int,
+// чтобы все символы алфавита были равновероятны.
+func generateReferralCode() (string, error) {
 	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	alphabet := big.NewInt(int64(len(chars)))
 	result := make([]byte, 8)
 	for i := range result {
-		result[i] = chars[time.Now().UnixNano()%int64(len(chars))]
+		n, err := rand.Int(rand.Reader, alphabet)
+		if err != nil {
+			return "", fmt.Errorf("read random: %w", err)
+		}
+		result[i] = chars[n.Int64()]
 	}
-	return string(result)
+	return string(result), nil
 }
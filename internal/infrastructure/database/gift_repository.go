@@ -0,0 +1,138 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+)
+
+// GiftRepository работает с подарочными подписками
+type GiftRepository struct {
+	db *DB
+}
+
+// NewGiftRepository создает новый репозиторий подарков
+func NewGiftRepository(db *DB) *GiftRepository {
+	return &GiftRepository{db: db}
+}
+
+const giftColumns = `id, code, buyer_id, months, amount, currency, status, payment_id, recipient_id, created_at, paid_at, redeemed_at`
+
+// scanGift читает строку с колонками giftColumns
+func scanGift(row interface{ Scan(dest ...any) error }) (*domain.Gift, error) {
+	gift := &domain.Gift{}
+	var paymentID sql.NullString
+	var recipientID sql.NullInt64
+	var paidAt, redeemedAt sql.NullTime
+	if err := row.Scan(&gift.ID, &gift.Code, &gift.BuyerID, &gift.Months, &gift.Amount, &gift.Currency, &gift.Status,
+		&paymentID, &recipientID, &gift.CreatedAt, &paidAt, &redeemedAt); err != nil {
+		return nil, err
+	}
+	if paymentID.Valid {
+		gift.PaymentID = &paymentID.String
+	}
+	if recipientID.Valid {
+		gift.RecipientID = &recipientID.Int64
+	}
+	if paidAt.Valid {
+		gift.PaidAt = &paidAt.Time
+	}
+	if redeemedAt.Valid {
+		gift.RedeemedAt = &redeemedAt.Time
+	}
+	return gift, nil
+}
+
+// Create сохраняет неоплаченный подарок
+func (r *GiftRepository) Create(gift *domain.Gift) error {
+	return r.db.QueryRow(`
+		INSERT INTO gifts (code, buyer_id, months, amount, currency, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		gift.Code, gift.BuyerID, gift.Months, gift.Amount, gift.Currency, gift.Status,
+	).Scan(&gift.ID, &gift.CreatedAt)
+}
+
+// SetPaymentID привязывает платеж к подарку
+func (r *GiftRepository) SetPaymentID(giftID int64, paymentID string) error {
+	_, err := r.db.Exec(`UPDATE gifts SET payment_id = $2 WHERE id = $1`, giftID, paymentID)
+	return err
+}
+
+// MarkPaid отмечает подарок оплаченным. Статус меняется только у неоплаченного подарка,
+// поэтому повторный вебхук не отправляет покупателю код второй раз.
+func (r *GiftRepository) MarkPaid(paymentID string, paidAt time.Time) (*domain.Gift, bool, error) {
+	gift, err := scanGift(r.db.QueryRow(`
+		UPDATE gifts SET status = $2, paid_at = $3
+		WHERE payment_id = $1 AND status = $4
+		RETURNING `+giftColumns,
+		paymentID, domain.GiftStatusPaid, paidAt, domain.GiftStatusPending))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return gift, true, nil
+}
+
+// Redeem активирует подарок и продлевает выданный Premium получателя на срок подарка в одной транзакции
+func (r *GiftRepository) Redeem(code string, recipientID int64, at time.Time) (*domain.Gift, time.Time, error) {
+	var until time.Time
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, until, err
+	}
+	defer tx.Rollback()
+
+	gift, err := scanGift(tx.QueryRow(`SELECT `+giftColumns+` FROM gifts WHERE code = $1 FOR UPDATE`, code))
+	if err == sql.ErrNoRows {
+		return nil, until, domain.ErrGiftNotFound
+	}
+	if err != nil {
+		return nil, until, err
+	}
+	switch gift.Status {
+	case domain.GiftStatusPaid:
+	case domain.GiftStatusRedeemed:
+		return gift, until, domain.ErrGiftRedeemed
+	default:
+		return nil, until, domain.ErrGiftNotFound
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE gifts SET status = $2, recipient_id = $3, redeemed_at = $4
+		WHERE id = $1`, gift.ID, domain.GiftStatusRedeemed, recipientID, at); err != nil {
+		return nil, until, err
+	}
+	// Продлеваем уже выданный Premium, а не перезаписываем его
+	if err := tx.QueryRow(`
+		UPDATE users
+		SET premium_until = GREATEST(COALESCE(premium_until, $1), $1) + make_interval(months => $2)
+		WHERE id = $3
+		RETURNING premium_until`, at, gift.Months, recipientID).Scan(&until); err != nil {
+		return nil, until, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, until, err
+	}
+
+	gift.Status = domain.GiftStatusRedeemed
+	gift.RecipientID = &recipientID
+	gift.RedeemedAt = &at
+	return gift, until, nil
+}
+
+// Cancel аннулирует оплаченный, но не активированный подарок после возврата оплаты
+func (r *GiftRepository) Cancel(paymentID string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE gifts SET status = $2
+		WHERE payment_id = $1 AND status IN ($3, $4)`,
+		paymentID, domain.GiftStatusCancelled, domain.GiftStatusPending, domain.GiftStatusPaid)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
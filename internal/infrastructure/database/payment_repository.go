@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/infrastructure/yookassa"
+)
+
+// PaymentRepository ведет журнал платежей
+type PaymentRepository struct {
+	db *DB
+}
+
+// NewPaymentRepository создает новый репозиторий платежей
+func NewPaymentRepository(db *DB) *PaymentRepository {
+	return &PaymentRepository{db: db}
+}
+
+const paymentColumns = `id, user_id, subscription_id, provider, provider_payment_id, kind, amount, currency,
+	status, failure_reason, receipt_status, COALESCE(idempotency_key, ''), promo_code_id, discount, created_at, updated_at`
+
+// Record сохраняет попытку оплаты или обновляет статус уже записанной.
+// Платеж ищется по ID провайдера, а если провайдер не вернул ID — по ключу идемпотентности.
+func (r *PaymentRepository) Record(payment *domain.Payment) error {
+	if payment.Currency == "" {
+		payment.Currency = "RUB"
+	}
+	var idempotencyKey sql.NullString
+	if payment.IdempotencyKey != "" {
+		idempotencyKey = sql.NullString{String: payment.IdempotencyKey, Valid: true}
+	}
+
+	conflict := `(idempotency_key)`
+	if payment.ProviderPaymentID != nil {
+		conflict = `(provider, provider_payment_id)`
+	}
+	return r.db.QueryRow(`
+		INSERT INTO payments (user_id, subscription_id, provider, provider_payment_id, kind, amount, currency,
+			status, failure_reason, receipt_status, idempotency_key, promo_code_id, discount)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT `+conflict+` DO UPDATE SET
+			status = EXCLUDED.status,
+			failure_reason = EXCLUDED.failure_reason,
+			receipt_status = COALESCE(NULLIF(EXCLUDED.receipt_status, ''), payments.receipt_status),
+			provider_payment_id = COALESCE(EXCLUDED.provider_payment_id, payments.provider_payment_id),
+			subscription_id = COALESCE(EXCLUDED.subscription_id, payments.subscription_id),
+			idempotency_key = COALESCE(payments.idempotency_key, EXCLUDED.idempotency_key),
+			promo_code_id = COALESCE(payments.promo_code_id, EXCLUDED.promo_code_id),
+			discount = GREATEST(payments.discount, EXCLUDED.discount),
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING id, kind, created_at, updated_at`,
+		payment.UserID, payment.SubscriptionID, payment.Provider, payment.ProviderPaymentID, payment.Kind,
+		payment.Amount, payment.Currency, payment.Status, payment.FailureReason, payment.ReceiptStatus, idempotencyKey,
+		payment.PromoCodeID, payment.Discount,
+	).Scan(&payment.ID, &payment.Kind, &payment.CreatedAt, &payment.UpdatedAt)
+}
+
+// scanPayment читает строку с колонками paymentColumns
+func scanPayment(row interface{ Scan(dest ...any) error }) (*domain.Payment, error) {
+	payment := &domain.Payment{}
+	var subscriptionID sql.NullInt64
+	var providerPaymentID sql.NullString
+	var promoCodeID sql.NullInt64
+	if err := row.Scan(&payment.ID, &payment.UserID, &subscriptionID, &payment.Provider, &providerPaymentID,
+		&payment.Kind, &payment.Amount, &payment.Currency, &payment.Status, &payment.FailureReason,
+		&payment.ReceiptStatus, &payment.IdempotencyKey, &promoCodeID, &payment.Discount,
+		&payment.CreatedAt, &payment.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if subscriptionID.Valid {
+		payment.SubscriptionID = &subscriptionID.Int64
+	}
+	if providerPaymentID.Valid {
+		payment.ProviderPaymentID = &providerPaymentID.String
+	}
+	if promoCodeID.Valid {
+		payment.PromoCodeID = &promoCodeID.Int64
+	}
+	return payment, nil
+}
+
+// GetByID возвращает платеж из журнала или nil, если его нет
+func (r *PaymentRepository) GetByID(id int64) (*domain.Payment, error) {
+	payment, err := scanPayment(r.db.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return payment, err
+}
+
+// GetByProviderPaymentID возвращает платеж по ID у провайдера или nil, если его нет
+func (r *PaymentRepository) GetByProviderPaymentID(provider, providerPaymentID string) (*domain.Payment, error) {
+	payment, err := scanPayment(r.db.QueryRow(`
+		SELECT `+paymentColumns+` FROM payments
+		WHERE provider = $1 AND provider_payment_id = $2`, provider, providerPaymentID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return payment, err
+}
+
+// GetUserPayments возвращает последние платежи пользователя
+func (r *PaymentRepository) GetUserPayments(userID int64, limit int) ([]*domain.Payment, error) {
+	rows, err := r.db.Query(`
+		SELECT `+paymentColumns+` FROM payments
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []*domain.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
+
+// GetTotals возвращает итоги платежей с начала периода по провайдерам и назначению
+func (r *PaymentRepository) GetTotals(since time.Time) ([]*domain.PaymentTotals, error) {
+	rows, err := r.db.Query(`
+		SELECT provider, kind,
+		       COUNT(*) FILTER (WHERE status = $2),
+		       COUNT(*) FILTER (WHERE status = $3),
+		       COUNT(*) FILTER (WHERE status = $4),
+		       COUNT(*) FILTER (WHERE status = $5),
+		       COALESCE(SUM(amount) FILTER (WHERE status = $2), 0),
+		       COUNT(*) FILTER (WHERE receipt_status = $6)
+		FROM payments
+		WHERE created_at >= $1
+		GROUP BY provider, kind
+		ORDER BY provider, kind`,
+		since, domain.PaymentStatusSucceeded, domain.PaymentStatusFailed, domain.PaymentStatusPending, domain.PaymentStatusRefunded,
+		yookassa.ReceiptCanceled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []*domain.PaymentTotals
+	for rows.Next() {
+		t := &domain.PaymentTotals{}
+		if err := rows.Scan(&t.Provider, &t.Kind, &t.Succeeded, &t.Failed, &t.Pending, &t.Refunded, &t.Revenue, &t.ReceiptsFailed); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"database/sql"
+
+	"ai_tg_writer/internal/domain"
+)
+
+// PromoRepository работает с промокодами и их применениями
+type PromoRepository struct {
+	db *DB
+}
+
+// NewPromoRepository создает новый репозиторий промокодов
+func NewPromoRepository(db *DB) *PromoRepository {
+	return &PromoRepository{db: db}
+}
+
+const promoColumns = `id, code, discount_type, discount_value, periods, max_uses, used_count, expires_at, active, created_at`
+
+// scanPromo читает строку с колонками promoColumns
+func scanPromo(row interface{ Scan(dest ...any) error }) (*domain.PromoCode, error) {
+	promo := &domain.PromoCode{}
+	var expiresAt sql.NullTime
+	if err := row.Scan(&promo.ID, &promo.Code, &promo.DiscountType, &promo.DiscountValue, &promo.Periods,
+		&promo.MaxUses, &promo.UsedCount, &expiresAt, &promo.Active, &promo.CreatedAt); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		promo.ExpiresAt = &expiresAt.Time
+	}
+	return promo, nil
+}
+
+// Create сохраняет новый промокод
+func (r *PromoRepository) Create(promo *domain.PromoCode) error {
+	return r.db.QueryRow(`
+		INSERT INTO promo_codes (code, discount_type, discount_value, periods, max_uses, expires_at, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, used_count, created_at`,
+		promo.Code, promo.DiscountType, promo.DiscountValue, promo.Periods, promo.MaxUses, promo.ExpiresAt, promo.Active,
+	).Scan(&promo.ID, &promo.UsedCount, &promo.CreatedAt)
+}
+
+// GetByCode возвращает промокод по коду. Возвращает nil, если код не найден.
+func (r *PromoRepository) GetByCode(code string) (*domain.PromoCode, error) {
+	promo, err := scanPromo(r.db.QueryRow(`SELECT `+promoColumns+` FROM promo_codes WHERE code = $1`, code))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return promo, err
+}
+
+// SetActive включает или выключает промокод
+func (r *PromoRepository) SetActive(code string, active bool) error {
+	result, err := r.db.Exec(`UPDATE promo_codes SET active = $2 WHERE code = $1`, code, active)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return domain.ErrPromoNotFound
+	}
+	return nil
+}
+
+// HasRedeemed проверяет, применял ли пользователь промокод
+func (r *PromoRepository) HasRedeemed(promoID, userID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM promo_redemptions WHERE promo_code_id = $1 AND user_id = $2)`,
+		promoID, userID).Scan(&exists)
+	return exists, err
+}
+
+// Redeem сохраняет применение промокода и увеличивает счетчик использований в одной транзакции.
+// Первая оплата уже со скидкой, поэтому продлений со скидкой на одно меньше, чем periods промокода.
+// Возвращает false, если пользователь уже применял код (например, при повторном вебхуке).
+func (r *PromoRepository) Redeem(redemption *domain.PromoRedemption) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var renewalsLeft sql.NullInt64
+	err = tx.QueryRow(`
+		INSERT INTO promo_redemptions (promo_code_id, user_id, subscription_id, renewals_left)
+		SELECT id, $2, $3, CASE WHEN periods = 0 THEN NULL ELSE periods - 1 END
+		FROM promo_codes WHERE id = $1
+		ON CONFLICT (promo_code_id, user_id) DO NOTHING
+		RETURNING id, renewals_left, created_at`,
+		redemption.PromoCodeID, redemption.UserID, redemption.SubscriptionID,
+	).Scan(&redemption.ID, &renewalsLeft, &redemption.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if renewalsLeft.Valid {
+		left := int(renewalsLeft.Int64)
+		redemption.RenewalsLeft = &left
+	}
+
+	result, err := tx.Exec(`
+		UPDATE promo_codes SET used_count = used_count + 1
+		WHERE id = $1 AND (max_uses = 0 OR used_count < max_uses)`, redemption.PromoCodeID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return false, err
+	} else if n == 0 {
+		return false, domain.ErrPromoExhausted
+	}
+	return true, tx.Commit()
+}
+
+// GetSubscriptionDiscount возвращает применение промокода, скидка по которому еще действует
+// для продлений подписки. Возвращает nil, если скидки нет.
+func (r *PromoRepository) GetSubscriptionDiscount(subscriptionID int64) (*domain.PromoRedemption, error) {
+	redemption := &domain.PromoRedemption{}
+	var renewalsLeft sql.NullInt64
+	var redemptionSubscriptionID sql.NullInt64
+	row := r.db.QueryRow(`
+		SELECT r.id, r.promo_code_id, r.user_id, r.subscription_id, r.renewals_left, r.created_at,
+		       p.id, p.code, p.discount_type, p.discount_value, p.periods, p.max_uses, p.used_count,
+		       p.expires_at, p.active, p.created_at
+		FROM promo_redemptions r
+		JOIN promo_codes p ON p.id = r.promo_code_id
+		WHERE r.subscription_id = $1 AND (r.renewals_left IS NULL OR r.renewals_left > 0)
+		ORDER BY r.created_at DESC
+		LIMIT 1`, subscriptionID)
+
+	promo := &domain.PromoCode{}
+	var expiresAt sql.NullTime
+	err := row.Scan(&redemption.ID, &redemption.PromoCodeID, &redemption.UserID, &redemptionSubscriptionID,
+		&renewalsLeft, &redemption.CreatedAt,
+		&promo.ID, &promo.Code, &promo.DiscountType, &promo.DiscountValue, &promo.Periods, &promo.MaxUses,
+		&promo.UsedCount, &expiresAt, &promo.Active, &promo.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if redemptionSubscriptionID.Valid {
+		redemption.SubscriptionID = &redemptionSubscriptionID.Int64
+	}
+	if renewalsLeft.Valid {
+		left := int(renewalsLeft.Int64)
+		redemption.RenewalsLeft = &left
+	}
+	if expiresAt.Valid {
+		promo.ExpiresAt = &expiresAt.Time
+	}
+	redemption.Promo = promo
+	return redemption, nil
+}
+
+// ConsumeRenewal уменьшает число продлений со скидкой; для бессрочной скидки ничего не меняет
+func (r *PromoRepository) ConsumeRenewal(redemptionID int64) error {
+	_, err := r.db.Exec(`
+		UPDATE promo_redemptions SET renewals_left = renewals_left - 1
+		WHERE id = $1 AND renewals_left > 0`, redemptionID)
+	return err
+}
+
+// GetStats возвращает итоги по всем промокодам: применения, оплаты со скидкой и сумму скидок
+func (r *PromoRepository) GetStats() ([]*domain.PromoStats, error) {
+	rows, err := r.db.Query(`
+		SELECT p.id, p.code, p.discount_type, p.discount_value, p.periods, p.max_uses, p.used_count,
+		       p.expires_at, p.active, p.created_at,
+		       COUNT(pay.id),
+		       COALESCE(SUM(pay.amount), 0),
+		       COALESCE(SUM(pay.discount), 0)
+		FROM promo_codes p
+		LEFT JOIN payments pay ON pay.promo_code_id = p.id AND pay.status = $1
+		GROUP BY p.id
+		ORDER BY p.created_at DESC`, domain.PaymentStatusSucceeded)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*domain.PromoStats
+	for rows.Next() {
+		s := &domain.PromoStats{}
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&s.Promo.ID, &s.Promo.Code, &s.Promo.DiscountType, &s.Promo.DiscountValue,
+			&s.Promo.Periods, &s.Promo.MaxUses, &s.Promo.UsedCount, &expiresAt, &s.Promo.Active, &s.Promo.CreatedAt,
+			&s.Payments, &s.Revenue, &s.Discount); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			s.Promo.ExpiresAt = &expiresAt.Time
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
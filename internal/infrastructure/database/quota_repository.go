@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+)
+
+// QuotaRepository учитывает расход квот по ресурсам и периодам
+type QuotaRepository struct {
+	db *DB
+}
+
+// NewQuotaRepository создает новый репозиторий квот
+func NewQuotaRepository(db *DB) *QuotaRepository {
+	return &QuotaRepository{db: db}
+}
+
+// GetUsed возвращает расход ресурса за период
+func (r *QuotaRepository) GetUsed(userID int64, resource domain.QuotaResource, periodStart time.Time) (int, error) {
+	var used int
+	err := r.db.QueryRow(`
+		SELECT used FROM quota_usage
+		WHERE user_id = $1 AND resource = $2 AND period_start = $3`,
+		userID, resource, periodStart).Scan(&used)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return used, err
+}
+
+// Consume атомарно списывает amount единиц ресурса, если расход не превысит limit.
+// Условие проверяется в том же запросе, поэтому параллельные генерации не выходят за лимит.
+func (r *QuotaRepository) Consume(userID int64, resource domain.QuotaResource, periodStart time.Time, amount, limit int) (bool, error) {
+	if limit != domain.UnlimitedQuota && amount > limit {
+		return false, nil
+	}
+
+	result, err := r.db.Exec(`
+		INSERT INTO quota_usage (user_id, resource, period_start, used)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, resource, period_start) DO UPDATE
+		SET used = quota_usage.used + EXCLUDED.used, updated_at = CURRENT_TIMESTAMP
+		WHERE $5 < 0 OR quota_usage.used + EXCLUDED.used <= $5`,
+		userID, resource, periodStart, amount, limit)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+
+	// Отмечаем активность пользователя: по ней строится сегмент неактивных в рассылках
+	if _, err := r.db.Exec(`UPDATE users SET last_usage = CURRENT_TIMESTAMP WHERE id = $1`, userID); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// Refund возвращает amount единиц ресурса в период списания
+func (r *QuotaRepository) Refund(userID int64, resource domain.QuotaResource, periodStart time.Time, amount int) error {
+	_, err := r.db.Exec(`
+		UPDATE quota_usage
+		SET used = GREATEST(used - $4, 0), updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND resource = $2 AND period_start = $3`,
+		userID, resource, periodStart, amount)
+	return err
+}
+
+// GetGranted возвращает единицы ресурса, выданные администратором с начала периода
+func (r *QuotaRepository) GetGranted(userID int64, resource domain.QuotaResource, periodStart time.Time) (int, error) {
+	var amount int
+	err := r.db.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0) FROM quota_grants
+		WHERE user_id = $1 AND resource = $2 AND created_at >= $3`,
+		userID, resource, periodStart).Scan(&amount)
+	return amount, err
+}
+
+// ResetUsage обнуляет расход всех ресурсов пользователя за период
+func (r *QuotaRepository) ResetUsage(userID int64, periodStart time.Time) error {
+	_, err := r.db.Exec(`DELETE FROM quota_usage WHERE user_id = $1 AND period_start = $2`, userID, periodStart)
+	return err
+}
+
+// GetUserAnchor возвращает дату регистрации и часовой пояс пользователя
+func (r *QuotaRepository) GetUserAnchor(userID int64) (time.Time, string, error) {
+	var createdAt time.Time
+	var timezone string
+	err := r.db.QueryRow(`SELECT created_at, timezone FROM users WHERE id = $1`, userID).Scan(&createdAt, &timezone)
+	if err == sql.ErrNoRows {
+		return time.Now().UTC(), domain.DefaultUserTimezone, nil
+	}
+	return createdAt, timezone, err
+}
+
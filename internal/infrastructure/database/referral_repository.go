@@ -0,0 +1,164 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+)
+
+// referralRewardGrantedBy значение granted_by в quota_grants для создания, начисленных
+// реферальной программой, а не администратором
+const referralRewardGrantedBy = 0
+
+// ReferralRepository работает с приглашениями реферальной программы
+type ReferralRepository struct {
+	db *DB
+}
+
+// NewReferralRepository создает новый репозиторий реферальной программы
+func NewReferralRepository(db *DB) *ReferralRepository {
+	return &ReferralRepository{db: db}
+}
+
+// GetReferrerByCode возвращает пользователя с реферальным кодом или 0, если код не найден
+func (r *ReferralRepository) GetReferrerByCode(code string) (int64, error) {
+	var userID int64
+	err := r.db.QueryRow(`SELECT id FROM users WHERE referral_code = $1`, code).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return userID, err
+}
+
+// Attach закрепляет пользователя за приглашающим, если у него еще нет приглашающего и оплат.
+// Взаимные приглашения не принимаются.
+func (r *ReferralRepository) Attach(referredID, referrerID int64) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE users SET referred_by = $2
+		WHERE id = $1 AND referred_by IS NULL AND id <> $2
+		  AND NOT EXISTS (SELECT 1 FROM users WHERE id = $2 AND referred_by = $1)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM payments
+		      WHERE user_id = $1 AND status IN ($3, $4) AND kind <> $5
+		  )`,
+		referredID, referrerID, domain.PaymentStatusSucceeded, domain.PaymentStatusRefunded, domain.PaymentKindTrial)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO referrals (referrer_id, referred_id, status)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`, referrerID, referredID, domain.ReferralStatusPending); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// GetPendingRewards возвращает приглашения без награды, приглашенные по которым оплатили
+// после перехода по ссылке. Привязка карты для пробного периода оплатой не считается.
+func (r *ReferralRepository) GetPendingRewards(limit int) ([]*domain.Referral, error) {
+	rows, err := r.db.Query(`
+		SELECT r.id, r.referrer_id, r.referred_id, r.status, r.created_at, p.id
+		FROM referrals r
+		JOIN LATERAL (
+		    SELECT id FROM payments
+		    WHERE user_id = r.referred_id AND status = $2 AND kind <> $3 AND created_at >= r.created_at
+		    ORDER BY created_at
+		    LIMIT 1
+		) p ON TRUE
+		WHERE r.status = $1
+		ORDER BY r.id
+		LIMIT $4`,
+		domain.ReferralStatusPending, domain.PaymentStatusSucceeded, domain.PaymentKindTrial, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var referrals []*domain.Referral
+	for rows.Next() {
+		referral := &domain.Referral{}
+		var paymentID int64
+		if err := rows.Scan(&referral.ID, &referral.ReferrerID, &referral.ReferredID, &referral.Status,
+			&referral.CreatedAt, &paymentID); err != nil {
+			return nil, err
+		}
+		referral.PaymentID = &paymentID
+		referrals = append(referrals, referral)
+	}
+	return referrals, rows.Err()
+}
+
+// Reward отмечает награду за приглашение и начисляет ее приглашающему в одной транзакции
+func (r *ReferralRepository) Reward(referral *domain.Referral, rewardType string, amount int, at time.Time) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE referrals
+		SET status = $2, payment_id = $3, reward_type = $4, reward_amount = $5, rewarded_at = $6
+		WHERE id = $1 AND status = $7`,
+		referral.ID, domain.ReferralStatusRewarded, referral.PaymentID, rewardType, amount, at, domain.ReferralStatusPending)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	switch rewardType {
+	case domain.ReferralRewardGenerations:
+		_, err = tx.Exec(`
+			INSERT INTO quota_grants (user_id, resource, amount, granted_by, created_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			referral.ReferrerID, domain.QuotaGenerations, amount, referralRewardGrantedBy, at.UTC())
+	case domain.ReferralRewardPremiumDays:
+		_, err = tx.Exec(`
+			UPDATE users
+			SET premium_until = GREATEST(COALESCE(premium_until, $1), $1) + make_interval(days => $2)
+			WHERE id = $3`, at.UTC(), amount, referral.ReferrerID)
+	default:
+		err = fmt.Errorf("unknown referral reward %q", rewardType)
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	referral.Status = domain.ReferralStatusRewarded
+	referral.RewardType = rewardType
+	referral.RewardAmount = amount
+	referral.RewardedAt = &at
+	return true, nil
+}
+
+// GetStats возвращает статистику приглашений пользователя
+func (r *ReferralRepository) GetStats(referrerID int64) (*domain.ReferralStats, error) {
+	stats := &domain.ReferralStats{}
+	err := r.db.QueryRow(`
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE status = $2),
+		       COALESCE(SUM(reward_amount) FILTER (WHERE reward_type = $3), 0),
+		       COALESCE(SUM(reward_amount) FILTER (WHERE reward_type = $4), 0)
+		FROM referrals WHERE referrer_id = $1`,
+		referrerID, domain.ReferralStatusRewarded, domain.ReferralRewardGenerations, domain.ReferralRewardPremiumDays,
+	).Scan(&stats.Invited, &stats.Paid, &stats.BonusGenerations, &stats.PremiumDays)
+	return stats, err
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+)
+
+// RefundRepository хранит возвраты платежей
+type RefundRepository struct {
+	db *DB
+}
+
+// NewRefundRepository создает новый репозиторий возвратов
+func NewRefundRepository(db *DB) *RefundRepository {
+	return &RefundRepository{db: db}
+}
+
+// Record сохраняет возврат. Запись с ID обновляется, возврат без ID ищется по ID провайдера:
+// так возврат, созданный ботом, и уведомление о нем попадают в одну строку.
+func (r *RefundRepository) Record(refund *domain.Refund) error {
+	if refund.Currency == "" {
+		refund.Currency = "RUB"
+	}
+	var appliedAt sql.NullTime
+	var err error
+	if refund.ID != 0 {
+		err = r.db.QueryRow(`
+			UPDATE refunds SET
+				provider_refund_id = COALESCE($2, provider_refund_id),
+				status = CASE WHEN status = $4 THEN status ELSE $3 END,
+				updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+			RETURNING status, created_at, applied_at`,
+			refund.ID, refund.ProviderRefundID, refund.Status, domain.RefundStatusSucceeded,
+		).Scan(&refund.Status, &refund.CreatedAt, &appliedAt)
+	} else {
+		conflict := ``
+		if refund.ProviderRefundID != nil {
+			conflict = `ON CONFLICT (provider, provider_refund_id) DO UPDATE SET
+				status = EXCLUDED.status,
+				updated_at = CURRENT_TIMESTAMP`
+		}
+		err = r.db.QueryRow(`
+			INSERT INTO refunds (payment_id, user_id, provider, provider_refund_id, amount, currency, status, reason, admin_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			`+conflict+`
+			RETURNING id, reason, admin_id, created_at, applied_at`,
+			refund.PaymentID, refund.UserID, refund.Provider, refund.ProviderRefundID, refund.Amount, refund.Currency,
+			refund.Status, refund.Reason, refund.AdminID,
+		).Scan(&refund.ID, &refund.Reason, &refund.AdminID, &refund.CreatedAt, &appliedAt)
+	}
+	if err != nil {
+		return err
+	}
+	if appliedAt.Valid {
+		refund.AppliedAt = &appliedAt.Time
+	}
+	return nil
+}
+
+// AdoptPending присваивает ID провайдера самому раннему ожидающему возврату, созданному ботом,
+// на ту же сумму, если возврата с этим ID еще нет
+func (r *RefundRepository) AdoptPending(refund *domain.Refund) (bool, error) {
+	var appliedAt sql.NullTime
+	err := r.db.QueryRow(`
+		UPDATE refunds SET provider_refund_id = $4, status = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM refunds
+			WHERE payment_id = $1 AND provider = $2 AND amount = $3
+			  AND provider_refund_id IS NULL AND status = $6
+			ORDER BY created_at, id
+			LIMIT 1
+			FOR UPDATE
+		)
+		AND provider_refund_id IS NULL
+		AND NOT EXISTS (SELECT 1 FROM refunds WHERE provider = $2 AND provider_refund_id = $4)
+		RETURNING id, reason, admin_id, created_at, applied_at`,
+		refund.PaymentID, refund.Provider, refund.Amount, refund.ProviderRefundID, refund.Status, domain.RefundStatusPending,
+	).Scan(&refund.ID, &refund.Reason, &refund.AdminID, &refund.CreatedAt, &appliedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if appliedAt.Valid {
+		refund.AppliedAt = &appliedAt.Time
+	}
+	return true, nil
+}
+
+// MarkApplied атомарно отмечает успешный возврат примененным
+func (r *RefundRepository) MarkApplied(id int64, appliedAt time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE refunds SET applied_at = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = $3 AND applied_at IS NULL`,
+		id, appliedAt, domain.RefundStatusSucceeded)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetRefundedTotal возвращает сумму возвратов по платежу, кроме отмененных провайдером
+func (r *RefundRepository) GetRefundedTotal(paymentID int64) (float64, error) {
+	var total float64
+	err := r.db.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0) FROM refunds
+		WHERE payment_id = $1 AND status <> $2`,
+		paymentID, domain.RefundStatusCanceled).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,31 @@
+package database
+
+import "time"
+
+// SubscriptionNoticeRepository хранит отправленные напоминания о продлении подписок
+type SubscriptionNoticeRepository struct {
+	db *DB
+}
+
+// NewSubscriptionNoticeRepository создает новый репозиторий напоминаний
+func NewSubscriptionNoticeRepository(db *DB) *SubscriptionNoticeRepository {
+	return &SubscriptionNoticeRepository{db: db}
+}
+
+// MarkNoticeSent отмечает напоминание отправленным. Возвращает false, если напоминание
+// этого вида о том же списании уже было отмечено (в том числе другим экземпляром воркера).
+func (r *SubscriptionNoticeRepository) MarkNoticeSent(subscriptionID int64, kind string, dueAt time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO subscription_notices (subscription_id, kind, due_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (subscription_id, kind, due_at) DO NOTHING`,
+		subscriptionID, kind, dueAt)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted > 0, nil
+}
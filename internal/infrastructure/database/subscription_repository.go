@@ -3,7 +3,9 @@ package database
 import (
 	"ai_tg_writer/internal/domain"
 	"database/sql"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 )
 
@@ -15,13 +17,20 @@ func NewSubscriptionRepository(db *DB) *SubscriptionRepository {
 	return &SubscriptionRepository{db: db}
 }
 
+// Create сохраняет новую подписку и записывает ее начальный статус в историю
 func (r *SubscriptionRepository) Create(subscription *domain.Subscription) error {
 	query := `
-		INSERT INTO subscriptions (user_id, subscription_id, tariff, status, amount, next_payment, last_payment, active)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO subscriptions (user_id, subscription_id, tariff, status, amount, next_payment, last_payment, active, status_changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
 		RETURNING id, created_at`
 
-	return r.db.QueryRow(
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow(
 		query,
 		subscription.UserID,
 		subscription.SubscriptionID,
@@ -31,13 +40,27 @@ func (r *SubscriptionRepository) Create(subscription *domain.Subscription) error
 		subscription.NextPayment,
 		subscription.LastPayment,
 		subscription.Active,
-	).Scan(&subscription.ID, &subscription.CreatedAt)
+	).Scan(&subscription.ID, &subscription.CreatedAt); err != nil {
+		return err
+	}
+
+	if err := insertSubscriptionEvent(tx, &domain.SubscriptionEvent{
+		SubscriptionID: subscription.ID,
+		UserID:         subscription.UserID,
+		ToStatus:       domain.SubscriptionStatus(subscription.Status),
+		Reason:         subscription.StatusReason,
+		CreatedAt:      subscription.CreatedAt,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *SubscriptionRepository) GetByUserID(userID int64) (*domain.Subscription, error) {
 	query := `
 		SELECT id, user_id, subscription_id, tariff, status, amount, next_payment, last_payment, created_at, cancelled_at, active,
-		       yk_customer_id, yk_payment_method_id, yk_last_payment_id, failed_attempts, next_retry, suspended_at
+		       yk_customer_id, yk_payment_method_id, yk_last_payment_id, payment_provider, failed_attempts, next_retry, suspended_at,
+		       paused_at, pause_until, trial_ends_at
 		FROM subscriptions
 		WHERE user_id = $1 AND active = true
 		ORDER BY created_at DESC
@@ -59,21 +82,30 @@ func (r *SubscriptionRepository) GetByUserID(userID int64) (*domain.Subscription
 		&subscription.YKCustomerID,
 		&subscription.YKPaymentMethodID,
 		&subscription.YKLastPaymentID,
+		&subscription.PaymentProvider,
 		&subscription.FailedAttempts,
 		&subscription.NextRetry,
 		&subscription.SuspendedAt,
+		&subscription.PausedAt,
+		&subscription.PauseUntil,
+		&subscription.TrialEndsAt,
 	)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
-	return subscription, err
+	if err != nil {
+		return nil, err
+	}
+	subscription.MarkLoaded()
+	return subscription, nil
 }
 
 func (r *SubscriptionRepository) GetAnyByUserID(userID int64) (*domain.Subscription, error) {
 	query := `
 		SELECT id, user_id, subscription_id, tariff, status, amount, next_payment, last_payment, created_at, cancelled_at, active,
-		       yk_customer_id, yk_payment_method_id, yk_last_payment_id, failed_attempts, next_retry, suspended_at
+		       yk_customer_id, yk_payment_method_id, yk_last_payment_id, payment_provider, failed_attempts, next_retry, suspended_at,
+		       paused_at, pause_until, trial_ends_at
 		FROM subscriptions
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -95,9 +127,13 @@ func (r *SubscriptionRepository) GetAnyByUserID(userID int64) (*domain.Subscript
 		&subscription.YKCustomerID,
 		&subscription.YKPaymentMethodID,
 		&subscription.YKLastPaymentID,
+		&subscription.PaymentProvider,
 		&subscription.FailedAttempts,
 		&subscription.NextRetry,
 		&subscription.SuspendedAt,
+		&subscription.PausedAt,
+		&subscription.PauseUntil,
+		&subscription.TrialEndsAt,
 	)
 
 	if err != nil {
@@ -107,6 +143,7 @@ func (r *SubscriptionRepository) GetAnyByUserID(userID int64) (*domain.Subscript
 		return nil, err
 	}
 
+	subscription.MarkLoaded()
 	return subscription, nil
 }
 
@@ -115,7 +152,7 @@ func (r *SubscriptionRepository) GetSubscriptionsDueForRenewal() ([]*domain.Subs
 	// Добавляем фильтр failed_attempts = 0, чтобы не обрабатывать подписки с неудачными попытками
 	query := `
 		SELECT id, user_id, subscription_id, tariff, status, amount, next_payment, last_payment, created_at, cancelled_at, active,
-		       yk_customer_id, yk_payment_method_id, yk_last_payment_id, failed_attempts, next_retry
+		       yk_customer_id, yk_payment_method_id, yk_last_payment_id, payment_provider, failed_attempts, next_retry
 		FROM subscriptions
 		WHERE active = true 
 		  AND status = 'active'
@@ -245,6 +282,7 @@ func (r *SubscriptionRepository) GetSubscriptionsDueForRenewal() ([]*domain.Subs
 			&subscription.YKCustomerID,
 			&subscription.YKPaymentMethodID,
 			&subscription.YKLastPaymentID,
+			&subscription.PaymentProvider,
 			&subscription.FailedAttempts,
 			&subscription.NextRetry,
 		)
@@ -252,6 +290,7 @@ func (r *SubscriptionRepository) GetSubscriptionsDueForRenewal() ([]*domain.Subs
 			log.Printf("❌ [SQL DEBUG] Row scan error: %v", err)
 			return nil, err
 		}
+		subscription.MarkLoaded()
 		subscriptions = append(subscriptions, subscription)
 		log.Printf("🔍 [SQL DEBUG] Found subscription: ID=%d, UserID=%d, NextPayment=%s, Active=%v, Status=%s, FailedAttempts=%d, NextRetry=%v",
 			subscription.ID, subscription.UserID, subscription.NextPayment.Format("2006-01-02 15:04:05"),
@@ -291,41 +330,230 @@ func (r *SubscriptionRepository) GetBySubscriptionID(subscriptionID int) (*domai
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
-	return subscription, err
+	if err != nil {
+		return nil, err
+	}
+	subscription.MarkLoaded()
+	return subscription, nil
 }
 
+// Update сохраняет подписку. Если статус изменился, переход проверяется машиной состояний
+// и записывается в историю с причиной subscription.StatusReason.
+// У подписки, прочитанной из базы, записываются только поля, измененные после чтения: так
+// продление или вебхук не затирают отмену или паузу, сохраненные параллельно. Если статус
+// изменился и в базе он уже не тот, что был при чтении, возвращается domain.ErrSubscriptionConflict.
 func (r *SubscriptionRepository) Update(subscription *domain.Subscription) error {
-	query := `
-		UPDATE subscriptions
-		SET tariff = $1, status = $2, amount = $3, next_payment = $4, last_payment = $5, active = $6, cancelled_at = $7,
-		    yk_customer_id = $8, yk_payment_method_id = $9, yk_last_payment_id = $10, 
-		    failed_attempts = $11, next_retry = $12, suspended_at = $13
-		WHERE id = $14`
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	_, err := r.db.Exec(
-		query,
-		subscription.Tariff,
-		subscription.Status,
-		subscription.Amount,
-		subscription.NextPayment,
-		subscription.LastPayment,
-		subscription.Active,
-		subscription.CancelledAt,
-		subscription.YKCustomerID,
-		subscription.YKPaymentMethodID,
-		subscription.YKLastPaymentID,
-		subscription.FailedAttempts,
-		subscription.NextRetry,
-		subscription.SuspendedAt,
-		subscription.ID,
-	)
-	return err
+	// Блокируем запись, чтобы статус не изменился между проверкой перехода и обновлением
+	var stored domain.SubscriptionStatus
+	if err := tx.QueryRow(`SELECT status FROM subscriptions WHERE id = $1 FOR UPDATE`, subscription.ID).Scan(&stored); err != nil {
+		return err
+	}
+	loaded := subscription.Loaded()
+	to := domain.SubscriptionStatus(subscription.Status)
+	if loaded != nil && loaded.Status == subscription.Status {
+		// Статус не менялся: сохраняем тот, что в базе
+		to = stored
+	} else {
+		if loaded != nil && domain.SubscriptionStatus(loaded.Status) != stored {
+			return fmt.Errorf("%w: subscription %d is %s, expected %s", domain.ErrSubscriptionConflict, subscription.ID, stored, loaded.Status)
+		}
+		if err := domain.ValidateTransition(stored, to); err != nil {
+			return err
+		}
+	}
+
+	columns, args := subscriptionChanges(loaded, subscription)
+	if len(columns) > 0 {
+		set := make([]string, len(columns))
+		for i, column := range columns {
+			set[i] = fmt.Sprintf("%s = $%d", column, i+1)
+		}
+		args = append(args, subscription.ID)
+		query := fmt.Sprintf(`UPDATE subscriptions SET %s WHERE id = $%d`, strings.Join(set, ", "), len(args))
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	if stored != to {
+		now := time.Now().UTC()
+		if _, err := tx.Exec(`UPDATE subscriptions SET status_changed_at = $1 WHERE id = $2`, now, subscription.ID); err != nil {
+			return err
+		}
+		if err := insertSubscriptionEvent(tx, &domain.SubscriptionEvent{
+			SubscriptionID: subscription.ID,
+			UserID:         subscription.UserID,
+			FromStatus:     stored,
+			ToStatus:       to,
+			Reason:         subscription.StatusReason,
+			CreatedAt:      now,
+		}); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	subscription.MarkLoaded()
+	return nil
+}
+
+// subscriptionChanges возвращает колонки и значения полей, измененных после чтения подписки;
+// для подписки, не читавшейся из базы (loaded == nil), — все поля.
+// Дата конца пробного периода не стирается.
+func subscriptionChanges(loaded, s *domain.Subscription) ([]string, []interface{}) {
+	var columns []string
+	var args []interface{}
+	all := loaded == nil
+	if all {
+		loaded = &domain.Subscription{}
+	}
+	add := func(changed bool, column string, value interface{}) {
+		if all || changed {
+			columns = append(columns, column)
+			args = append(args, value)
+		}
+	}
+	add(loaded.Tariff != s.Tariff, "tariff", s.Tariff)
+	add(loaded.Status != s.Status, "status", s.Status)
+	add(loaded.Amount != s.Amount, "amount", s.Amount)
+	add(!loaded.NextPayment.Equal(s.NextPayment), "next_payment", s.NextPayment)
+	add(!loaded.LastPayment.Equal(s.LastPayment), "last_payment", s.LastPayment)
+	add(loaded.Active != s.Active, "active", s.Active)
+	add(!equalTimePtr(loaded.CancelledAt, s.CancelledAt), "cancelled_at", s.CancelledAt)
+	add(!equalStringPtr(loaded.YKCustomerID, s.YKCustomerID), "yk_customer_id", s.YKCustomerID)
+	add(!equalStringPtr(loaded.YKPaymentMethodID, s.YKPaymentMethodID), "yk_payment_method_id", s.YKPaymentMethodID)
+	add(!equalStringPtr(loaded.YKLastPaymentID, s.YKLastPaymentID), "yk_last_payment_id", s.YKLastPaymentID)
+	add(loaded.FailedAttempts != s.FailedAttempts, "failed_attempts", s.FailedAttempts)
+	add(!equalTimePtr(loaded.NextRetry, s.NextRetry), "next_retry", s.NextRetry)
+	add(!equalTimePtr(loaded.SuspendedAt, s.SuspendedAt), "suspended_at", s.SuspendedAt)
+	add(!equalTimePtr(loaded.PausedAt, s.PausedAt), "paused_at", s.PausedAt)
+	add(!equalTimePtr(loaded.PauseUntil, s.PauseUntil), "pause_until", s.PauseUntil)
+	if s.TrialEndsAt != nil && !equalTimePtr(loaded.TrialEndsAt, s.TrialEndsAt) {
+		columns = append(columns, "trial_ends_at")
+		args = append(args, s.TrialEndsAt)
+	}
+	return columns, args
+}
+
+func equalTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
 
+// UpdateStatus переводит действующую подписку пользователя в статус status
 func (r *SubscriptionRepository) UpdateStatus(userID int64, status domain.SubscriptionStatus) error {
-	query := `UPDATE subscriptions SET status = $1 WHERE user_id = $2 AND active = true`
-	_, err := r.db.Exec(query, string(status), userID)
-	return err
+	return r.transition(userID, status, "status update", "", nil)
+}
+
+// transition переводит действующую подписку пользователя в статус to по правилам машины
+// состояний и записывает переход в историю. set — дополнительные присваивания в UPDATE,
+// $3 в них — время перехода; eligible (если задан) отбирает подписки, которые нужно переводить.
+// Если действующей подписки нет или она не подходит, ничего не делает.
+func (r *SubscriptionRepository) transition(userID int64, to domain.SubscriptionStatus, reason, set string, eligible func(*domain.Subscription) bool) error {
+	now := time.Now().UTC()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	subscription := &domain.Subscription{}
+	var nextPayment sql.NullTime
+	err = tx.QueryRow(`
+		SELECT id, user_id, status, active, next_payment
+		FROM subscriptions
+		WHERE user_id = $1 AND active = true
+		ORDER BY created_at DESC
+		LIMIT 1
+		FOR UPDATE`, userID).Scan(&subscription.ID, &subscription.UserID, &subscription.Status, &subscription.Active, &nextPayment)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	subscription.NextPayment = nextPayment.Time
+	if eligible != nil && !eligible(subscription) {
+		return nil
+	}
+
+	event, err := subscription.Transition(to, reason, now)
+	if err != nil {
+		return fmt.Errorf("subscription %d: %w", subscription.ID, err)
+	}
+
+	changedAt := "$3"
+	if event == nil {
+		// Статус не меняется: время последней смены статуса оставляем прежним
+		changedAt = "COALESCE(status_changed_at, $3)"
+	}
+	query := `UPDATE subscriptions SET status = $1, active = $2, status_changed_at = ` + changedAt
+	if set != "" {
+		query += ", " + set
+	}
+	if _, err := tx.Exec(query+` WHERE id = $4`, subscription.Status, subscription.Active, now, subscription.ID); err != nil {
+		return err
+	}
+	if event != nil {
+		if err := insertSubscriptionEvent(tx, event); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// insertSubscriptionEvent записывает переход статуса подписки в историю
+func insertSubscriptionEvent(tx *sql.Tx, event *domain.SubscriptionEvent) error {
+	_, err := tx.Exec(`
+		INSERT INTO subscription_events (subscription_id, user_id, from_status, to_status, reason, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6)`,
+		event.SubscriptionID, event.UserID, string(event.FromStatus), string(event.ToStatus), event.Reason, event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error recording subscription event: %w", err)
+	}
+	return nil
+}
+
+// GetSubscriptionEvents получает последние переходы статусов подписок пользователя, новые первыми
+func (r *SubscriptionRepository) GetSubscriptionEvents(userID int64, limit int) ([]*domain.SubscriptionEvent, error) {
+	rows, err := r.db.Query(`
+		SELECT id, subscription_id, user_id, COALESCE(from_status, ''), to_status, reason, created_at
+		FROM subscription_events
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*domain.SubscriptionEvent
+	for rows.Next() {
+		event := &domain.SubscriptionEvent{}
+		if err := rows.Scan(&event.ID, &event.SubscriptionID, &event.UserID, &event.FromStatus, &event.ToStatus,
+			&event.Reason, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
 }
 
 func (r *SubscriptionRepository) UpdateNextPayment(userID int64, nextPayment time.Time) error {
@@ -334,37 +562,42 @@ func (r *SubscriptionRepository) UpdateNextPayment(userID int64, nextPayment tim
 	return err
 }
 
+// Cancel отключает автопродление: подписка действует до конца оплаченного периода
 func (r *SubscriptionRepository) Cancel(userID int64) error {
-	now := time.Now().UTC() // Используем UTC время
-	query := `UPDATE subscriptions SET 
-		status = 'cancelled',
-		cancelled_at = $1,
+	return r.transition(userID, domain.SubscriptionStatusCancelled, "auto-renewal cancelled", `
+		cancelled_at = $3,
 		failed_attempts = 0,
 		next_retry = NULL,
 		suspended_at = NULL,
 		yk_payment_method_id = NULL,
-		yk_last_payment_id = NULL
-		WHERE user_id = $2 AND active = true`
-	_, err := r.db.Exec(query, now, userID)
-	return err
+		yk_last_payment_id = NULL`, nil)
 }
 
 // CancelExpired полностью отменяет подписку когда оплаченный период истек
 func (r *SubscriptionRepository) CancelExpired(userID int64) error {
 	now := time.Now().UTC() // Используем UTC время
-	query := `UPDATE subscriptions SET 
-		active = false,
-		status = 'expired',
-		cancelled_at = $1,
+	paidPeriodOver := func(subscription *domain.Subscription) bool {
+		return !subscription.NextPayment.After(now)
+	}
+	return r.transition(userID, domain.SubscriptionStatusExpired, "paid period is over", `
+		cancelled_at = $3,
 		next_payment = NULL,
 		yk_payment_method_id = NULL,
 		yk_last_payment_id = NULL,
 		failed_attempts = 0,
 		next_retry = NULL,
-		suspended_at = NULL
-		WHERE user_id = $2 AND status = 'cancelled' AND next_payment <= $1`
-	_, err := r.db.Exec(query, now, userID)
-	return err
+		suspended_at = NULL`, paidPeriodOver)
+}
+
+// Revoke немедленно завершает подписку, не дожидаясь конца оплаченного периода (возврат оплаты)
+func (r *SubscriptionRepository) Revoke(userID int64) error {
+	return r.transition(userID, domain.SubscriptionStatusExpired, "payment refunded", `
+		cancelled_at = COALESCE(cancelled_at, $3),
+		next_payment = $3,
+		yk_payment_method_id = NULL,
+		failed_attempts = 0,
+		next_retry = NULL,
+		suspended_at = NULL`, nil)
 }
 
 func (r *SubscriptionRepository) GetActiveSubscriptions() ([]*domain.Subscription, error) {
@@ -398,24 +631,26 @@ func (r *SubscriptionRepository) GetActiveSubscriptions() ([]*domain.Subscriptio
 		if err != nil {
 			return nil, err
 		}
+		subscription.MarkLoaded()
 		subscriptions = append(subscriptions, subscription)
 	}
 
 	return subscriptions, nil
 }
 
-func (r *SubscriptionRepository) UpdateYooKassaBindings(userID int64, customerID, paymentMethodID, lastPaymentID string) error {
+// UpdatePaymentBinding сохраняет способ оплаты и провайдера, через которого будут списываться продления
+func (r *SubscriptionRepository) UpdatePaymentBinding(userID int64, provider, customerID, paymentMethodID, lastPaymentID string) error {
 	// Обновляем самую новую подписку пользователя (включая pending)
 	_, err := r.db.Exec(`
 		UPDATE subscriptions
-		SET yk_customer_id = $1, yk_payment_method_id = $2, yk_last_payment_id = $3
+		SET yk_customer_id = $1, yk_payment_method_id = $2, yk_last_payment_id = $3, payment_provider = $5
 		WHERE id = (
 			SELECT id FROM subscriptions 
 			WHERE user_id = $4 
 			ORDER BY created_at DESC 
 			LIMIT 1
 		)`,
-		customerID, paymentMethodID, lastPaymentID, userID,
+		customerID, paymentMethodID, lastPaymentID, userID, provider,
 	)
 	return err
 }
@@ -425,12 +660,12 @@ func (r *SubscriptionRepository) GetSubscriptionsDueForRetry() ([]*domain.Subscr
 	// Теперь все время хранится в UTC, поэтому используем просто NOW()
 	query := `
 		SELECT id, user_id, subscription_id, tariff, status, amount, next_payment, last_payment, created_at, cancelled_at, active,
-		       yk_customer_id, yk_payment_method_id, yk_last_payment_id, failed_attempts, next_retry, suspended_at
+		       yk_customer_id, yk_payment_method_id, yk_last_payment_id, payment_provider, failed_attempts, next_retry, suspended_at,
+		       paused_at, pause_until, trial_ends_at
 		FROM subscriptions
 		WHERE active = true 
 		  AND status = 'active'
 		  AND failed_attempts > 0
-		  AND failed_attempts < 3
 		  AND next_retry <= NOW()
 		  AND yk_customer_id IS NOT NULL 
 		  AND yk_payment_method_id IS NOT NULL`
@@ -475,14 +710,20 @@ func (r *SubscriptionRepository) GetSubscriptionsDueForRetry() ([]*domain.Subscr
 			&subscription.YKCustomerID,
 			&subscription.YKPaymentMethodID,
 			&subscription.YKLastPaymentID,
+			&subscription.PaymentProvider,
 			&subscription.FailedAttempts,
 			&subscription.NextRetry,
 			&subscription.SuspendedAt,
+			&subscription.PausedAt,
+			&subscription.PauseUntil,
+			&subscription.TrialEndsAt,
+		&subscription.TrialEndsAt,
 		)
 		if err != nil {
 			log.Printf("❌ [SQL DEBUG] Row scan error: %v", err)
 			return nil, err
 		}
+		subscription.MarkLoaded()
 		subscriptions = append(subscriptions, subscription)
 		log.Printf("🔍 [SQL DEBUG] Found retry subscription: ID=%d, UserID=%d, FailedAttempts=%d, NextRetry=%s",
 			subscription.ID, subscription.UserID, subscription.FailedAttempts,
@@ -493,39 +734,71 @@ func (r *SubscriptionRepository) GetSubscriptionsDueForRetry() ([]*domain.Subscr
 	return subscriptions, nil
 }
 
-// IncrementFailedAttempts увеличивает счетчик неудачных попыток
-func (r *SubscriptionRepository) IncrementFailedAttempts(userID int64) error {
-	query := `UPDATE subscriptions SET failed_attempts = failed_attempts + 1 WHERE user_id = $1 AND active = true`
-	_, err := r.db.Exec(query, userID)
-	return err
-}
+// GetSubscriptionsRenewingBefore получает подписки с автопродлением, списание по которым наступит до until
+func (r *SubscriptionRepository) GetSubscriptionsRenewingBefore(until time.Time) ([]*domain.Subscription, error) {
+	query := `
+		SELECT id, user_id, subscription_id, tariff, status, amount, next_payment, last_payment, created_at, cancelled_at, active,
+		       yk_customer_id, yk_payment_method_id, yk_last_payment_id, payment_provider, failed_attempts, next_retry, trial_ends_at
+		FROM subscriptions
+		WHERE active = true
+		  AND status = 'active'
+		  AND failed_attempts = 0
+		  AND next_payment > NOW()
+		  AND next_payment <= $1
+		  AND yk_customer_id IS NOT NULL
+		  AND yk_payment_method_id IS NOT NULL
+		  AND user_id NOT IN (SELECT id FROM users WHERE bot_blocked = TRUE)`
 
-// SuspendSubscription приостанавливает подписку после 3 неудачных попыток
-func (r *SubscriptionRepository) SuspendSubscription(userID int64) error {
-	now := time.Now().UTC() // Используем UTC время
-	query := `UPDATE subscriptions SET 
-		status = 'suspended',
-		active = false,
-		tariff = 'free',
-		next_payment = NULL,
-		suspended_at = $1,
-		failed_attempts = 0,
-		next_retry = NULL
-		WHERE user_id = $2 AND active = true`
-	_, err := r.db.Exec(query, now, userID)
-	return err
+	rows, err := r.db.Query(query, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []*domain.Subscription
+	for rows.Next() {
+		subscription := &domain.Subscription{}
+		if err := rows.Scan(
+			&subscription.ID,
+			&subscription.UserID,
+			&subscription.SubscriptionID,
+			&subscription.Tariff,
+			&subscription.Status,
+			&subscription.Amount,
+			&subscription.NextPayment,
+			&subscription.LastPayment,
+			&subscription.CreatedAt,
+			&subscription.CancelledAt,
+			&subscription.Active,
+			&subscription.YKCustomerID,
+			&subscription.YKPaymentMethodID,
+			&subscription.YKLastPaymentID,
+			&subscription.PaymentProvider,
+			&subscription.FailedAttempts,
+			&subscription.NextRetry,
+			&subscription.TrialEndsAt,
+		); err != nil {
+			return nil, err
+		}
+		subscription.MarkLoaded()
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
 }
 
-// GetAllActiveSubscriptions получает все активные подписки для диагностики
-func (r *SubscriptionRepository) GetAllActiveSubscriptions() ([]*domain.Subscription, error) {
+// GetSubscriptionsPausedUntil получает подписки на паузе, которая заканчивается не позже until
+func (r *SubscriptionRepository) GetSubscriptionsPausedUntil(until time.Time) ([]*domain.Subscription, error) {
 	query := `
 		SELECT id, user_id, subscription_id, tariff, status, amount, next_payment, last_payment, created_at, cancelled_at, active,
-		       yk_customer_id, yk_payment_method_id, yk_last_payment_id, failed_attempts, next_retry, suspended_at
+		       yk_customer_id, yk_payment_method_id, yk_last_payment_id, payment_provider, failed_attempts, next_retry, suspended_at,
+		       paused_at, pause_until, trial_ends_at
 		FROM subscriptions
-		WHERE active = true 
-		ORDER BY next_payment ASC`
+		WHERE active = true
+		  AND status = 'paused'
+		  AND pause_until <= $1
+		ORDER BY pause_until`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, until)
 	if err != nil {
 		return nil, err
 	}
@@ -534,7 +807,7 @@ func (r *SubscriptionRepository) GetAllActiveSubscriptions() ([]*domain.Subscrip
 	var subscriptions []*domain.Subscription
 	for rows.Next() {
 		subscription := &domain.Subscription{}
-		err := rows.Scan(
+		if err := rows.Scan(
 			&subscription.ID,
 			&subscription.UserID,
 			&subscription.SubscriptionID,
@@ -549,29 +822,102 @@ func (r *SubscriptionRepository) GetAllActiveSubscriptions() ([]*domain.Subscrip
 			&subscription.YKCustomerID,
 			&subscription.YKPaymentMethodID,
 			&subscription.YKLastPaymentID,
+			&subscription.PaymentProvider,
 			&subscription.FailedAttempts,
 			&subscription.NextRetry,
 			&subscription.SuspendedAt,
-		)
-		if err != nil {
+			&subscription.PausedAt,
+			&subscription.PauseUntil,
+			&subscription.TrialEndsAt,
+		&subscription.TrialEndsAt,
+		); err != nil {
 			return nil, err
 		}
+		subscription.MarkLoaded()
 		subscriptions = append(subscriptions, subscription)
 	}
+	return subscriptions, rows.Err()
+}
 
-	return subscriptions, nil
+// CountPausesSince считает паузы пользователя начиная с since по истории статусов подписок
+func (r *SubscriptionRepository) CountPausesSince(userID int64, since time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM subscription_events
+		WHERE user_id = $1 AND to_status = $2 AND created_at >= $3`,
+		userID, string(domain.SubscriptionStatusPaused), since).Scan(&count)
+	return count, err
+}
+
+// HasSubscriptionHistory проверяет, была ли у пользователя подписка или пробный период.
+// Неоплаченные заявки (pending) не считаются.
+func (r *SubscriptionRepository) HasSubscriptionHistory(userID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM subscriptions
+			WHERE user_id = $1 AND (status <> 'pending' OR trial_ends_at IS NOT NULL)
+		)`, userID).Scan(&exists)
+	return exists, err
+}
+
+// ClaimRenewal захватывает подписку для списания на ttl. Захват не удается, пока подписку держит
+// другой экземпляр воркера или если после чтения подписки изменились дата списания или счетчик неудач:
+// значит, это списание уже проведено. Номер попытки растет только после освобождения захвата,
+// поэтому повторный захват после сбоя воркера получает тот же номер и тот же ключ идемпотентности.
+func (r *SubscriptionRepository) ClaimRenewal(subscription *domain.Subscription, ttl time.Duration) (int, bool, error) {
+	var attempt int
+	err := r.db.QueryRow(`
+		UPDATE subscriptions
+		SET renewal_attempt = CASE WHEN renewal_claimed_until IS NULL THEN renewal_attempt + 1 ELSE renewal_attempt END,
+		    renewal_claimed_until = NOW() + make_interval(secs => $4)
+		WHERE id = $1 AND next_payment = $2 AND failed_attempts = $3
+		  AND (renewal_claimed_until IS NULL OR renewal_claimed_until < NOW())
+		RETURNING renewal_attempt`,
+		subscription.ID, subscription.NextPayment, subscription.FailedAttempts, ttl.Seconds()).Scan(&attempt)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return attempt, true, nil
+}
+
+// ReleaseRenewal освобождает захват подписки после списания
+func (r *SubscriptionRepository) ReleaseRenewal(subscriptionID int64) error {
+	_, err := r.db.Exec(`UPDATE subscriptions SET renewal_claimed_until = NULL WHERE id = $1`, subscriptionID)
+	return err
+}
+
+// IncrementFailedAttempts увеличивает счетчик неудачных попыток
+func (r *SubscriptionRepository) IncrementFailedAttempts(userID int64) error {
+	query := `UPDATE subscriptions SET failed_attempts = failed_attempts + 1 WHERE user_id = $1 AND active = true`
+	_, err := r.db.Exec(query, userID)
+	return err
+}
+
+// SuspendSubscription приостанавливает подписку после исчерпания повторных попыток списания
+func (r *SubscriptionRepository) SuspendSubscription(userID int64) error {
+	return r.transition(userID, domain.SubscriptionStatusSuspended, "renewal retries exhausted", `
+		tariff = 'free',
+		next_payment = NULL,
+		suspended_at = $3,
+		failed_attempts = 0,
+		next_retry = NULL`, nil)
 }
 
-// GetUserPaymentHistory получает историю всех платежей пользователя
-func (r *SubscriptionRepository) GetUserPaymentHistory(userID int64) ([]*domain.Subscription, error) {
+// GetAllActiveSubscriptions получает все активные подписки для диагностики
+func (r *SubscriptionRepository) GetAllActiveSubscriptions() ([]*domain.Subscription, error) {
 	query := `
 		SELECT id, user_id, subscription_id, tariff, status, amount, next_payment, last_payment, created_at, cancelled_at, active,
-		       yk_customer_id, yk_payment_method_id, yk_last_payment_id, failed_attempts, next_retry, suspended_at
+		       yk_customer_id, yk_payment_method_id, yk_last_payment_id, payment_provider, failed_attempts, next_retry, suspended_at,
+		       paused_at, pause_until, trial_ends_at
 		FROM subscriptions
-		WHERE user_id = $1
-		ORDER BY created_at DESC`
+		WHERE active = true 
+		ORDER BY next_payment ASC`
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.Query(query)
 	if err != nil {
 		return nil, err
 	}
@@ -580,67 +926,37 @@ func (r *SubscriptionRepository) GetUserPaymentHistory(userID int64) ([]*domain.
 	var subscriptions []*domain.Subscription
 	for rows.Next() {
 		subscription := &domain.Subscription{}
-		
-		// Используем временные переменные для полей, которые могут быть NULL
-		var nextPayment, lastPayment, cancelledAt, nextRetry, suspendedAt sql.NullTime
-		var ykCustomerID, ykPaymentMethodID, ykLastPaymentID sql.NullString
-		var subscriptionID sql.NullInt32
-		
 		err := rows.Scan(
 			&subscription.ID,
 			&subscription.UserID,
-			&subscriptionID,
+			&subscription.SubscriptionID,
 			&subscription.Tariff,
 			&subscription.Status,
 			&subscription.Amount,
-			&nextPayment,
-			&lastPayment,
+			&subscription.NextPayment,
+			&subscription.LastPayment,
 			&subscription.CreatedAt,
-			&cancelledAt,
+			&subscription.CancelledAt,
 			&subscription.Active,
-			&ykCustomerID,
-			&ykPaymentMethodID,
-			&ykLastPaymentID,
+			&subscription.YKCustomerID,
+			&subscription.YKPaymentMethodID,
+			&subscription.YKLastPaymentID,
+			&subscription.PaymentProvider,
 			&subscription.FailedAttempts,
-			&nextRetry,
-			&suspendedAt,
+			&subscription.NextRetry,
+			&subscription.SuspendedAt,
+			&subscription.PausedAt,
+			&subscription.PauseUntil,
+			&subscription.TrialEndsAt,
+		&subscription.TrialEndsAt,
 		)
 		if err != nil {
 			return nil, err
 		}
-		
-		// Копируем значения из временных переменных
-		if subscriptionID.Valid {
-			val := int(subscriptionID.Int32)
-			subscription.SubscriptionID = &val
-		}
-		if nextPayment.Valid {
-			subscription.NextPayment = nextPayment.Time
-		}
-		if lastPayment.Valid {
-			subscription.LastPayment = lastPayment.Time
-		}
-		if cancelledAt.Valid {
-			subscription.CancelledAt = &cancelledAt.Time
-		}
-		if ykCustomerID.Valid {
-			subscription.YKCustomerID = &ykCustomerID.String
-		}
-		if ykPaymentMethodID.Valid {
-			subscription.YKPaymentMethodID = &ykPaymentMethodID.String
-		}
-		if ykLastPaymentID.Valid {
-			subscription.YKLastPaymentID = &ykPaymentMethodID.String
-		}
-		if nextRetry.Valid {
-			subscription.NextRetry = &nextRetry.Time
-		}
-		if suspendedAt.Valid {
-			subscription.SuspendedAt = &suspendedAt.Time
-		}
-		
+		subscription.MarkLoaded()
 		subscriptions = append(subscriptions, subscription)
 	}
 
 	return subscriptions, nil
 }
+
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+)
+
+func TestSubscriptionChangesWritesOnlyModifiedFields(t *testing.T) {
+	nextPayment := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	subscription := &domain.Subscription{ID: 1, UserID: 42, Tariff: "premium", Status: string(domain.SubscriptionStatusActive),
+		Amount: 990, NextPayment: nextPayment, Active: true, FailedAttempts: 1}
+	subscription.MarkLoaded()
+
+	// Продление меняет даты и счетчик неудач, но не статус: отмена, сохраненная параллельно, не затирается
+	renewed := nextPayment.AddDate(0, 1, 0)
+	subscription.LastPayment = nextPayment
+	subscription.NextPayment = renewed
+	subscription.FailedAttempts = 0
+
+	columns, args := subscriptionChanges(subscription.Loaded(), subscription)
+	if expected := []string{"next_payment", "last_payment", "failed_attempts"}; !reflect.DeepEqual(columns, expected) {
+		t.Fatalf("Ожидались колонки %v, получено %v", expected, columns)
+	}
+	if len(args) != len(columns) || args[0] != renewed {
+		t.Errorf("Значения не соответствуют колонкам: %v", args)
+	}
+
+	// Подписка, не читавшаяся из базы, сохраняется целиком
+	if columns, _ := subscriptionChanges(nil, subscription); len(columns) != 15 {
+		t.Errorf("Без состояния из базы должны записываться все поля, получено %v", columns)
+	}
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"ai_tg_writer/internal/domain"
+)
+
+// TariffRepository работает с каталогом тарифов
+type TariffRepository struct {
+	db *DB
+}
+
+// NewTariffRepository создает новый репозиторий тарифов
+func NewTariffRepository(db *DB) *TariffRepository {
+	return &TariffRepository{db: db}
+}
+
+const tariffColumns = `id, name, COALESCE(description, ''), price, currency, period, quotas, features, visible, sort_order, provider, retry_hours`
+
+// GetAll возвращает все тарифы, включая скрытые
+func (r *TariffRepository) GetAll() ([]*domain.Tariff, error) {
+	return r.query(`SELECT ` + tariffColumns + ` FROM tariffs ORDER BY sort_order, id`)
+}
+
+// GetVisible возвращает тарифы, доступные для покупки
+func (r *TariffRepository) GetVisible() ([]*domain.Tariff, error) {
+	return r.query(`SELECT ` + tariffColumns + ` FROM tariffs WHERE visible = TRUE AND price > 0 ORDER BY sort_order, id`)
+}
+
+// GetByID возвращает тариф по ID. Возвращает nil, если тариф не найден.
+func (r *TariffRepository) GetByID(id string) (*domain.Tariff, error) {
+	tariffs, err := r.query(`SELECT `+tariffColumns+` FROM tariffs WHERE id = $1`, id)
+	if err != nil || len(tariffs) == 0 {
+		return nil, err
+	}
+	return tariffs[0], nil
+}
+
+// Save создает тариф или обновляет существующий
+func (r *TariffRepository) Save(tariff *domain.Tariff) error {
+	features := tariff.Features
+	if features == nil {
+		features = []string{}
+	}
+	featuresJSON, err := json.Marshal(features)
+	if err != nil {
+		return fmt.Errorf("marshal features: %w", err)
+	}
+	quotas := tariff.Quotas
+	if quotas == nil {
+		quotas = map[domain.QuotaResource]int{}
+	}
+	quotasJSON, err := json.Marshal(quotas)
+	if err != nil {
+		return fmt.Errorf("marshal quotas: %w", err)
+	}
+	retryHours := tariff.RetryHours
+	if retryHours == nil {
+		retryHours = []int{}
+	}
+	retryJSON, err := json.Marshal(retryHours)
+	if err != nil {
+		return fmt.Errorf("marshal retry hours: %w", err)
+	}
+	if tariff.Currency == "" {
+		tariff.Currency = "RUB"
+	}
+
+	_, err = r.db.Exec(`
+		INSERT INTO tariffs (id, name, description, price, currency, period, quotas, features, visible, sort_order, provider, retry_hours)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name,
+			description = EXCLUDED.description,
+			price = EXCLUDED.price,
+			currency = EXCLUDED.currency,
+			period = EXCLUDED.period,
+			quotas = EXCLUDED.quotas,
+			features = EXCLUDED.features,
+			visible = EXCLUDED.visible,
+			sort_order = EXCLUDED.sort_order,
+			provider = EXCLUDED.provider,
+			retry_hours = EXCLUDED.retry_hours,
+			updated_at = CURRENT_TIMESTAMP`,
+		tariff.ID, tariff.Name, tariff.Description, tariff.Price, tariff.Currency, tariff.Period,
+		string(quotasJSON), string(featuresJSON), tariff.Visible, tariff.SortOrder, tariff.Provider, string(retryJSON))
+	return err
+}
+
+// query выполняет выборку тарифов
+func (r *TariffRepository) query(query string, args ...interface{}) ([]*domain.Tariff, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tariffs []*domain.Tariff
+	for rows.Next() {
+		tariff := &domain.Tariff{}
+		var quotas, features, retryHours []byte
+		if err := rows.Scan(&tariff.ID, &tariff.Name, &tariff.Description, &tariff.Price, &tariff.Currency,
+			&tariff.Period, &quotas, &features, &tariff.Visible, &tariff.SortOrder, &tariff.Provider, &retryHours); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(quotas, &tariff.Quotas); err != nil {
+			return nil, fmt.Errorf("unmarshal quotas of tariff %s: %w", tariff.ID, err)
+		}
+		if err := json.Unmarshal(features, &tariff.Features); err != nil {
+			return nil, fmt.Errorf("unmarshal features of tariff %s: %w", tariff.ID, err)
+		}
+		if err := json.Unmarshal(retryHours, &tariff.RetryHours); err != nil {
+			return nil, fmt.Errorf("unmarshal retry hours of tariff %s: %w", tariff.ID, err)
+		}
+		tariffs = append(tariffs, tariff)
+	}
+	return tariffs, rows.Err()
+}
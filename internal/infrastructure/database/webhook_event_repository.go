@@ -0,0 +1,76 @@
+package database
+
+import (
+	"database/sql"
+
+	"ai_tg_writer/internal/domain"
+)
+
+// webhookProcessingTimeout через сколько зависшая обработка (упавший процесс) может быть подхвачена повторной доставкой
+const webhookProcessingTimeout = "5 minutes"
+
+// WebhookEventRepository хранит входящие уведомления провайдеров и состояние их обработки
+type WebhookEventRepository struct {
+	db *DB
+}
+
+// NewWebhookEventRepository создает новый репозиторий уведомлений
+func NewWebhookEventRepository(db *DB) *WebhookEventRepository {
+	return &WebhookEventRepository{db: db}
+}
+
+// Receive сохраняет событие. Если событие с тем же ключом уже есть, возвращает сохраненную запись.
+func (r *WebhookEventRepository) Receive(event *domain.WebhookEvent) (*domain.WebhookEvent, error) {
+	_, err := r.db.Exec(`
+		INSERT INTO webhook_events (provider, event_key, event_type, object_id, payload)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (provider, event_key) DO NOTHING`,
+		event.Provider, event.EventKey, event.EventType, event.ObjectID, string(event.Payload))
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &domain.WebhookEvent{}
+	var processedAt sql.NullTime
+	err = r.db.QueryRow(`
+		SELECT id, provider, event_key, event_type, object_id, payload, status, attempts, last_error, received_at, processed_at
+		FROM webhook_events
+		WHERE provider = $1 AND event_key = $2`,
+		event.Provider, event.EventKey).Scan(&stored.ID, &stored.Provider, &stored.EventKey, &stored.EventType,
+		&stored.ObjectID, &stored.Payload, &stored.Status, &stored.Attempts, &stored.LastError, &stored.ReceivedAt, &processedAt)
+	if err != nil {
+		return nil, err
+	}
+	if processedAt.Valid {
+		stored.ProcessedAt = &processedAt.Time
+	}
+	return stored, nil
+}
+
+// Claim атомарно переводит событие в processing и увеличивает счетчик попыток
+func (r *WebhookEventRepository) Claim(id int64) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE webhook_events
+		SET status = $2, attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (
+			status IN ($3, $4)
+			OR (status = $2 AND updated_at < CURRENT_TIMESTAMP - INTERVAL '`+webhookProcessingTimeout+`')
+		)`,
+		id, domain.WebhookEventProcessing, domain.WebhookEventReceived, domain.WebhookEventFailed)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Finish фиксирует результат обработки события
+func (r *WebhookEventRepository) Finish(id int64, status domain.WebhookEventStatus, lastError string) error {
+	_, err := r.db.Exec(`
+		UPDATE webhook_events
+		SET status = $2, last_error = $3, updated_at = CURRENT_TIMESTAMP,
+			processed_at = CASE WHEN $4 THEN CURRENT_TIMESTAMP ELSE processed_at END
+		WHERE id = $1`,
+		id, status, lastError, status.IsFinal())
+	return err
+}
@@ -94,8 +94,8 @@ func NewProdamusHandler(apiKey, apiURL, webhookSecret string) *ProdamusHandler {
 	}
 }
 
-// CreateSubscriptionLink создает ссылку для оформления подписки
-func (h *ProdamusHandler) CreateSubscriptionLink(userID int64, tariff string, amount float64, subscriptionID int) (string, error) {
+// CreateSubscriptionLink создает ссылку для оформления подписки и возвращает ее вместе с order_id
+func (h *ProdamusHandler) CreateSubscriptionLink(userID int64, tariff string, amount float64, subscriptionID int) (string, string, error) {
 	data := url.Values{}
 
 	// Генерируем уникальный order_id
@@ -119,11 +119,11 @@ func (h *ProdamusHandler) CreateSubscriptionLink(userID int64, tariff string, am
 	// Формируем URL
 	paymentURL := fmt.Sprintf("%s?%s", h.APIURL, data.Encode())
 
-	return paymentURL, nil
+	return paymentURL, orderID, nil
 }
 
-// CreatePaymentLink создает ссылку для разового платежа
-func (h *ProdamusHandler) CreatePaymentLink(userID int64, amount float64, description string) (string, error) {
+// CreatePaymentLink создает ссылку для разового платежа и возвращает ее вместе с order_id
+func (h *ProdamusHandler) CreatePaymentLink(userID int64, amount float64, description string) (string, string, error) {
 	data := url.Values{}
 
 	// Генерируем уникальный order_id
@@ -144,19 +144,19 @@ func (h *ProdamusHandler) CreatePaymentLink(userID int64, amount float64, descri
 	// Формируем URL
 	paymentURL := fmt.Sprintf("%s?%s", h.APIURL, data.Encode())
 
-	return paymentURL, nil
+	return paymentURL, orderID, nil
 }
 
 // VerifyWebhook проверяет подпись вебхука
 func (h *ProdamusHandler) VerifyWebhook(data url.Values, signature string) bool {
 	expectedSignature := h.CreateSignature(data)
-	return expectedSignature == signature
+	return hmac.Equal([]byte(expectedSignature), []byte(strings.ToLower(signature)))
 }
 
 // VerifySubscriptionWebhook проверяет подпись вебхука подписки
 func (h *ProdamusHandler) VerifySubscriptionWebhook(data url.Values, signature string) bool {
 	expectedSignature := h.CreateSubscriptionSignature(data)
-	return strings.ToLower(expectedSignature) == strings.ToLower(signature)
+	return hmac.Equal([]byte(strings.ToLower(expectedSignature)), []byte(strings.ToLower(signature)))
 }
 
 // ProcessWebhook обрабатывает вебхуки от Prodamus
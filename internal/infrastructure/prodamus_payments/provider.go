@@ -0,0 +1,101 @@
+package prodamus_payments
+
+import (
+	"ai_tg_writer/internal/domain"
+	"fmt"
+)
+
+// Provider платежный провайдер Prodamus: оплата по ссылке на платежную форму.
+// Продления Prodamus списывает сам и присылает уведомление, возвраты оформляются
+// в личном кабинете — эти операции через API не поддерживаются.
+type Provider struct {
+	handler *ProdamusHandler
+}
+
+// NewProvider создает провайдера поверх обработчика платежной формы
+func NewProvider(handler *ProdamusHandler) *Provider {
+	return &Provider{handler: handler}
+}
+
+// Name возвращает имя провайдера в журнале платежей
+func (p *Provider) Name() string {
+	return domain.PaymentProviderProdamus
+}
+
+// CreatePayment формирует ссылку на платежную форму. Первая оплата подписки оформляет
+// подписку Prodamus (order_id sub_*), остальные платежи разовые (order_id pay_*).
+func (p *Provider) CreatePayment(req *domain.PaymentRequest) (*domain.ProviderPayment, error) {
+	var (
+		paymentURL, orderID string
+		err                 error
+	)
+	if req.Kind == domain.PaymentKindInitial {
+		subscriptionID := 0
+		if req.SubscriptionID != nil {
+			subscriptionID = int(*req.SubscriptionID)
+		}
+		paymentURL, orderID, err = p.handler.CreateSubscriptionLink(req.UserID, req.Description, req.Amount, subscriptionID)
+	} else {
+		paymentURL, orderID, err = p.handler.CreatePaymentLink(req.UserID, req.Amount, req.Description)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = "RUB"
+	}
+	return &domain.ProviderPayment{
+		Payment: &domain.Payment{
+			UserID:            req.UserID,
+			SubscriptionID:    req.SubscriptionID,
+			Provider:          domain.PaymentProviderProdamus,
+			ProviderPaymentID: &orderID,
+			Kind:              req.Kind,
+			Amount:            req.Amount,
+			Currency:          currency,
+			Status:            domain.PaymentStatusPending,
+		},
+		ConfirmationURL: paymentURL,
+	}, nil
+}
+
+// ChargeSaved не поддерживается: продления подписки Prodamus списывает по своему расписанию
+func (p *Provider) ChargeSaved(req *domain.ChargeRequest) (*domain.ProviderPayment, error) {
+	return nil, fmt.Errorf("%w: prodamus charges subscriptions itself", domain.ErrProviderUnsupported)
+}
+
+// GetPayment не поддерживается: статус платежа приходит только в уведомлении
+func (p *Provider) GetPayment(providerPaymentID string) (*domain.ProviderPayment, error) {
+	return nil, fmt.Errorf("%w: prodamus has no payment status API", domain.ErrProviderUnsupported)
+}
+
+// Refund не поддерживается: возврат оформляется в личном кабинете Prodamus
+func (p *Provider) Refund(req *domain.RefundRequest) (*domain.ProviderRefund, error) {
+	return nil, fmt.Errorf("%w: prodamus refunds are made in the dashboard", domain.ErrProviderUnsupported)
+}
+
+// PaymentStatus переводит payment_status из уведомления об оплате в статус журнала платежей.
+// Оплаченным считается только success; неизвестные статусы остаются ожидающими.
+func PaymentStatus(status string) domain.PaymentStatus {
+	switch status {
+	case "success":
+		return domain.PaymentStatusSucceeded
+	case "order_canceled", "order_denied":
+		return domain.PaymentStatusFailed
+	}
+	return domain.PaymentStatusPending
+}
+
+// VerifyWebhook проверяет подпись уведомления из заголовка Sign. Уведомления о подписках
+// подписываются по JSON-представлению формы, об оплатах — по отсортированным параметрам.
+func (p *Provider) VerifyWebhook(req *domain.WebhookRequest) error {
+	if req.Signature == "" {
+		return fmt.Errorf("signature is missing")
+	}
+	if p.handler.VerifyWebhook(req.Form, req.Signature) || p.handler.VerifySubscriptionWebhook(req.Form, req.Signature) {
+		return nil
+	}
+	return fmt.Errorf("invalid signature")
+}
@@ -17,6 +17,7 @@ type Client struct {
 	SecretKey string
 	BaseURL   string
 	HTTP      *http.Client
+	Receipt   ReceiptSettings // Чеки 54-ФЗ для платежей и возвратов
 }
 
 func New() *Client {
@@ -24,6 +25,7 @@ func New() *Client {
 		ShopID:    os.Getenv("YK_SHOP_ID"),
 		SecretKey: os.Getenv("YK_SECRET_KEY"),
 		BaseURL:   "https://api.yookassa.ru/v3",
+		Receipt:   receiptSettingsFromEnv(),
 		HTTP: &http.Client{
 			Timeout: 15 * time.Second,
 			// Добавляем пул соединений для лучшей производительности при параллельных запросах
@@ -92,46 +94,41 @@ func min(a, b int) int {
 type Amount struct{ Value, Currency string }
 
 // 5.1 Первичный платеж с сохранением метода + customer.id
-func (c *Client) CreateInitialPayment(idemKey string, amount Amount, description, customerID, returnURL string, metadata map[string]string) (map[string]any, error) {
-	payload := map[string]any{
+func (c *Client) CreateInitialPayment(idemKey string, amount Amount, description, customerID, returnURL string, contact ReceiptCustomer, metadata map[string]string) (map[string]any, error) {
+	payload := c.redirectPaymentPayload(amount, description, returnURL, contact, metadata)
+	payload["save_payment_method"] = true
+	payload["customer"] = map[string]string{
+		"id": customerID, // обязателен для привязки
+	}
+	var out map[string]any
+	err := c.do(idemKey, "POST", "/payments", payload, &out)
+	return out, err
+}
+
+// 5.1.1 Разовый платеж без сохранения метода (пакеты кредитов)
+func (c *Client) CreateOneTimePayment(idemKey string, amount Amount, description, returnURL string, contact ReceiptCustomer, metadata map[string]string) (map[string]any, error) {
+	payload := c.redirectPaymentPayload(amount, description, returnURL, contact, metadata)
+	var out map[string]any
+	err := c.do(idemKey, "POST", "/payments", payload, &out)
+	return out, err
+}
+
+// redirectPaymentPayload тело платежа с переходом на страницу оплаты и чеком
+func (c *Client) redirectPaymentPayload(amount Amount, description, returnURL string, contact ReceiptCustomer, metadata map[string]string) map[string]any {
+	return c.withReceipt(map[string]any{
 		"amount":  map[string]string{"value": amount.Value, "currency": amount.Currency},
 		"capture": true,
 		"confirmation": map[string]string{
 			"type": "redirect", "return_url": returnURL,
 		},
-		"description":         description,
-		"save_payment_method": true,
-		"customer": map[string]string{
-			"id": customerID, // обязателен для привязки
-		},
-		"metadata": metadata,
-		"receipt": map[string]any{
-			"customer": map[string]string{
-				"email": "noreply@aiwhisper.ru",
-			},
-			"items": []map[string]any{
-				{
-					"description": description,
-					"amount": map[string]string{
-						"value":    amount.Value,
-						"currency": amount.Currency,
-					},
-					"vat_code":        "1", // НДС 20%
-					"quantity":        "1",
-					"payment_subject": "service",      // Предмет расчета - услуга
-					"payment_mode":    "full_payment", // Способ расчета - полный расчет
-				},
-			},
-		},
-	}
-	var out map[string]any
-	err := c.do(idemKey, "POST", "/payments", payload, &out)
-	return out, err
+		"description": description,
+		"metadata":    metadata,
+	}, description, amount, contact)
 }
 
 // 5.2 Рекуррентный платеж по сохраненному payment_method_id + customer_id
-func (c *Client) CreateRecurringPayment(idemKey string, amount Amount, description, customerID, paymentMethodID string, metadata map[string]string) (map[string]any, error) {
-	payload := map[string]any{
+func (c *Client) CreateRecurringPayment(idemKey string, amount Amount, description, customerID, paymentMethodID string, contact ReceiptCustomer, metadata map[string]string) (map[string]any, error) {
+	payload := c.withReceipt(map[string]any{
 		"amount":            map[string]string{"value": amount.Value, "currency": amount.Currency},
 		"capture":           true,
 		"payment_method_id": paymentMethodID,
@@ -140,7 +137,7 @@ func (c *Client) CreateRecurringPayment(idemKey string, amount Amount, descripti
 		},
 		"description": description,
 		"metadata":    metadata,
-	}
+	}, description, amount, contact)
 	var out map[string]any
 	err := c.do(idemKey, "POST", "/payments", payload, &out)
 	return out, err
@@ -152,6 +149,28 @@ func (c *Client) GetPayment(id string) (map[string]any, error) {
 	return out, err
 }
 
+// CreateRefund возвращает полную или частичную сумму успешного платежа.
+// Чек возврата формируется на возвращаемую сумму с наименованием itemDescription.
+func (c *Client) CreateRefund(idemKey, paymentID string, amount Amount, description, itemDescription string, contact ReceiptCustomer) (map[string]any, error) {
+	payload := c.withReceipt(map[string]any{
+		"payment_id": paymentID,
+		"amount":     map[string]string{"value": amount.Value, "currency": amount.Currency},
+	}, itemDescription, amount, contact)
+	if description != "" {
+		payload["description"] = description
+	}
+	var out map[string]any
+	err := c.do(idemKey, "POST", "/refunds", payload, &out)
+	return out, err
+}
+
+// GetRefund возвращает возврат по ID
+func (c *Client) GetRefund(id string) (map[string]any, error) {
+	var out map[string]any
+	err := c.do("", "GET", "/refunds/"+id, nil, &out)
+	return out, err
+}
+
 // CreateCustomer создает customer в YooKassa
 func (c *Client) CreateCustomer(idemKey string, email, phone string) (map[string]any, error) {
 	payload := map[string]any{
@@ -0,0 +1,48 @@
+package yookassa
+
+import "net"
+
+// События HTTP-уведомлений, которые обрабатывает бот
+const (
+	EventPaymentSucceeded    = "payment.succeeded"
+	EventPaymentCanceled     = "payment.canceled"
+	EventRefundSucceeded     = "refund.succeeded"
+	EventPaymentMethodActive = "payment_method.active"
+)
+
+// notificationNetworks адреса, с которых YooKassa отправляет HTTP-уведомления
+// (https://yookassa.ru/developers/using-api/webhooks#ip)
+var notificationNetworks = mustParseCIDRs(
+	"185.71.76.0/27",
+	"185.71.77.0/27",
+	"77.75.153.0/25",
+	"77.75.156.11/32",
+	"77.75.156.35/32",
+	"77.75.154.128/25",
+	"2a02:5180::/32",
+)
+
+// IsNotificationIP проверяет, входит ли адрес в опубликованные диапазоны YooKassa
+func IsNotificationIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range notificationNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,237 @@
+package yookassa
+
+import (
+	"ai_tg_writer/internal/domain"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Provider платежный провайдер YooKassa: платежи по ссылке, автопродление по сохраненному
+// способу оплаты, возвраты с чеками и уведомления с опубликованных адресов
+type Provider struct {
+	client     *Client
+	allowAnyIP bool // YK_WEBHOOK_ALLOW_ANY_IP=true отключает проверку адреса (только для отладки)
+}
+
+// NewProvider создает провайдера поверх клиента API
+func NewProvider(client *Client) *Provider {
+	return &Provider{
+		client:     client,
+		allowAnyIP: os.Getenv("YK_WEBHOOK_ALLOW_ANY_IP") == "true",
+	}
+}
+
+// Name возвращает имя провайдера в журнале платежей
+func (p *Provider) Name() string {
+	return domain.PaymentProviderYooKassa
+}
+
+// CreatePayment создает платеж с переходом на страницу оплаты. Первая оплата подписки
+// и привязка карты для пробного периода сохраняют способ оплаты, остальные платежи разовые.
+func (p *Provider) CreatePayment(req *domain.PaymentRequest) (*domain.ProviderPayment, error) {
+	amount := toAmount(req.Amount, req.Currency)
+	metadata := paymentMetadata(req.UserID, req.SubscriptionID, req.Kind, req.Metadata)
+	contact := ReceiptCustomer{Email: req.Email}
+
+	var (
+		payment map[string]any
+		err     error
+	)
+	if req.Kind == domain.PaymentKindInitial || req.Kind == domain.PaymentKindTrial {
+		// customer.id — Telegram ID пользователя: к нему привязывается сохраненный способ оплаты
+		payment, err = p.client.CreateInitialPayment(req.IdempotencyKey, amount, req.Description,
+			strconv.FormatInt(req.UserID, 10), req.ReturnURL, contact, metadata)
+	} else {
+		payment, err = p.client.CreateOneTimePayment(req.IdempotencyKey, amount, req.Description,
+			req.ReturnURL, contact, metadata)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := providerPayment(payment)
+	if result.Payment == nil {
+		return nil, fmt.Errorf("payment id not found in response")
+	}
+	return result, nil
+}
+
+// ChargeSaved списывает продление по сохраненному способу оплаты
+func (p *Provider) ChargeSaved(req *domain.ChargeRequest) (*domain.ProviderPayment, error) {
+	var subscriptionID *int64
+	if req.SubscriptionID != 0 {
+		subscriptionID = &req.SubscriptionID
+	}
+	payment, err := p.client.CreateRecurringPayment(
+		req.IdempotencyKey,
+		toAmount(req.Amount, req.Currency),
+		req.Description,
+		req.CustomerID,
+		req.PaymentMethodID,
+		ReceiptCustomer{Email: req.Email},
+		paymentMetadata(req.UserID, subscriptionID, domain.PaymentKindRecurring, nil),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return providerPayment(payment), nil
+}
+
+// GetPayment перечитывает платеж из API
+func (p *Provider) GetPayment(providerPaymentID string) (*domain.ProviderPayment, error) {
+	payment, err := p.client.GetPayment(providerPaymentID)
+	if err != nil {
+		return nil, err
+	}
+	return providerPayment(payment), nil
+}
+
+// Refund возвращает сумму платежа; чек возврата оформляется с наименованием исходной услуги
+func (p *Provider) Refund(req *domain.RefundRequest) (*domain.ProviderRefund, error) {
+	refund, err := p.client.CreateRefund(
+		req.IdempotencyKey,
+		req.ProviderPaymentID,
+		toAmount(req.Amount, req.Currency),
+		req.Reason,
+		req.Description,
+		ReceiptCustomer{Email: req.Email},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return providerRefund(refund), nil
+}
+
+// GetRefund перечитывает возврат из API
+func (p *Provider) GetRefund(id string) (*domain.ProviderRefund, error) {
+	refund, err := p.client.GetRefund(id)
+	if err != nil {
+		return nil, err
+	}
+	return providerRefund(refund), nil
+}
+
+// VerifyWebhook принимает уведомления только с адресов YooKassa. Подписи у уведомлений нет,
+// поэтому обработчик все равно перечитывает объект из API.
+func (p *Provider) VerifyWebhook(req *domain.WebhookRequest) error {
+	if p.allowAnyIP || IsNotificationIP(req.SourceIP) {
+		return nil
+	}
+	return fmt.Errorf("notification from untrusted address %s", req.SourceIP)
+}
+
+func toAmount(amount float64, currency string) Amount {
+	if currency == "" {
+		currency = "RUB"
+	}
+	return Amount{Value: fmt.Sprintf("%.2f", amount), Currency: currency}
+}
+
+// paymentMetadata формирует metadata платежа: по ней уведомление сопоставляется
+// с пользователем, подпиской и назначением платежа
+func paymentMetadata(userID int64, subscriptionID *int64, kind string, extra map[string]string) map[string]string {
+	metadata := map[string]string{"tg_user_id": strconv.FormatInt(userID, 10)}
+	for key, value := range extra {
+		metadata[key] = value
+	}
+	if subscriptionID != nil {
+		metadata["subscription_id"] = strconv.FormatInt(*subscriptionID, 10)
+	}
+	switch kind {
+	case domain.PaymentKindRecurring:
+		metadata["type"] = "recurring"
+	case domain.PaymentKindCreditPack, domain.PaymentKindTrial, domain.PaymentKindGift:
+		metadata["kind"] = kind
+	}
+	return metadata
+}
+
+// providerPayment переводит объект платежа YooKassa в состояние платежа у провайдера
+func providerPayment(payment map[string]any) *domain.ProviderPayment {
+	result := &domain.ProviderPayment{Payment: paymentRecord(payment)}
+	if conf, ok := payment["confirmation"].(map[string]any); ok {
+		result.ConfirmationURL, _ = conf["confirmation_url"].(string)
+	}
+	if method, ok := payment["payment_method"].(map[string]any); ok {
+		result.PaymentMethodID, _ = method["id"].(string)
+	}
+	return result
+}
+
+// paymentRecord переводит объект платежа YooKassa в запись журнала.
+// Возвращает nil, если в платеже нет ID или пользователя из metadata.
+func paymentRecord(payment map[string]any) *domain.Payment {
+	id, _ := payment["id"].(string)
+	meta, _ := payment["metadata"].(map[string]any)
+	tgUser, _ := meta["tg_user_id"].(string)
+	userID, err := strconv.ParseInt(tgUser, 10, 64)
+	if id == "" || err != nil {
+		return nil
+	}
+
+	record := &domain.Payment{
+		UserID:            userID,
+		Provider:          domain.PaymentProviderYooKassa,
+		ProviderPaymentID: &id,
+		Kind:              domain.PaymentKindInitial,
+		Status:            domain.PaymentStatusPending,
+	}
+	if kind, _ := meta["kind"].(string); kind == domain.PaymentKindCreditPack || kind == domain.PaymentKindTrial || kind == domain.PaymentKindGift {
+		record.Kind = kind
+	} else if paymentType, _ := meta["type"].(string); paymentType == "recurring" {
+		record.Kind = domain.PaymentKindRecurring
+	}
+	if raw, _ := meta["subscription_id"].(string); raw != "" {
+		if subscriptionID, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			record.SubscriptionID = &subscriptionID
+		}
+	}
+	if amount, ok := payment["amount"].(map[string]any); ok {
+		if value, ok := amount["value"].(string); ok {
+			record.Amount, _ = strconv.ParseFloat(value, 64)
+		}
+		record.Currency, _ = amount["currency"].(string)
+	}
+
+	record.ReceiptStatus, _ = payment["receipt_registration"].(string)
+
+	status, _ := payment["status"].(string)
+	switch status {
+	case "succeeded":
+		record.Status = domain.PaymentStatusSucceeded
+		// Частично возвращенный платеж остается оплаченным
+		if refunded, ok := payment["refunded_amount"].(map[string]any); ok {
+			if value, _ := refunded["value"].(string); value != "" {
+				if amount, err := strconv.ParseFloat(value, 64); err == nil && amount > 0 && amount >= record.Amount {
+					record.Status = domain.PaymentStatusRefunded
+				}
+			}
+		}
+	case "canceled":
+		record.Status = domain.PaymentStatusFailed
+		record.FailureReason = "canceled"
+		if details, ok := payment["cancellation_details"].(map[string]any); ok {
+			if reason, ok := details["reason"].(string); ok && reason != "" {
+				record.FailureReason = reason
+			}
+		}
+	}
+	return record
+}
+
+// providerRefund переводит объект возврата YooKassa в состояние возврата у провайдера
+func providerRefund(refund map[string]any) *domain.ProviderRefund {
+	result := &domain.ProviderRefund{}
+	result.ID, _ = refund["id"].(string)
+	result.ProviderPaymentID, _ = refund["payment_id"].(string)
+	result.Reason, _ = refund["description"].(string)
+	status, _ := refund["status"].(string)
+	result.Status = domain.RefundStatus(status)
+	if amount, ok := refund["amount"].(map[string]any); ok {
+		if value, ok := amount["value"].(string); ok {
+			result.Amount, _ = strconv.ParseFloat(value, 64)
+		}
+		result.Currency, _ = amount["currency"].(string)
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package yookassa
+
+import (
+	"testing"
+
+	"ai_tg_writer/internal/domain"
+)
+
+func TestPaymentRecord(t *testing.T) {
+	record := paymentRecord(map[string]any{
+		"id":     "2d1c-recurring",
+		"status": "canceled",
+		"amount": map[string]any{"value": "990.00", "currency": "RUB"},
+		"metadata": map[string]any{
+			"tg_user_id":      "42",
+			"subscription_id": "7",
+			"type":            "recurring",
+		},
+		"cancellation_details": map[string]any{"party": "payment_network", "reason": "insufficient_funds"},
+	})
+	if record == nil {
+		t.Fatal("Ожидалась запись журнала")
+	}
+	if record.UserID != 42 || record.Kind != domain.PaymentKindRecurring || record.Amount != 990 {
+		t.Errorf("Неверно разобран платеж: %+v", record)
+	}
+	if record.SubscriptionID == nil || *record.SubscriptionID != 7 {
+		t.Errorf("Ожидалась привязка к подписке 7: %+v", record.SubscriptionID)
+	}
+	if record.Status != domain.PaymentStatusFailed || record.FailureReason != "insufficient_funds" {
+		t.Errorf("Ожидался отказ с причиной insufficient_funds: %s, %q", record.Status, record.FailureReason)
+	}
+
+	// Пакет кредитов определяется по metadata.kind
+	record = paymentRecord(map[string]any{
+		"id":                   "2d1c-credits",
+		"status":               "succeeded",
+		"receipt_registration": "canceled",
+		"metadata":             map[string]any{"tg_user_id": "42", "kind": domain.PaymentKindCreditPack},
+	})
+	if record == nil || record.Kind != domain.PaymentKindCreditPack || record.Status != domain.PaymentStatusSucceeded {
+		t.Errorf("Ожидался успешный платеж за пакет кредитов: %+v", record)
+	}
+	// Статус чека хранится отдельно от статуса оплаты
+	if record != nil && record.ReceiptStatus != "canceled" {
+		t.Errorf("Ожидался незарегистрированный чек, получено %q", record.ReceiptStatus)
+	}
+
+	// Без пользователя в metadata платеж не записывается
+	if record := paymentRecord(map[string]any{"id": "2d1c", "status": "succeeded"}); record != nil {
+		t.Errorf("Платеж без tg_user_id не должен попадать в журнал: %+v", record)
+	}
+}
@@ -0,0 +1,106 @@
+package yookassa
+
+import (
+	"os"
+	"strconv"
+)
+
+// Статусы регистрации чека в объекте платежа (поле receipt_registration)
+const (
+	ReceiptPending   = "pending"
+	ReceiptSucceeded = "succeeded"
+	ReceiptCanceled  = "canceled"
+)
+
+// ReceiptSettings налоговые настройки чеков 54-ФЗ, передаваемых вместе с платежами и возвратами
+type ReceiptSettings struct {
+	Enabled        bool   // YK_RECEIPT_ENABLED=false отключает передачу чеков (магазин без онлайн-кассы)
+	VATCode        int    // YK_RECEIPT_VAT_CODE: 1 — без НДС, 2 — 0%, 3 — 10%, 4 — 20%, 5 — 10/110, 6 — 20/120
+	TaxSystemCode  int    // YK_RECEIPT_TAX_SYSTEM_CODE: система налогообложения 1–6, 0 — не передавать
+	PaymentSubject string // YK_RECEIPT_PAYMENT_SUBJECT: признак предмета расчета
+	PaymentMode    string // YK_RECEIPT_PAYMENT_MODE: признак способа расчета
+	FallbackEmail  string // YK_RECEIPT_EMAIL: адрес для чека, если пользователь не указал свой
+}
+
+// ReceiptCustomer контакты покупателя, на которые отправляется чек
+type ReceiptCustomer struct {
+	Email string
+	Phone string
+}
+
+// receiptSettingsFromEnv читает налоговые настройки чеков из окружения
+func receiptSettingsFromEnv() ReceiptSettings {
+	return ReceiptSettings{
+		Enabled:        os.Getenv("YK_RECEIPT_ENABLED") != "false",
+		VATCode:        envInt("YK_RECEIPT_VAT_CODE", 1),
+		TaxSystemCode:  envInt("YK_RECEIPT_TAX_SYSTEM_CODE", 0),
+		PaymentSubject: envString("YK_RECEIPT_PAYMENT_SUBJECT", "service"),
+		PaymentMode:    envString("YK_RECEIPT_PAYMENT_MODE", "full_payment"),
+		FallbackEmail:  envString("YK_RECEIPT_EMAIL", "noreply@aiwhisper.ru"),
+	}
+}
+
+// receipt формирует чек с одной позицией на всю сумму. Возвращает nil, если чеки отключены.
+func (s ReceiptSettings) receipt(description string, amount Amount, customer ReceiptCustomer) map[string]any {
+	if !s.Enabled {
+		return nil
+	}
+	contact := map[string]string{}
+	if customer.Email != "" {
+		contact["email"] = customer.Email
+	}
+	if customer.Phone != "" {
+		contact["phone"] = customer.Phone
+	}
+	if len(contact) == 0 {
+		contact["email"] = s.FallbackEmail
+	}
+
+	receipt := map[string]any{
+		"customer": contact,
+		"items": []map[string]any{
+			{
+				"description":     truncateRunes(description, 128), // Ограничение длины наименования в чеке
+				"amount":          map[string]string{"value": amount.Value, "currency": amount.Currency},
+				"vat_code":        s.VATCode,
+				"quantity":        "1",
+				"payment_subject": s.PaymentSubject,
+				"payment_mode":    s.PaymentMode,
+			},
+		},
+	}
+	if s.TaxSystemCode > 0 {
+		receipt["tax_system_code"] = s.TaxSystemCode
+	}
+	return receipt
+}
+
+// withReceipt добавляет чек в тело запроса, если чеки включены
+func (c *Client) withReceipt(payload map[string]any, description string, amount Amount, customer ReceiptCustomer) map[string]any {
+	if receipt := c.Receipt.receipt(description, amount, customer); receipt != nil {
+		payload["receipt"] = receipt
+	}
+	return payload
+}
+
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return n
+	}
+	return def
+}
+
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit])
+}
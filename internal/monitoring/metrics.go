@@ -678,6 +678,111 @@ func RecordProcessingStep(step, status string) {
 	processingSteps.WithLabelValues(step, status).Inc()
 }
 
+// ===== МЕТРИКИ ДОСТАВКИ В TELEGRAM =====
+
+var (
+	// Telegram Rate Limited - ответы 429 от Telegram
+	telegramRateLimited = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_rate_limited_total",
+			Help: "Total number of 429 responses from Telegram",
+		},
+		[]string{"priority"}, // interactive, bulk
+	)
+
+	// Bot Block Events - блокировки и разблокировки бота пользователями
+	botBlockEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_bot_block_events_total",
+			Help: "Total number of users blocking or unblocking the bot",
+		},
+		[]string{"event", "source"}, // blocked, unblocked; update, send_error, message
+	)
+
+	// Broadcast Messages - сообщения рассылок по статусу доставки
+	broadcastMessages = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_broadcast_messages_total",
+			Help: "Total number of broadcast messages by delivery status",
+		},
+		[]string{"status"}, // sent, failed, blocked
+	)
+
+	// Quota Consumption - списания и возвраты квот по ресурсам
+	quotaConsumption = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "quota_consumption_total",
+			Help: "Total number of quota operations by resource and result",
+		},
+		[]string{"resource", "result"}, // generations, rewrites, edits, audio_minutes; consumed, rejected, refunded
+	)
+)
+
+func RecordTelegramRateLimited(priority string) {
+	telegramRateLimited.WithLabelValues(priority).Inc()
+}
+
+func RecordBotBlockEvent(event, source string) {
+	botBlockEvents.WithLabelValues(event, source).Inc()
+}
+
+func RecordBroadcastMessage(status string) {
+	broadcastMessages.WithLabelValues(status).Inc()
+}
+
+func RecordQuotaConsumption(resource, result string, amount int) {
+	quotaConsumption.WithLabelValues(resource, result).Add(float64(amount))
+}
+
+// ===== МЕТРИКИ ИСТОЧНИКОВ ПРИВЛЕЧЕНИЯ =====
+
+var (
+	// Acquisition Signups - новые пользователи по источнику первого касания
+	acquisitionSignups = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "acquisition_signups_total",
+			Help: "Total number of users attributed to an acquisition source on first /start",
+		},
+		[]string{"source"}, // organic, campaign, referral, gift
+	)
+
+	// Acquisition Funnel - пользователи источника на этапах воронки за все время
+	acquisitionFunnel = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "acquisition_funnel_users",
+			Help: "Number of users by acquisition source, campaign and funnel stage",
+		},
+		[]string{"source", "campaign", "stage"}, // stage: signup, first_generation, first_payment
+	)
+
+	// Acquisition Revenue - выручка от пользователей источника за все время
+	acquisitionRevenue = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "acquisition_revenue_rubles",
+			Help: "Succeeded payments of users by acquisition source and campaign",
+		},
+		[]string{"source", "campaign"},
+	)
+)
+
+func RecordAcquisitionSignup(source string) {
+	acquisitionSignups.WithLabelValues(source).Inc()
+}
+
+// ResetAcquisitionFunnel удаляет значения воронки перед обновлением, чтобы не оставались выбывшие кампании
+func ResetAcquisitionFunnel() {
+	acquisitionFunnel.Reset()
+	acquisitionRevenue.Reset()
+}
+
+func SetAcquisitionFunnel(source, campaign, stage string, users int) {
+	acquisitionFunnel.WithLabelValues(source, campaign, stage).Set(float64(users))
+}
+
+func SetAcquisitionRevenue(source, campaign string, revenue float64) {
+	acquisitionRevenue.WithLabelValues(source, campaign).Set(revenue)
+}
+
 // InitMetrics инициализирует все метрики
 func InitMetrics() {
 	// Метрики уже зарегистрированы при импорте пакета благодаря promauto
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/monitoring"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+)
+
+// acquisitionMetricCampaigns сколько кампаний с наибольшим числом пользователей экспортируется
+// в метрики; остальные суммируются в кампанию acquisitionOtherCampaign. Название кампании
+// приходит из ссылки, и без ограничения число рядов метрик росло бы неконтролируемо.
+const acquisitionMetricCampaigns = 50
+
+// acquisitionOtherCampaign кампания в метриках для кампаний за пределами acquisitionMetricCampaigns
+const acquisitionOtherCampaign = "other"
+
+// Этапы воронки в метриках
+const (
+	acquisitionStageSignup          = "signup"
+	acquisitionStageFirstGeneration = "first_generation"
+	acquisitionStageFirstPayment    = "first_payment"
+)
+
+// AcquisitionService сохраняет источник первого касания пользователей и строит по ним воронку
+type AcquisitionService struct {
+	repo domain.AcquisitionRepository
+	now  func() time.Time
+}
+
+// NewAcquisitionService создает сервис источников привлечения
+func NewAcquisitionService(repo domain.AcquisitionRepository) *AcquisitionService {
+	return &AcquisitionService{repo: repo, now: time.Now}
+}
+
+// Record сохраняет источник, если пользователь открыл бота впервые. Повторные переходы
+// по другим ссылкам источник не меняют.
+func (s *AcquisitionService) Record(userID int64, acquisition domain.Acquisition) (bool, error) {
+	recorded, err := s.repo.Record(userID, acquisition, s.now())
+	if err != nil {
+		return false, fmt.Errorf("record acquisition: %w", err)
+	}
+	if recorded {
+		monitoring.RecordAcquisitionSignup(acquisition.Source)
+		if acquisition.Campaign != "" {
+			log.Printf("📣 User %d acquired via %s %q", userID, acquisition.Source, acquisition.Campaign)
+		} else {
+			log.Printf("📣 User %d acquired via %s", userID, acquisition.Source)
+		}
+	}
+	return recorded, nil
+}
+
+// Funnel возвращает воронку по источникам для пользователей, пришедших начиная с since
+func (s *AcquisitionService) Funnel(since time.Time) ([]*domain.AcquisitionStats, error) {
+	funnel, err := s.repo.GetFunnel(since)
+	if err != nil {
+		return nil, fmt.Errorf("get acquisition funnel: %w", err)
+	}
+	return funnel, nil
+}
+
+// RefreshMetrics обновляет метрики воронки по всем пользователям
+func (s *AcquisitionService) RefreshMetrics() error {
+	funnel, err := s.Funnel(time.Time{})
+	if err != nil {
+		return err
+	}
+
+	monitoring.ResetAcquisitionFunnel()
+	for _, stats := range limitCampaigns(funnel, acquisitionMetricCampaigns) {
+		monitoring.SetAcquisitionFunnel(stats.Source, stats.Campaign, acquisitionStageSignup, stats.Signups)
+		monitoring.SetAcquisitionFunnel(stats.Source, stats.Campaign, acquisitionStageFirstGeneration, stats.FirstGeneration)
+		monitoring.SetAcquisitionFunnel(stats.Source, stats.Campaign, acquisitionStageFirstPayment, stats.FirstPayment)
+		monitoring.SetAcquisitionRevenue(stats.Source, stats.Campaign, stats.Revenue)
+	}
+	return nil
+}
+
+// limitCampaigns оставляет не больше limit кампаний (воронка отсортирована по числу пользователей),
+// остальные суммирует в acquisitionOtherCampaign
+func limitCampaigns(funnel []*domain.AcquisitionStats, limit int) []*domain.AcquisitionStats {
+	var limited []*domain.AcquisitionStats
+	var other *domain.AcquisitionStats
+	campaigns := 0
+	for _, stats := range funnel {
+		if stats.Source != domain.AcquisitionCampaign {
+			limited = append(limited, stats)
+			continue
+		}
+		if campaigns < limit {
+			campaigns++
+			limited = append(limited, stats)
+			continue
+		}
+		if other == nil {
+			other = &domain.AcquisitionStats{Acquisition: domain.Acquisition{
+				Source: domain.AcquisitionCampaign, Campaign: acquisitionOtherCampaign}}
+			limited = append(limited, other)
+		}
+		other.Signups += stats.Signups
+		other.FirstGeneration += stats.FirstGeneration
+		other.FirstPayment += stats.FirstPayment
+		other.Revenue += stats.Revenue
+	}
+	return limited
+}
+
+// WriteAcquisitionCSV выгружает воронку в CSV для маркетинга
+func WriteAcquisitionCSV(w io.Writer, funnel []*domain.AcquisitionStats) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"source", "campaign", "signups", "first_generation", "first_payment", "revenue"}); err != nil {
+		return err
+	}
+	for _, stats := range funnel {
+		record := []string{
+			stats.Source,
+			stats.Campaign,
+			strconv.Itoa(stats.Signups),
+			strconv.Itoa(stats.FirstGeneration),
+			strconv.Itoa(stats.FirstPayment),
+			strconv.FormatFloat(stats.Revenue, 'f', 2, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
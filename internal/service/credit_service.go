@@ -0,0 +1,122 @@
+package service
+
+import (
+	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/monitoring"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// CreditService продает пакеты кредитов разовым платежом и показывает баланс пользователя
+type CreditService struct {
+	repo      domain.CreditRepository
+	payments  domain.PaymentRepository
+	contacts  domain.UserContactRepository
+	providers *PaymentProviders
+	now       func() time.Time
+}
+
+// NewCreditService создает сервис пакетов кредитов
+func NewCreditService(repo domain.CreditRepository, payments domain.PaymentRepository, contacts domain.UserContactRepository, providers *PaymentProviders) *CreditService {
+	return &CreditService{
+		repo:      repo,
+		payments:  payments,
+		contacts:  contacts,
+		providers: providers,
+		now:       time.Now,
+	}
+}
+
+// GetPacks возвращает пакеты, доступные для покупки
+func (s *CreditService) GetPacks() ([]*domain.CreditPack, error) {
+	return s.repo.GetVisiblePacks()
+}
+
+// CreatePurchaseLink создает покупку пакета и возвращает ссылку на разовую оплату.
+// Способ оплаты не сохраняется: пакет не продлевается автоматически.
+func (s *CreditService) CreatePurchaseLink(userID int64, packID string) (string, error) {
+	pack, err := s.repo.GetPack(packID)
+	if err != nil {
+		return "", fmt.Errorf("get credit pack: %w", err)
+	}
+	if pack == nil || !pack.Visible {
+		return "", fmt.Errorf("credit pack %s is not available", packID)
+	}
+	provider, err := s.providers.ForUser(userID, nil)
+	if err != nil {
+		return "", err
+	}
+
+	purchase := &domain.CreditPurchase{
+		UserID:   userID,
+		PackID:   pack.ID,
+		Credits:  pack.Credits,
+		Amount:   pack.Price,
+		Currency: pack.Currency,
+		Status:   domain.CreditPurchasePending,
+	}
+	if err := s.repo.CreatePurchase(purchase); err != nil {
+		return "", fmt.Errorf("create credit purchase: %w", err)
+	}
+
+	idem := fmt.Sprintf("credits-%d", purchase.ID)
+	payment, err := provider.CreatePayment(&domain.PaymentRequest{
+		UserID:         userID,
+		Kind:           domain.PaymentKindCreditPack,
+		Amount:         pack.Price,
+		Currency:       pack.Currency,
+		Description:    fmt.Sprintf("Пакет «%s» AI TG Writer", pack.Name),
+		IdempotencyKey: idem,
+		ReturnURL:      getenv("YK_RETURN_URL_ADDRESS", ""),
+		Email:          receiptEmail(s.contacts, userID),
+		Metadata:       map[string]string{"purchase_id": strconv.FormatInt(purchase.ID, 10)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("create one-time payment: %w", err)
+	}
+
+	if payment.Payment == nil || payment.Payment.ProviderPaymentID == nil {
+		return "", fmt.Errorf("payment id not found in response")
+	}
+	paymentID := *payment.Payment.ProviderPaymentID
+	if err := s.repo.SetPurchasePaymentID(purchase.ID, paymentID); err != nil {
+		return "", fmt.Errorf("save payment id: %w", err)
+	}
+	payment.Payment.IdempotencyKey = idem
+	recordPayment(s.payments, payment.Payment)
+
+	if payment.ConfirmationURL == "" {
+		return "", fmt.Errorf("confirmation_url not found")
+	}
+	log.Printf("💳 Credit purchase %d created for user %d via %s: pack=%s, payment=%s",
+		purchase.ID, userID, provider.Name(), pack.ID, paymentID)
+	return payment.ConfirmationURL, nil
+}
+
+// CompletePurchase зачисляет кредиты по успешному платежу. Возвращает nil,
+// если платеж уже был обработан, — повторный вебхук не меняет баланс.
+func (s *CreditService) CompletePurchase(provider, paymentID string) (*domain.CreditPurchase, error) {
+	purchase, credited, err := s.repo.MarkPurchasePaid(paymentID, s.now())
+	if err != nil {
+		return nil, fmt.Errorf("mark credit purchase paid: %w", err)
+	}
+	if !credited {
+		log.Printf("ℹ️ Credit purchase for payment %s already processed or not found", paymentID)
+		return nil, nil
+	}
+	monitoring.RecordPayment("success", provider, purchase.Amount, purchase.PaidAt.Sub(purchase.CreatedAt))
+	log.Printf("✅ Credited %d credits to user %d (purchase %d)", purchase.Credits, purchase.UserID, purchase.ID)
+	return purchase, nil
+}
+
+// Balance возвращает действующий остаток кредитов пользователя
+func (s *CreditService) Balance(userID int64) (*domain.CreditBalance, error) {
+	return s.repo.GetBalance(userID, s.now())
+}
+
+// History возвращает последние покупки пакетов пользователя
+func (s *CreditService) History(userID int64, limit int) ([]*domain.CreditPurchase, error) {
+	return s.repo.GetUserPurchases(userID, limit)
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"ai_tg_writer/internal/config"
+	"ai_tg_writer/internal/domain"
+	"fmt"
+	"time"
+)
+
+// entitlementSubscriptions источник текущей подписки пользователя
+type entitlementSubscriptions interface {
+	GetUserSubscription(userID int64) (*domain.Subscription, error)
+}
+
+// grantedPremiumSource источник Premium, выданного администратором
+type grantedPremiumSource interface {
+	GetPremiumUntil(userID int64) (*time.Time, error)
+}
+
+// EntitlementService отвечает на вопрос «что пользователю доступно сейчас и до какого времени».
+// Бот, API, движок квот и воркер определяют доступ только через него, чтобы grace period
+// и правила отмененных подписок везде совпадали.
+type EntitlementService struct {
+	subs        entitlementSubscriptions
+	granted     grantedPremiumSource // nil — выданный Premium не учитывается
+	gracePeriod time.Duration
+	now         func() time.Time
+}
+
+// NewEntitlementService создает сервис прав доступа; grace period берется из GRACE_PERIOD_DAYS
+func NewEntitlementService(subs entitlementSubscriptions, granted grantedPremiumSource, cfg *config.Config) *EntitlementService {
+	return &EntitlementService{
+		subs:        subs,
+		granted:     granted,
+		gracePeriod: cfg.GracePeriod(),
+		now:         time.Now,
+	}
+}
+
+// Get возвращает права пользователя на текущий момент. Выданный администратором Premium
+// важнее подписки, но сведения о подписке сохраняются для экранов бота.
+func (s *EntitlementService) Get(userID int64) (*domain.Entitlement, error) {
+	subscription, err := s.subs.GetUserSubscription(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting subscription: %w", err)
+	}
+	entitlement := s.ForSubscription(subscription)
+
+	if s.granted != nil {
+		until, err := s.granted.GetPremiumUntil(userID)
+		if err != nil {
+			return nil, fmt.Errorf("error getting granted premium: %w", err)
+		}
+		if until != nil && s.now().Before(*until) {
+			entitlement.Source = domain.EntitlementGranted
+			entitlement.TariffID = domain.PremiumTariffID
+			entitlement.Since = nil
+			entitlement.Until = until
+		}
+	}
+	return entitlement, nil
+}
+
+// ForSubscription возвращает права, которые дает подписка на текущий момент
+func (s *EntitlementService) ForSubscription(subscription *domain.Subscription) *domain.Entitlement {
+	return subscriptionEntitlement(subscription, s.gracePeriod, s.now())
+}
+
+// subscriptionPeriodStart возвращает начало оплаченного периода: последнюю оплату (активацию,
+// продление или начало пробного периода), чтобы периоды квот совпадали с датами списаний.
+// Запись подписки создается до оплаты, поэтому дата создания — только запасной вариант.
+func subscriptionPeriodStart(subscription *domain.Subscription) *time.Time {
+	start := subscription.LastPayment
+	if start.IsZero() {
+		start = subscription.CreatedAt
+	}
+	return &start
+}
+
+// subscriptionEntitlement возвращает права, которые дает подписка в момент now:
+//   - active — до даты списания, а если продление не прошло — еще grace period после нее;
+//   - пробный период — до даты первого списания, без grace period;
+//   - cancelled — до конца оплаченного периода, без продления;
+//   - остальные статусы и неактивная подписка доступа не дают.
+func subscriptionEntitlement(subscription *domain.Subscription, gracePeriod time.Duration, now time.Time) *domain.Entitlement {
+	free := &domain.Entitlement{Source: domain.EntitlementFree, TariffID: domain.FreeTariffID, Subscription: subscription}
+	if subscription == nil {
+		return free
+	}
+	free.Status = domain.SubscriptionStatus(subscription.Status)
+	if !subscription.Active {
+		return free
+	}
+
+	paid := &domain.Entitlement{
+		Source:       domain.EntitlementSubscription,
+		TariffID:     subscription.Tariff,
+		Status:       free.Status,
+		Since:        subscriptionPeriodStart(subscription),
+		Subscription: subscription,
+	}
+	if !subscription.NextPayment.IsZero() {
+		until := subscription.NextPayment
+		paid.Until = &until
+	}
+
+	switch free.Status {
+	case domain.SubscriptionStatusActive:
+		paid.AutoRenew = subscription.YKPaymentMethodID != nil
+		if subscription.InTrial() {
+			// Пробный период не оплачен: если первое списание не прошло, доступ сразу заканчивается
+			paid.Source = domain.EntitlementTrial
+			if paid.Until == nil || now.Before(*paid.Until) {
+				return paid
+			}
+			return free
+		}
+		if paid.Until == nil || now.Before(*paid.Until) {
+			return paid
+		}
+		graceUntil := paid.Until.Add(gracePeriod)
+		if now.Before(graceUntil) {
+			paid.Source = domain.EntitlementGrace
+			paid.Until = &graceUntil
+			return paid
+		}
+	case domain.SubscriptionStatusCancelled:
+		if paid.Until != nil && now.Before(*paid.Until) {
+			return paid
+		}
+	}
+	return free
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"ai_tg_writer/internal/config"
+	"ai_tg_writer/internal/domain"
+)
+
+type stubGrantedPremium struct {
+	until *time.Time
+}
+
+func (g *stubGrantedPremium) GetPremiumUntil(userID int64) (*time.Time, error) {
+	return g.until, nil
+}
+
+func TestEntitlementForSubscription(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	paidUntil := now.Add(48 * time.Hour)
+	overdue := now.Add(-24 * time.Hour)         // Продление не прошло сутки назад: внутри grace period
+	longOverdue := now.Add(-4 * 24 * time.Hour) // Grace period в 3 дня уже закончился
+	method := "pm_1"
+
+	subscription := func(status domain.SubscriptionStatus, active bool, nextPayment time.Time) *domain.Subscription {
+		return &domain.Subscription{Tariff: "premium", Status: string(status), Active: active,
+			NextPayment: nextPayment, YKPaymentMethodID: &method}
+	}
+
+	trial := func(nextPayment time.Time) *domain.Subscription {
+		sub := subscription(domain.SubscriptionStatusActive, true, nextPayment)
+		sub.LastPayment = nextPayment.Add(-7 * 24 * time.Hour)
+		sub.TrialEndsAt = &nextPayment
+		return sub
+	}
+
+	// Пауза сдвигает следующее списание на остаток оплаченного периода после ее окончания
+	paused := func(pauseUntil time.Time) *domain.Subscription {
+		sub := subscription(domain.SubscriptionStatusPaused, true, pauseUntil.Add(20*24*time.Hour))
+		pausedAt := pauseUntil.Add(-14 * 24 * time.Hour)
+		sub.PausedAt, sub.PauseUntil = &pausedAt, &pauseUntil
+		return sub
+	}
+
+	cases := []struct {
+		name         string
+		subscription *domain.Subscription
+		source       domain.EntitlementSource
+		until        *time.Time
+		autoRenew    bool
+	}{
+		{"без подписки", nil, domain.EntitlementFree, nil, false},
+		{"ожидает оплаты", subscription(domain.SubscriptionStatusPending, true, paidUntil), domain.EntitlementFree, nil, false},
+		{"активна", subscription(domain.SubscriptionStatusActive, true, paidUntil), domain.EntitlementSubscription, &paidUntil, true},
+		{"продление в grace period", subscription(domain.SubscriptionStatusActive, true, overdue),
+			domain.EntitlementGrace, timePtr(overdue.Add(3 * 24 * time.Hour)), true},
+		{"grace period закончился", subscription(domain.SubscriptionStatusActive, true, longOverdue), domain.EntitlementFree, nil, false},
+		{"неактивная запись", subscription(domain.SubscriptionStatusActive, false, paidUntil), domain.EntitlementFree, nil, false},
+		{"отменена, период оплачен", subscription(domain.SubscriptionStatusCancelled, true, paidUntil),
+			domain.EntitlementSubscription, &paidUntil, false},
+		{"отменена, период закончился", subscription(domain.SubscriptionStatusCancelled, true, overdue), domain.EntitlementFree, nil, false},
+		{"истекла", subscription(domain.SubscriptionStatusExpired, false, paidUntil), domain.EntitlementFree, nil, false},
+		{"приостановлена", subscription(domain.SubscriptionStatusSuspended, true, paidUntil), domain.EntitlementFree, nil, false},
+		{"пробный период", trial(paidUntil), domain.EntitlementTrial, &paidUntil, true},
+		{"пробный период без оплаты", trial(overdue), domain.EntitlementFree, nil, false},
+		{"на паузе", paused(paidUntil), domain.EntitlementFree, nil, false},
+		{"пауза закончилась, не возобновлена", paused(overdue), domain.EntitlementFree, nil, false},
+	}
+
+	entitlements := NewEntitlementService(nil, nil, &config.Config{GracePeriodDays: 3})
+	entitlements.now = func() time.Time { return now }
+	for _, tc := range cases {
+		entitlement := entitlements.ForSubscription(tc.subscription)
+		if entitlement.Source != tc.source {
+			t.Errorf("%s: ожидался доступ %s, получен %s", tc.name, tc.source, entitlement.Source)
+			continue
+		}
+		if tc.until != nil && (entitlement.Until == nil || !entitlement.Until.Equal(*tc.until)) {
+			t.Errorf("%s: ожидался доступ до %v, получено %v", tc.name, *tc.until, entitlement.Until)
+		}
+		if tc.until == nil && entitlement.Until != nil {
+			t.Errorf("%s: бесплатный доступ не должен иметь срока, получено %v", tc.name, *entitlement.Until)
+		}
+		if entitlement.AutoRenew != tc.autoRenew {
+			t.Errorf("%s: ожидалось автопродление %v", tc.name, tc.autoRenew)
+		}
+		if entitlement.IsPremium() != (tc.source != domain.EntitlementFree) {
+			t.Errorf("%s: IsPremium не совпадает с источником доступа", tc.name)
+		}
+	}
+}
+
+func TestEntitlementGrantedPremium(t *testing.T) {
+	now := time.Now()
+	subs := &stubQuotaSubscriptions{subscription: &domain.Subscription{
+		Tariff: "premium_week", Status: string(domain.SubscriptionStatusCancelled), Active: true, NextPayment: now.Add(-time.Hour)}}
+	granted := &stubGrantedPremium{until: timePtr(now.Add(24 * time.Hour))}
+	entitlements := NewEntitlementService(subs, granted, &config.Config{GracePeriodDays: 3})
+
+	entitlement, err := entitlements.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entitlement.Source != domain.EntitlementGranted || entitlement.TariffID != domain.PremiumTariffID {
+		t.Errorf("Выданный Premium важнее истекшей подписки: %+v", entitlement)
+	}
+	if entitlement.Status != domain.SubscriptionStatusCancelled {
+		t.Errorf("Статус подписки должен сохраниться для экранов бота: %s", entitlement.Status)
+	}
+
+	granted.until = timePtr(now.Add(-time.Minute))
+	if entitlement, _ := entitlements.Get(1); entitlement.IsPremium() {
+		t.Errorf("Истекший выданный Premium не дает доступа: %+v", entitlement)
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
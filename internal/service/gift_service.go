@@ -0,0 +1,199 @@
+package service
+
+import (
+	"ai_tg_writer/internal/config"
+	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/monitoring"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// giftCodeBytes длина случайной части кода подарка: 5 байт — 8 символов base32
+const giftCodeBytes = 5
+
+// GiftOption вариант подарочного Premium, доступный для покупки
+type GiftOption struct {
+	Months   int
+	Price    float64
+	Currency string
+}
+
+// GiftService продает подарочный Premium разовым платежом и активирует подарки по коду
+type GiftService struct {
+	repo      domain.GiftRepository
+	tariffs   domain.TariffRepository
+	payments  domain.PaymentRepository
+	contacts  domain.UserContactRepository
+	providers *PaymentProviders
+	config    *config.Config
+	now       func() time.Time
+}
+
+// NewGiftService создает сервис подарочных подписок
+func NewGiftService(repo domain.GiftRepository, tariffs domain.TariffRepository, payments domain.PaymentRepository, contacts domain.UserContactRepository, providers *PaymentProviders, cfg *config.Config) *GiftService {
+	return &GiftService{
+		repo:      repo,
+		tariffs:   tariffs,
+		payments:  payments,
+		contacts:  contacts,
+		providers: providers,
+		config:    cfg,
+		now:       time.Now,
+	}
+}
+
+// GiftOptions возвращает варианты подарка: цена — месячный тариф, умноженный на число месяцев.
+// Возвращает пустой список, если подарки выключены, не подключена YooKassa или нет тарифа.
+func (s *GiftService) GiftOptions() ([]GiftOption, error) {
+	if len(s.config.GiftMonthOptions) == 0 || !s.providers.Has(domain.PaymentProviderYooKassa) {
+		return nil, nil
+	}
+	tariff, err := s.tariffs.GetByID(s.config.GiftTariffID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting gift tariff: %w", err)
+	}
+	if tariff == nil {
+		log.Printf("⚠️ Gift tariff %q not found, gifts are disabled", s.config.GiftTariffID)
+		return nil, nil
+	}
+	options := make([]GiftOption, 0, len(s.config.GiftMonthOptions))
+	for _, months := range s.config.GiftMonthOptions {
+		options = append(options, GiftOption{
+			Months:   months,
+			Price:    tariff.Price * float64(months),
+			Currency: tariff.Currency,
+		})
+	}
+	return options, nil
+}
+
+// giftOption возвращает вариант подарка на указанное число месяцев
+func (s *GiftService) giftOption(months int) (*GiftOption, error) {
+	options, err := s.GiftOptions()
+	if err != nil {
+		return nil, err
+	}
+	for _, option := range options {
+		if option.Months == months {
+			return &option, nil
+		}
+	}
+	return nil, fmt.Errorf("gift for %d months is not available", months)
+}
+
+// CreateGiftLink создает подарок и возвращает ссылку на разовую оплату через YooKassa.
+// Код подарка отправляется покупателю после успешной оплаты.
+func (s *GiftService) CreateGiftLink(buyerID int64, months int) (string, error) {
+	option, err := s.giftOption(months)
+	if err != nil {
+		return "", err
+	}
+	provider, err := s.providers.Get(domain.PaymentProviderYooKassa)
+	if err != nil {
+		return "", err
+	}
+	code, err := newGiftCode()
+	if err != nil {
+		return "", err
+	}
+
+	gift := &domain.Gift{
+		Code:     code,
+		BuyerID:  buyerID,
+		Months:   option.Months,
+		Amount:   option.Price,
+		Currency: option.Currency,
+		Status:   domain.GiftStatusPending,
+	}
+	if err := s.repo.Create(gift); err != nil {
+		return "", fmt.Errorf("create gift: %w", err)
+	}
+
+	idem := fmt.Sprintf("gift-%d", gift.ID)
+	payment, err := provider.CreatePayment(&domain.PaymentRequest{
+		UserID:         buyerID,
+		Kind:           domain.PaymentKindGift,
+		Amount:         gift.Amount,
+		Currency:       gift.Currency,
+		Description:    giftReceiptItem,
+		IdempotencyKey: idem,
+		ReturnURL:      getenv("YK_RETURN_URL_ADDRESS", ""),
+		Email:          receiptEmail(s.contacts, buyerID),
+		Metadata:       map[string]string{"gift_id": strconv.FormatInt(gift.ID, 10)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("create gift payment: %w", err)
+	}
+
+	if payment.Payment == nil || payment.Payment.ProviderPaymentID == nil {
+		return "", fmt.Errorf("payment id not found in response")
+	}
+	paymentID := *payment.Payment.ProviderPaymentID
+	if err := s.repo.SetPaymentID(gift.ID, paymentID); err != nil {
+		return "", fmt.Errorf("save payment id: %w", err)
+	}
+	payment.Payment.IdempotencyKey = idem
+	recordPayment(s.payments, payment.Payment)
+
+	if payment.ConfirmationURL == "" {
+		return "", fmt.Errorf("confirmation_url not found")
+	}
+	log.Printf("🎁 Gift %d created for user %d: months=%d, payment=%s", gift.ID, buyerID, gift.Months, paymentID)
+	return payment.ConfirmationURL, nil
+}
+
+// CompleteGiftPurchase отмечает подарок оплаченным. Возвращает nil, если платеж
+// уже был обработан, — повторный вебхук не отправляет код второй раз.
+func (s *GiftService) CompleteGiftPurchase(provider, paymentID string) (*domain.Gift, error) {
+	gift, paid, err := s.repo.MarkPaid(paymentID, s.now())
+	if err != nil {
+		return nil, fmt.Errorf("mark gift paid: %w", err)
+	}
+	if !paid {
+		log.Printf("ℹ️ Gift for payment %s already processed or not found", paymentID)
+		return nil, nil
+	}
+	monitoring.RecordPayment("success", provider, gift.Amount, gift.PaidAt.Sub(gift.CreatedAt))
+	log.Printf("✅ Gift %d paid by user %d: months=%d", gift.ID, gift.BuyerID, gift.Months)
+	return gift, nil
+}
+
+// RedeemGift активирует подарок по коду и продлевает выданный Premium получателя.
+// Возвращает подарок и новую дату окончания Premium.
+func (s *GiftService) RedeemGift(code string, recipientID int64) (*domain.Gift, time.Time, error) {
+	code = NormalizeGiftCode(code)
+	if code == "" {
+		return nil, time.Time{}, domain.ErrGiftNotFound
+	}
+	gift, until, err := s.repo.Redeem(code, recipientID, s.now())
+	if err != nil {
+		if errors.Is(err, domain.ErrGiftNotFound) || errors.Is(err, domain.ErrGiftRedeemed) {
+			return gift, until, err
+		}
+		return nil, until, fmt.Errorf("redeem gift: %w", err)
+	}
+	log.Printf("🎁 Gift %d redeemed by user %d: premium until %s", gift.ID, recipientID, until.Format(time.RFC3339))
+	return gift, until, nil
+}
+
+// NormalizeGiftCode приводит код подарка к виду в базе: без префикса ссылки и в верхнем регистре
+func NormalizeGiftCode(code string) string {
+	code = strings.TrimSpace(code)
+	code = strings.TrimPrefix(code, domain.GiftStartPrefix)
+	return strings.ToUpper(code)
+}
+
+// newGiftCode генерирует случайный код подарка из букв и цифр base32
+func newGiftCode() (string, error) {
+	buf := make([]byte, giftCodeBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate gift code: %w", err)
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
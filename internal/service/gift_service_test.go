@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ai_tg_writer/internal/config"
+	"ai_tg_writer/internal/domain"
+)
+
+type stubGiftRepo struct {
+	domain.GiftRepository
+	gifts map[string]*domain.Gift
+}
+
+func (r *stubGiftRepo) Redeem(code string, recipientID int64, at time.Time) (*domain.Gift, time.Time, error) {
+	gift, ok := r.gifts[code]
+	if !ok || gift.Status == domain.GiftStatusPending {
+		return nil, time.Time{}, domain.ErrGiftNotFound
+	}
+	if gift.Status == domain.GiftStatusRedeemed {
+		return gift, time.Time{}, domain.ErrGiftRedeemed
+	}
+	gift.Status = domain.GiftStatusRedeemed
+	gift.RecipientID = &recipientID
+	return gift, at.AddDate(0, gift.Months, 0), nil
+}
+
+func TestGiftOptionsPricedByMonths(t *testing.T) {
+	tariffs := &stubTariffRepo{tariffs: map[string]*domain.Tariff{
+		"premium": {ID: "premium", Price: 990, Currency: "RUB"},
+	}}
+	providers := NewPaymentProviders(domain.PaymentProviderYooKassa, nil, &stubProvider{name: domain.PaymentProviderYooKassa})
+	cfg := &config.Config{GiftMonthOptions: []int{1, 3}, GiftTariffID: "premium"}
+	gifts := NewGiftService(&stubGiftRepo{}, tariffs, nil, nil, providers, cfg)
+
+	options, err := gifts.GiftOptions()
+	if err != nil {
+		t.Fatalf("GiftOptions: %v", err)
+	}
+	if len(options) != 2 || options[0].Price != 990 || options[1].Price != 2970 {
+		t.Fatalf("unexpected options: %+v", options)
+	}
+
+	// Без YooKassa подарки не продаются: Prodamus не отличает подарок от пакета кредитов
+	prodamusOnly := NewPaymentProviders(domain.PaymentProviderProdamus, nil, &stubProvider{name: domain.PaymentProviderProdamus})
+	if options, _ := NewGiftService(&stubGiftRepo{}, tariffs, nil, nil, prodamusOnly, cfg).GiftOptions(); len(options) != 0 {
+		t.Fatalf("gifts must be disabled without YooKassa, got %+v", options)
+	}
+}
+
+func TestRedeemGiftOnce(t *testing.T) {
+	repo := &stubGiftRepo{gifts: map[string]*domain.Gift{
+		"ABCD2345": {ID: 1, Code: "ABCD2345", BuyerID: 1, Months: 3, Status: domain.GiftStatusPaid},
+	}}
+	gifts := NewGiftService(repo, nil, nil, nil, nil, &config.Config{})
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	gifts.now = func() time.Time { return now }
+
+	// Код из ссылки приходит с префиксом, введенный вручную — в любом регистре
+	gift, until, err := gifts.RedeemGift(" gift_abcd2345", 2)
+	if err != nil {
+		t.Fatalf("RedeemGift: %v", err)
+	}
+	if gift.ID != 1 || !until.Equal(now.AddDate(0, 3, 0)) {
+		t.Fatalf("unexpected redemption: gift=%+v until=%s", gift, until)
+	}
+
+	if _, _, err := gifts.RedeemGift("ABCD2345", 3); !errors.Is(err, domain.ErrGiftRedeemed) {
+		t.Fatalf("expected ErrGiftRedeemed, got %v", err)
+	}
+	if _, _, err := gifts.RedeemGift("", 3); !errors.Is(err, domain.ErrGiftNotFound) {
+		t.Fatalf("expected ErrGiftNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"ai_tg_writer/internal/domain"
+	"fmt"
+	"log"
+	"time"
+)
+
+// paymentHistoryLimit сколько последних платежей показывать в истории оплат
+const paymentHistoryLimit = 20
+
+// Наименования услуг в чеках; возврат оформляется с тем же наименованием, что и платеж
+const (
+	subscriptionReceiptItem = "Подписка AI TG Writer"
+	renewalReceiptItem      = "Продление подписки AI TG Writer"
+	creditPackReceiptItem   = "Пакет постов AI TG Writer"
+	trialReceiptItem        = "Привязка карты для пробного периода AI TG Writer (возвращается)"
+	giftReceiptItem         = "Подарочная подписка AI TG Writer"
+)
+
+// receiptItem возвращает наименование услуги в чеке по назначению платежа
+func receiptItem(kind string) string {
+	switch kind {
+	case domain.PaymentKindRecurring:
+		return renewalReceiptItem
+	case domain.PaymentKindCreditPack:
+		return creditPackReceiptItem
+	case domain.PaymentKindTrial:
+		return trialReceiptItem
+	case domain.PaymentKindGift:
+		return giftReceiptItem
+	}
+	return subscriptionReceiptItem
+}
+
+// receiptEmail возвращает email пользователя для чека. Без email чек уходит
+// на адрес магазина из настроек провайдера: платеж важнее отсутствующего контакта.
+func receiptEmail(contacts domain.UserContactRepository, userID int64) string {
+	if contacts == nil {
+		return ""
+	}
+	email, err := contacts.GetUserEmail(userID)
+	if err != nil {
+		log.Printf("⚠️ Failed to get email of user %d for receipt: %v", userID, err)
+	}
+	return email
+}
+
+// recordPayment пишет попытку оплаты в журнал. Ошибка журнала не прерывает оплату:
+// деньги уже списаны или списание отклонено, статус подписки важнее записи в истории.
+func recordPayment(payments domain.PaymentRepository, payment *domain.Payment) {
+	if payments == nil || payment == nil {
+		return
+	}
+	if err := payments.Record(payment); err != nil {
+		log.Printf("❌ Failed to record %s payment of user %d: %v", payment.Provider, payment.UserID, err)
+	}
+}
+
+// RecordPayment пишет в журнал платеж, пришедший из вебхука провайдера
+func (s *SubscriptionService) RecordPayment(payment *domain.Payment) {
+	recordPayment(s.payments, payment)
+}
+
+// GetUserPaymentHistory возвращает последние платежи пользователя из журнала
+func (s *SubscriptionService) GetUserPaymentHistory(userID int64) ([]*domain.Payment, error) {
+	if s.payments == nil {
+		return nil, fmt.Errorf("payments ledger is not configured")
+	}
+	return s.payments.GetUserPayments(userID, paymentHistoryLimit)
+}
+
+// GetPaymentTotals возвращает итоги платежей с начала периода для отчетов администраторов
+func (s *SubscriptionService) GetPaymentTotals(since time.Time) ([]*domain.PaymentTotals, error) {
+	if s.payments == nil {
+		return nil, fmt.Errorf("payments ledger is not configured")
+	}
+	return s.payments.GetTotals(since)
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"ai_tg_writer/internal/domain"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// PaymentProviders подключенные платежные провайдеры. Новая оплата идет через провайдера,
+// закрепленного за пользователем, затем через провайдера тарифа, иначе — через провайдера
+// по умолчанию. Продления и возвраты идут через провайдера, принявшего исходный платеж.
+type PaymentProviders struct {
+	providers map[string]domain.PaymentProvider
+	fallback  string
+	users     domain.PaymentProviderRepository
+}
+
+// NewPaymentProviders создает реестр провайдеров; fallback — имя провайдера по умолчанию,
+// users может быть nil — тогда выбор пользователя не учитывается
+func NewPaymentProviders(fallback string, users domain.PaymentProviderRepository, providers ...domain.PaymentProvider) *PaymentProviders {
+	registry := &PaymentProviders{
+		providers: make(map[string]domain.PaymentProvider, len(providers)),
+		fallback:  fallback,
+		users:     users,
+	}
+	for _, provider := range providers {
+		registry.providers[provider.Name()] = provider
+	}
+	return registry
+}
+
+// Get возвращает подключенного провайдера по имени
+func (p *PaymentProviders) Get(name string) (domain.PaymentProvider, error) {
+	if p == nil {
+		return nil, fmt.Errorf("payment providers are not configured")
+	}
+	provider, ok := p.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("payment provider %q is not configured", name)
+	}
+	return provider, nil
+}
+
+// Has проверяет, подключен ли провайдер
+func (p *PaymentProviders) Has(name string) bool {
+	_, err := p.Get(name)
+	return err == nil
+}
+
+// Names возвращает имена подключенных провайдеров
+func (p *PaymentProviders) Names() []string {
+	if p == nil {
+		return nil
+	}
+	names := make([]string, 0, len(p.providers))
+	for name := range p.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ForUser выбирает провайдера для новой оплаты пользователя; tariff может быть nil
+// (покупка пакета кредитов). Выбор, указывающий на неподключенного провайдера, пропускается.
+func (p *PaymentProviders) ForUser(userID int64, tariff *domain.Tariff) (domain.PaymentProvider, error) {
+	if p == nil {
+		return nil, fmt.Errorf("payment providers are not configured")
+	}
+	if p.users != nil {
+		name, err := p.users.GetUserPaymentProvider(userID)
+		if err != nil {
+			log.Printf("⚠️ Failed to get payment provider of user %d: %v", userID, err)
+		} else if provider, ok := p.providers[name]; ok {
+			return provider, nil
+		} else if name != "" {
+			log.Printf("⚠️ Payment provider %q of user %d is not configured, ignoring", name, userID)
+		}
+	}
+	if tariff != nil && tariff.Provider != "" {
+		if provider, ok := p.providers[tariff.Provider]; ok {
+			return provider, nil
+		}
+		log.Printf("⚠️ Payment provider %q of tariff %s is not configured, ignoring", tariff.Provider, tariff.ID)
+	}
+	return p.Get(p.fallback)
+}
+
+// SetUserProvider закрепляет провайдера за пользователем; пустое имя возвращает выбор по тарифу
+func (p *PaymentProviders) SetUserProvider(userID int64, name string) error {
+	if p == nil || p.users == nil {
+		return fmt.Errorf("payment provider selection is not configured")
+	}
+	if name != "" && !p.Has(name) {
+		return fmt.Errorf("payment provider %q is not configured", name)
+	}
+	return p.users.SetUserPaymentProvider(userID, name)
+}
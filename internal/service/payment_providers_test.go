@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+
+	"ai_tg_writer/internal/domain"
+)
+
+type stubProvider struct {
+	domain.PaymentProvider
+	name string
+}
+
+func (p *stubProvider) Name() string { return p.name }
+
+type stubUserProviders map[int64]string
+
+func (r stubUserProviders) GetUserPaymentProvider(userID int64) (string, error) {
+	return r[userID], nil
+}
+
+func (r stubUserProviders) SetUserPaymentProvider(userID int64, provider string) error {
+	r[userID] = provider
+	return nil
+}
+
+func TestPaymentProvidersForUser(t *testing.T) {
+	users := stubUserProviders{}
+	providers := NewPaymentProviders(domain.PaymentProviderYooKassa, users,
+		&stubProvider{name: domain.PaymentProviderYooKassa}, &stubProvider{name: domain.PaymentProviderProdamus})
+	tariff := &domain.Tariff{ID: "premium", Provider: domain.PaymentProviderProdamus}
+
+	cases := []struct {
+		name     string
+		user     string
+		tariff   *domain.Tariff
+		expected string
+	}{
+		{"по умолчанию", "", nil, domain.PaymentProviderYooKassa},
+		{"провайдер тарифа", "", tariff, domain.PaymentProviderProdamus},
+		{"выбор пользователя важнее тарифа", domain.PaymentProviderYooKassa, tariff, domain.PaymentProviderYooKassa},
+		{"неподключенный провайдер пропускается", "stripe", nil, domain.PaymentProviderYooKassa},
+	}
+	for _, tc := range cases {
+		users[42] = tc.user
+		provider, err := providers.ForUser(42, tc.tariff)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if provider.Name() != tc.expected {
+			t.Errorf("%s: ожидался %s, получен %s", tc.name, tc.expected, provider.Name())
+		}
+	}
+
+	if err := providers.SetUserProvider(42, "stripe"); err == nil {
+		t.Error("Неподключенного провайдера нельзя закрепить за пользователем")
+	}
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"ai_tg_writer/internal/domain"
+	"ai_tg_writer/internal/monitoring"
+	"fmt"
+	"log"
+	"time"
+)
+
+// defaultFreeGenerations бесплатный лимит созданий, если тариф free недоступен в каталоге
+const defaultFreeGenerations = 5
+
+// quotaSubscriptions источник тарифов для движка квот
+type quotaSubscriptions interface {
+	GetTariff(id string) (*domain.Tariff, error)
+}
+
+// QuotaService проверяет, списывает и возвращает квоты ресурсов по тарифу пользователя.
+// Все обработчики бота и API работают с лимитами только через него.
+type QuotaService struct {
+	repo         domain.QuotaRepository
+	subs         quotaSubscriptions
+	entitlements *EntitlementService
+	credits      domain.CreditRepository // nil — пакеты кредитов не подключены
+	now          func() time.Time
+}
+
+// NewQuotaService создает сервис квот; тариф пользователя определяется по его правам доступа
+func NewQuotaService(repo domain.QuotaRepository, subs quotaSubscriptions, entitlements *EntitlementService, credits domain.CreditRepository) *QuotaService {
+	return &QuotaService{
+		repo:         repo,
+		subs:         subs,
+		entitlements: entitlements,
+		credits:      credits,
+		now:          time.Now,
+	}
+}
+
+// quotaPlan тариф и период учета, действующие для пользователя сейчас
+type quotaPlan struct {
+	tariff *domain.Tariff
+	start  time.Time
+	end    time.Time
+}
+
+// resolve определяет тариф и текущий период квот пользователя.
+// Период подписчика отсчитывается от последней оплаты подписки с длиной периода тарифа,
+// остальных — помесячно от даты регистрации; границы считаются в часовом поясе пользователя.
+func (s *QuotaService) resolve(userID int64) (*quotaPlan, error) {
+	signup, timezone, err := s.repo.GetUserAnchor(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user anchor: %w", err)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Printf("⚠️ Unknown timezone %q of user %d, using %s", timezone, userID, domain.DefaultUserTimezone)
+		if loc, err = time.LoadLocation(domain.DefaultUserTimezone); err != nil {
+			loc = time.UTC
+		}
+	}
+
+	tariff, subscribedAt, err := s.resolveTariff(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor, period := signup, domain.TariffPeriodMonth
+	if subscribedAt != nil {
+		anchor = *subscribedAt
+		if domain.IsValidTariffPeriod(tariff.Period) {
+			period = tariff.Period
+		}
+	}
+	start, end := domain.QuotaPeriod(anchor.In(loc), period, s.now())
+	return &quotaPlan{tariff: tariff, start: start, end: end}, nil
+}
+
+// resolveTariff возвращает тариф, лимиты которого действуют для пользователя,
+// и начало оплаченного периода, если лимиты дает подписка
+func (s *QuotaService) resolveTariff(userID int64) (*domain.Tariff, *time.Time, error) {
+	entitlement, err := s.entitlements.Get(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case entitlement.Source == domain.EntitlementGranted:
+		tariff, err := s.tariffOrUnlimited(domain.PremiumTariffID)
+		return tariff, nil, err
+	case entitlement.HasSubscriptionAccess():
+		tariff, err := s.subs.GetTariff(entitlement.TariffID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting tariff %s: %w", entitlement.TariffID, err)
+		}
+		if tariff == nil {
+			// Тариф удален из каталога — подписчик сохраняет лимиты Premium
+			tariff, err = s.tariffOrUnlimited(domain.PremiumTariffID)
+		}
+		return tariff, entitlement.Since, err
+	}
+
+	tariff, err := s.subs.GetTariff(domain.FreeTariffID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting free tariff: %w", err)
+	}
+	if tariff == nil {
+		tariff = &domain.Tariff{
+			ID:     domain.FreeTariffID,
+			Quotas: map[domain.QuotaResource]int{domain.QuotaGenerations: defaultFreeGenerations},
+		}
+	}
+	return tariff, nil, nil
+}
+
+// tariffOrUnlimited возвращает тариф каталога или тариф без ограничений, если его нет
+func (s *QuotaService) tariffOrUnlimited(id string) (*domain.Tariff, error) {
+	tariff, err := s.subs.GetTariff(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tariff %s: %w", id, err)
+	}
+	if tariff == nil {
+		return &domain.Tariff{ID: id}, nil
+	}
+	return tariff, nil
+}
+
+// Check возвращает состояние квоты ресурса; хватает ли остатка, показывает QuotaStatus.Allows
+func (s *QuotaService) Check(userID int64, resource domain.QuotaResource) (*domain.QuotaStatus, error) {
+	plan, err := s.resolve(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.status(userID, plan, resource)
+}
+
+// status рассчитывает состояние квоты ресурса по тарифу
+func (s *QuotaService) status(userID int64, plan *quotaPlan, resource domain.QuotaResource) (*domain.QuotaStatus, error) {
+	if !domain.IsValidQuotaResource(resource) {
+		return nil, fmt.Errorf("unknown quota resource: %s", resource)
+	}
+
+	used, err := s.repo.GetUsed(userID, resource, plan.start)
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s usage: %w", resource, err)
+	}
+
+	status := &domain.QuotaStatus{
+		Resource:    resource,
+		TariffID:    plan.tariff.ID,
+		PeriodStart: plan.start,
+		PeriodEnd:   plan.end,
+		Limit:       plan.tariff.QuotaLimit(resource),
+		Used:        used,
+		Remaining:   domain.UnlimitedQuota,
+	}
+	if status.IsUnlimited() {
+		return status, nil
+	}
+
+	granted, err := s.repo.GetGranted(userID, resource, plan.start)
+	if err != nil {
+		return nil, fmt.Errorf("error getting granted %s: %w", resource, err)
+	}
+	status.Granted = granted
+	status.Limit += granted
+	status.Remaining = status.Limit - used
+	if status.Remaining < 0 {
+		status.Remaining = 0
+	}
+
+	if s.usesCredits(resource) {
+		balance, err := s.credits.GetBalance(userID, s.now())
+		if err != nil {
+			return nil, fmt.Errorf("error getting credit balance: %w", err)
+		}
+		status.Credits = balance.Credits
+	}
+	return status, nil
+}
+
+// usesCredits проверяет, можно ли оплатить ресурс купленными кредитами
+func (s *QuotaService) usesCredits(resource domain.QuotaResource) bool {
+	return s.credits != nil && domain.IsCreditResource(resource)
+}
+
+// Usage возвращает состояние квот пользователя по всем ресурсам
+func (s *QuotaService) Usage(userID int64) ([]*domain.QuotaStatus, error) {
+	plan, err := s.resolve(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*domain.QuotaStatus, 0, len(domain.QuotaResources))
+	for _, resource := range domain.QuotaResources {
+		status, err := s.status(userID, plan, resource)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Consume списывает amount единиц ресурса. Если лимита не хватает, возвращает ErrQuotaExceeded.
+// При ограниченном лимите сначала расходуются купленные кредиты, затем квота тарифа.
+// Возвращенное списание передается в Refund, если операция не удалась.
+func (s *QuotaService) Consume(userID int64, resource domain.QuotaResource, amount int) (*domain.QuotaConsumption, error) {
+	if amount <= 0 {
+		return nil, nil
+	}
+	status, err := s.Check(userID, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if !status.IsUnlimited() && status.Credits >= amount {
+		purchaseID, err := s.credits.ConsumeCredits(userID, amount, s.now())
+		if err != nil {
+			return nil, fmt.Errorf("error consuming credits: %w", err)
+		}
+		// 0 — кредиты разошлись параллельно или разбиты по покупкам, списываем квоту тарифа
+		if purchaseID != 0 {
+			monitoring.RecordQuotaConsumption(string(resource), "credited", amount)
+			return &domain.QuotaConsumption{
+				UserID:           userID,
+				Resource:         resource,
+				Amount:           amount,
+				PeriodStart:      status.PeriodStart,
+				CreditPurchaseID: purchaseID,
+			}, nil
+		}
+	}
+
+	// Лимит с учетом выданного; расход проверяется атомарно в репозитории
+	ok, err := s.repo.Consume(userID, resource, status.PeriodStart, amount, status.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("error consuming %s: %w", resource, err)
+	}
+	if !ok {
+		monitoring.RecordQuotaConsumption(string(resource), "rejected", amount)
+		return nil, fmt.Errorf("%s: %w", resource, domain.ErrQuotaExceeded)
+	}
+
+	monitoring.RecordQuotaConsumption(string(resource), "consumed", amount)
+	return &domain.QuotaConsumption{
+		UserID:      userID,
+		Resource:    resource,
+		Amount:      amount,
+		PeriodStart: status.PeriodStart,
+	}, nil
+}
+
+// Refund возвращает списанные единицы ресурса. Пустое списание игнорируется.
+func (s *QuotaService) Refund(consumption *domain.QuotaConsumption) error {
+	if consumption == nil {
+		return nil
+	}
+	if consumption.PaidByCredits() {
+		if s.credits == nil {
+			return fmt.Errorf("credits are not configured")
+		}
+		if err := s.credits.RefundCredits(consumption.CreditPurchaseID, consumption.Amount); err != nil {
+			return fmt.Errorf("error refunding credits: %w", err)
+		}
+		monitoring.RecordQuotaConsumption(string(consumption.Resource), "refunded", consumption.Amount)
+		log.Printf("↩️ Refunded %d credits to purchase %d of user %d", consumption.Amount, consumption.CreditPurchaseID, consumption.UserID)
+		return nil
+	}
+	if err := s.repo.Refund(consumption.UserID, consumption.Resource, consumption.PeriodStart, consumption.Amount); err != nil {
+		return fmt.Errorf("error refunding %s: %w", consumption.Resource, err)
+	}
+	monitoring.RecordQuotaConsumption(string(consumption.Resource), "refunded", consumption.Amount)
+	log.Printf("↩️ Refunded %d %s to user %d", consumption.Amount, consumption.Resource, consumption.UserID)
+	return nil
+}
+
+// ResetUsage обнуляет расход всех ресурсов пользователя в текущем периоде
+func (s *QuotaService) ResetUsage(userID int64) error {
+	plan, err := s.resolve(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.ResetUsage(userID, plan.start); err != nil {
+		return fmt.Errorf("error resetting quota usage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ai_tg_writer/internal/config"
+	"ai_tg_writer/internal/domain"
+)
+
+type stubQuotaRepo struct {
+	used     map[domain.QuotaResource]int
+	granted  map[domain.QuotaResource]int
+	signup   time.Time
+	timezone string
+}
+
+func (r *stubQuotaRepo) GetUsed(userID int64, resource domain.QuotaResource, periodStart time.Time) (int, error) {
+	return r.used[resource], nil
+}
+
+func (r *stubQuotaRepo) Consume(userID int64, resource domain.QuotaResource, periodStart time.Time, amount, limit int) (bool, error) {
+	if limit != domain.UnlimitedQuota && r.used[resource]+amount > limit {
+		return false, nil
+	}
+	r.used[resource] += amount
+	return true, nil
+}
+
+func (r *stubQuotaRepo) Refund(userID int64, resource domain.QuotaResource, periodStart time.Time, amount int) error {
+	r.used[resource] -= amount
+	return nil
+}
+
+func (r *stubQuotaRepo) GetGranted(userID int64, resource domain.QuotaResource, periodStart time.Time) (int, error) {
+	return r.granted[resource], nil
+}
+
+func (r *stubQuotaRepo) ResetUsage(userID int64, periodStart time.Time) error {
+	r.used = map[domain.QuotaResource]int{}
+	return nil
+}
+
+func (r *stubQuotaRepo) GetUserAnchor(userID int64) (time.Time, string, error) {
+	return r.signup, r.timezone, nil
+}
+
+type stubQuotaSubscriptions struct {
+	subscription *domain.Subscription
+	tariffs      map[string]*domain.Tariff
+}
+
+func (s *stubQuotaSubscriptions) GetUserSubscription(userID int64) (*domain.Subscription, error) {
+	return s.subscription, nil
+}
+
+func (s *stubQuotaSubscriptions) GetTariff(id string) (*domain.Tariff, error) {
+	return s.tariffs[id], nil
+}
+
+type stubCreditRepo struct {
+	remaining map[int64]int // Остаток по ID покупки
+}
+
+func (r *stubCreditRepo) GetVisiblePacks() ([]*domain.CreditPack, error)       { return nil, nil }
+func (r *stubCreditRepo) GetPack(id string) (*domain.CreditPack, error)        { return nil, nil }
+func (r *stubCreditRepo) CreatePurchase(purchase *domain.CreditPurchase) error { return nil }
+func (r *stubCreditRepo) SetPurchasePaymentID(purchaseID int64, paymentID string) error {
+	return nil
+}
+func (r *stubCreditRepo) MarkPurchasePaid(paymentID string, paidAt time.Time) (*domain.CreditPurchase, bool, error) {
+	return nil, false, nil
+}
+func (r *stubCreditRepo) GetPurchaseByPaymentID(paymentID string) (*domain.CreditPurchase, error) {
+	return nil, nil
+}
+func (r *stubCreditRepo) RevokeCredits(purchaseID int64, amount int) (int, error) { return 0, nil }
+func (r *stubCreditRepo) GetUserPurchases(userID int64, limit int) ([]*domain.CreditPurchase, error) {
+	return nil, nil
+}
+
+func (r *stubCreditRepo) GetBalance(userID int64, now time.Time) (*domain.CreditBalance, error) {
+	balance := &domain.CreditBalance{}
+	for _, remaining := range r.remaining {
+		balance.Credits += remaining
+	}
+	return balance, nil
+}
+
+func (r *stubCreditRepo) ConsumeCredits(userID int64, amount int, now time.Time) (int64, error) {
+	for id, remaining := range r.remaining {
+		if remaining >= amount {
+			r.remaining[id] -= amount
+			return id, nil
+		}
+	}
+	return 0, nil
+}
+
+func (r *stubCreditRepo) RefundCredits(purchaseID int64, amount int) error {
+	r.remaining[purchaseID] += amount
+	return nil
+}
+
+func TestQuotaServiceConsumeAndRefund(t *testing.T) {
+	repo := &stubQuotaRepo{
+		used:    map[domain.QuotaResource]int{},
+		granted: map[domain.QuotaResource]int{domain.QuotaRewrites: 1},
+	}
+	subs := &stubQuotaSubscriptions{tariffs: map[string]*domain.Tariff{
+		domain.FreeTariffID:    {ID: domain.FreeTariffID, Quotas: map[domain.QuotaResource]int{domain.QuotaGenerations: 1, domain.QuotaRewrites: 1}},
+		domain.PremiumTariffID: {ID: domain.PremiumTariffID},
+	}}
+	quotas := NewQuotaService(repo, subs, NewEntitlementService(subs, nil, &config.Config{}), nil)
+
+	// Бесплатный лимит расходуется, следующее списание отклоняется
+	consumption, err := quotas.Consume(1, domain.QuotaGenerations, 1)
+	if err != nil {
+		t.Fatalf("Первое создание должно пройти: %v", err)
+	}
+	if _, err := quotas.Consume(1, domain.QuotaGenerations, 1); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Fatalf("Ожидалась ошибка ErrQuotaExceeded, получено %v", err)
+	}
+
+	// Возврат после неудачной генерации снова открывает лимит
+	if err := quotas.Refund(consumption); err != nil {
+		t.Fatalf("Ошибка возврата: %v", err)
+	}
+	if status, _ := quotas.Check(1, domain.QuotaGenerations); !status.Allows(1) {
+		t.Errorf("После возврата лимит должен быть доступен: %+v", status)
+	}
+
+	// Выданные администратором единицы добавляются к лимиту тарифа
+	if status, _ := quotas.Check(1, domain.QuotaRewrites); status.Limit != 2 || status.Remaining != 2 {
+		t.Errorf("Ожидался лимит рерайтов 2 с учетом выданного, получено %+v", status)
+	}
+
+	// Ресурс, отсутствующий в тарифе, не ограничен
+	if status, _ := quotas.Check(1, domain.QuotaAudioMinutes); !status.IsUnlimited() {
+		t.Errorf("Минуты аудио не ограничены тарифом free: %+v", status)
+	}
+
+	// Активная подписка снимает лимиты тарифа free
+	subs.subscription = &domain.Subscription{Tariff: domain.PremiumTariffID, Status: "active", Active: true}
+	if _, err := quotas.Consume(1, domain.QuotaGenerations, 5); err != nil {
+		t.Errorf("Подписчик не ограничен по созданиям: %v", err)
+	}
+}
+
+func TestQuotaServiceAnchoredPeriod(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skipf("Нет данных часовых поясов: %v", err)
+	}
+	repo := &stubQuotaRepo{
+		used:     map[domain.QuotaResource]int{},
+		signup:   time.Date(2025, 1, 31, 10, 0, 0, 0, time.UTC),
+		timezone: "Europe/Moscow",
+	}
+	subs := &stubQuotaSubscriptions{tariffs: map[string]*domain.Tariff{
+		domain.FreeTariffID: {ID: domain.FreeTariffID, Quotas: map[domain.QuotaResource]int{domain.QuotaGenerations: 5}},
+		"premium_week":      {ID: "premium_week", Period: domain.TariffPeriodWeek, Quotas: map[domain.QuotaResource]int{domain.QuotaGenerations: 20}},
+	}}
+	quotas := NewQuotaService(repo, subs, NewEntitlementService(subs, nil, &config.Config{}), nil)
+	quotas.now = func() time.Time { return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	// Бесплатный период привязан к дате регистрации; в феврале нет 31-го — период начался 28-го
+	status, err := quotas.Check(1, domain.QuotaGenerations)
+	if err != nil {
+		t.Fatalf("Ошибка проверки квоты: %v", err)
+	}
+	if want := time.Date(2025, 2, 28, 0, 0, 0, 0, moscow); !status.PeriodStart.Equal(want) {
+		t.Errorf("Ожидалось начало периода %v, получено %v", want, status.PeriodStart)
+	}
+	if want := time.Date(2025, 3, 31, 0, 0, 0, 0, moscow); !status.PeriodEnd.Equal(want) {
+		t.Errorf("Ожидалось обновление лимитов %v, получено %v", want, status.PeriodEnd)
+	}
+
+	// Период подписчика отсчитывается от оплаты, а не от создания записи подписки, с длиной периода тарифа
+	subs.subscription = &domain.Subscription{Tariff: "premium_week", Status: "active", Active: true,
+		CreatedAt:   time.Date(2025, 2, 20, 9, 0, 0, 0, time.UTC),
+		LastPayment: time.Date(2025, 2, 27, 22, 30, 0, 0, time.UTC)} // 28.02 01:30 по Москве
+	status, err = quotas.Check(1, domain.QuotaGenerations)
+	if err != nil {
+		t.Fatalf("Ошибка проверки квоты: %v", err)
+	}
+	if want := time.Date(2025, 2, 28, 0, 0, 0, 0, moscow); !status.PeriodStart.Equal(want) || status.Limit != 20 {
+		t.Errorf("Ожидался недельный период с %v и лимитом 20, получено %+v", want, status)
+	}
+	if want := time.Date(2025, 3, 7, 0, 0, 0, 0, moscow); !status.PeriodEnd.Equal(want) {
+		t.Errorf("Ожидалось обновление лимитов %v, получено %v", want, status.PeriodEnd)
+	}
+}
+
+func TestQuotaServiceCreditsBeforeFreeQuota(t *testing.T) {
+	repo := &stubQuotaRepo{used: map[domain.QuotaResource]int{}}
+	subs := &stubQuotaSubscriptions{tariffs: map[string]*domain.Tariff{
+		domain.FreeTariffID: {ID: domain.FreeTariffID, Quotas: map[domain.QuotaResource]int{domain.QuotaGenerations: 1}},
+	}}
+	credits := &stubCreditRepo{remaining: map[int64]int{7: 1}}
+	quotas := NewQuotaService(repo, subs, NewEntitlementService(subs, nil, &config.Config{}), credits)
+
+	// Кредит расходуется раньше бесплатного лимита
+	consumption, err := quotas.Consume(1, domain.QuotaGenerations, 1)
+	if err != nil {
+		t.Fatalf("Создание за кредит должно пройти: %v", err)
+	}
+	if consumption.CreditPurchaseID != 7 || repo.used[domain.QuotaGenerations] != 0 {
+		t.Fatalf("Ожидалось списание кредита покупки 7 без расхода квоты, получено %+v, used=%d",
+			consumption, repo.used[domain.QuotaGenerations])
+	}
+
+	// Возврат зачисляет кредит в ту же покупку
+	if err := quotas.Refund(consumption); err != nil {
+		t.Fatalf("Ошибка возврата: %v", err)
+	}
+	if credits.remaining[7] != 1 {
+		t.Errorf("Кредит не вернулся в покупку: %v", credits.remaining)
+	}
+
+	// Без кредитов списывается бесплатный лимит, затем операция отклоняется
+	credits.remaining[7] = 0
+	if consumption, err := quotas.Consume(1, domain.QuotaGenerations, 1); err != nil || consumption.PaidByCredits() {
+		t.Fatalf("Ожидалось списание бесплатного лимита, получено %+v, %v", consumption, err)
+	}
+	if _, err := quotas.Consume(1, domain.QuotaGenerations, 1); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Errorf("Ожидалась ошибка ErrQuotaExceeded, получено %v", err)
+	}
+
+	// Минуты аудио кредитами не оплачиваются
+	if status, _ := quotas.Check(1, domain.QuotaAudioMinutes); status.Credits != 0 {
+		t.Errorf("Кредиты не относятся к минутам аудио: %+v", status)
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"ai_tg_writer/internal/config"
+	"ai_tg_writer/internal/domain"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// referralRewardBatch сколько наград начислять за один проход воркера
+const referralRewardBatch = 100
+
+// referralNotifier сообщает приглашающему о начисленной награде
+type referralNotifier interface {
+	SendReferralRewardMessage(referral *domain.Referral) error
+}
+
+// ReferralReward награда приглашающему за первую оплату приглашенного
+type ReferralReward struct {
+	Type   string // domain.ReferralRewardGenerations или domain.ReferralRewardPremiumDays
+	Amount int
+}
+
+// ReferralService закрепляет пришедших по реферальной ссылке пользователей за приглашающими
+// и начисляет награды за первую оплату приглашенных
+type ReferralService struct {
+	repo     domain.ReferralRepository
+	notifier referralNotifier
+	config   *config.Config
+	now      func() time.Time
+}
+
+// NewReferralService создает сервис реферальной программы; notifier может быть nil —
+// тогда приглашающий не уведомляется о награде
+func NewReferralService(repo domain.ReferralRepository, notifier referralNotifier, cfg *config.Config) *ReferralService {
+	return &ReferralService{
+		repo:     repo,
+		notifier: notifier,
+		config:   cfg,
+		now:      time.Now,
+	}
+}
+
+// Reward возвращает награду за приглашение или nil, если награда выключена в настройках
+func (s *ReferralService) Reward() *ReferralReward {
+	if !domain.IsValidReferralReward(s.config.ReferralReward) || s.config.ReferralRewardAmount <= 0 {
+		return nil
+	}
+	return &ReferralReward{Type: s.config.ReferralReward, Amount: s.config.ReferralRewardAmount}
+}
+
+// ReferrerByCode возвращает владельца реферального кода или 0, если код не найден
+func (s *ReferralService) ReferrerByCode(code string) (int64, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return 0, nil
+	}
+	referrerID, err := s.repo.GetReferrerByCode(code)
+	if err != nil {
+		return 0, fmt.Errorf("get referrer by code: %w", err)
+	}
+	return referrerID, nil
+}
+
+// Attach закрепляет пользователя за владельцем реферального кода и возвращает приглашающего.
+// Возвращает false, если код не найден, это код самого пользователя или пользователь уже закреплен.
+func (s *ReferralService) Attach(userID int64, code string) (int64, bool, error) {
+	referrerID, err := s.ReferrerByCode(code)
+	if err != nil {
+		return 0, false, err
+	}
+	if referrerID == 0 || referrerID == userID {
+		return 0, false, nil
+	}
+	attached, err := s.repo.Attach(userID, referrerID)
+	if err != nil {
+		return 0, false, fmt.Errorf("attach referral: %w", err)
+	}
+	if !attached {
+		return 0, false, nil
+	}
+	log.Printf("🤝 User %d joined by referral of user %d", userID, referrerID)
+	return referrerID, true, nil
+}
+
+// RewardPending начисляет награды за приглашенных, которые оплатили впервые.
+// Награда начисляется один раз, даже если воркер обработает приглашение повторно.
+func (s *ReferralService) RewardPending() (int, error) {
+	reward := s.Reward()
+	if reward == nil {
+		return 0, nil
+	}
+	referrals, err := s.repo.GetPendingRewards(referralRewardBatch)
+	if err != nil {
+		return 0, fmt.Errorf("get pending referral rewards: %w", err)
+	}
+
+	rewarded := 0
+	for _, referral := range referrals {
+		ok, err := s.repo.Reward(referral, reward.Type, reward.Amount, s.now())
+		if err != nil {
+			log.Printf("❌ Failed to reward referral %d of user %d: %v", referral.ID, referral.ReferrerID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		rewarded++
+		log.Printf("🎉 Referral %d rewarded: user %d got %d %s for user %d",
+			referral.ID, referral.ReferrerID, reward.Amount, reward.Type, referral.ReferredID)
+		if s.notifier != nil {
+			if err := s.notifier.SendReferralRewardMessage(referral); err != nil {
+				log.Printf("❌ Failed to notify user %d about referral reward: %v", referral.ReferrerID, err)
+			}
+		}
+	}
+	return rewarded, nil
+}
+
+// Stats возвращает статистику приглашений пользователя
+func (s *ReferralService) Stats(userID int64) (*domain.ReferralStats, error) {
+	return s.repo.GetStats(userID)
+}
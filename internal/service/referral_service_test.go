@@ -0,0 +1,102 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"ai_tg_writer/internal/config"
+	"ai_tg_writer/internal/domain"
+)
+
+type stubReferralRepo struct {
+	codes    map[string]int64
+	attached map[int64]int64
+	pending  []*domain.Referral
+}
+
+func (r *stubReferralRepo) GetReferrerByCode(code string) (int64, error) { return r.codes[code], nil }
+
+func (r *stubReferralRepo) Attach(referredID, referrerID int64) (bool, error) {
+	if _, ok := r.attached[referredID]; ok {
+		return false, nil
+	}
+	r.attached[referredID] = referrerID
+	return true, nil
+}
+
+func (r *stubReferralRepo) GetPendingRewards(limit int) ([]*domain.Referral, error) {
+	var result []*domain.Referral
+	for _, referral := range r.pending {
+		if referral.Status == domain.ReferralStatusPending {
+			result = append(result, referral)
+		}
+	}
+	return result, nil
+}
+
+func (r *stubReferralRepo) Reward(referral *domain.Referral, rewardType string, amount int, at time.Time) (bool, error) {
+	if referral.Status != domain.ReferralStatusPending {
+		return false, nil
+	}
+	referral.Status = domain.ReferralStatusRewarded
+	referral.RewardType = rewardType
+	referral.RewardAmount = amount
+	return true, nil
+}
+
+func (r *stubReferralRepo) GetStats(referrerID int64) (*domain.ReferralStats, error) {
+	return &domain.ReferralStats{}, nil
+}
+
+type stubReferralNotifier struct {
+	sent []*domain.Referral
+}
+
+func (n *stubReferralNotifier) SendReferralRewardMessage(referral *domain.Referral) error {
+	n.sent = append(n.sent, referral)
+	return nil
+}
+
+func TestReferralAttach(t *testing.T) {
+	repo := &stubReferralRepo{codes: map[string]int64{"ABCD1234": 1}, attached: map[int64]int64{}}
+	referrals := NewReferralService(repo, nil, &config.Config{})
+
+	if referrerID, ok, err := referrals.Attach(2, " abcd1234"); err != nil || !ok || referrerID != 1 {
+		t.Fatalf("expected user 2 attached to user 1, got referrer=%d ok=%v err=%v", referrerID, ok, err)
+	}
+	if _, ok, _ := referrals.Attach(2, "ABCD1234"); ok {
+		t.Fatal("user must not be attached twice")
+	}
+	if _, ok, _ := referrals.Attach(1, "ABCD1234"); ok {
+		t.Fatal("user must not invite themselves")
+	}
+	if _, ok, _ := referrals.Attach(3, "UNKNOWN1"); ok {
+		t.Fatal("unknown code must be ignored")
+	}
+}
+
+func TestReferralRewardPendingOnce(t *testing.T) {
+	paymentID := int64(10)
+	repo := &stubReferralRepo{pending: []*domain.Referral{
+		{ID: 1, ReferrerID: 1, ReferredID: 2, Status: domain.ReferralStatusPending, PaymentID: &paymentID},
+	}}
+	notifier := &stubReferralNotifier{}
+	cfg := &config.Config{ReferralReward: domain.ReferralRewardPremiumDays, ReferralRewardAmount: 7}
+	referrals := NewReferralService(repo, notifier, cfg)
+
+	for i := 0; i < 2; i++ {
+		if _, err := referrals.RewardPending(); err != nil {
+			t.Fatalf("RewardPending: %v", err)
+		}
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].RewardAmount != 7 || notifier.sent[0].RewardType != domain.ReferralRewardPremiumDays {
+		t.Fatalf("expected one premium days reward, got %+v", notifier.sent)
+	}
+
+	// Награда выключена: приглашения остаются без награды
+	repo.pending = append(repo.pending, &domain.Referral{ID: 2, ReferrerID: 1, ReferredID: 3, Status: domain.ReferralStatusPending, PaymentID: &paymentID})
+	cfg.ReferralReward = "none"
+	if rewarded, _ := referrals.RewardPending(); rewarded != 0 || repo.pending[1].Status != domain.ReferralStatusPending {
+		t.Fatalf("disabled reward must not be granted, rewarded=%d", rewarded)
+	}
+}
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"ai_tg_writer/internal/domain"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// cardBindingRefundReason причина автоматического возврата платежа за привязку карты
+const cardBindingRefundReason = "возврат платежа за привязку карты"
+
+// RefundOutcome возврат и его последствия для пользователя
+type RefundOutcome struct {
+	Refund         *domain.Refund
+	Payment        *domain.Payment
+	Full           bool // Платеж возвращен полностью
+	Downgraded     bool // Подписка завершена досрочно
+	RevokedCredits int  // Сколько неизрасходованных кредитов списано
+	GiftCancelled  bool // Неактивированный подарок аннулирован
+}
+
+// refundNotifier отправляет пользователю уведомление о возврате
+type refundNotifier interface {
+	SendRefundMessage(outcome *RefundOutcome) error
+}
+
+// RefundService оформляет возвраты через провайдера платежа и применяет их последствия:
+// полный возврат оплаты текущего периода завершает подписку, возврат пакета списывает кредиты
+type RefundService struct {
+	refunds   domain.RefundRepository
+	payments  domain.PaymentRepository
+	subs      domain.SubscriptionRepository
+	credits   domain.CreditRepository
+	gifts     domain.GiftRepository
+	contacts  domain.UserContactRepository
+	providers *PaymentProviders
+	notifier  refundNotifier
+	now       func() time.Time
+}
+
+// NewRefundService создает сервис возвратов; notifier может быть nil — тогда пользователь не уведомляется
+func NewRefundService(refunds domain.RefundRepository, payments domain.PaymentRepository, subs domain.SubscriptionRepository,
+	credits domain.CreditRepository, gifts domain.GiftRepository, contacts domain.UserContactRepository, providers *PaymentProviders, notifier refundNotifier) *RefundService {
+	return &RefundService{
+		refunds:   refunds,
+		payments:  payments,
+		subs:      subs,
+		credits:   credits,
+		gifts:     gifts,
+		contacts:  contacts,
+		providers: providers,
+		notifier:  notifier,
+		now:       time.Now,
+	}
+}
+
+// roundAmount округляет сумму до копеек
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// FindPayment ищет платеж по ID в журнале или по ID платежа у подключенных провайдеров
+func (s *RefundService) FindPayment(ref string) (*domain.Payment, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return s.payments.GetByID(id)
+	}
+	for _, provider := range s.providers.Names() {
+		payment, err := s.payments.GetByProviderPaymentID(provider, ref)
+		if err != nil || payment != nil {
+			return payment, err
+		}
+	}
+	return nil, nil
+}
+
+// Refundable возвращает сумму платежа, которую еще можно вернуть
+func (s *RefundService) Refundable(payment *domain.Payment) (float64, error) {
+	refunded, err := s.refunds.GetRefundedTotal(payment.ID)
+	if err != nil {
+		return 0, fmt.Errorf("get refunded total: %w", err)
+	}
+	return roundAmount(payment.Amount - refunded), nil
+}
+
+// Refund возвращает amount по платежу; 0 — весь невозвращенный остаток.
+// Если провайдер провел возврат сразу, последствия применяются до ответа,
+// иначе — по уведомлению об успешном возврате.
+func (s *RefundService) Refund(payment *domain.Payment, amount float64, adminID int64, reason string) (*RefundOutcome, error) {
+	return s.refund(payment, amount, &adminID, reason)
+}
+
+// RefundCardBinding возвращает платеж за привязку карты для пробного периода: он только проверяет карту.
+// Повторный вызов для уже возвращенного платежа вернет domain.ErrRefundNotAllowed.
+func (s *RefundService) RefundCardBinding(payment *domain.Payment) (*RefundOutcome, error) {
+	if payment.Kind != domain.PaymentKindTrial {
+		return nil, fmt.Errorf("%w: payment %d is not a card binding", domain.ErrRefundNotAllowed, payment.ID)
+	}
+	return s.refund(payment, 0, nil, cardBindingRefundReason)
+}
+
+// refund оформляет возврат у провайдера; adminID nil — возврат оформлен автоматически
+func (s *RefundService) refund(payment *domain.Payment, amount float64, adminID *int64, reason string) (*RefundOutcome, error) {
+	if payment.ProviderPaymentID == nil {
+		return nil, fmt.Errorf("%w: payment %d has no provider payment id", domain.ErrRefundNotAllowed, payment.ID)
+	}
+	if payment.Status != domain.PaymentStatusSucceeded && payment.Status != domain.PaymentStatusRefunded {
+		return nil, fmt.Errorf("%w: payment %d is %s", domain.ErrRefundNotAllowed, payment.ID, payment.Status)
+	}
+	provider, err := s.providers.Get(payment.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	refundable, err := s.Refundable(payment)
+	if err != nil {
+		return nil, err
+	}
+	amount = roundAmount(amount)
+	if amount == 0 {
+		amount = refundable
+	}
+	if amount <= 0 || amount > refundable {
+		return nil, fmt.Errorf("%w: %.2f requested, %.2f refundable", domain.ErrRefundNotAllowed, amount, refundable)
+	}
+
+	refund := &domain.Refund{
+		PaymentID: payment.ID,
+		UserID:    payment.UserID,
+		Provider:  provider.Name(),
+		Amount:    amount,
+		Currency:  payment.Currency,
+		Status:    domain.RefundStatusPending,
+		Reason:    reason,
+		AdminID:   adminID,
+	}
+	if err := s.refunds.Record(refund); err != nil {
+		return nil, fmt.Errorf("create refund: %w", err)
+	}
+
+	response, err := provider.Refund(&domain.RefundRequest{
+		ProviderPaymentID: *payment.ProviderPaymentID,
+		Amount:            amount,
+		Currency:          payment.Currency,
+		Reason:            reason,
+		Description:       receiptItem(payment.Kind),
+		Email:             receiptEmail(s.contacts, payment.UserID),
+		IdempotencyKey:    fmt.Sprintf("refund-%d", refund.ID),
+	})
+	if err != nil {
+		// Отмененный возврат не уменьшает остаток; если провайдер все же провел его,
+		// уведомление об успешном возврате создаст запись заново по ID провайдера
+		refund.Status = domain.RefundStatusCanceled
+		if rerr := s.refunds.Record(refund); rerr != nil {
+			log.Printf("❌ Failed to cancel refund %d: %v", refund.ID, rerr)
+		}
+		if errors.Is(err, domain.ErrProviderUnsupported) {
+			return nil, fmt.Errorf("%w: %v", domain.ErrRefundNotAllowed, err)
+		}
+		return nil, fmt.Errorf("create %s refund: %w", provider.Name(), err)
+	}
+
+	providerID, status := response.ID, response.Status
+	if providerID != "" {
+		refund.ProviderRefundID = &providerID
+	}
+	refund.Status = status
+	if err := s.refunds.Record(refund); err != nil {
+		return nil, fmt.Errorf("save refund %s: %w", providerID, err)
+	}
+	initiator := "automatically"
+	if adminID != nil {
+		initiator = fmt.Sprintf("by admin %d", *adminID)
+	}
+	log.Printf("↩️ Refund %d (%s) of %.2f for payment %d created %s: %s",
+		refund.ID, providerID, amount, payment.ID, initiator, status)
+
+	switch refund.Status {
+	case domain.RefundStatusSucceeded:
+		// nil без ошибки — последствия уже применены по уведомлению, пришедшему раньше ответа
+		if outcome, err := s.apply(refund, payment); err != nil || outcome != nil {
+			return outcome, err
+		}
+	case domain.RefundStatusCanceled:
+		return nil, fmt.Errorf("%w: %s canceled refund %s", domain.ErrRefundNotAllowed, provider.Name(), providerID)
+	}
+	return &RefundOutcome{Refund: refund, Payment: payment}, nil
+}
+
+// ApplyProviderRefund применяет успешный возврат из уведомления провайдера, включая возвраты,
+// оформленные в личном кабинете. Возвращает nil, если возврат уже применен или платеж неизвестен.
+func (s *RefundService) ApplyProviderRefund(provider string, providerRefund *domain.ProviderRefund) (*RefundOutcome, error) {
+	providerID, paymentID := providerRefund.ID, providerRefund.ProviderPaymentID
+	if providerRefund.Status != domain.RefundStatusSucceeded || providerID == "" {
+		return nil, nil
+	}
+	payment, err := s.payments.GetByProviderPaymentID(provider, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("get payment %s: %w", paymentID, err)
+	}
+	if payment == nil {
+		log.Printf("⚠️ Refund %s for unknown payment %s, skipping", providerID, paymentID)
+		return nil, nil
+	}
+
+	refund := &domain.Refund{
+		PaymentID:        payment.ID,
+		UserID:           payment.UserID,
+		Provider:         provider,
+		ProviderRefundID: &providerID,
+		Amount:           providerRefund.Amount,
+		Currency:         payment.Currency,
+		Status:           domain.RefundStatusSucceeded,
+		Reason:           providerRefund.Reason,
+	}
+	// Уведомление о возврате, созданном ботом, может прийти раньше ответа API с ID провайдера
+	adopted, err := s.refunds.AdoptPending(refund)
+	if err != nil {
+		return nil, fmt.Errorf("adopt refund %s: %w", providerID, err)
+	}
+	if adopted {
+		log.Printf("🔗 Refund %s matched to pending refund %d", providerID, refund.ID)
+	} else if err := s.refunds.Record(refund); err != nil {
+		return nil, fmt.Errorf("save refund %s: %w", providerID, err)
+	}
+	return s.apply(refund, payment)
+}
+
+// apply применяет последствия успешного возврата ровно один раз
+func (s *RefundService) apply(refund *domain.Refund, payment *domain.Payment) (*RefundOutcome, error) {
+	applied, err := s.refunds.MarkApplied(refund.ID, s.now())
+	if err != nil {
+		return nil, fmt.Errorf("mark refund %d applied: %w", refund.ID, err)
+	}
+	if !applied {
+		log.Printf("ℹ️ Refund %d already applied", refund.ID)
+		return nil, nil
+	}
+
+	refundable, err := s.Refundable(payment)
+	if err != nil {
+		return nil, err
+	}
+	outcome := &RefundOutcome{Refund: refund, Payment: payment, Full: refundable <= 0}
+
+	// Частично возвращенный платеж остается оплаченным: остаток еще можно вернуть
+	if outcome.Full {
+		payment.Status = domain.PaymentStatusRefunded
+		recordPayment(s.payments, payment)
+	}
+
+	switch payment.Kind {
+	case domain.PaymentKindCreditPack:
+		if err := s.revokeCredits(outcome); err != nil {
+			return nil, err
+		}
+	case domain.PaymentKindInitial, domain.PaymentKindRecurring:
+		if err := s.downgrade(outcome); err != nil {
+			return nil, err
+		}
+	case domain.PaymentKindGift:
+		if err := s.cancelGift(outcome); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("✅ Refund %d applied to payment %d of user %d: full=%v, downgraded=%v, revoked_credits=%d, gift_cancelled=%v",
+		refund.ID, payment.ID, payment.UserID, outcome.Full, outcome.Downgraded, outcome.RevokedCredits, outcome.GiftCancelled)
+
+	if s.notifier != nil {
+		if err := s.notifier.SendRefundMessage(outcome); err != nil {
+			log.Printf("❌ Failed to notify user %d about refund %d: %v", payment.UserID, refund.ID, err)
+		}
+	}
+	return outcome, nil
+}
+
+// downgrade завершает подписку, если полностью возвращена оплата текущего периода.
+// Возврат за уже прошедший период и частичный возврат подписку не меняют.
+func (s *RefundService) downgrade(outcome *RefundOutcome) error {
+	if !outcome.Full {
+		return nil
+	}
+	subscription, err := s.subs.GetByUserID(outcome.Payment.UserID)
+	if err != nil {
+		return fmt.Errorf("get subscription: %w", err)
+	}
+	if subscription == nil || subscription.YKLastPaymentID == nil || *subscription.YKLastPaymentID != *outcome.Payment.ProviderPaymentID {
+		return nil
+	}
+	if err := s.subs.Revoke(outcome.Payment.UserID); err != nil {
+		return fmt.Errorf("revoke subscription: %w", err)
+	}
+	outcome.Downgraded = true
+	return nil
+}
+
+// revokeCredits списывает неизрасходованные кредиты пакета пропорционально возвращенной сумме
+func (s *RefundService) revokeCredits(outcome *RefundOutcome) error {
+	if s.credits == nil {
+		return nil
+	}
+	purchase, err := s.credits.GetPurchaseByPaymentID(*outcome.Payment.ProviderPaymentID)
+	if err != nil {
+		return fmt.Errorf("get credit purchase: %w", err)
+	}
+	if purchase == nil {
+		return nil
+	}
+	credits := purchase.Credits
+	if !outcome.Full && outcome.Payment.Amount > 0 {
+		credits = int(math.Ceil(float64(purchase.Credits) * outcome.Refund.Amount / outcome.Payment.Amount))
+	}
+	revoked, err := s.credits.RevokeCredits(purchase.ID, credits)
+	if err != nil {
+		return fmt.Errorf("revoke credits: %w", err)
+	}
+	outcome.RevokedCredits = revoked
+	return nil
+}
+
+// cancelGift аннулирует неактивированный подарок при полном возврате оплаты.
+// Активированный подарок не отзывается: Premium уже выдан получателю.
+func (s *RefundService) cancelGift(outcome *RefundOutcome) error {
+	if s.gifts == nil || !outcome.Full {
+		return nil
+	}
+	cancelled, err := s.gifts.Cancel(*outcome.Payment.ProviderPaymentID)
+	if err != nil {
+		return fmt.Errorf("cancel gift: %w", err)
+	}
+	outcome.GiftCancelled = cancelled
+	return nil
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"ai_tg_writer/internal/domain"
+)
+
+type stubRefundRepo struct {
+	refunds []*domain.Refund
+}
+
+func (r *stubRefundRepo) byProviderID(providerID *string) *domain.Refund {
+	for _, refund := range r.refunds {
+		if providerID != nil && refund.ProviderRefundID != nil && *refund.ProviderRefundID == *providerID {
+			return refund
+		}
+	}
+	return nil
+}
+
+func (r *stubRefundRepo) Record(refund *domain.Refund) error {
+	if refund.ID != 0 {
+		stored := r.refunds[refund.ID-1]
+		if refund.ProviderRefundID != nil {
+			stored.ProviderRefundID = refund.ProviderRefundID
+		}
+		if stored.Status != domain.RefundStatusSucceeded {
+			stored.Status = refund.Status
+		}
+		refund.Status, refund.AppliedAt = stored.Status, stored.AppliedAt
+		return nil
+	}
+	if stored := r.byProviderID(refund.ProviderRefundID); stored != nil {
+		stored.Status = refund.Status
+		refund.ID, refund.AppliedAt = stored.ID, stored.AppliedAt
+		return nil
+	}
+	stored := *refund
+	stored.ID = int64(len(r.refunds) + 1)
+	r.refunds = append(r.refunds, &stored)
+	refund.ID = stored.ID
+	return nil
+}
+
+func (r *stubRefundRepo) AdoptPending(refund *domain.Refund) (bool, error) {
+	if r.byProviderID(refund.ProviderRefundID) != nil {
+		return false, nil
+	}
+	for _, stored := range r.refunds {
+		if stored.PaymentID == refund.PaymentID && stored.Amount == refund.Amount && stored.ProviderRefundID == nil && stored.Status == domain.RefundStatusPending {
+			stored.ProviderRefundID, stored.Status = refund.ProviderRefundID, refund.Status
+			refund.ID, refund.Reason, refund.AdminID, refund.AppliedAt = stored.ID, stored.Reason, stored.AdminID, stored.AppliedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *stubRefundRepo) MarkApplied(id int64, appliedAt time.Time) (bool, error) {
+	stored := r.refunds[id-1]
+	if stored.Status != domain.RefundStatusSucceeded || stored.AppliedAt != nil {
+		return false, nil
+	}
+	stored.AppliedAt = &appliedAt
+	return true, nil
+}
+
+func (r *stubRefundRepo) GetRefundedTotal(paymentID int64) (float64, error) {
+	var total float64
+	for _, refund := range r.refunds {
+		if refund.PaymentID == paymentID && refund.Status != domain.RefundStatusCanceled {
+			total += refund.Amount
+		}
+	}
+	return total, nil
+}
+
+type stubPaymentRepo struct {
+	payment *domain.Payment
+}
+
+func (r *stubPaymentRepo) Record(payment *domain.Payment) error      { return nil }
+func (r *stubPaymentRepo) GetByID(id int64) (*domain.Payment, error) { return r.payment, nil }
+func (r *stubPaymentRepo) GetByProviderPaymentID(provider, providerPaymentID string) (*domain.Payment, error) {
+	return r.payment, nil
+}
+func (r *stubPaymentRepo) GetUserPayments(userID int64, limit int) ([]*domain.Payment, error) {
+	return nil, nil
+}
+func (r *stubPaymentRepo) GetTotals(since time.Time) ([]*domain.PaymentTotals, error) {
+	return nil, nil
+}
+
+type stubRevokeCredits struct {
+	stubCreditRepo
+	purchase *domain.CreditPurchase
+}
+
+func (r *stubRevokeCredits) GetPurchaseByPaymentID(paymentID string) (*domain.CreditPurchase, error) {
+	return r.purchase, nil
+}
+
+func (r *stubRevokeCredits) RevokeCredits(purchaseID int64, amount int) (int, error) {
+	revoked := min(amount, r.purchase.Remaining)
+	r.purchase.Remaining -= revoked
+	return revoked, nil
+}
+
+func TestRefundServiceRevokesCreditsOnce(t *testing.T) {
+	providerPaymentID := "pay-credits"
+	payments := &stubPaymentRepo{payment: &domain.Payment{
+		ID: 3, UserID: 42, Provider: domain.PaymentProviderYooKassa, ProviderPaymentID: &providerPaymentID,
+		Kind: domain.PaymentKindCreditPack, Amount: 490, Currency: "RUB", Status: domain.PaymentStatusSucceeded,
+	}}
+	credits := &stubRevokeCredits{purchase: &domain.CreditPurchase{ID: 9, Credits: 15, Remaining: 10}}
+	refunds := NewRefundService(&stubRefundRepo{}, payments, nil, credits, nil, nil, nil, nil)
+
+	notification := &domain.ProviderRefund{
+		ID:                "refund-1",
+		ProviderPaymentID: providerPaymentID,
+		Status:            domain.RefundStatusSucceeded,
+		Amount:            245,
+		Currency:          "RUB",
+	}
+
+	// Половина суммы — половина кредитов пакета, с округлением вверх
+	outcome, err := refunds.ApplyProviderRefund(domain.PaymentProviderYooKassa, notification)
+	if err != nil {
+		t.Fatalf("Ошибка применения возврата: %v", err)
+	}
+	if outcome == nil || outcome.Full || outcome.RevokedCredits != 8 || credits.purchase.Remaining != 2 {
+		t.Fatalf("Ожидалось списание 8 кредитов при частичном возврате, получено %+v, remaining=%d",
+			outcome, credits.purchase.Remaining)
+	}
+	if payments.payment.Status != domain.PaymentStatusSucceeded {
+		t.Errorf("Частично возвращенный платеж должен остаться оплаченным, статус %s", payments.payment.Status)
+	}
+
+	// Повторное уведомление о том же возврате не списывает кредиты снова
+	if outcome, err := refunds.ApplyProviderRefund(domain.PaymentProviderYooKassa, notification); err != nil || outcome != nil {
+		t.Fatalf("Повторный возврат не должен применяться: %+v, %v", outcome, err)
+	}
+
+	// Возврат остатка списывает все, что не израсходовано
+	notification = &domain.ProviderRefund{
+		ID:                "refund-2",
+		ProviderPaymentID: providerPaymentID,
+		Status:            domain.RefundStatusSucceeded,
+		Amount:            245,
+		Currency:          "RUB",
+	}
+	outcome, err = refunds.ApplyProviderRefund(domain.PaymentProviderYooKassa, notification)
+	if err != nil {
+		t.Fatalf("Ошибка применения возврата: %v", err)
+	}
+	if !outcome.Full || outcome.RevokedCredits != 2 || credits.purchase.Remaining != 0 {
+		t.Errorf("Ожидался полный возврат со списанием остатка, получено %+v", outcome)
+	}
+	if payments.payment.Status != domain.PaymentStatusRefunded {
+		t.Errorf("Полностью возвращенный платеж должен стать возвращенным, статус %s", payments.payment.Status)
+	}
+}
+
+// stubEarlyRefundProvider присылает уведомление об успешном возврате раньше, чем отвечает API
+type stubEarlyRefundProvider struct {
+	domain.PaymentProvider
+	notify func(*domain.ProviderRefund)
+}
+
+func (p *stubEarlyRefundProvider) Name() string { return domain.PaymentProviderYooKassa }
+func (p *stubEarlyRefundProvider) Refund(req *domain.RefundRequest) (*domain.ProviderRefund, error) {
+	refund := &domain.ProviderRefund{
+		ID:                "refund-early",
+		ProviderPaymentID: req.ProviderPaymentID,
+		Amount:            req.Amount,
+		Currency:          req.Currency,
+		Status:            domain.RefundStatusSucceeded,
+	}
+	p.notify(refund)
+	return refund, nil
+}
+
+func TestRefundNotificationBeforeAPIResponse(t *testing.T) {
+	providerPaymentID := "pay-early"
+	payments := &stubPaymentRepo{payment: &domain.Payment{
+		ID: 5, UserID: 42, Provider: domain.PaymentProviderYooKassa, ProviderPaymentID: &providerPaymentID,
+		Kind: domain.PaymentKindCreditPack, Amount: 490, Currency: "RUB", Status: domain.PaymentStatusSucceeded,
+	}}
+	credits := &stubRevokeCredits{purchase: &domain.CreditPurchase{ID: 9, Credits: 15, Remaining: 10}}
+	repo := &stubRefundRepo{}
+	provider := &stubEarlyRefundProvider{}
+	providers := NewPaymentProviders(domain.PaymentProviderYooKassa, nil, provider)
+	refunds := NewRefundService(repo, payments, nil, credits, nil, nil, providers, nil)
+
+	var early *RefundOutcome
+	provider.notify = func(notification *domain.ProviderRefund) {
+		var err error
+		if early, err = refunds.ApplyProviderRefund(domain.PaymentProviderYooKassa, notification); err != nil {
+			t.Fatalf("Ошибка применения уведомления: %v", err)
+		}
+	}
+
+	outcome, err := refunds.Refund(payments.payment, 245, 1, "по просьбе пользователя")
+	if err != nil {
+		t.Fatalf("Ошибка возврата: %v", err)
+	}
+	if early == nil || early.Refund.AdminID == nil {
+		t.Fatalf("Уведомление должно примениться к возврату из админки, получено %+v", early)
+	}
+	if len(repo.refunds) != 1 || repo.refunds[0].Status != domain.RefundStatusSucceeded {
+		t.Fatalf("Ожидалась одна успешная запись возврата, получено %d", len(repo.refunds))
+	}
+	if outcome == nil || outcome.Refund.ID != early.Refund.ID || outcome.Refund.Status != domain.RefundStatusSucceeded {
+		t.Errorf("Ответ API должен обновить ту же запись, получено %+v", outcome)
+	}
+	if credits.purchase.Remaining != 2 {
+		t.Errorf("Кредиты должны списаться один раз, осталось %d", credits.purchase.Remaining)
+	}
+	if refundable, _ := refunds.Refundable(payments.payment); refundable != 245 {
+		t.Errorf("Остаток к возврату должен быть 245, получено %.2f", refundable)
+	}
+}
+
+// stubRefundProvider сразу проводит возврат
+type stubRefundProvider struct {
+	domain.PaymentProvider
+	requests []*domain.RefundRequest
+}
+
+func (p *stubRefundProvider) Name() string { return domain.PaymentProviderYooKassa }
+func (p *stubRefundProvider) Refund(req *domain.RefundRequest) (*domain.ProviderRefund, error) {
+	p.requests = append(p.requests, req)
+	return &domain.ProviderRefund{
+		ID:                fmt.Sprintf("refund-%d", len(p.requests)),
+		ProviderPaymentID: req.ProviderPaymentID,
+		Amount:            req.Amount,
+		Currency:          req.Currency,
+		Status:            domain.RefundStatusSucceeded,
+	}, nil
+}
+
+func TestRefundCardBindingOnce(t *testing.T) {
+	providerPaymentID := "pay-trial"
+	payments := &stubPaymentRepo{payment: &domain.Payment{
+		ID: 7, UserID: 42, Provider: domain.PaymentProviderYooKassa, ProviderPaymentID: &providerPaymentID,
+		Kind: domain.PaymentKindTrial, Amount: 1, Currency: "RUB", Status: domain.PaymentStatusSucceeded,
+	}}
+	provider := &stubRefundProvider{}
+	providers := NewPaymentProviders(domain.PaymentProviderYooKassa, nil, provider)
+	refunds := NewRefundService(&stubRefundRepo{}, payments, nil, nil, nil, nil, providers, nil)
+
+	outcome, err := refunds.RefundCardBinding(payments.payment)
+	if err != nil {
+		t.Fatalf("Ошибка возврата привязки карты: %v", err)
+	}
+	if !outcome.Full || outcome.Refund.Amount != 1 || outcome.Refund.AdminID != nil {
+		t.Errorf("Ожидался полный автоматический возврат 1 ₽, получено %+v", outcome.Refund)
+	}
+	if payments.payment.Status != domain.PaymentStatusRefunded {
+		t.Errorf("Платеж за привязку должен стать возвращенным, статус %s", payments.payment.Status)
+	}
+
+	// Повторное уведомление об оплате не возвращает деньги второй раз
+	if _, err := refunds.RefundCardBinding(payments.payment); !errors.Is(err, domain.ErrRefundNotAllowed) {
+		t.Errorf("Повторный возврат должен быть запрещен, получено %v", err)
+	}
+	if len(provider.requests) != 1 {
+		t.Errorf("Провайдер должен получить один запрос возврата, получено %d", len(provider.requests))
+	}
+
+	payments.payment.Kind = domain.PaymentKindInitial
+	if _, err := refunds.RefundCardBinding(payments.payment); !errors.Is(err, domain.ErrRefundNotAllowed) {
+		t.Errorf("Оплату подписки нельзя вернуть как привязку карты, получено %v", err)
+	}
+}
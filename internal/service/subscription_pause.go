@@ -0,0 +1,206 @@
+package service
+
+import (
+	"ai_tg_writer/internal/domain"
+	"fmt"
+	"log"
+	"time"
+)
+
+// pauseEndingNoticeKind вид напоминания о скором окончании паузы в журнале отправленных напоминаний
+const pauseEndingNoticeKind = "pause_ending"
+
+// dayUnit возвращает длительность «дня» для настроек в днях: в режиме разработки — минута
+func (s *SubscriptionService) dayUnit() time.Duration {
+	if s.config.IsDevMode() {
+		return time.Minute
+	}
+	return 24 * time.Hour
+}
+
+// PauseOptions возвращает варианты длительности паузы в днях (PAUSE_OPTION_DAYS)
+func (s *SubscriptionService) PauseOptions() []int {
+	return s.config.PauseOptionDays
+}
+
+// PausesLeft возвращает, сколько раз пользователь еще может поставить подписку на паузу
+// за скользящие 12 месяцев
+func (s *SubscriptionService) PausesLeft(userID int64) (int, error) {
+	used, err := s.repo.CountPausesSince(userID, time.Now().UTC().AddDate(-1, 0, 0))
+	if err != nil {
+		return 0, fmt.Errorf("error counting pauses: %w", err)
+	}
+	if left := s.config.MaxPausesPerYear - used; left > 0 {
+		return left, nil
+	}
+	return 0, nil
+}
+
+// CanPause проверяет, можно ли поставить подписку на паузу: она активна, продлевается
+// автоматически, оплаченный период еще идет, нет неудачных списаний и это не пробный период
+func CanPause(subscription *domain.Subscription, now time.Time) bool {
+	return subscription != nil &&
+		subscription.Status == string(domain.SubscriptionStatusActive) &&
+		!subscription.InTrial() &&
+		subscription.YKPaymentMethodID != nil &&
+		subscription.FailedAttempts == 0 &&
+		subscription.NextPayment.After(now)
+}
+
+// PauseSubscription ставит подписку пользователя на паузу на days дней. На время паузы
+// Premium недоступен и списаний нет, а дата следующего списания сдвигается на длительность паузы.
+func (s *SubscriptionService) PauseSubscription(userID int64, days int) (*domain.Subscription, error) {
+	allowed := false
+	for _, option := range s.config.PauseOptionDays {
+		if option == days {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: unsupported pause duration %d", domain.ErrPauseNotAllowed, days)
+	}
+
+	subscription, err := s.repo.GetByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting subscription: %w", err)
+	}
+	now := time.Now().UTC()
+	if !CanPause(subscription, now) {
+		return nil, domain.ErrPauseNotAllowed
+	}
+
+	left, err := s.PausesLeft(userID)
+	if err != nil {
+		return nil, err
+	}
+	if left == 0 {
+		return nil, domain.ErrPauseLimitReached
+	}
+
+	if _, err := subscription.Transition(domain.SubscriptionStatusPaused, fmt.Sprintf("paused by user for %d days", days), now); err != nil {
+		return nil, err
+	}
+	duration := time.Duration(days) * s.dayUnit()
+	until := now.Add(duration)
+	subscription.PauseUntil = &until
+	subscription.NextPayment = subscription.NextPayment.Add(duration)
+
+	if err := s.repo.Update(subscription); err != nil {
+		return nil, fmt.Errorf("error pausing subscription: %w", err)
+	}
+
+	log.Printf("⏸ Subscription paused for user %d until %s, next payment moved to %s",
+		userID, until.Format("2006-01-02 15:04:05"), subscription.NextPayment.Format("2006-01-02 15:04:05"))
+	return subscription, nil
+}
+
+// ResumeSubscription досрочно снимает подписку пользователя с паузы
+func (s *SubscriptionService) ResumeSubscription(userID int64) (*domain.Subscription, error) {
+	subscription, err := s.repo.GetByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting subscription: %w", err)
+	}
+	if subscription == nil || subscription.Status != string(domain.SubscriptionStatusPaused) {
+		return nil, fmt.Errorf("subscription is not paused")
+	}
+	if err := s.resume(subscription, "resumed by user", time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// resume возвращает подписку с паузы. Если пауза снята досрочно, неиспользованная часть
+// паузы вычитается из даты следующего списания.
+func (s *SubscriptionService) resume(subscription *domain.Subscription, reason string, now time.Time) error {
+	if subscription.PauseUntil != nil && subscription.PauseUntil.After(now) {
+		subscription.NextPayment = subscription.NextPayment.Add(-subscription.PauseUntil.Sub(now))
+	}
+	if _, err := subscription.Transition(domain.SubscriptionStatusActive, reason, now); err != nil {
+		return err
+	}
+	if err := s.repo.Update(subscription); err != nil {
+		return fmt.Errorf("error resuming subscription: %w", err)
+	}
+	log.Printf("▶️ Subscription resumed for user %d (%s), next payment %s",
+		subscription.UserID, reason, subscription.NextPayment.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+// ResumeEndedPauses возобновляет подписки, пауза которых закончилась, и сообщает об этом пользователям
+func (s *SubscriptionService) ResumeEndedPauses() (int, error) {
+	now := time.Now().UTC()
+	subscriptions, err := s.repo.GetSubscriptionsPausedUntil(now)
+	if err != nil {
+		return 0, fmt.Errorf("error getting ended pauses: %w", err)
+	}
+
+	resumed := 0
+	for _, subscription := range subscriptions {
+		if err := s.resume(subscription, "pause ended", now); err != nil {
+			log.Printf("❌ Failed to resume subscription for user %d: %v", subscription.UserID, err)
+			continue
+		}
+		s.sendSubscriptionResumedMessage(subscription.UserID, subscription.NextPayment)
+		resumed++
+	}
+	return resumed, nil
+}
+
+// SendPauseEndingReminders напоминает за день до окончания паузы, что подписка возобновится.
+// Напоминание отправляется один раз на каждую паузу.
+func (s *SubscriptionService) SendPauseEndingReminders() (int, error) {
+	if s.notices == nil {
+		return 0, nil
+	}
+
+	now := time.Now().UTC()
+	subscriptions, err := s.repo.GetSubscriptionsPausedUntil(now.Add(s.dayUnit()))
+	if err != nil {
+		return 0, fmt.Errorf("error getting ending pauses: %w", err)
+	}
+
+	sent := 0
+	for _, subscription := range subscriptions {
+		if subscription.PauseUntil == nil || !subscription.PauseUntil.After(now) {
+			continue // Пауза уже закончилась: подписку возобновит ResumeEndedPauses
+		}
+		marked, err := s.notices.MarkNoticeSent(subscription.ID, pauseEndingNoticeKind, *subscription.PauseUntil)
+		if err != nil {
+			log.Printf("❌ Failed to mark pause ending reminder for user %d: %v", subscription.UserID, err)
+			continue
+		}
+		if !marked {
+			continue
+		}
+		s.sendPauseEndingMessage(subscription.UserID, *subscription.PauseUntil, subscription.NextPayment)
+		sent++
+	}
+	return sent, nil
+}
+
+// sendPauseEndingMessage напоминает о скором окончании паузы
+func (s *SubscriptionService) sendPauseEndingMessage(userID int64, resumeAt, chargeAt time.Time) {
+	if s.bot != nil {
+		if err := s.bot.SendPauseEndingMessage(userID, resumeAt, chargeAt); err != nil {
+			log.Printf("❌ Failed to send pause ending reminder to user %d: %v", userID, err)
+		} else {
+			log.Printf("📨 Pause ending reminder sent to user %d", userID)
+		}
+	} else {
+		log.Printf("📨 Should send pause ending reminder to user %d - bot not configured", userID)
+	}
+}
+
+// sendSubscriptionResumedMessage сообщает о возобновлении подписки после паузы
+func (s *SubscriptionService) sendSubscriptionResumedMessage(userID int64, nextPayment time.Time) {
+	if s.bot != nil {
+		if err := s.bot.SendSubscriptionResumedMessage(userID, nextPayment); err != nil {
+			log.Printf("❌ Failed to send subscription resumed message to user %d: %v", userID, err)
+		} else {
+			log.Printf("📨 Subscription resumed message sent to user %d", userID)
+		}
+	} else {
+		log.Printf("📨 Should send subscription resumed message to user %d - bot not configured", userID)
+	}
+}
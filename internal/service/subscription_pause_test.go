@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ai_tg_writer/internal/config"
+	"ai_tg_writer/internal/domain"
+)
+
+type stubPauseRepo struct {
+	domain.SubscriptionRepository
+	subscription *domain.Subscription
+	pauses       int
+}
+
+func (r *stubPauseRepo) GetByUserID(userID int64) (*domain.Subscription, error) {
+	return r.subscription, nil
+}
+func (r *stubPauseRepo) Update(subscription *domain.Subscription) error {
+	if subscription.Status == string(domain.SubscriptionStatusPaused) {
+		r.pauses++
+	}
+	return nil
+}
+func (r *stubPauseRepo) CountPausesSince(userID int64, since time.Time) (int, error) {
+	return r.pauses, nil
+}
+
+func TestPauseSubscriptionShiftsNextPayment(t *testing.T) {
+	method := "pm_1"
+	nextPayment := time.Now().UTC().Add(10 * 24 * time.Hour)
+	repo := &stubPauseRepo{subscription: &domain.Subscription{UserID: 42, Status: string(domain.SubscriptionStatusActive),
+		Active: true, NextPayment: nextPayment, YKPaymentMethodID: &method}}
+	cfg := &config.Config{Mode: "production", PauseOptionDays: []int{7, 14}, MaxPausesPerYear: 1}
+	s := NewSubscriptionService(repo, nil, nil, nil, nil, nil, nil, cfg)
+
+	if _, err := s.PauseSubscription(42, 30); !errors.Is(err, domain.ErrPauseNotAllowed) {
+		t.Errorf("Длительность не из PAUSE_OPTION_DAYS должна отклоняться: %v", err)
+	}
+
+	sub, err := s.PauseSubscription(42, 14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Status != string(domain.SubscriptionStatusPaused) || sub.PauseUntil == nil {
+		t.Fatalf("Подписка должна встать на паузу: %+v", sub)
+	}
+	if got := sub.NextPayment.Sub(nextPayment); got != 14*24*time.Hour {
+		t.Errorf("Списание должно сдвинуться на 14 дней, сдвинуто на %v", got)
+	}
+	if NewEntitlementService(nil, nil, cfg).ForSubscription(sub).IsPremium() {
+		t.Error("На паузе Premium недоступен")
+	}
+
+	// Досрочное возобновление возвращает неиспользованную часть паузы
+	if _, err := s.ResumeSubscription(42); err != nil {
+		t.Fatal(err)
+	}
+	if got := sub.NextPayment.Sub(nextPayment); got < 0 || got > time.Minute {
+		t.Errorf("После досрочного возобновления дата списания должна вернуться, сдвиг %v", got)
+	}
+	if sub.PauseUntil != nil || sub.Status != string(domain.SubscriptionStatusActive) {
+		t.Errorf("Подписка должна снова быть активной: %+v", sub)
+	}
+
+	if _, err := s.PauseSubscription(42, 7); !errors.Is(err, domain.ErrPauseLimitReached) {
+		t.Errorf("Лимит пауз за год исчерпан, ожидалась ошибка: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"ai_tg_writer/internal/domain"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"unicode"
+)
+
+// CheckPromoCode проверяет, что пользователь может применить промокод при оформлении подписки.
+// Возвращает ошибки domain.ErrPromo*, по которым бот объясняет причину отказа.
+func (s *SubscriptionService) CheckPromoCode(userID int64, code string) (*domain.PromoCode, error) {
+	code = domain.NormalizePromoCode(code)
+	if s.promos == nil || code == "" {
+		return nil, domain.ErrPromoNotFound
+	}
+	promo, err := s.promos.GetByCode(code)
+	if err != nil {
+		return nil, fmt.Errorf("error getting promo code: %w", err)
+	}
+	if promo == nil {
+		return nil, domain.ErrPromoNotFound
+	}
+	if err := promo.CheckAvailable(time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	used, err := s.promos.HasRedeemed(promo.ID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking promo redemption: %w", err)
+	}
+	if used {
+		return nil, domain.ErrPromoAlreadyUsed
+	}
+	return promo, nil
+}
+
+// redeemPromo закрепляет за подпиской промокод, по которому оплачен первый платеж:
+// промокод находится по записи платежа в журнале
+func (s *SubscriptionService) redeemPromo(userID int64, provider, paymentID string) {
+	if s.promos == nil || s.payments == nil {
+		return
+	}
+	payment, err := s.payments.GetByProviderPaymentID(provider, paymentID)
+	if err != nil {
+		log.Printf("❌ Error getting payment %s for promo redemption: %v", paymentID, err)
+		return
+	}
+	if payment == nil || payment.PromoCodeID == nil {
+		return
+	}
+
+	redemption := &domain.PromoRedemption{PromoCodeID: *payment.PromoCodeID, UserID: userID, SubscriptionID: payment.SubscriptionID}
+	redeemed, err := s.promos.Redeem(redemption)
+	switch {
+	case errors.Is(err, domain.ErrPromoExhausted):
+		// Лимит исчерпали, пока пользователь оплачивал: первая оплата уже со скидкой, продления — по полной цене
+		log.Printf("⚠️ Promo %d usage limit reached before payment %s of user %d, renewals at full price",
+			*payment.PromoCodeID, paymentID, userID)
+	case err != nil:
+		log.Printf("❌ Error redeeming promo %d for user %d: %v", *payment.PromoCodeID, userID, err)
+	case redeemed:
+		log.Printf("🎟 Promo %d redeemed by user %d", *payment.PromoCodeID, userID)
+	}
+}
+
+// renewalDiscount возвращает действующую скидку по промокоду на продление подписки
+// и ее размер. Скидка сохраняется, даже если промокод потом выключили или он истек.
+func (s *SubscriptionService) renewalDiscount(subscription *domain.Subscription) (*domain.PromoRedemption, float64) {
+	if s.promos == nil {
+		return nil, 0
+	}
+	redemption, err := s.promos.GetSubscriptionDiscount(subscription.ID)
+	if err != nil {
+		log.Printf("❌ Error getting promo discount of subscription %d, charging full price: %v", subscription.ID, err)
+		return nil, 0
+	}
+	if redemption == nil {
+		return nil, 0
+	}
+	return redemption, redemption.Promo.Discount(subscription.Amount)
+}
+
+// consumeRenewalDiscount отмечает, что продление списано со скидкой
+func (s *SubscriptionService) consumeRenewalDiscount(redemption *domain.PromoRedemption) {
+	if redemption == nil || redemption.RenewalsLeft == nil {
+		return
+	}
+	if err := s.promos.ConsumeRenewal(redemption.ID); err != nil {
+		log.Printf("❌ Error consuming promo renewal %d: %v", redemption.ID, err)
+	}
+}
+
+// promoCodeID возвращает промокод скидки для записи платежа в журнал
+func promoCodeID(redemption *domain.PromoRedemption) *int64 {
+	if redemption == nil {
+		return nil
+	}
+	return &redemption.PromoCodeID
+}
+
+// CreatePromoCode проверяет и сохраняет новый промокод
+func (s *SubscriptionService) CreatePromoCode(promo *domain.PromoCode) error {
+	if s.promos == nil {
+		return fmt.Errorf("promo codes are not configured")
+	}
+	promo.Code = domain.NormalizePromoCode(promo.Code)
+	if err := ValidatePromoCode(promo); err != nil {
+		return err
+	}
+	existing, err := s.promos.GetByCode(promo.Code)
+	if err != nil {
+		return fmt.Errorf("error getting promo code: %w", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("promo code %s already exists", promo.Code)
+	}
+	if err := s.promos.Create(promo); err != nil {
+		return fmt.Errorf("error creating promo code: %w", err)
+	}
+	log.Printf("🎟 Promo %s created: %s %.2f, periods=%d, max_uses=%d",
+		promo.Code, promo.DiscountType, promo.DiscountValue, promo.Periods, promo.MaxUses)
+	return nil
+}
+
+// ValidatePromoCode проверяет корректность промокода перед сохранением
+func ValidatePromoCode(promo *domain.PromoCode) error {
+	if promo.Code == "" || len(promo.Code) > 50 {
+		return fmt.Errorf("promo code must be 1-50 characters")
+	}
+	// Код выводится в Markdown-сообщениях бота, поэтому только буквы, цифры и дефис
+	for _, r := range promo.Code {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' {
+			return fmt.Errorf("promo code may contain only letters, digits and dashes")
+		}
+	}
+	if !domain.IsValidPromoDiscountType(promo.DiscountType) {
+		return fmt.Errorf("unknown discount type: %s", promo.DiscountType)
+	}
+	if promo.DiscountValue <= 0 {
+		return fmt.Errorf("discount must be positive")
+	}
+	if promo.DiscountType == domain.PromoDiscountPercent && promo.DiscountValue > 100 {
+		return fmt.Errorf("percent discount must be at most 100")
+	}
+	if promo.Periods < 0 || promo.MaxUses < 0 {
+		return fmt.Errorf("periods and usage limit must not be negative")
+	}
+	return nil
+}
+
+// SetPromoCodeActive включает или выключает промокод; уже примененные скидки сохраняются
+func (s *SubscriptionService) SetPromoCodeActive(code string, active bool) error {
+	if s.promos == nil {
+		return fmt.Errorf("promo codes are not configured")
+	}
+	return s.promos.SetActive(domain.NormalizePromoCode(code), active)
+}
+
+// GetPromoStats возвращает отчет по промокодам
+func (s *SubscriptionService) GetPromoStats() ([]*domain.PromoStats, error) {
+	if s.promos == nil {
+		return nil, fmt.Errorf("promo codes are not configured")
+	}
+	return s.promos.GetStats()
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ai_tg_writer/internal/config"
+	"ai_tg_writer/internal/domain"
+)
+
+type stubPromoRepo struct {
+	domain.PromoRepository
+	promo      *domain.PromoCode
+	redemption *domain.PromoRedemption
+	redeemed   map[int64]bool
+}
+
+func (r *stubPromoRepo) GetByCode(code string) (*domain.PromoCode, error) {
+	if r.promo == nil || r.promo.Code != code {
+		return nil, nil
+	}
+	return r.promo, nil
+}
+func (r *stubPromoRepo) HasRedeemed(promoID, userID int64) (bool, error) {
+	return r.redeemed[userID], nil
+}
+func (r *stubPromoRepo) GetSubscriptionDiscount(subscriptionID int64) (*domain.PromoRedemption, error) {
+	if r.redemption == nil || (r.redemption.RenewalsLeft != nil && *r.redemption.RenewalsLeft == 0) {
+		return nil, nil
+	}
+	return r.redemption, nil
+}
+func (r *stubPromoRepo) ConsumeRenewal(redemptionID int64) error {
+	*r.redemption.RenewalsLeft--
+	return nil
+}
+
+type stubChargeProvider struct {
+	domain.PaymentProvider
+	charged []float64
+}
+
+func (p *stubChargeProvider) Name() string { return domain.PaymentProviderYooKassa }
+func (p *stubChargeProvider) ChargeSaved(req *domain.ChargeRequest) (*domain.ProviderPayment, error) {
+	p.charged = append(p.charged, req.Amount)
+	id := "pay"
+	return &domain.ProviderPayment{Payment: &domain.Payment{UserID: req.UserID, Provider: p.Name(),
+		ProviderPaymentID: &id, Amount: req.Amount, Status: domain.PaymentStatusSucceeded}}, nil
+}
+
+func TestCheckPromoCode(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	promos := &stubPromoRepo{redeemed: map[int64]bool{7: true}}
+	s := NewSubscriptionService(nil, nil, nil, nil, nil, promos, nil, &config.Config{})
+
+	promos.promo = &domain.PromoCode{ID: 1, Code: "SPRING", DiscountType: domain.PromoDiscountPercent, DiscountValue: 20, Active: true}
+	if promo, err := s.CheckPromoCode(42, " spring "); err != nil || promo.ID != 1 {
+		t.Errorf("Код должен находиться без учета регистра и пробелов: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		userID int64
+		promo  domain.PromoCode
+		err    error
+	}{
+		{"выключен", 42, domain.PromoCode{Code: "SPRING"}, domain.ErrPromoNotFound},
+		{"истек", 42, domain.PromoCode{Code: "SPRING", Active: true, ExpiresAt: &expired}, domain.ErrPromoExpired},
+		{"лимит исчерпан", 42, domain.PromoCode{Code: "SPRING", Active: true, MaxUses: 10, UsedCount: 10}, domain.ErrPromoExhausted},
+		{"уже применен пользователем", 7, domain.PromoCode{Code: "SPRING", Active: true}, domain.ErrPromoAlreadyUsed},
+	}
+	for _, tc := range cases {
+		promo := tc.promo
+		promos.promo = &promo
+		if _, err := s.CheckPromoCode(tc.userID, "SPRING"); !errors.Is(err, tc.err) {
+			t.Errorf("%s: ожидалась ошибка %v, получено %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestRecurringPaymentAppliesPromoForRenewalsLeft(t *testing.T) {
+	customer, method := "42", "pm_1"
+	subscription := &domain.Subscription{ID: 1, UserID: 42, Tariff: "premium", Status: string(domain.SubscriptionStatusActive),
+		Active: true, Amount: 990, YKCustomerID: &customer, YKPaymentMethodID: &method, PaymentProvider: domain.PaymentProviderYooKassa}
+	renewalsLeft := 1
+	promos := &stubPromoRepo{redemption: &domain.PromoRedemption{ID: 5, PromoCodeID: 1, RenewalsLeft: &renewalsLeft,
+		Promo: &domain.PromoCode{ID: 1, Code: "HALF", DiscountType: domain.PromoDiscountPercent, DiscountValue: 50}}}
+	provider := &stubChargeProvider{}
+	providers := NewPaymentProviders(domain.PaymentProviderYooKassa, nil, provider)
+	s := NewSubscriptionService(&stubDunningRepo{}, nil, nil, nil, nil, promos, providers, &config.Config{Mode: "production"})
+
+	for i := 0; i < 2; i++ {
+		if err := s.ProcessRecurringPayment(subscription); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(provider.charged) != 2 || provider.charged[0] != 495 || provider.charged[1] != 990 {
+		t.Errorf("Ожидались списания 495 и 990 (скидка на одно продление), получено %v", provider.charged)
+	}
+	if renewalsLeft != 0 {
+		t.Errorf("Продление со скидкой должно быть израсходовано, осталось %d", renewalsLeft)
+	}
+}
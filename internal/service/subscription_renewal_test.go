@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"ai_tg_writer/internal/config"
+	"ai_tg_writer/internal/domain"
+)
+
+// stubRenewalRepo хранит одну подписку и захватывает ее для списания так же, как ClaimRenewal в базе
+type stubRenewalRepo struct {
+	domain.SubscriptionRepository
+	mu      sync.Mutex
+	stored  domain.Subscription
+	claimed bool
+	attempt int
+}
+
+func (r *stubRenewalRepo) ClaimRenewal(subscription *domain.Subscription, ttl time.Duration) (int, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.claimed || !r.stored.NextPayment.Equal(subscription.NextPayment) || r.stored.FailedAttempts != subscription.FailedAttempts {
+		return 0, false, nil
+	}
+	r.claimed = true
+	r.attempt++
+	return r.attempt, true, nil
+}
+func (r *stubRenewalRepo) ReleaseRenewal(subscriptionID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.claimed = false
+	return nil
+}
+func (r *stubRenewalRepo) Update(subscription *domain.Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stored = *subscription
+	return nil
+}
+
+// stubConcurrentProvider считает списания; запрос к провайдеру занимает время, как в YooKassa
+type stubConcurrentProvider struct {
+	domain.PaymentProvider
+	mu   sync.Mutex
+	keys []string
+}
+
+func (p *stubConcurrentProvider) Name() string { return domain.PaymentProviderYooKassa }
+func (p *stubConcurrentProvider) ChargeSaved(req *domain.ChargeRequest) (*domain.ProviderPayment, error) {
+	time.Sleep(10 * time.Millisecond)
+	p.mu.Lock()
+	p.keys = append(p.keys, req.IdempotencyKey)
+	p.mu.Unlock()
+	id := "pay"
+	return &domain.ProviderPayment{Payment: &domain.Payment{UserID: req.UserID, Provider: p.Name(),
+		ProviderPaymentID: &id, Amount: req.Amount, Status: domain.PaymentStatusSucceeded}}, nil
+}
+
+func TestConcurrentWorkersChargeRenewalOnce(t *testing.T) {
+	customer, method := "42", "pm_1"
+	dueAt := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	subscription := domain.Subscription{ID: 7, UserID: 42, Tariff: "premium", Status: string(domain.SubscriptionStatusActive),
+		Active: true, Amount: 990, NextPayment: dueAt, YKCustomerID: &customer, YKPaymentMethodID: &method,
+		PaymentProvider: domain.PaymentProviderYooKassa}
+	repo := &stubRenewalRepo{stored: subscription}
+	provider := &stubConcurrentProvider{}
+	providers := NewPaymentProviders(domain.PaymentProviderYooKassa, nil, provider)
+	s := NewSubscriptionService(repo, nil, nil, nil, nil, nil, providers, &config.Config{Mode: "production"})
+
+	// Каждый воркер прочитал подписку до того, как ее продлил другой
+	const workers = 5
+	start := make(chan struct{})
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(stale domain.Subscription) {
+			defer wg.Done()
+			<-start
+			errs <- s.ProcessRecurringPayment(&stale)
+		}(subscription)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil && !errors.Is(err, domain.ErrRenewalInProgress) {
+			t.Fatal(err)
+		}
+	}
+
+	// Воркер, прочитавший подписку до продления, но дошедший до нее позже, тоже не списывает
+	stale := subscription
+	if err := s.ProcessRecurringPayment(&stale); !errors.Is(err, domain.ErrRenewalInProgress) {
+		t.Errorf("Продленная подписка не должна списываться повторно, получено %v", err)
+	}
+
+	if len(provider.keys) != 1 {
+		t.Fatalf("Ожидалось одно списание, получено %d: %v", len(provider.keys), provider.keys)
+	}
+	if want := "renewal-7-20261001T090000-1"; provider.keys[0] != want {
+		t.Errorf("Ключ идемпотентности %q, ожидался %q", provider.keys[0], want)
+	}
+	if !repo.stored.NextPayment.After(dueAt) {
+		t.Errorf("Дата следующего списания должна сдвинуться, получено %v", repo.stored.NextPayment)
+	}
+}
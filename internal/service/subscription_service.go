@@ -3,43 +3,66 @@ package service
 import (
 	"ai_tg_writer/internal/config"
 	"ai_tg_writer/internal/domain"
-	"ai_tg_writer/internal/infrastructure/yookassa"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
 	"time"
 )
 
+// SubscriptionNotifier отправляет пользователю уведомления о продлении подписки
+type SubscriptionNotifier interface {
+	SendPaymentFailedMessage(userID int64, attempt, maxAttempts int, nextRetry time.Time) error
+	// SendPaymentFinalNoticeMessage предупреждает, что следующая неудачная попытка приостановит подписку
+	SendPaymentFinalNoticeMessage(userID int64, nextRetry time.Time) error
+	SendSubscriptionSuspendedMessage(userID int64, attempts int) error
+	// SendRenewalReminderMessage напоминает о предстоящем списании; за день до списания — с кнопкой отмены
+	SendRenewalReminderMessage(userID int64, amount float64, chargeAt time.Time, daysLeft int) error
+	// SendPauseEndingMessage напоминает, что пауза скоро закончится и подписка возобновится
+	SendPauseEndingMessage(userID int64, resumeAt, chargeAt time.Time) error
+	// SendSubscriptionResumedMessage сообщает, что пауза закончилась и Premium снова доступен
+	SendSubscriptionResumedMessage(userID int64, nextPayment time.Time) error
+	// SendTrialEndingMessage напоминает, что пробный период заканчивается и спишется цена тарифа
+	SendTrialEndingMessage(userID int64, amount float64, chargeAt time.Time) error
+}
+
 type SubscriptionService struct {
-	repo   domain.SubscriptionRepository
-	yk     *yookassa.Client
-	config *config.Config
-	bot    interface {
-		SendPaymentFailedMessage(userID int64, attempt int) error
-		SendSubscriptionSuspendedMessage(userID int64) error
-	} // Интерфейс для отправки сообщений в Telegram
+	repo      domain.SubscriptionRepository
+	tariffs   domain.TariffRepository
+	payments  domain.PaymentRepository
+	contacts  domain.UserContactRepository
+	notices   domain.SubscriptionNoticeRepository
+	promos    domain.PromoRepository
+	providers *PaymentProviders
+	config    *config.Config
+	bot       SubscriptionNotifier // Интерфейс для отправки сообщений в Telegram
 }
 
-func NewSubscriptionService(repo domain.SubscriptionRepository, ykClient *yookassa.Client, cfg *config.Config) *SubscriptionService {
+func NewSubscriptionService(repo domain.SubscriptionRepository, tariffs domain.TariffRepository, payments domain.PaymentRepository, contacts domain.UserContactRepository, notices domain.SubscriptionNoticeRepository, promos domain.PromoRepository, providers *PaymentProviders, cfg *config.Config) *SubscriptionService {
 	return &SubscriptionService{
-		repo:   repo,
-		yk:     ykClient,
-		config: cfg,
-		bot:    nil, // Будет установлен позже
+		repo:      repo,
+		tariffs:   tariffs,
+		payments:  payments,
+		contacts:  contacts,
+		notices:   notices,
+		promos:    promos,
+		providers: providers,
+		config:    cfg,
+		bot:       nil, // Будет установлен позже
 	}
 }
 
 // NewSubscriptionServiceWithBot создает сервис с ботом для отправки сообщений
-func NewSubscriptionServiceWithBot(repo domain.SubscriptionRepository, ykClient *yookassa.Client, cfg *config.Config, bot interface {
-	SendPaymentFailedMessage(userID int64, attempt int) error
-	SendSubscriptionSuspendedMessage(userID int64) error
-}) *SubscriptionService {
+func NewSubscriptionServiceWithBot(repo domain.SubscriptionRepository, tariffs domain.TariffRepository, payments domain.PaymentRepository, contacts domain.UserContactRepository, notices domain.SubscriptionNoticeRepository, promos domain.PromoRepository, providers *PaymentProviders, cfg *config.Config, bot SubscriptionNotifier) *SubscriptionService {
 	return &SubscriptionService{
-		repo:   repo,
-		yk:     ykClient,
-		config: cfg,
-		bot:    bot,
+		repo:      repo,
+		tariffs:   tariffs,
+		payments:  payments,
+		contacts:  contacts,
+		notices:   notices,
+		promos:    promos,
+		providers: providers,
+		config:    cfg,
+		bot:       bot,
 	}
 }
 
@@ -62,9 +85,10 @@ func (s *SubscriptionService) CreateSubscription(userID int64, tariff string, am
 		Tariff:         tariff,
 		Status:         string(domain.SubscriptionStatusPending),
 		Amount:         amount,
-		NextPayment:    time.Now().UTC().Add(s.config.SubscriptionInterval), // Используем UTC время
-		LastPayment:    time.Now().UTC(),                                    // Используем UTC время
-		Active:         false,                                               // Станет true после успешной оплаты
+		NextPayment:    s.nextPaymentDate(tariff, time.Now().UTC()), // Используем UTC время
+		LastPayment:    time.Now().UTC(),                            // Используем UTC время
+		Active:         false,                                       // Станет true после успешной оплаты
+		StatusReason:   "checkout started",
 	}
 
 	if err := s.repo.Create(subscription); err != nil {
@@ -79,6 +103,11 @@ func (s *SubscriptionService) GetUserSubscription(userID int64) (*domain.Subscri
 	return s.repo.GetByUserID(userID)
 }
 
+// GetSubscriptionHistory получает последние переходы статусов подписок пользователя
+func (s *SubscriptionService) GetSubscriptionHistory(userID int64, limit int) ([]*domain.SubscriptionEvent, error) {
+	return s.repo.GetSubscriptionEvents(userID, limit)
+}
+
 // CancelSubscription отменяет подписку
 func (s *SubscriptionService) CancelSubscription(userID int64) error {
 	log.Printf("🔄 Starting subscription cancellation for user %d", userID)
@@ -129,16 +158,21 @@ func (s *SubscriptionService) ProcessPayment(userID int64, amount float64) error
 	log.Printf("📝 Activating subscription for user %d: ID=%d, Status=%s, Active=%v",
 		userID, subscription.ID, subscription.Status, subscription.Active)
 
+	now := time.Now().UTC() // Используем UTC время
+
+	// Активируем подписку через машину состояний: истекшую подписку оплатой не восстановить
+	if _, err := subscription.Transition(domain.SubscriptionStatusActive, "payment succeeded", now); err != nil {
+		return fmt.Errorf("error activating subscription: %w", err)
+	}
+
 	// Сбрасываем все счетчики неудачных попыток при успешной оплате
 	subscription.FailedAttempts = 0
 	subscription.NextRetry = nil
 	subscription.SuspendedAt = nil
 
-	// Обновляем статус и даты
-	subscription.Status = string(domain.SubscriptionStatusActive)
-	subscription.LastPayment = time.Now().UTC()                                    // Используем UTC время
-	subscription.NextPayment = time.Now().UTC().Add(s.config.SubscriptionInterval) // Используем UTC время
-	subscription.Active = true                                                     // Активируем подписку
+	// Обновляем даты
+	subscription.LastPayment = now
+	subscription.NextPayment = s.nextPaymentDate(subscription.Tariff, now)
 
 	if err := s.repo.Update(subscription); err != nil {
 		return fmt.Errorf("error updating subscription: %w", err)
@@ -148,30 +182,14 @@ func (s *SubscriptionService) ProcessPayment(userID int64, amount float64) error
 	return nil
 }
 
-// IsUserSubscribed проверяет, есть ли у пользователя активная подписка
+// IsUserSubscribed проверяет, дает ли подписка пользователю доступ сейчас:
+// правила те же, что у EntitlementService, без учета выданного администратором Premium
 func (s *SubscriptionService) IsUserSubscribed(userID int64) (bool, error) {
 	subscription, err := s.repo.GetByUserID(userID)
 	if err != nil {
 		return false, fmt.Errorf("error checking subscription: %w", err)
 	}
-
-	// Подписка считается активной если:
-	// 1. Она существует
-	// 2. Поле active = true (подписка не истекла)
-	// 3. Статус 'active' или 'cancelled' (отмененная но еще активная до конца периода)
-	// 4. Не приостановлена (suspended)
-	if subscription == nil || !subscription.Active {
-		return false, nil
-	}
-
-	// Проверяем, что подписка не приостановлена
-	if subscription.Status == string(domain.SubscriptionStatusSuspended) {
-		return false, nil
-	}
-
-	// Подписка активна если статус 'active' или 'cancelled' (отмененная но еще работает)
-	return subscription.Status == string(domain.SubscriptionStatusActive) ||
-		subscription.Status == string(domain.SubscriptionStatusCancelled), nil
+	return subscriptionEntitlement(subscription, s.config.GracePeriod(), time.Now()).HasSubscriptionAccess(), nil
 }
 
 // GetUserTariff получает тариф пользователя
@@ -181,17 +199,19 @@ func (s *SubscriptionService) GetUserTariff(userID int64) (string, error) {
 		return "", fmt.Errorf("error getting subscription: %w", err)
 	}
 
-	if subscription == nil || !subscription.Active {
-		return "free", nil
-	}
-
-	return subscription.Tariff, nil
+	return subscriptionEntitlement(subscription, s.config.GracePeriod(), time.Now()).TariffID, nil
 }
 
 // CreateSubscriptionLink создает ссылку для оплаты подписки
 func (s *SubscriptionService) CreateSubscriptionLink(userID int64, tariff string, amount float64) (string, error) {
+	return s.CreateSubscriptionLinkWithPromo(userID, tariff, amount, "")
+}
+
+// CreateSubscriptionLinkWithPromo создает ссылку для оплаты подписки. Непустой promoCode уменьшает
+// сумму первой оплаты; в подписке сохраняется полная цена тарифа, скидку на продления дает промокод.
+func (s *SubscriptionService) CreateSubscriptionLinkWithPromo(userID int64, tariff string, amount float64, promoCode string) (string, error) {
 	log.Printf("=== CreateSubscriptionLink START ===")
-	log.Printf("UserID: %d, Tariff: %s, Amount: %.2f", userID, tariff, amount)
+	log.Printf("UserID: %d, Tariff: %s, Amount: %.2f, Promo: %q", userID, tariff, amount, promoCode)
 
 	// Убедимся, что есть запись подписки в БД (pending)
 	sub, err := s.repo.GetByUserID(userID)
@@ -203,81 +223,88 @@ func (s *SubscriptionService) CreateSubscriptionLink(userID int64, tariff string
 
 	if sub == nil {
 		log.Printf("📝 Creating new subscription...")
-		if _, err := s.CreateSubscription(userID, tariff, amount); err != nil {
+		if sub, err = s.CreateSubscription(userID, tariff, amount); err != nil {
 			log.Printf("❌ Error creating subscription: %v", err)
 			return "", err
 		}
 		log.Printf("✅ Subscription created successfully")
 	}
 
-	if s.yk == nil {
-		log.Printf("❌ YooKassa client is nil")
-		return "", fmt.Errorf("yookassa client is not configured")
+	var tariffInfo *domain.Tariff
+	if s.tariffs != nil {
+		if tariffInfo, err = s.tariffs.GetByID(tariff); err != nil {
+			log.Printf("⚠️ Error getting tariff %s for provider selection: %v", tariff, err)
+		}
+	}
+	provider, err := s.providers.ForUser(userID, tariffInfo)
+	if err != nil {
+		log.Printf("❌ No payment provider for user %d: %v", userID, err)
+		return "", err
+	}
+	log.Printf("✅ Payment provider selected: %s", provider.Name())
+
+	var promo *domain.PromoCode
+	discount := 0.0
+	if promoCode != "" {
+		if promo, err = s.CheckPromoCode(userID, promoCode); err != nil {
+			return "", err
+		}
+		// Продления Prodamus списывает сам по цене подписки: скидку на них не применить
+		if provider.Name() == domain.PaymentProviderProdamus {
+			return "", fmt.Errorf("%w: promo codes with %s", domain.ErrProviderUnsupported, provider.Name())
+		}
+		discount = promo.Discount(amount)
+		log.Printf("🎟 Promo %s applied for user %d: discount=%.2f", promo.Code, userID, discount)
 	}
-	log.Printf("✅ YooKassa client is configured")
 
 	// Формируем платеж с сохранением метода
-	value := fmt.Sprintf("%.2f", amount)
 	idem := fmt.Sprintf("%d-%d", userID, time.Now().UTC().UnixNano()) // Используем UTC время
-	returnURL := getenv("YK_RETURN_URL_ADDRESS", "")
-
-	log.Printf("💳 Calling YooKassa CreateInitialPayment...")
-	log.Printf("   Value: %s, IdempotenceKey: %s", value, idem)
-	log.Printf("   ReturnURL: %s", returnURL)
-	log.Printf("   CustomerID: %s", strconv.FormatInt(userID, 10))
-
-	payment, err := s.yk.CreateInitialPayment(
-		idem,
-		yookassa.Amount{Value: value, Currency: "RUB"},
-		"Подписка AI TG Writer",
-		strconv.FormatInt(userID, 10),
-		returnURL,
-		map[string]string{"tg_user_id": strconv.FormatInt(userID, 10)},
-	)
+	subscriptionID := sub.ID
+	log.Printf("💳 Creating initial payment via %s: amount=%.2f, IdempotenceKey=%s", provider.Name(), amount-discount, idem)
+
+	payment, err := provider.CreatePayment(&domain.PaymentRequest{
+		UserID:         userID,
+		SubscriptionID: &subscriptionID,
+		Kind:           domain.PaymentKindInitial,
+		Amount:         amount - discount,
+		Currency:       "RUB",
+		Description:    subscriptionReceiptItem,
+		IdempotencyKey: idem,
+		ReturnURL:      getenv("YK_RETURN_URL_ADDRESS", ""),
+		Email:          receiptEmail(s.contacts, userID),
+	})
 	if err != nil {
-		log.Printf("❌ YooKassa CreateInitialPayment error: %v", err)
+		log.Printf("❌ %s CreatePayment error: %v", provider.Name(), err)
 		return "", fmt.Errorf("create initial payment: %w", err)
 	}
-	log.Printf("✅ YooKassa CreateInitialPayment success")
-
-	// Логируем весь ответ от YooKassa для отладки
-	log.Printf("=== YooKassa CreateInitialPayment Response ===")
-	log.Printf("UserID: %d, Amount: %s, IdempotenceKey: %s", userID, value, idem)
-	log.Printf("Full response: %+v", payment)
 
-	// Выводим ключевые поля
-	if id, ok := payment["id"].(string); ok {
-		log.Printf("Payment ID: %s", id)
-	}
-	if status, ok := payment["status"].(string); ok {
-		log.Printf("Payment Status: %s", status)
-	}
-	if conf, ok := payment["confirmation"].(map[string]any); ok {
-		log.Printf("Confirmation: %+v", conf)
-		if confURL, ok := conf["confirmation_url"].(string); ok {
-			log.Printf("Confirmation URL: %s", confURL)
+	if payment.Payment != nil {
+		payment.Payment.IdempotencyKey = idem
+		if promo != nil {
+			payment.Payment.PromoCodeID = &promo.ID
+			payment.Payment.Discount = discount
 		}
+		recordPayment(s.payments, payment.Payment)
+		log.Printf("Payment ID: %s, Status: %s", *payment.Payment.ProviderPaymentID, payment.Payment.Status)
 	}
-	log.Printf("=== End YooKassa Response ===")
-
-	// Достаем confirmation_url из ответа
-	conf, ok := payment["confirmation"].(map[string]any)
-	if !ok {
-		return "", fmt.Errorf("confirmation not found in response")
-	}
-	url, _ := conf["confirmation_url"].(string)
-	if url == "" {
+	if payment.ConfirmationURL == "" {
 		return "", fmt.Errorf("confirmation_url not found")
 	}
-	return url, nil
+	log.Printf("Confirmation URL: %s", payment.ConfirmationURL)
+	return payment.ConfirmationURL, nil
 }
 
-// SaveYooKassaBindingAndActivate сохраняет customer/payment_method и активирует подписку
-func (s *SubscriptionService) SaveYooKassaBindingAndActivate(userID int64, customerID, paymentMethodID, paymentID string, amount float64) error {
-	if err := s.repo.UpdateYooKassaBindings(userID, customerID, paymentMethodID, paymentID); err != nil {
+// SavePaymentBindingAndActivate сохраняет customer/payment_method провайдера и активирует подписку;
+// следующие продления списываются через этого провайдера
+func (s *SubscriptionService) SavePaymentBindingAndActivate(userID int64, provider, customerID, paymentMethodID, paymentID string, amount float64) error {
+	if err := s.repo.UpdatePaymentBinding(userID, provider, customerID, paymentMethodID, paymentID); err != nil {
 		return fmt.Errorf("update bindings: %w", err)
 	}
-	return s.ProcessPayment(userID, amount)
+	if err := s.ProcessPayment(userID, amount); err != nil {
+		return err
+	}
+	s.redeemPromo(userID, provider, paymentID)
+	return nil
 }
 
 // small helper for env with default
@@ -296,66 +323,135 @@ func (s *SubscriptionService) GetSubscriptionsDueForRenewal() ([]*domain.Subscri
 // ProcessRecurringPayment обрабатывает рекуррентный платеж для подписки
 func (s *SubscriptionService) ProcessRecurringPayment(subscription *domain.Subscription) error {
 	if subscription.YKCustomerID == nil || subscription.YKPaymentMethodID == nil {
-		return fmt.Errorf("missing YooKassa binding data")
+		return fmt.Errorf("missing payment binding data")
+	}
+	attempt, err := s.claimRenewal(subscription)
+	if err != nil {
+		return err
 	}
+	defer s.releaseRenewal(subscription)
+	return s.chargeRenewal(subscription, attempt)
+}
 
-	log.Printf("🔄 Processing recurring payment for user %d, subscription ID %d",
-		subscription.UserID, subscription.ID)
+// renewalClaimTTL на сколько подписка захватывается для списания; дольше любого запроса к провайдеру,
+// чтобы захват упавшего экземпляра воркера освободился к следующим проходам
+const renewalClaimTTL = 10 * time.Minute
 
-	// Создаем идемпотентный ключ
-	idempotenceKey := fmt.Sprintf("%d-recurring-%d", subscription.UserID, time.Now().UTC().Unix()) // Используем UTC время
+// claimRenewal захватывает подписку для списания, чтобы при нескольких экземплярах воркера
+// одно продление не списывалось дважды, и возвращает номер попытки списания
+func (s *SubscriptionService) claimRenewal(subscription *domain.Subscription) (int, error) {
+	attempt, claimed, err := s.repo.ClaimRenewal(subscription, renewalClaimTTL)
+	if err != nil {
+		return 0, fmt.Errorf("claim subscription %d for renewal: %w", subscription.ID, err)
+	}
+	if !claimed {
+		return 0, domain.ErrRenewalInProgress
+	}
+	return attempt, nil
+}
+
+// releaseRenewal освобождает захват подписки; если не удалось, захват истечет через renewalClaimTTL
+func (s *SubscriptionService) releaseRenewal(subscription *domain.Subscription) {
+	if err := s.repo.ReleaseRenewal(subscription.ID); err != nil {
+		log.Printf("❌ Failed to release renewal claim of subscription %d: %v", subscription.ID, err)
+	}
+}
+
+// renewalIdempotencyKey ключ идемпотентности списания: подписка, период оплаты и номер попытки.
+// Повтор той же попытки (например, после сбоя воркера) не приводит к второму списанию у провайдера.
+func renewalIdempotencyKey(subscription *domain.Subscription, attempt int) string {
+	return fmt.Sprintf("renewal-%d-%s-%d", subscription.ID, subscription.NextPayment.UTC().Format("20060102T150405"), attempt)
+}
+
+// chargeRenewal списывает продление с сохраненного метода оплаты по захваченной подписке
+func (s *SubscriptionService) chargeRenewal(subscription *domain.Subscription, attempt int) error {
+	provider, err := s.providers.Get(subscription.PaymentProvider)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("🔄 Processing recurring payment for user %d, subscription ID %d via %s (attempt %d)",
+		subscription.UserID, subscription.ID, provider.Name(), attempt)
+
+	idempotenceKey := renewalIdempotencyKey(subscription, attempt)
+
+	// Скидка по промокоду действует на заданное число продлений
+	redemption, discount := s.renewalDiscount(subscription)
+	amount := subscription.Amount - discount
 
 	// Создаем рекуррентный платеж
-	payment, err := s.yk.CreateRecurringPayment(
-		idempotenceKey,
-		yookassa.Amount{
-			Value:    fmt.Sprintf("%.2f", subscription.Amount),
-			Currency: "RUB",
-		},
-		"Продление подписки AI TG Writer",
-		*subscription.YKCustomerID,
-		*subscription.YKPaymentMethodID,
-		map[string]string{
-			"tg_user_id":      fmt.Sprintf("%d", subscription.UserID),
-			"subscription_id": fmt.Sprintf("%d", subscription.ID),
-			"type":            "recurring",
-		},
-	)
+	payment, err := provider.ChargeSaved(&domain.ChargeRequest{
+		UserID:          subscription.UserID,
+		SubscriptionID:  subscription.ID,
+		Amount:          amount,
+		Currency:        "RUB",
+		Description:     renewalReceiptItem,
+		IdempotencyKey:  idempotenceKey,
+		CustomerID:      *subscription.YKCustomerID,
+		PaymentMethodID: *subscription.YKPaymentMethodID,
+		Email:           receiptEmail(s.contacts, subscription.UserID),
+	})
 
 	if err != nil {
 		log.Printf("❌ Recurring payment failed for user %d: %v", subscription.UserID, err)
+		subscriptionID := subscription.ID
+		recordPayment(s.payments, &domain.Payment{
+			UserID:         subscription.UserID,
+			SubscriptionID: &subscriptionID,
+			Provider:       provider.Name(),
+			Kind:           domain.PaymentKindRecurring,
+			Amount:         amount,
+			Currency:       "RUB",
+			Status:         domain.PaymentStatusFailed,
+			FailureReason:  err.Error(),
+			IdempotencyKey: idempotenceKey,
+			PromoCodeID:    promoCodeID(redemption),
+			Discount:       discount,
+		})
 		return s.handlePaymentFailure(subscription)
 	}
 
 	// Проверяем статус платежа
-	status, ok := payment["status"].(string)
-	if !ok || status == "canceled" {
-		log.Printf("❌ Recurring payment canceled for user %d, status: %s", subscription.UserID, status)
+	if payment.Payment == nil {
+		log.Printf("❌ Recurring payment of user %d returned no payment", subscription.UserID)
+		return s.handlePaymentFailure(subscription)
+	}
+	payment.Payment.IdempotencyKey = idempotenceKey
+	payment.Payment.PromoCodeID = promoCodeID(redemption)
+	payment.Payment.Discount = discount
+	recordPayment(s.payments, payment.Payment)
+
+	status := payment.Payment.Status
+	if status == domain.PaymentStatusFailed {
+		log.Printf("❌ Recurring payment canceled for user %d: %s", subscription.UserID, payment.Payment.FailureReason)
 		return s.handlePaymentFailure(subscription)
 	}
 
-	log.Printf("✅ Recurring payment created for user %d: %s, status: %s", subscription.UserID, payment["id"], status)
+	log.Printf("✅ Recurring payment created for user %d: %s, status: %s", subscription.UserID, *payment.Payment.ProviderPaymentID, status)
 
 	// Если платеж успешный, сбрасываем счетчик неудач и восстанавливаем подписку
-	if status == "succeeded" {
+	if status == domain.PaymentStatusSucceeded {
 		log.Printf("✅ Payment succeeded for user %d, resetting failure counters and restoring subscription", subscription.UserID)
 
+		if _, err := subscription.Transition(domain.SubscriptionStatusActive, "renewal payment succeeded", time.Now().UTC()); err != nil {
+			return fmt.Errorf("error restoring subscription: %w", err)
+		}
+
 		// Сбрасываем все поля неудачных попыток
 		subscription.FailedAttempts = 0
 		subscription.NextRetry = nil
 		subscription.SuspendedAt = nil
-		subscription.Active = true
-		subscription.Status = "active"
 
 		// Обновляем параметры успешного платежа
 		subscription.LastPayment = time.Now().UTC()
-		subscription.NextPayment = time.Now().UTC().Add(s.config.SubscriptionInterval)
+		subscription.NextPayment = s.nextPaymentDate(subscription.Tariff, time.Now().UTC())
 
 		// Обновляем подписку в базе
 		if err := s.repo.Update(subscription); err != nil {
 			log.Printf("❌ Failed to update subscription after successful payment: %v", err)
 			return err
 		}
+		s.consumeRenewalDiscount(redemption)
 
 		log.Printf("✅ Subscription restored for user %d after successful payment", subscription.UserID)
 		return nil
@@ -365,53 +461,178 @@ func (s *SubscriptionService) ProcessRecurringPayment(subscription *domain.Subsc
 	return s.handlePaymentFailure(subscription)
 }
 
-// GetAvailableTariffs возвращает доступные тарифы
+// GetAvailableTariffs возвращает тарифы, доступные для покупки
 func (s *SubscriptionService) GetAvailableTariffs() []domain.Tariff {
-	return []domain.Tariff{
-		{
-			ID:          "premium",
-			Name:        "Premium",
-			Price:       990.0,
-			Period:      "month",
-			Description: "Премиум подписка с неограниченными возможностями",
-			Features: []string{
-				"Неограниченное количество запросов",
-				"Приоритетная поддержка",
-				"Расширенные функции",
-				"Доступ к новым возможностям",
-			},
-		},
-	}
-}
-
-// handlePaymentFailure обрабатывает неудачную попытку оплаты
+	if s.tariffs == nil {
+		return nil
+	}
+	tariffs, err := s.tariffs.GetVisible()
+	if err != nil {
+		log.Printf("❌ Error getting available tariffs: %v", err)
+		return nil
+	}
+
+	available := make([]domain.Tariff, 0, len(tariffs))
+	for _, tariff := range tariffs {
+		available = append(available, *tariff)
+	}
+	return available
+}
+
+// GetTariff возвращает тариф из каталога. Возвращает nil, если тариф не найден.
+func (s *SubscriptionService) GetTariff(id string) (*domain.Tariff, error) {
+	if s.tariffs == nil {
+		return nil, fmt.Errorf("tariff catalogue is not configured")
+	}
+	return s.tariffs.GetByID(id)
+}
+
+// GetAllTariffs возвращает весь каталог тарифов, включая скрытые
+func (s *SubscriptionService) GetAllTariffs() ([]*domain.Tariff, error) {
+	if s.tariffs == nil {
+		return nil, fmt.Errorf("tariff catalogue is not configured")
+	}
+	return s.tariffs.GetAll()
+}
+
+// SaveTariff проверяет и сохраняет тариф. Цена действующих подписок не меняется:
+// при продлении списывается сумма, сохраненная в подписке.
+func (s *SubscriptionService) SaveTariff(tariff *domain.Tariff) error {
+	if s.tariffs == nil {
+		return fmt.Errorf("tariff catalogue is not configured")
+	}
+	if err := ValidateTariff(tariff); err != nil {
+		return err
+	}
+	if err := s.tariffs.Save(tariff); err != nil {
+		return fmt.Errorf("error saving tariff: %w", err)
+	}
+	log.Printf("💎 Tariff %s saved: price=%.2f %s, period=%s, visible=%v",
+		tariff.ID, tariff.Price, tariff.Currency, tariff.Period, tariff.Visible)
+	return nil
+}
+
+// maxRetryAttempts максимальное число повторных списаний в расписании тарифа
+const maxRetryAttempts = 10
+
+// ValidateTariff проверяет корректность тарифа перед сохранением
+func ValidateTariff(tariff *domain.Tariff) error {
+	if tariff.ID == "" || len(tariff.ID) > 50 {
+		return fmt.Errorf("tariff id must be 1-50 characters")
+	}
+	if tariff.Name == "" {
+		return fmt.Errorf("tariff name is required")
+	}
+	if tariff.Price < 0 {
+		return fmt.Errorf("tariff price must not be negative")
+	}
+	if !domain.IsValidTariffPeriod(tariff.Period) {
+		return fmt.Errorf("unknown tariff period: %s", tariff.Period)
+	}
+	if tariff.Currency != "" && len(tariff.Currency) != 3 {
+		return fmt.Errorf("currency must be a 3-letter code")
+	}
+	if tariff.Provider != "" && !domain.IsKnownPaymentProvider(tariff.Provider) {
+		return fmt.Errorf("unknown payment provider: %s", tariff.Provider)
+	}
+	if len(tariff.RetryHours) > maxRetryAttempts {
+		return fmt.Errorf("retry schedule must have at most %d intervals", maxRetryAttempts)
+	}
+	for _, hours := range tariff.RetryHours {
+		if hours <= 0 {
+			return fmt.Errorf("retry intervals must be positive")
+		}
+	}
+	for resource, limit := range tariff.Quotas {
+		if !domain.IsValidQuotaResource(resource) {
+			return fmt.Errorf("unknown quota resource: %s", resource)
+		}
+		if limit < 0 {
+			return fmt.Errorf("quota %s must not be negative", resource)
+		}
+	}
+	if tariff.ID == domain.FreeTariffID && (tariff.Price != 0 || tariff.Visible) {
+		return fmt.Errorf("free tariff must be free and hidden")
+	}
+	if tariff.ID != domain.FreeTariffID && tariff.Visible && tariff.Price == 0 {
+		return fmt.Errorf("visible tariff must have a price")
+	}
+	return nil
+}
+
+// nextPaymentDate рассчитывает дату следующего списания по периоду тарифа.
+// В режиме разработки используется короткий интервал из конфигурации, как и раньше.
+func (s *SubscriptionService) nextPaymentDate(tariffID string, from time.Time) time.Time {
+	if s.config.IsDevMode() {
+		return from.Add(s.config.SubscriptionInterval)
+	}
+
+	period := domain.TariffPeriodMonth
+	if tariff, err := s.GetTariff(tariffID); err != nil {
+		log.Printf("⚠️ Error getting tariff %s, using monthly period: %v", tariffID, err)
+	} else if tariff != nil {
+		period = tariff.Period
+	}
+
+	switch period {
+	case domain.TariffPeriodDay:
+		return from.AddDate(0, 0, 1)
+	case domain.TariffPeriodWeek:
+		return from.AddDate(0, 0, 7)
+	case domain.TariffPeriodMonth:
+		return domain.AddMonths(from, 1)
+	case domain.TariffPeriodYear:
+		return domain.AddMonths(from, 12)
+	default:
+		return from.Add(s.config.SubscriptionInterval)
+	}
+}
+
+// retrySchedule возвращает интервалы повторных списаний для тарифа подписки:
+// расписание тарифа или расписание по умолчанию из конфигурации
+func (s *SubscriptionService) retrySchedule(tariffID string) []int {
+	tariff, err := s.GetTariff(tariffID)
+	if err != nil {
+		log.Printf("⚠️ Error getting tariff %s, using default retry schedule: %v", tariffID, err)
+	} else if tariff != nil && len(tariff.RetryHours) > 0 {
+		return tariff.RetryHours
+	}
+	return s.config.DunningRetryHours
+}
+
+// retryInterval переводит интервал расписания в длительность: часы, в режиме разработки — минуты
+func (s *SubscriptionService) retryInterval(hours int) time.Duration {
+	if s.config.IsDevMode() {
+		return time.Duration(hours) * time.Minute
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// handlePaymentFailure обрабатывает неудачную попытку оплаты: планирует следующую попытку
+// по расписанию тарифа, а когда расписание исчерпано — приостанавливает подписку
 func (s *SubscriptionService) handlePaymentFailure(subscription *domain.Subscription) error {
 	log.Printf("🔄 Handling payment failure for user %d, attempt %d", subscription.UserID, subscription.FailedAttempts+1)
 
 	// Увеличиваем счетчик неудачных попыток
 	subscription.FailedAttempts++
 
-	if subscription.FailedAttempts >= 3 {
-		// После 3 неудач приостанавливаем подписку
-		log.Printf("❌ Suspending subscription for user %d after 3 failed attempts", subscription.UserID)
+	schedule := s.retrySchedule(subscription.Tariff)
+	maxAttempts := len(schedule) + 1
+
+	if subscription.FailedAttempts >= maxAttempts {
+		// Расписание исчерпано — приостанавливаем подписку
+		log.Printf("❌ Suspending subscription for user %d after %d failed attempts", subscription.UserID, subscription.FailedAttempts)
 		if err := s.repo.SuspendSubscription(subscription.UserID); err != nil {
 			return fmt.Errorf("failed to suspend subscription: %w", err)
 		}
 
 		// Отправляем уведомление о приостановке
-		s.sendSubscriptionSuspendedMessage(subscription.UserID)
+		s.sendSubscriptionSuspendedMessage(subscription.UserID, subscription.FailedAttempts)
 		return nil
 	}
 
 	// Планируем следующую попытку
-	var retryInterval time.Duration
-	if s.config.IsDevMode() {
-		retryInterval = 1 * time.Minute // Для разработки
-	} else {
-		retryInterval = 1 * time.Hour // Для продакшена
-	}
-
-	nextRetry := time.Now().UTC().Add(retryInterval) // Используем UTC время
+	nextRetry := time.Now().UTC().Add(s.retryInterval(schedule[subscription.FailedAttempts-1])) // Используем UTC время
 	subscription.NextRetry = &nextRetry
 
 	// Обновляем подписку ОДИН РАЗ со всеми изменениями
@@ -420,11 +641,15 @@ func (s *SubscriptionService) handlePaymentFailure(subscription *domain.Subscrip
 		return fmt.Errorf("failed to update subscription: %w", err)
 	}
 
-	log.Printf("⏰ Next retry scheduled for user %d at %s (attempt %d)",
-		subscription.UserID, nextRetry.Format("15:04:05"), subscription.FailedAttempts)
+	log.Printf("⏰ Next retry scheduled for user %d at %s (attempt %d of %d)",
+		subscription.UserID, nextRetry.Format("2006-01-02 15:04:05"), subscription.FailedAttempts, maxAttempts)
 
-	// Отправляем уведомление о неудачной попытке
-	s.sendPaymentFailedMessage(subscription.UserID, subscription.FailedAttempts)
+	// Перед последней попыткой предупреждаем о приостановке, иначе — о неудачном списании
+	if subscription.FailedAttempts == maxAttempts-1 {
+		s.sendPaymentFinalNoticeMessage(subscription.UserID, nextRetry)
+	} else {
+		s.sendPaymentFailedMessage(subscription.UserID, subscription.FailedAttempts, maxAttempts, nextRetry)
+	}
 	return nil
 }
 
@@ -438,11 +663,6 @@ func (s *SubscriptionService) GetAllActiveSubscriptions() ([]*domain.Subscriptio
 	return s.repo.GetAllActiveSubscriptions()
 }
 
-// GetUserPaymentHistory получает историю всех платежей пользователя
-func (s *SubscriptionService) GetUserPaymentHistory(userID int64) ([]*domain.Subscription, error) {
-	return s.repo.GetUserPaymentHistory(userID)
-}
-
 // RetryPayment пытается списать деньги с текущего метода оплаты
 func (s *SubscriptionService) RetryPayment(userID int64) error {
 	// Используем GetAnyByUserID чтобы найти подписку независимо от статуса active
@@ -457,12 +677,22 @@ func (s *SubscriptionService) RetryPayment(userID int64) error {
 
 	// Проверяем, что подписка не приостановлена
 	if subscription.Status == string(domain.SubscriptionStatusSuspended) {
-		return fmt.Errorf("subscription is suspended after 3 failed attempts")
+		return fmt.Errorf("subscription is suspended after failed payment attempts")
 	}
 
 	log.Printf("🔄 Starting retry payment for user %d (previous failed attempts: %d)",
 		userID, subscription.FailedAttempts)
 
+	if subscription.YKCustomerID == nil || subscription.YKPaymentMethodID == nil {
+		return fmt.Errorf("missing payment binding data")
+	}
+	// Захватываем подписку до сброса счетчиков, чтобы не пересечься с повторной попыткой воркера
+	attempt, err := s.claimRenewal(subscription)
+	if err != nil {
+		return err
+	}
+	defer s.releaseRenewal(subscription)
+
 	// Сбрасываем счетчик неудач и время повторной попытки
 	subscription.FailedAttempts = 0
 	subscription.NextRetry = nil
@@ -475,7 +705,7 @@ func (s *SubscriptionService) RetryPayment(userID int64) error {
 	log.Printf("✅ Counters reset for user %d, starting recurring payment", userID)
 
 	// Пытаемся списать деньги
-	return s.ProcessRecurringPayment(subscription)
+	return s.chargeRenewal(subscription, attempt)
 }
 
 // ChangePaymentMethod создает новую ссылку для оплаты с новым методом
@@ -494,15 +724,76 @@ func (s *SubscriptionService) ChangePaymentMethod(userID int64) (string, error)
 	return s.CreateSubscriptionLink(userID, subscription.Tariff, subscription.Amount)
 }
 
-// CancelExpiredSubscription полностью отменяет истекшую отмененную подписку
+// CancelExpiredSubscription полностью отменяет подписку, доступ по которой закончился
 func (s *SubscriptionService) CancelExpiredSubscription(userID int64) error {
 	return s.repo.CancelExpired(userID)
 }
 
+// renewalNoticeKind вид напоминания о продлении в журнале отправленных напоминаний
+func renewalNoticeKind(daysBefore int) string {
+	return fmt.Sprintf("renewal_reminder_%d", daysBefore)
+}
+
+// SendRenewalReminders напоминает о предстоящих списаниях за дни из RENEWAL_REMINDER_DAYS.
+// Каждое напоминание отправляется один раз на дату списания; если воркер пропустил
+// несколько сроков, уходит только ближайшее к списанию напоминание.
+func (s *SubscriptionService) SendRenewalReminders() (int, error) {
+	if len(s.config.RenewalReminderDays) == 0 || s.notices == nil {
+		return 0, nil
+	}
+
+	unit := 24 * time.Hour
+	if s.config.IsDevMode() {
+		unit = time.Minute
+	}
+	maxDays := 0
+	for _, days := range s.config.RenewalReminderDays {
+		if days > maxDays {
+			maxDays = days
+		}
+	}
+
+	now := time.Now().UTC()
+	subscriptions, err := s.repo.GetSubscriptionsRenewingBefore(now.Add(time.Duration(maxDays) * unit))
+	if err != nil {
+		return 0, fmt.Errorf("error getting upcoming renewals: %w", err)
+	}
+
+	sent := 0
+	for _, subscription := range subscriptions {
+		if subscription.InTrial() {
+			continue // О конце пробного периода напоминает SendTrialEndingReminders
+		}
+		// Ближайший к списанию срок напоминания, который уже наступил
+		daysBefore := 0
+		for _, days := range s.config.RenewalReminderDays {
+			if !subscription.NextPayment.After(now.Add(time.Duration(days)*unit)) && (daysBefore == 0 || days < daysBefore) {
+				daysBefore = days
+			}
+		}
+		if daysBefore == 0 {
+			continue
+		}
+
+		marked, err := s.notices.MarkNoticeSent(subscription.ID, renewalNoticeKind(daysBefore), subscription.NextPayment)
+		if err != nil {
+			log.Printf("❌ Failed to mark renewal reminder for user %d: %v", subscription.UserID, err)
+			continue
+		}
+		if !marked {
+			continue
+		}
+		_, discount := s.renewalDiscount(subscription)
+		s.sendRenewalReminderMessage(subscription.UserID, subscription.Amount-discount, subscription.NextPayment, daysBefore)
+		sent++
+	}
+	return sent, nil
+}
+
 // sendPaymentFailedMessage отправляет уведомление о неудачной попытке оплаты
-func (s *SubscriptionService) sendPaymentFailedMessage(userID int64, attempt int) {
+func (s *SubscriptionService) sendPaymentFailedMessage(userID int64, attempt, maxAttempts int, nextRetry time.Time) {
 	if s.bot != nil {
-		if err := s.bot.SendPaymentFailedMessage(userID, attempt); err != nil {
+		if err := s.bot.SendPaymentFailedMessage(userID, attempt, maxAttempts, nextRetry); err != nil {
 			log.Printf("❌ Failed to send payment failed message to user %d: %v", userID, err)
 		} else {
 			log.Printf("📨 Payment failed message sent to user %d (attempt %d)", userID, attempt)
@@ -513,9 +804,9 @@ func (s *SubscriptionService) sendPaymentFailedMessage(userID int64, attempt int
 }
 
 // sendSubscriptionSuspendedMessage отправляет уведомление о приостановке подписки
-func (s *SubscriptionService) sendSubscriptionSuspendedMessage(userID int64) {
+func (s *SubscriptionService) sendSubscriptionSuspendedMessage(userID int64, attempts int) {
 	if s.bot != nil {
-		if err := s.bot.SendSubscriptionSuspendedMessage(userID); err != nil {
+		if err := s.bot.SendSubscriptionSuspendedMessage(userID, attempts); err != nil {
 			log.Printf("❌ Failed to send subscription suspended message to user %d: %v", userID, err)
 		} else {
 			log.Printf("📨 Subscription suspended message sent to user %d", userID)
@@ -524,3 +815,29 @@ func (s *SubscriptionService) sendSubscriptionSuspendedMessage(userID int64) {
 		log.Printf("📨 Should send subscription suspended message to user %d - bot not configured", userID)
 	}
 }
+
+// sendPaymentFinalNoticeMessage предупреждает о последней попытке списания перед приостановкой
+func (s *SubscriptionService) sendPaymentFinalNoticeMessage(userID int64, nextRetry time.Time) {
+	if s.bot != nil {
+		if err := s.bot.SendPaymentFinalNoticeMessage(userID, nextRetry); err != nil {
+			log.Printf("❌ Failed to send final payment notice to user %d: %v", userID, err)
+		} else {
+			log.Printf("📨 Final payment notice sent to user %d", userID)
+		}
+	} else {
+		log.Printf("📨 Should send final payment notice to user %d - bot not configured", userID)
+	}
+}
+
+// sendRenewalReminderMessage напоминает о предстоящем списании
+func (s *SubscriptionService) sendRenewalReminderMessage(userID int64, amount float64, chargeAt time.Time, daysLeft int) {
+	if s.bot != nil {
+		if err := s.bot.SendRenewalReminderMessage(userID, amount, chargeAt, daysLeft); err != nil {
+			log.Printf("❌ Failed to send renewal reminder to user %d: %v", userID, err)
+		} else {
+			log.Printf("📨 Renewal reminder sent to user %d (%d days before charge)", userID, daysLeft)
+		}
+	} else {
+		log.Printf("📨 Should send renewal reminder to user %d - bot not configured", userID)
+	}
+}
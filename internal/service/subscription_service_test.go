@@ -0,0 +1,170 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"ai_tg_writer/internal/config"
+	"ai_tg_writer/internal/domain"
+)
+
+type stubDunningRepo struct {
+	domain.SubscriptionRepository
+	upcoming  []*domain.Subscription
+	suspended bool
+}
+
+func (r *stubDunningRepo) Update(subscription *domain.Subscription) error { return nil }
+func (r *stubDunningRepo) SuspendSubscription(userID int64) error {
+	r.suspended = true
+	return nil
+}
+func (r *stubDunningRepo) ClaimRenewal(subscription *domain.Subscription, ttl time.Duration) (int, bool, error) {
+	return 1, true, nil
+}
+func (r *stubDunningRepo) ReleaseRenewal(subscriptionID int64) error { return nil }
+func (r *stubDunningRepo) GetSubscriptionsRenewingBefore(until time.Time) ([]*domain.Subscription, error) {
+	return r.upcoming, nil
+}
+
+type stubTariffRepo struct {
+	domain.TariffRepository
+	tariffs map[string]*domain.Tariff
+}
+
+func (r *stubTariffRepo) GetByID(id string) (*domain.Tariff, error) { return r.tariffs[id], nil }
+
+type stubNotices map[string]bool
+
+func (n stubNotices) MarkNoticeSent(subscriptionID int64, kind string, dueAt time.Time) (bool, error) {
+	key := kind + dueAt.String()
+	if n[key] {
+		return false, nil
+	}
+	n[key] = true
+	return true, nil
+}
+
+type stubNotifier struct {
+	failed, finalNotices, suspended int
+	reminders                       []int
+	pauseEnding, resumed            int
+	trialEnding                     int
+}
+
+func (n *stubNotifier) SendPaymentFailedMessage(userID int64, attempt, maxAttempts int, nextRetry time.Time) error {
+	n.failed++
+	return nil
+}
+func (n *stubNotifier) SendPaymentFinalNoticeMessage(userID int64, nextRetry time.Time) error {
+	n.finalNotices++
+	return nil
+}
+func (n *stubNotifier) SendSubscriptionSuspendedMessage(userID int64, attempts int) error {
+	n.suspended++
+	return nil
+}
+func (n *stubNotifier) SendRenewalReminderMessage(userID int64, amount float64, chargeAt time.Time, daysLeft int) error {
+	n.reminders = append(n.reminders, daysLeft)
+	return nil
+}
+
+func (n *stubNotifier) SendPauseEndingMessage(userID int64, resumeAt, chargeAt time.Time) error {
+	n.pauseEnding++
+	return nil
+}
+func (n *stubNotifier) SendSubscriptionResumedMessage(userID int64, nextPayment time.Time) error {
+	n.resumed++
+	return nil
+}
+
+func (n *stubNotifier) SendTrialEndingMessage(userID int64, amount float64, chargeAt time.Time) error {
+	n.trialEnding++
+	return nil
+}
+
+func TestHandlePaymentFailureFollowsTariffSchedule(t *testing.T) {
+	repo := &stubDunningRepo{}
+	tariffs := &stubTariffRepo{tariffs: map[string]*domain.Tariff{
+		"premium": {ID: "premium", RetryHours: []int{2, 24}},
+	}}
+	notifier := &stubNotifier{}
+	cfg := &config.Config{Mode: "production", DunningRetryHours: []int{1}}
+	s := NewSubscriptionServiceWithBot(repo, tariffs, nil, nil, nil, nil, nil, cfg, notifier)
+	subscription := &domain.Subscription{UserID: 42, Tariff: "premium"}
+
+	before := time.Now().UTC()
+	if err := s.handlePaymentFailure(subscription); err != nil {
+		t.Fatal(err)
+	}
+	if subscription.NextRetry == nil || subscription.NextRetry.Sub(before) < 2*time.Hour {
+		t.Fatalf("Первая повторная попытка должна быть через 2 часа, получено %v", subscription.NextRetry)
+	}
+	if notifier.failed != 1 || notifier.finalNotices != 0 {
+		t.Errorf("После первой неудачи ожидалось обычное уведомление: %+v", notifier)
+	}
+
+	if err := s.handlePaymentFailure(subscription); err != nil {
+		t.Fatal(err)
+	}
+	if notifier.finalNotices != 1 || repo.suspended {
+		t.Errorf("Перед последней попыткой ожидалось предупреждение без приостановки: %+v", notifier)
+	}
+
+	if err := s.handlePaymentFailure(subscription); err != nil {
+		t.Fatal(err)
+	}
+	if !repo.suspended || notifier.suspended != 1 {
+		t.Error("После исчерпания расписания подписка должна быть приостановлена")
+	}
+}
+
+func TestSendRenewalRemindersOncePerCharge(t *testing.T) {
+	chargeAt := time.Now().UTC().Add(20 * time.Hour)
+	repo := &stubDunningRepo{upcoming: []*domain.Subscription{{ID: 1, UserID: 42, Amount: 299, NextPayment: chargeAt}}}
+	notifier := &stubNotifier{}
+	cfg := &config.Config{Mode: "production", RenewalReminderDays: []int{3, 1}}
+	s := NewSubscriptionServiceWithBot(repo, nil, nil, nil, stubNotices{}, nil, nil, cfg, notifier)
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.SendRenewalReminders(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Списание меньше чем через сутки: уходит только напоминание за день, и только один раз
+	if len(notifier.reminders) != 1 || notifier.reminders[0] != 1 {
+		t.Errorf("Ожидалось одно напоминание за день до списания, получено %v", notifier.reminders)
+	}
+}
+
+func TestNextPaymentDateFollowsTariffPeriod(t *testing.T) {
+	tariffs := &stubTariffRepo{tariffs: map[string]*domain.Tariff{
+		"week":  {ID: "week", Period: domain.TariffPeriodWeek},
+		"month": {ID: "month", Period: domain.TariffPeriodMonth},
+		"year":  {ID: "year", Period: domain.TariffPeriodYear},
+	}}
+	cfg := &config.Config{Mode: "production", SubscriptionInterval: 30 * 24 * time.Hour}
+	s := NewSubscriptionService(nil, tariffs, nil, nil, nil, nil, nil, cfg)
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 12, 0, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name     string
+		tariff   string
+		from     time.Time
+		expected time.Time
+	}{
+		{"неделя", "week", date(2026, time.January, 15), date(2026, time.January, 22)},
+		{"месяц", "month", date(2026, time.January, 15), date(2026, time.February, 15)},
+		{"месяц с 31-го", "month", date(2026, time.January, 31), date(2026, time.February, 28)},
+		{"месяц с 29 февраля", "month", date(2028, time.February, 29), date(2028, time.March, 29)},
+		{"год", "year", date(2026, time.January, 15), date(2027, time.January, 15)},
+		{"год с 29 февраля", "year", date(2028, time.February, 29), date(2029, time.February, 28)},
+	}
+	for _, tc := range cases {
+		if got := s.nextPaymentDate(tc.tariff, tc.from); !got.Equal(tc.expected) {
+			t.Errorf("%s: ожидалось %s, получено %s", tc.name, tc.expected, got)
+		}
+	}
+}
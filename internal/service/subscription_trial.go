@@ -0,0 +1,194 @@
+package service
+
+import (
+	"ai_tg_writer/internal/domain"
+	"fmt"
+	"log"
+	"time"
+)
+
+// trialEndingNoticeKind вид напоминания о конце пробного периода в журнале отправленных напоминаний
+const trialEndingNoticeKind = "trial_ending"
+
+// minTrialBindingAmount минимальная сумма платежа в YooKassa
+const minTrialBindingAmount = 1.0
+
+// TrialOffer условия пробного периода, доступного пользователю
+type TrialOffer struct {
+	Days          int
+	Tariff        *domain.Tariff // Тариф, который действует в пробный период и оплачивается после него
+	BindingAmount float64        // Сумма, которая спишется при привязке карты
+}
+
+// TrialOffer возвращает условия пробного периода или nil, если он пользователю недоступен:
+// пробный период выключен, не подключена YooKassa или у пользователя уже была подписка
+func (s *SubscriptionService) TrialOffer(userID int64) (*TrialOffer, error) {
+	if s.config.TrialDays <= 0 || s.tariffs == nil || !s.providers.Has(domain.PaymentProviderYooKassa) {
+		return nil, nil
+	}
+	tariff, err := s.tariffs.GetByID(s.config.TrialTariffID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting trial tariff: %w", err)
+	}
+	if tariff == nil {
+		log.Printf("⚠️ Trial tariff %q not found, trial is disabled", s.config.TrialTariffID)
+		return nil, nil
+	}
+	used, err := s.repo.HasSubscriptionHistory(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking subscription history: %w", err)
+	}
+	if used {
+		return nil, nil
+	}
+	return &TrialOffer{Days: s.config.TrialDays, Tariff: tariff, BindingAmount: s.trialBindingAmount()}, nil
+}
+
+// trialBindingAmount возвращает сумму привязки карты не меньше минимального платежа YooKassa
+func (s *SubscriptionService) trialBindingAmount() float64 {
+	if s.config.TrialBindingAmount < minTrialBindingAmount {
+		return minTrialBindingAmount
+	}
+	return s.config.TrialBindingAmount
+}
+
+// CreateTrialLink создает ссылку на привязку карты для пробного периода. Карта привязывается
+// первым платежом YooKassa на символическую сумму, который возвращается после сохранения карты
+// (RefundService.RefundCardBinding); цена тарифа спишется после пробного периода.
+func (s *SubscriptionService) CreateTrialLink(userID int64) (string, error) {
+	offer, err := s.TrialOffer(userID)
+	if err != nil {
+		return "", err
+	}
+	if offer == nil {
+		return "", fmt.Errorf("trial is not available for user %d", userID)
+	}
+
+	sub, err := s.repo.GetByUserID(userID)
+	if err != nil {
+		return "", fmt.Errorf("get subscription: %w", err)
+	}
+	if sub == nil {
+		if sub, err = s.CreateSubscription(userID, offer.Tariff.ID, offer.Tariff.Price); err != nil {
+			return "", err
+		}
+	}
+
+	provider, err := s.providers.Get(domain.PaymentProviderYooKassa)
+	if err != nil {
+		return "", err
+	}
+	idem := fmt.Sprintf("trial-%d-%d", userID, time.Now().UTC().UnixNano())
+	subscriptionID := sub.ID
+	log.Printf("🎁 Creating trial binding payment for user %d: amount=%.2f, IdempotenceKey=%s", userID, offer.BindingAmount, idem)
+
+	payment, err := provider.CreatePayment(&domain.PaymentRequest{
+		UserID:         userID,
+		SubscriptionID: &subscriptionID,
+		Kind:           domain.PaymentKindTrial,
+		Amount:         offer.BindingAmount,
+		Currency:       "RUB",
+		Description:    trialReceiptItem,
+		IdempotencyKey: idem,
+		ReturnURL:      getenv("YK_RETURN_URL_ADDRESS", ""),
+		Email:          receiptEmail(s.contacts, userID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("create trial payment: %w", err)
+	}
+	if payment.Payment != nil {
+		payment.Payment.IdempotencyKey = idem
+		recordPayment(s.payments, payment.Payment)
+	}
+	if payment.ConfirmationURL == "" {
+		return "", fmt.Errorf("confirmation_url not found")
+	}
+	return payment.ConfirmationURL, nil
+}
+
+// SavePaymentBindingAndStartTrial сохраняет привязанную карту и начинает пробный период:
+// подписка активна до конца пробного периода, затем воркер спишет цену тарифа через
+// ProcessRecurringPayment. Пробный период начинается только у неоплаченной (pending) подписки без него.
+func (s *SubscriptionService) SavePaymentBindingAndStartTrial(userID int64, provider, customerID, paymentMethodID, paymentID string) error {
+	if err := s.repo.UpdatePaymentBinding(userID, provider, customerID, paymentMethodID, paymentID); err != nil {
+		return fmt.Errorf("update bindings: %w", err)
+	}
+
+	subscription, err := s.repo.GetAnyByUserID(userID)
+	if err != nil {
+		return fmt.Errorf("error getting subscription: %w", err)
+	}
+	if subscription == nil {
+		return fmt.Errorf("subscription not found")
+	}
+	// Доступность пробного периода проверялась при создании ссылки. Если пользователь оплатил
+	// вторую ссылку или старую после перехода на платную подписку, пробный период не начинается
+	// заново: иначе он бы продлился, а оплата продления сдвинулась. Сохраняется только карта.
+	if subscription.TrialEndsAt != nil || subscription.Status != string(domain.SubscriptionStatusPending) {
+		log.Printf("⚠️ Trial binding payment %s of user %d: trial already used (status %s), only the card is saved",
+			paymentID, userID, subscription.Status)
+		return nil
+	}
+
+	now := time.Now().UTC()
+	if _, err := subscription.Transition(domain.SubscriptionStatusActive, "trial started", now); err != nil {
+		return fmt.Errorf("error starting trial: %w", err)
+	}
+	trialEndsAt := now.Add(s.config.TrialPeriod())
+	subscription.FailedAttempts = 0
+	subscription.NextRetry = nil
+	subscription.LastPayment = now
+	subscription.NextPayment = trialEndsAt
+	subscription.TrialEndsAt = &trialEndsAt
+
+	if err := s.repo.Update(subscription); err != nil {
+		return fmt.Errorf("error updating subscription: %w", err)
+	}
+	log.Printf("🎁 Trial started for user %d until %s, then %.2f will be charged",
+		userID, trialEndsAt.Format("2006-01-02 15:04:05"), subscription.Amount)
+	return nil
+}
+
+// SendTrialEndingReminders напоминает за день до конца пробного периода о предстоящем списании.
+// Напоминание отправляется один раз на каждый пробный период.
+func (s *SubscriptionService) SendTrialEndingReminders() (int, error) {
+	if s.notices == nil {
+		return 0, nil
+	}
+
+	subscriptions, err := s.repo.GetSubscriptionsRenewingBefore(time.Now().UTC().Add(s.dayUnit()))
+	if err != nil {
+		return 0, fmt.Errorf("error getting ending trials: %w", err)
+	}
+
+	sent := 0
+	for _, subscription := range subscriptions {
+		if !subscription.InTrial() {
+			continue
+		}
+		marked, err := s.notices.MarkNoticeSent(subscription.ID, trialEndingNoticeKind, subscription.NextPayment)
+		if err != nil {
+			log.Printf("❌ Failed to mark trial ending reminder for user %d: %v", subscription.UserID, err)
+			continue
+		}
+		if !marked {
+			continue
+		}
+		s.sendTrialEndingMessage(subscription.UserID, subscription.Amount, subscription.NextPayment)
+		sent++
+	}
+	return sent, nil
+}
+
+// sendTrialEndingMessage напоминает о конце пробного периода
+func (s *SubscriptionService) sendTrialEndingMessage(userID int64, amount float64, chargeAt time.Time) {
+	if s.bot != nil {
+		if err := s.bot.SendTrialEndingMessage(userID, amount, chargeAt); err != nil {
+			log.Printf("❌ Failed to send trial ending reminder to user %d: %v", userID, err)
+		} else {
+			log.Printf("📨 Trial ending reminder sent to user %d", userID)
+		}
+	} else {
+		log.Printf("📨 Should send trial ending reminder to user %d - bot not configured", userID)
+	}
+}
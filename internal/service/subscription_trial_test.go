@@ -0,0 +1,97 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"ai_tg_writer/internal/config"
+	"ai_tg_writer/internal/domain"
+)
+
+type stubTrialRepo struct {
+	domain.SubscriptionRepository
+	subscription *domain.Subscription
+}
+
+func (r *stubTrialRepo) UpdatePaymentBinding(userID int64, provider, customerID, paymentMethodID, lastPaymentID string) error {
+	r.subscription.YKPaymentMethodID = &paymentMethodID
+	r.subscription.YKLastPaymentID = &lastPaymentID
+	return nil
+}
+func (r *stubTrialRepo) GetAnyByUserID(userID int64) (*domain.Subscription, error) {
+	return r.subscription, nil
+}
+func (r *stubTrialRepo) Update(subscription *domain.Subscription) error { return nil }
+func (r *stubTrialRepo) GetSubscriptionsRenewingBefore(until time.Time) ([]*domain.Subscription, error) {
+	return []*domain.Subscription{r.subscription}, nil
+}
+
+func TestStartTrialAndRemindBeforeConversion(t *testing.T) {
+	repo := &stubTrialRepo{subscription: &domain.Subscription{ID: 1, UserID: 42, Tariff: "premium",
+		Status: string(domain.SubscriptionStatusPending), Amount: 299}}
+	notifier := &stubNotifier{}
+	// В режиме разработки дни считаются минутами: пробный период — 1 минута, напоминание — за минуту
+	cfg := &config.Config{Mode: "development", TrialDays: 1, RenewalReminderDays: []int{1}, GracePeriodDays: 3}
+	s := NewSubscriptionServiceWithBot(repo, nil, nil, nil, stubNotices{}, nil, nil, cfg, notifier)
+
+	if err := s.SavePaymentBindingAndStartTrial(42, domain.PaymentProviderYooKassa, "42", "pm_1", "pay_1"); err != nil {
+		t.Fatal(err)
+	}
+	sub := repo.subscription
+	if sub.Status != string(domain.SubscriptionStatusActive) || !sub.InTrial() {
+		t.Fatalf("Должен начаться пробный период: %+v", sub)
+	}
+	if !sub.NextPayment.Equal(*sub.TrialEndsAt) {
+		t.Errorf("Первое списание должно совпадать с концом пробного периода: %v != %v", sub.NextPayment, *sub.TrialEndsAt)
+	}
+	if source := NewEntitlementService(nil, nil, cfg).ForSubscription(sub).Source; source != domain.EntitlementTrial {
+		t.Errorf("Ожидался доступ по пробному периоду, получен %s", source)
+	}
+	if CanPause(sub, time.Now().UTC()) {
+		t.Error("Пробный период нельзя поставить на паузу")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.SendTrialEndingReminders(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if notifier.trialEnding != 1 {
+		t.Errorf("Напоминание о конце пробного периода должно прийти один раз, пришло %d", notifier.trialEnding)
+	}
+	if sent, _ := s.SendRenewalReminders(); sent != 0 {
+		t.Errorf("Обычное напоминание о продлении в пробный период не отправляется, отправлено %d", sent)
+	}
+
+	// Оплата продления завершает пробный период
+	sub.LastPayment = sub.NextPayment
+	if sub.InTrial() {
+		t.Error("После первого списания пробный период закончился")
+	}
+}
+
+func TestSecondTrialBindingDoesNotRestartTrial(t *testing.T) {
+	repo := &stubTrialRepo{subscription: &domain.Subscription{ID: 1, UserID: 42, Tariff: "premium",
+		Status: string(domain.SubscriptionStatusPending), Amount: 299}}
+	cfg := &config.Config{Mode: "production", TrialDays: 7}
+	s := NewSubscriptionService(repo, nil, nil, nil, nil, nil, nil, cfg)
+
+	if err := s.SavePaymentBindingAndStartTrial(42, domain.PaymentProviderYooKassa, "42", "pm_1", "pay_1"); err != nil {
+		t.Fatal(err)
+	}
+	sub := repo.subscription
+	trialEndsAt, nextPayment := *sub.TrialEndsAt, sub.NextPayment
+
+	// Вторая ссылка на пробный период оплачена позже: карта сохраняется, пробный период не продлевается
+	time.Sleep(time.Millisecond)
+	if err := s.SavePaymentBindingAndStartTrial(42, domain.PaymentProviderYooKassa, "42", "pm_2", "pay_2"); err != nil {
+		t.Fatal(err)
+	}
+	if !sub.TrialEndsAt.Equal(trialEndsAt) || !sub.NextPayment.Equal(nextPayment) {
+		t.Errorf("Пробный период не должен начаться заново: конец %v (был %v), списание %v (было %v)",
+			*sub.TrialEndsAt, trialEndsAt, sub.NextPayment, nextPayment)
+	}
+	if *sub.YKPaymentMethodID != "pm_2" {
+		t.Errorf("Новая карта должна сохраниться, сохранена %s", *sub.YKPaymentMethodID)
+	}
+}
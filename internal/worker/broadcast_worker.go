@@ -0,0 +1,182 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"ai_tg_writer/internal/infrastructure/bot"
+	"ai_tg_writer/internal/infrastructure/database"
+	"ai_tg_writer/internal/monitoring"
+)
+
+const (
+	// broadcastBatchSize получателей за один проход: между пачками проверяется пауза/отмена
+	broadcastBatchSize = 20
+	// broadcastPollInterval период проверки запущенных рассылок
+	broadcastPollInterval = 5 * time.Second
+	// broadcastClaimTTL на сколько захватывается пачка получателей; с запасом больше времени
+	// отправки пачки с фоновым приоритетом, чтобы пачка упавшего экземпляра досталась другим
+	broadcastClaimTTL = 10 * time.Minute
+)
+
+// broadcastStore хранилище рассылок, с которым работает воркер
+type broadcastStore interface {
+	GetRunning() ([]*database.Broadcast, error)
+	GetByID(id int64) (*database.Broadcast, error)
+	ClaimRecipients(id int64, limit int, ttl time.Duration) ([]int64, error)
+	CountUnfinishedRecipients(id int64) (int, error)
+	MarkRecipient(id, userID int64, status, errorText string) error
+	Transition(id int64, to database.BroadcastStatus, from ...database.BroadcastStatus) (bool, error)
+	GetDeliveryStats(id int64) (map[string]int, error)
+}
+
+// broadcastSender отправляет сообщения рассылки и отчет автору
+type broadcastSender interface {
+	SendBroadcast(broadcast *database.Broadcast, userID int64) error
+	SendBroadcastReport(broadcast *database.Broadcast, stats map[string]int)
+}
+
+// BroadcastWorker отправляет запущенные рассылки в фоне.
+// Темп отправки ограничивает планировщик бота: рассылки идут с фоновым приоритетом.
+// Экземпляров воркера может быть несколько: получатели захватываются пачками, и каждому
+// получателю сообщение отправляет один экземпляр.
+type BroadcastWorker struct {
+	repo broadcastStore
+	bot  broadcastSender
+}
+
+// NewBroadcastWorker создает новый воркер рассылок
+func NewBroadcastWorker(repo *database.BroadcastRepository, bot *bot.Bot) *BroadcastWorker {
+	return &BroadcastWorker{
+		repo: repo,
+		bot:  bot,
+	}
+}
+
+// Start запускает воркер в горутине
+func (w *BroadcastWorker) Start(ctx context.Context) {
+	go w.run(ctx)
+}
+
+// run основной цикл воркера
+func (w *BroadcastWorker) run(ctx context.Context) {
+	log.Printf("📣 Starting broadcast worker (check every %s)", broadcastPollInterval)
+
+	ticker := time.NewTicker(broadcastPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("🛑 Broadcast worker stopped")
+			return
+		case <-ticker.C:
+			w.processBroadcasts(ctx)
+		}
+	}
+}
+
+// processBroadcasts отправляет все запущенные рассылки
+func (w *BroadcastWorker) processBroadcasts(ctx context.Context) {
+	broadcasts, err := w.repo.GetRunning()
+	if err != nil {
+		log.Printf("❌ Error getting running broadcasts: %v", err)
+		return
+	}
+	for _, broadcast := range broadcasts {
+		w.processBroadcast(ctx, broadcast.ID)
+	}
+}
+
+// processBroadcast отправляет рассылку пачками, пока она не завершится или не будет остановлена
+func (w *BroadcastWorker) processBroadcast(ctx context.Context, id int64) {
+	for ctx.Err() == nil {
+		// Перечитываем рассылку, чтобы учесть паузу или отмену
+		broadcast, err := w.repo.GetByID(id)
+		if err != nil {
+			log.Printf("❌ Error getting broadcast #%d: %v", id, err)
+			return
+		}
+		if broadcast == nil || broadcast.Status != database.BroadcastStatusRunning {
+			return
+		}
+
+		recipients, err := w.repo.ClaimRecipients(id, broadcastBatchSize, broadcastClaimTTL)
+		if err != nil {
+			log.Printf("❌ Error claiming recipients of broadcast #%d: %v", id, err)
+			return
+		}
+		if len(recipients) == 0 {
+			// Остальные пачки могут еще отправлять другие экземпляры: завершает рассылку тот,
+			// кто застанет ее без неотправленных получателей
+			unfinished, err := w.repo.CountUnfinishedRecipients(id)
+			if err != nil {
+				log.Printf("❌ Error counting recipients of broadcast #%d: %v", id, err)
+				return
+			}
+			if unfinished == 0 {
+				w.complete(broadcast)
+			}
+			return
+		}
+
+		for _, userID := range recipients {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := w.deliver(broadcast, userID); err != nil {
+				// Без сохраненного статуса получатель остался бы в очереди и получил бы сообщение повторно
+				log.Printf("❌ Error saving delivery status of broadcast #%d for user %d: %v", id, userID, err)
+				return
+			}
+		}
+
+		log.Printf("📣 Broadcast #%d: batch of %d recipient(s) processed (total %d)",
+			id, len(recipients), broadcast.Total)
+	}
+}
+
+// deliver отправляет рассылку одному получателю и сохраняет статус доставки
+func (w *BroadcastWorker) deliver(broadcast *database.Broadcast, userID int64) error {
+	status := database.RecipientStatusSent
+	errorText := ""
+	if err := w.bot.SendBroadcast(broadcast, userID); err != nil {
+		status = database.RecipientStatusFailed
+		if errors.Is(err, bot.ErrUserBlocked) {
+			status = database.RecipientStatusBlocked
+		}
+		errorText = err.Error()
+	}
+	monitoring.RecordBroadcastMessage(status)
+
+	return w.repo.MarkRecipient(broadcast.ID, userID, status, errorText)
+}
+
+// complete завершает рассылку и отправляет отчет автору
+func (w *BroadcastWorker) complete(broadcast *database.Broadcast) {
+	ok, err := w.repo.Transition(broadcast.ID, database.BroadcastStatusCompleted, database.BroadcastStatusRunning)
+	if err != nil {
+		log.Printf("❌ Error completing broadcast #%d: %v", broadcast.ID, err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	broadcast, err = w.repo.GetByID(broadcast.ID)
+	if err != nil || broadcast == nil {
+		log.Printf("❌ Error getting completed broadcast: %v", err)
+		return
+	}
+	stats, err := w.repo.GetDeliveryStats(broadcast.ID)
+	if err != nil {
+		log.Printf("❌ Error getting delivery stats of broadcast #%d: %v", broadcast.ID, err)
+		return
+	}
+
+	log.Printf("✅ Broadcast #%d completed: sent=%d failed=%d total=%d",
+		broadcast.ID, broadcast.Sent, broadcast.Failed, broadcast.Total)
+	w.bot.SendBroadcastReport(broadcast, stats)
+}
@@ -0,0 +1,135 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"ai_tg_writer/internal/infrastructure/database"
+)
+
+// stubBroadcastStore хранит одну рассылку и захватывает получателей так же, как ClaimRecipients в базе
+type stubBroadcastStore struct {
+	mu         sync.Mutex
+	broadcast  database.Broadcast
+	recipients map[int64]string
+}
+
+func (s *stubBroadcastStore) GetRunning() ([]*database.Broadcast, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	broadcast := s.broadcast
+	return []*database.Broadcast{&broadcast}, nil
+}
+func (s *stubBroadcastStore) GetByID(id int64) (*database.Broadcast, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	broadcast := s.broadcast
+	return &broadcast, nil
+}
+func (s *stubBroadcastStore) ClaimRecipients(id int64, limit int, ttl time.Duration) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var claimed []int64
+	for userID, status := range s.recipients {
+		if len(claimed) == limit {
+			break
+		}
+		if status == database.RecipientStatusPending {
+			s.recipients[userID] = database.RecipientStatusSending
+			claimed = append(claimed, userID)
+		}
+	}
+	return claimed, nil
+}
+func (s *stubBroadcastStore) CountUnfinishedRecipients(id int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unfinished := 0
+	for _, status := range s.recipients {
+		if status == database.RecipientStatusPending || status == database.RecipientStatusSending {
+			unfinished++
+		}
+	}
+	return unfinished, nil
+}
+func (s *stubBroadcastStore) MarkRecipient(id, userID int64, status, errorText string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.recipients[userID] == database.RecipientStatusSending {
+		s.recipients[userID] = status
+		s.broadcast.Sent++
+	}
+	return nil
+}
+func (s *stubBroadcastStore) Transition(id int64, to database.BroadcastStatus, from ...database.BroadcastStatus) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, status := range from {
+		if s.broadcast.Status == status {
+			s.broadcast.Status = to
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (s *stubBroadcastStore) GetDeliveryStats(id int64) (map[string]int, error) {
+	return map[string]int{}, nil
+}
+
+// stubBroadcastSender считает сообщения каждому получателю; отправка занимает время, как в Telegram
+type stubBroadcastSender struct {
+	mu      sync.Mutex
+	sent    map[int64]int
+	reports int
+}
+
+func (s *stubBroadcastSender) SendBroadcast(broadcast *database.Broadcast, userID int64) error {
+	time.Sleep(time.Millisecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent[userID]++
+	return nil
+}
+func (s *stubBroadcastSender) SendBroadcastReport(broadcast *database.Broadcast, stats map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports++
+}
+
+func TestConcurrentBroadcastWorkersSendOnce(t *testing.T) {
+	const recipients = 100
+	store := &stubBroadcastStore{
+		broadcast:  database.Broadcast{ID: 1, Status: database.BroadcastStatusRunning, Total: recipients},
+		recipients: make(map[int64]string),
+	}
+	for userID := int64(1); userID <= recipients; userID++ {
+		store.recipients[userID] = database.RecipientStatusPending
+	}
+	sender := &stubBroadcastSender{sent: make(map[int64]int)}
+
+	// Два экземпляра бота обрабатывают одну рассылку одновременно
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := &BroadcastWorker{repo: store, bot: sender}
+			w.processBroadcasts(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	if len(sender.sent) != recipients {
+		t.Errorf("Сообщение должны получить все %d получателей, получили %d", recipients, len(sender.sent))
+	}
+	for userID, count := range sender.sent {
+		if count != 1 {
+			t.Errorf("Получатель %d получил сообщение %d раз", userID, count)
+		}
+	}
+	if store.broadcast.Status != database.BroadcastStatusCompleted || sender.reports != 1 {
+		t.Errorf("Рассылка должна завершиться с одним отчетом: статус %s, отчетов %d", store.broadcast.Status, sender.reports)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"ai_tg_writer/internal/config"
 	"ai_tg_writer/internal/service"
 	"context"
+	"errors"
 	"log"
 	"sync"
 	"sync/atomic"
@@ -14,13 +15,19 @@ import (
 
 type SubscriptionWorker struct {
 	subscriptionService *service.SubscriptionService
+	entitlements        *service.EntitlementService
+	referrals           *service.ReferralService
+	acquisitions        *service.AcquisitionService
 	config              *config.Config
 }
 
 // NewSubscriptionWorker создает новый воркер для обработки подписок
-func NewSubscriptionWorker(subscriptionService *service.SubscriptionService, config *config.Config) *SubscriptionWorker {
+func NewSubscriptionWorker(subscriptionService *service.SubscriptionService, entitlements *service.EntitlementService, referrals *service.ReferralService, acquisitions *service.AcquisitionService, config *config.Config) *SubscriptionWorker {
 	return &SubscriptionWorker{
 		subscriptionService: subscriptionService,
+		entitlements:        entitlements,
+		referrals:           referrals,
+		acquisitions:        acquisitions,
 		config:              config,
 	}
 }
@@ -72,14 +79,29 @@ func (w *SubscriptionWorker) run(ctx context.Context) {
 
 // processSubscriptions обрабатывает подписки, которые нужно продлить и повторные попытки
 func (w *SubscriptionWorker) processSubscriptions() {
+	// Напоминаем об окончании пауз и возобновляем подписки, пауза которых закончилась
+	w.processPausedSubscriptions()
+
+	// Напоминаем о предстоящих списаниях
+	w.processRenewalReminders()
+
+	// Предупреждаем о конце пробного периода и списании цены тарифа
+	w.processTrialReminders()
+
 	// Обрабатываем обычные продления
 	w.processRenewals()
 
 	// Обрабатываем повторные попытки
 	w.processRetries()
 
-	// Обрабатываем истекшие отмененные подписки
-	w.processExpiredCancelledSubscriptions()
+	// Завершаем подписки, доступ по которым закончился
+	w.processExpiredSubscriptions()
+
+	// Начисляем награды за первые оплаты приглашенных пользователей
+	w.processReferralRewards()
+
+	// Обновляем метрики воронки по источникам привлечения
+	w.processAcquisitionMetrics()
 }
 
 // processRenewals обрабатывает подписки для продления
@@ -153,7 +175,7 @@ func (w *SubscriptionWorker) processRenewals() {
 		var wg sync.WaitGroup
 
 		// Счетчик успешных и неуспешных платежей
-		var successCount, errorCount int32
+		var successCount, errorCount, skippedCount int32
 
 		log.Printf("💳 [YooKassa] Начинаем параллельную обработку %d подписок (лимит: %d)",
 			len(subscriptions), maxConcurrentPayments)
@@ -172,8 +194,13 @@ func (w *SubscriptionWorker) processRenewals() {
 				log.Printf("💳 [YooKassa] Начинаем обработку платежа для пользователя %d (ID: %d)",
 					subscription.UserID, subscription.ID)
 
-				// Обрабатываем платеж
-				if err := w.subscriptionService.ProcessRecurringPayment(subscription); err != nil {
+				// Обрабатываем платеж; подписку, захваченную другим экземпляром воркера, пропускаем
+				err := w.subscriptionService.ProcessRecurringPayment(subscription)
+				if errors.Is(err, domain.ErrRenewalInProgress) {
+					atomic.AddInt32(&skippedCount, 1)
+					log.Printf("⏭️ [YooKassa] Renewal of user %d is already processed by another worker, skipping",
+						subscription.UserID)
+				} else if err != nil {
 					atomic.AddInt32(&errorCount, 1)
 					log.Printf("❌ [YooKassa] Failed to process recurring payment for user %d: %v",
 						subscription.UserID, err)
@@ -192,8 +219,8 @@ func (w *SubscriptionWorker) processRenewals() {
 		// Логируем итоговую статистику
 		finalSuccess := atomic.LoadInt32(&successCount)
 		finalError := atomic.LoadInt32(&errorCount)
-		log.Printf("🎉 [YooKassa] Все платежи обработаны. Успешно: %d, Ошибок: %d",
-			finalSuccess, finalError)
+		log.Printf("🎉 [YooKassa] Все платежи обработаны. Успешно: %d, Ошибок: %d, Пропущено: %d",
+			finalSuccess, finalError, atomic.LoadInt32(&skippedCount))
 	}
 }
 
@@ -227,7 +254,7 @@ func (w *SubscriptionWorker) processRetries() {
 		var wg sync.WaitGroup
 
 		// Счетчик успешных и неуспешных повторных попыток
-		var successCount, errorCount int32
+		var successCount, errorCount, skippedCount int32
 
 		log.Printf("🔄 [Retry] Начинаем параллельную обработку %d повторных попыток (лимит: %d)",
 			len(subscriptions), maxConcurrentRetries)
@@ -246,8 +273,13 @@ func (w *SubscriptionWorker) processRetries() {
 				log.Printf("🔄 [Retry] Начинаем повторную попытку для пользователя %d (ID: %d, Попытка: %d)",
 					subscription.UserID, subscription.ID, subscription.FailedAttempts+1)
 
-				// Обрабатываем повторную попытку
-				if err := w.subscriptionService.ProcessRecurringPayment(subscription); err != nil {
+				// Обрабатываем повторную попытку; подписку, захваченную другим экземпляром воркера, пропускаем
+				err := w.subscriptionService.ProcessRecurringPayment(subscription)
+				if errors.Is(err, domain.ErrRenewalInProgress) {
+					atomic.AddInt32(&skippedCount, 1)
+					log.Printf("⏭️ [Retry] Retry of user %d is already processed by another worker, skipping",
+						subscription.UserID)
+				} else if err != nil {
 					atomic.AddInt32(&errorCount, 1)
 					log.Printf("❌ [Retry] Failed to retry payment for user %d: %v", subscription.UserID, err)
 				} else {
@@ -265,21 +297,65 @@ func (w *SubscriptionWorker) processRetries() {
 		// Логируем итоговую статистику
 		finalSuccess := atomic.LoadInt32(&successCount)
 		finalError := atomic.LoadInt32(&errorCount)
-		log.Printf("🎉 [Retry] Все повторные попытки завершены. Успешно: %d, Ошибок: %d",
-			finalSuccess, finalError)
+		log.Printf("🎉 [Retry] Все повторные попытки завершены. Успешно: %d, Ошибок: %d, Пропущено: %d",
+			finalSuccess, finalError, atomic.LoadInt32(&skippedCount))
+	}
+}
+
+// processRenewalReminders отправляет напоминания о предстоящих списаниях
+func (w *SubscriptionWorker) processRenewalReminders() {
+	sent, err := w.subscriptionService.SendRenewalReminders()
+	if err != nil {
+		log.Printf("❌ Error sending renewal reminders: %v", err)
+		return
+	}
+	if sent > 0 {
+		log.Printf("🔔 Sent %d renewal reminder(s)", sent)
+	}
+}
+
+// processTrialReminders напоминает за день до конца пробного периода о первом списании
+func (w *SubscriptionWorker) processTrialReminders() {
+	sent, err := w.subscriptionService.SendTrialEndingReminders()
+	if err != nil {
+		log.Printf("❌ Error sending trial ending reminders: %v", err)
+		return
+	}
+	if sent > 0 {
+		log.Printf("🎁 Sent %d trial ending reminder(s)", sent)
+	}
+}
+
+// processPausedSubscriptions напоминает о скором окончании пауз и возобновляет подписки после паузы
+func (w *SubscriptionWorker) processPausedSubscriptions() {
+	reminded, err := w.subscriptionService.SendPauseEndingReminders()
+	if err != nil {
+		log.Printf("❌ Error sending pause ending reminders: %v", err)
+	} else if reminded > 0 {
+		log.Printf("🔔 Sent %d pause ending reminder(s)", reminded)
+	}
+
+	resumed, err := w.subscriptionService.ResumeEndedPauses()
+	if err != nil {
+		log.Printf("❌ Error resuming paused subscriptions: %v", err)
+		return
+	}
+	if resumed > 0 {
+		log.Printf("▶️ Resumed %d subscription(s) after pause", resumed)
 	}
 }
 
-// processExpiredCancelledSubscriptions обрабатывает истекшие отмененные подписки
-func (w *SubscriptionWorker) processExpiredCancelledSubscriptions() {
+// processExpiredSubscriptions завершает подписки, доступ по которым закончился: отмененные
+// после оплаченного периода и неоплаченные после grace period. Подписки с запланированной
+// повторной попыткой списания завершает расписание повторов, а не воркер.
+func (w *SubscriptionWorker) processExpiredSubscriptions() {
 	now := time.Now()
 	if w.config.IsDevMode() {
-		log.Printf("⏰ [DEV] Checking for expired cancelled subscriptions... [NOW: %s]", now.Format("2006-01-02 15:04:05"))
+		log.Printf("⏰ [DEV] Checking for expired subscriptions... [NOW: %s]", now.Format("2006-01-02 15:04:05"))
 	} else {
-		log.Printf("⏰ [PROD] Checking for expired cancelled subscriptions... [NOW: %s]", now.Format("2006-01-02 15:04:05"))
+		log.Printf("⏰ [PROD] Checking for expired subscriptions... [NOW: %s]", now.Format("2006-01-02 15:04:05"))
 	}
 
-	// Получаем все активные подписки со статусом 'cancelled'
 	allActive, err := w.subscriptionService.GetAllActiveSubscriptions()
 	if err != nil {
 		log.Printf("❌ Error getting all active subscriptions: %v", err)
@@ -288,28 +364,59 @@ func (w *SubscriptionWorker) processExpiredCancelledSubscriptions() {
 
 	expiredCount := 0
 	for _, sub := range allActive {
-		// Проверяем только отмененные подписки
-		if sub.Status == "cancelled" && sub.NextPayment.Before(now) {
-			log.Printf("🔄 Found expired cancelled subscription for user %d (expired at %s)",
-				sub.UserID, sub.NextPayment.Format("2006-01-02 15:04:05"))
-
-			// Полностью отменяем подписку
-			if err := w.subscriptionService.CancelExpiredSubscription(sub.UserID); err != nil {
-				log.Printf("❌ Failed to cancel expired subscription for user %d: %v", sub.UserID, err)
-			} else {
-				log.Printf("✅ Successfully cancelled expired subscription for user %d", sub.UserID)
-				expiredCount++
-			}
+		status := domain.SubscriptionStatus(sub.Status)
+		if status != domain.SubscriptionStatusActive && status != domain.SubscriptionStatusCancelled {
+			continue
+		}
+		if sub.FailedAttempts > 0 && sub.NextRetry != nil {
+			continue
+		}
+		if w.entitlements.ForSubscription(sub).HasSubscriptionAccess() {
+			continue
+		}
+
+		log.Printf("🔄 Found expired %s subscription for user %d (paid until %s)",
+			sub.Status, sub.UserID, sub.NextPayment.Format("2006-01-02 15:04:05"))
+		if err := w.subscriptionService.CancelExpiredSubscription(sub.UserID); err != nil {
+			log.Printf("❌ Failed to cancel expired subscription for user %d: %v", sub.UserID, err)
+		} else {
+			log.Printf("✅ Successfully cancelled expired subscription for user %d", sub.UserID)
+			expiredCount++
 		}
 	}
 
 	if expiredCount > 0 {
-		log.Printf("✅ Processed %d expired cancelled subscription(s)", expiredCount)
+		log.Printf("✅ Processed %d expired subscription(s)", expiredCount)
 	} else {
 		if w.config.IsDevMode() {
-			log.Printf("✅ [DEV] No expired cancelled subscriptions found")
+			log.Printf("✅ [DEV] No expired subscriptions found")
 		} else {
-			log.Printf("✅ [PROD] No expired cancelled subscriptions found")
+			log.Printf("✅ [PROD] No expired subscriptions found")
 		}
 	}
 }
+
+// processReferralRewards начисляет приглашающим награды за первые оплаты приглашенных
+func (w *SubscriptionWorker) processReferralRewards() {
+	if w.referrals == nil {
+		return
+	}
+	rewarded, err := w.referrals.RewardPending()
+	if err != nil {
+		log.Printf("❌ Error rewarding referrals: %v", err)
+		return
+	}
+	if rewarded > 0 {
+		log.Printf("🤝 Rewarded %d referral(s)", rewarded)
+	}
+}
+
+// processAcquisitionMetrics обновляет метрики воронки по источникам привлечения
+func (w *SubscriptionWorker) processAcquisitionMetrics() {
+	if w.acquisitions == nil {
+		return
+	}
+	if err := w.acquisitions.RefreshMetrics(); err != nil {
+		log.Printf("❌ Error refreshing acquisition metrics: %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Пользователь заблокировал бота (my_chat_member = kicked или ошибка 403 при отправке)
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS bot_blocked BOOLEAN DEFAULT FALSE,
+  ADD COLUMN IF NOT EXISTS bot_blocked_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_bot_blocked ON users(bot_blocked) WHERE bot_blocked = TRUE;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_bot_blocked;
+ALTER TABLE users
+  DROP COLUMN IF EXISTS bot_blocked,
+  DROP COLUMN IF EXISTS bot_blocked_at;
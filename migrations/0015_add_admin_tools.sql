@@ -0,0 +1,41 @@
+-- +goose Up
+-- Администраторы, баны и выданный вручную Premium
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS is_banned BOOLEAN DEFAULT FALSE,
+  ADD COLUMN IF NOT EXISTS banned_at TIMESTAMP,
+  ADD COLUMN IF NOT EXISTS premium_until TIMESTAMP;
+
+-- Дополнительные бесплатные создания, выданные администратором (действуют в месяце выдачи)
+CREATE TABLE IF NOT EXISTS quota_grants (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    amount INTEGER NOT NULL,
+    granted_by BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_grants_user_created ON quota_grants(user_id, created_at);
+
+-- Журнал действий администраторов
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+    id SERIAL PRIMARY KEY,
+    admin_id BIGINT NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    target_user_id BIGINT,
+    details TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_created_at ON admin_audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_target ON admin_audit_log(target_user_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_admin_audit_log_target;
+DROP INDEX IF EXISTS idx_admin_audit_log_created_at;
+DROP TABLE IF EXISTS admin_audit_log;
+DROP INDEX IF EXISTS idx_quota_grants_user_created;
+DROP TABLE IF EXISTS quota_grants;
+ALTER TABLE users
+  DROP COLUMN IF EXISTS is_banned,
+  DROP COLUMN IF EXISTS banned_at,
+  DROP COLUMN IF EXISTS premium_until;
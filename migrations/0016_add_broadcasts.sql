@@ -0,0 +1,43 @@
+-- +goose Up
+-- Пользователь отказался от маркетинговых рассылок
+ALTER TABLE users ADD COLUMN IF NOT EXISTS marketing_opt_out BOOLEAN DEFAULT FALSE;
+
+-- Рассылки администраторов
+CREATE TABLE IF NOT EXISTS broadcasts (
+    id SERIAL PRIMARY KEY,
+    admin_id BIGINT NOT NULL,
+    text TEXT NOT NULL,
+    entities JSONB,                                 -- Форматирование (MessageEntity)
+    buttons JSONB,                                  -- Кнопки-ссылки
+    segment VARCHAR(20) NOT NULL,                   -- all, free, premium, churned, inactive
+    inactive_days INTEGER DEFAULT 0,
+    is_marketing BOOLEAN DEFAULT TRUE,              -- Маркетинговые рассылки не получают отписавшиеся
+    status VARCHAR(20) DEFAULT 'draft',             -- draft, running, paused, completed, cancelled
+    total INTEGER DEFAULT 0,
+    sent INTEGER DEFAULT 0,
+    failed INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_broadcasts_status ON broadcasts(status);
+
+-- Статус доставки рассылки каждому получателю
+CREATE TABLE IF NOT EXISTS broadcast_recipients (
+    broadcast_id INTEGER REFERENCES broadcasts(id) ON DELETE CASCADE,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) DEFAULT 'pending',           -- pending, sent, failed, blocked
+    error TEXT,
+    sent_at TIMESTAMP,
+    PRIMARY KEY (broadcast_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_broadcast_recipients_pending ON broadcast_recipients(broadcast_id) WHERE status = 'pending';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_broadcast_recipients_pending;
+DROP TABLE IF EXISTS broadcast_recipients;
+DROP INDEX IF EXISTS idx_broadcasts_status;
+DROP TABLE IF EXISTS broadcasts;
+ALTER TABLE users DROP COLUMN IF EXISTS marketing_opt_out;
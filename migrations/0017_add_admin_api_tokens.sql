@@ -0,0 +1,15 @@
+-- +goose Up
+-- Токены доступа к административному REST API (хранится только SHA-256 хеш)
+CREATE TABLE IF NOT EXISTS admin_api_tokens (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    role VARCHAR(20) NOT NULL,                      -- viewer, operator, admin
+    created_by BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS admin_api_tokens;
@@ -0,0 +1,37 @@
+-- +goose Up
+-- Каталог тарифов: цены, периоды и лимиты редактируются администраторами
+CREATE TABLE IF NOT EXISTS tariffs (
+    id VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT DEFAULT '',
+    price NUMERIC(10,2) NOT NULL DEFAULT 0,
+    currency CHAR(3) NOT NULL DEFAULT 'RUB',
+    period VARCHAR(10) NOT NULL DEFAULT 'month',    -- day, week, month, year
+    posts_per_month INTEGER,                        -- NULL — без ограничений
+    features JSONB NOT NULL DEFAULT '[]',
+    visible BOOLEAN NOT NULL DEFAULT FALSE,         -- Показывать при покупке
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Бесплатный тариф не продается, он задает лимит пользователей без подписки
+INSERT INTO tariffs (id, name, description, price, period, posts_per_month, visible, sort_order)
+VALUES ('free', 'Бесплатный', 'Базовый доступ', 0, 'month', 5, FALSE, 0)
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO tariffs (id, name, description, price, period, posts_per_month, features, visible, sort_order)
+VALUES ('premium', 'Premium', 'Премиум подписка с неограниченными возможностями', 990, 'month', NULL,
+        '["🚀 Неограниченное количество постов", "⚡ Приоритетная обработка запросов", "🎨 Расширенные настройки стилизации", "📈 Детальная аналитика использования", "🔧 Эксклюзивные функции и шаблоны", "💬 Приоритетная техподдержка"]',
+        TRUE, 10)
+ON CONFLICT (id) DO NOTHING;
+
+-- Годовой тариф создается скрытым: администратор включает его командой /tariff_set premium_year visible on
+INSERT INTO tariffs (id, name, description, price, period, posts_per_month, features, visible, sort_order)
+VALUES ('premium_year', 'Premium на год', 'Премиум подписка с оплатой за год', 9900, 'year', NULL,
+        '["🚀 Неограниченное количество постов", "⚡ Приоритетная обработка запросов", "🎨 Расширенные настройки стилизации", "📈 Детальная аналитика использования", "🔧 Эксклюзивные функции и шаблоны", "💬 Приоритетная техподдержка", "💰 Два месяца в подарок"]',
+        FALSE, 20)
+ON CONFLICT (id) DO NOTHING;
+
+-- +goose Down
+DROP TABLE IF EXISTS tariffs;
@@ -0,0 +1,39 @@
+-- +goose Up
+-- Лимиты тарифа по ресурсам: {"generations": 5, "rewrites": 3}; отсутствующий ресурс не ограничен
+ALTER TABLE tariffs ADD COLUMN IF NOT EXISTS quotas JSONB NOT NULL DEFAULT '{}';
+
+UPDATE tariffs SET quotas = jsonb_build_object('generations', posts_per_month)
+WHERE posts_per_month IS NOT NULL;
+
+UPDATE tariffs SET quotas = quotas || '{"rewrites": 3, "edits": 10, "audio_minutes": 30}'
+WHERE id = 'free';
+
+ALTER TABLE tariffs DROP COLUMN IF EXISTS posts_per_month;
+
+-- Расход квот по ресурсам за период
+CREATE TABLE IF NOT EXISTS quota_usage (
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    resource VARCHAR(20) NOT NULL,                  -- generations, rewrites, edits, audio_minutes
+    period_start DATE NOT NULL,
+    used INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, resource, period_start)
+);
+
+-- Переносим историю созданий постов из usage_stats помесячно
+INSERT INTO quota_usage (user_id, resource, period_start, used)
+SELECT user_id, 'generations', date_trunc('month', date)::date, SUM(usage_count)
+FROM usage_stats
+WHERE user_id IS NOT NULL
+GROUP BY user_id, date_trunc('month', date)
+ON CONFLICT DO NOTHING;
+
+-- Выданные администратором единицы относятся к конкретному ресурсу
+ALTER TABLE quota_grants ADD COLUMN IF NOT EXISTS resource VARCHAR(20) NOT NULL DEFAULT 'generations';
+
+-- +goose Down
+ALTER TABLE quota_grants DROP COLUMN IF EXISTS resource;
+DROP TABLE IF EXISTS quota_usage;
+ALTER TABLE tariffs ADD COLUMN IF NOT EXISTS posts_per_month INTEGER;
+UPDATE tariffs SET posts_per_month = (quotas->>'generations')::int WHERE quotas ? 'generations';
+ALTER TABLE tariffs DROP COLUMN IF EXISTS quotas;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Часовой пояс пользователя: в нем считаются границы периодов квот
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'Europe/Moscow';
+
+-- Периоды квот привязаны к дате подписки или регистрации и начинаются в полночь по времени пользователя,
+-- поэтому начало периода хранится как момент времени. Расход календарных месяцев остается в истории.
+ALTER TABLE quota_usage ALTER COLUMN period_start TYPE TIMESTAMPTZ USING period_start::timestamp AT TIME ZONE 'UTC';
+
+-- +goose Down
+ALTER TABLE quota_usage ALTER COLUMN period_start TYPE DATE USING (period_start AT TIME ZONE 'UTC')::date;
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
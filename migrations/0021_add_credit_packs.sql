@@ -0,0 +1,40 @@
+-- +goose Up
+-- Пакеты кредитов: разовая покупка без подписки, один кредит — одно создание, рерайт или правка
+CREATE TABLE IF NOT EXISTS credit_packs (
+    id VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    credits INTEGER NOT NULL CHECK (credits > 0),
+    price NUMERIC(10,2) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'RUB',
+    validity_days INTEGER NOT NULL DEFAULT 90,     -- Срок действия кредитов с момента оплаты
+    visible BOOLEAN NOT NULL DEFAULT TRUE,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO credit_packs (id, name, credits, price, validity_days, sort_order)
+VALUES ('pack_5', '5 постов', 5, 199, 90, 10),
+       ('pack_15', '15 постов', 15, 490, 180, 20)
+ON CONFLICT (id) DO NOTHING;
+
+-- Покупки пакетов: остаток расходуется до expires_at, начиная с покупки, которая сгорит раньше
+CREATE TABLE IF NOT EXISTS credit_purchases (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    pack_id VARCHAR(50) NOT NULL REFERENCES credit_packs(id),
+    credits INTEGER NOT NULL,
+    remaining INTEGER NOT NULL DEFAULT 0 CHECK (remaining >= 0),
+    amount NUMERIC(10,2) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'RUB',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',  -- pending, paid
+    payment_id VARCHAR(255) UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    paid_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_purchases_balance ON credit_purchases(user_id, expires_at) WHERE status = 'paid' AND remaining > 0;
+
+-- +goose Down
+DROP TABLE IF EXISTS credit_purchases;
+DROP TABLE IF EXISTS credit_packs;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- Журнал платежей: каждая попытка оплаты у провайдера, включая продления, отказы и разовые покупки
+CREATE TABLE IF NOT EXISTS payments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    subscription_id INTEGER REFERENCES subscriptions(id) ON DELETE SET NULL,
+    provider VARCHAR(20) NOT NULL,                  -- yookassa, prodamus
+    provider_payment_id VARCHAR(255),               -- NULL, если провайдер не принял запрос
+    kind VARCHAR(20) NOT NULL,                      -- initial, recurring, credit_pack
+    amount NUMERIC(10,2) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'RUB',
+    status VARCHAR(20) NOT NULL,                    -- pending, succeeded, failed
+    failure_reason TEXT NOT NULL DEFAULT '',
+    idempotency_key VARCHAR(255) UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, provider_payment_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_user ON payments(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_payments_created ON payments(created_at);
+
+-- Переносим последние известные оплаты подписок: раньше отдельного журнала не было
+INSERT INTO payments (user_id, subscription_id, provider, provider_payment_id, kind, amount, status, created_at, updated_at)
+SELECT user_id, id, 'yookassa', yk_last_payment_id, 'initial', amount, 'succeeded',
+       COALESCE(last_payment, created_at), COALESCE(last_payment, created_at)
+FROM subscriptions
+WHERE yk_last_payment_id IS NOT NULL AND user_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+-- +goose Down
+DROP TABLE IF EXISTS payments;
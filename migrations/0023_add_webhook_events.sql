@@ -0,0 +1,21 @@
+-- +goose Up
+-- Входящие уведомления платежных провайдеров: каждое событие обрабатывается ровно один раз,
+-- повторные доставки находят запись по event_key и не запускают обработку заново
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id BIGSERIAL PRIMARY KEY,
+    provider VARCHAR(20) NOT NULL,
+    event_key VARCHAR(255) NOT NULL,                -- событие:ID объекта
+    event_type VARCHAR(64) NOT NULL,
+    object_id VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'received', -- received, processing, processed, ignored, failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    received_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMPTZ,
+    UNIQUE (provider, event_key)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_events;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- Возвраты платежей: полные и частичные, оформленные из админки или в личном кабинете провайдера.
+-- applied_at отмечает, что последствия возврата (понижение подписки, списание кредитов) уже применены
+CREATE TABLE IF NOT EXISTS refunds (
+    id BIGSERIAL PRIMARY KEY,
+    payment_id BIGINT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL,
+    provider_refund_id VARCHAR(255),                -- NULL, пока провайдер не принял запрос
+    amount NUMERIC(10,2) NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL DEFAULT 'RUB',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',  -- pending, succeeded, canceled
+    reason TEXT NOT NULL DEFAULT '',
+    admin_id BIGINT,                                -- NULL — возврат оформлен у провайдера
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    applied_at TIMESTAMPTZ,
+    UNIQUE (provider, provider_refund_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_payment ON refunds(payment_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS refunds;
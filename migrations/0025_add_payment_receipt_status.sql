@@ -0,0 +1,9 @@
+-- +goose Up
+-- Статус регистрации чека 54-ФЗ по платежу: pending, succeeded, canceled; пусто — чек не передавался
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS receipt_status VARCHAR(20) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_payments_receipt_failed ON payments(created_at) WHERE receipt_status = 'canceled';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_payments_receipt_failed;
+ALTER TABLE payments DROP COLUMN IF EXISTS receipt_status;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Выбор платежного провайдера: закрепленный за пользователем важнее провайдера тарифа,
+-- пустое значение — провайдер по умолчанию (PAYMENT_PROVIDER)
+ALTER TABLE tariffs ADD COLUMN IF NOT EXISTS provider VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS payment_provider VARCHAR(20) NOT NULL DEFAULT '';
+
+-- Провайдер, у которого сохранен способ оплаты: через него списываются продления.
+-- Все существующие привязки сделаны через YooKassa.
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS payment_provider VARCHAR(20) NOT NULL DEFAULT 'yookassa';
+
+-- +goose Down
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS payment_provider;
+ALTER TABLE users DROP COLUMN IF EXISTS payment_provider;
+ALTER TABLE tariffs DROP COLUMN IF EXISTS provider;
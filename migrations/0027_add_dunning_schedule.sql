@@ -0,0 +1,18 @@
+-- +goose Up
+-- Расписание повторных списаний тарифа: интервалы в часах между неудачными попытками.
+-- Пустой массив — расписание по умолчанию (DUNNING_RETRY_HOURS).
+ALTER TABLE tariffs ADD COLUMN IF NOT EXISTS retry_hours JSONB NOT NULL DEFAULT '[]';
+
+-- Отправленные напоминания о продлении: одно напоминание каждого вида на каждую дату списания
+CREATE TABLE IF NOT EXISTS subscription_notices (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    due_at TIMESTAMP NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (subscription_id, kind, due_at)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS subscription_notices;
+ALTER TABLE tariffs DROP COLUMN IF EXISTS retry_hours;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- История статусов подписок: каждый переход проверяется машиной состояний
+-- (domain.ValidateTransition) и записывается вместе с причиной
+CREATE TABLE IF NOT EXISTS subscription_events (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL,
+    from_status VARCHAR(20), -- NULL — подписка создана
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_events_user ON subscription_events(user_id, created_at DESC);
+
+-- Время последней смены статуса подписки
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS status_changed_at;
+DROP TABLE IF EXISTS subscription_events;
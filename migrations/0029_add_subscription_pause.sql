@@ -0,0 +1,11 @@
+-- +goose Up
+-- Пауза подписки по просьбе пользователя: paused_at — начало паузы, pause_until — автоматическое возобновление
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS paused_at TIMESTAMP;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS pause_until TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_pause_until ON subscriptions(pause_until) WHERE status = 'paused';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_subscriptions_pause_until;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS pause_until;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS paused_at;
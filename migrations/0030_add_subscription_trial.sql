@@ -0,0 +1,6 @@
+-- +goose Up
+-- Конец пробного периода подписки: до первой оплаты после этой даты подписка считается пробной
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS trial_ends_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS trial_ends_at;
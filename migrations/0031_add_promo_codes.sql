@@ -0,0 +1,42 @@
+-- +goose Up
+-- Промокоды на скидку при оформлении подписки: процент или фиксированная сумма
+-- на первую оплату или на N оплат, с лимитом использований и сроком действия
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR(50) NOT NULL UNIQUE,               -- Хранится в верхнем регистре
+    discount_type VARCHAR(10) NOT NULL,             -- percent, fixed
+    discount_value NUMERIC(10,2) NOT NULL CHECK (discount_value > 0),
+    periods INTEGER NOT NULL DEFAULT 1 CHECK (periods >= 0), -- 0 — скидка на все оплаты
+    max_uses INTEGER NOT NULL DEFAULT 0 CHECK (max_uses >= 0), -- 0 — без ограничений
+    used_count INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Применения промокодов: каждый пользователь применяет код один раз.
+-- renewals_left — сколько продлений еще со скидкой, NULL — все продления
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+    id BIGSERIAL PRIMARY KEY,
+    promo_code_id BIGINT NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    subscription_id INTEGER REFERENCES subscriptions(id) ON DELETE SET NULL,
+    renewals_left INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (promo_code_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_subscription ON promo_redemptions(subscription_id);
+
+-- Скидка по промокоду в журнале платежей для отчета по кодам
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS promo_code_id BIGINT REFERENCES promo_codes(id) ON DELETE SET NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS discount NUMERIC(10,2) NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_payments_promo_code ON payments(promo_code_id) WHERE promo_code_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_payments_promo_code;
+ALTER TABLE payments DROP COLUMN IF EXISTS discount;
+ALTER TABLE payments DROP COLUMN IF EXISTS promo_code_id;
+DROP TABLE IF EXISTS promo_redemptions;
+DROP TABLE IF EXISTS promo_codes;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Подарочные подписки: покупатель оплачивает разовым платежом N месяцев Premium
+-- и передает код получателю, активация продлевает выданный Premium (users.premium_until)
+CREATE TABLE IF NOT EXISTS gifts (
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR(32) NOT NULL UNIQUE,
+    buyer_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    months INTEGER NOT NULL CHECK (months > 0),
+    amount NUMERIC(10,2) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'RUB',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, paid, redeemed, cancelled
+    payment_id VARCHAR(255) UNIQUE,
+    recipient_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    paid_at TIMESTAMPTZ,
+    redeemed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_gifts_buyer ON gifts(buyer_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS gifts;
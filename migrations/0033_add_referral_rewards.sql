@@ -0,0 +1,33 @@
+-- +goose Up
+-- Реферальная программа: пользователь приходит по ссылке /start <referral_code>,
+-- приглашающий получает награду за первую оплату приглашенного
+CREATE TABLE IF NOT EXISTS referrals (
+    id SERIAL PRIMARY KEY,
+    referrer_id BIGINT REFERENCES users(id),
+    referred_id BIGINT REFERENCES users(id),
+    status VARCHAR(20) DEFAULT 'pending',
+    commission_earned DECIMAL(10,2) DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(referrer_id, referred_id)
+);
+
+ALTER TABLE referrals
+  ADD COLUMN IF NOT EXISTS payment_id BIGINT REFERENCES payments(id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS reward_type VARCHAR(20),               -- generations, premium_days
+  ADD COLUMN IF NOT EXISTS reward_amount INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS rewarded_at TIMESTAMPTZ;
+
+-- У пользователя один приглашающий
+CREATE UNIQUE INDEX IF NOT EXISTS idx_referrals_referred ON referrals(referred_id);
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer ON referrals(referrer_id);
+CREATE INDEX IF NOT EXISTS idx_referrals_pending ON referrals(status) WHERE status = 'pending';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_referrals_pending;
+DROP INDEX IF EXISTS idx_referrals_referrer;
+DROP INDEX IF EXISTS idx_referrals_referred;
+ALTER TABLE referrals
+  DROP COLUMN IF EXISTS rewarded_at,
+  DROP COLUMN IF EXISTS reward_amount,
+  DROP COLUMN IF EXISTS reward_type,
+  DROP COLUMN IF EXISTS payment_id;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Источник привлечения: по какой ссылке пользователь впервые открыл бота (first-touch)
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS acquisition_source VARCHAR(20),   -- organic, campaign, referral, gift
+  ADD COLUMN IF NOT EXISTS acquisition_campaign VARCHAR(64), -- Название кампании из /start c_<кампания>
+  ADD COLUMN IF NOT EXISTS acquired_at TIMESTAMPTZ;
+
+-- Источник существующих пользователей неизвестен
+UPDATE users SET acquisition_source = 'organic', acquired_at = created_at
+WHERE acquisition_source IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_acquisition ON users(acquisition_source, acquisition_campaign);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_acquisition;
+ALTER TABLE users
+  DROP COLUMN IF EXISTS acquired_at,
+  DROP COLUMN IF EXISTS acquisition_campaign,
+  DROP COLUMN IF EXISTS acquisition_source;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Захват подписки для списания: при нескольких экземплярах воркера продление списывает только один из них.
+-- renewal_attempt — номер попытки списания, из него и периода оплаты строится ключ идемпотентности.
+ALTER TABLE subscriptions
+  ADD COLUMN IF NOT EXISTS renewal_claimed_until TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS renewal_attempt INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE subscriptions
+  DROP COLUMN IF EXISTS renewal_attempt,
+  DROP COLUMN IF EXISTS renewal_claimed_until;
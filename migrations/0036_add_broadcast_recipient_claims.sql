@@ -0,0 +1,10 @@
+-- +goose Up
+-- Захват пачки получателей: при нескольких экземплярах бота каждый получатель достается одному из них
+ALTER TABLE broadcast_recipients ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMPTZ; -- status = 'sending' до этого времени
+
+CREATE INDEX IF NOT EXISTS idx_broadcast_recipients_sending ON broadcast_recipients(broadcast_id, claimed_until) WHERE status = 'sending';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_broadcast_recipients_sending;
+UPDATE broadcast_recipients SET status = 'pending' WHERE status = 'sending';
+ALTER TABLE broadcast_recipients DROP COLUMN IF EXISTS claimed_until;